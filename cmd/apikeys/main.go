@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"user-microservice/config"
+	"user-microservice/internal/apikeys"
+	apikeysRepo "user-microservice/internal/apikeys/repository/mongodb"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/models"
+	"user-microservice/pkg/db/mongodb"
+)
+
+// defaultExpiration - default validity of the created keys
+const defaultExpiration = 365 * 24 * time.Hour
+
+// validScopes - scopes that can be granted to the keys
+var validScopes = map[string]bool{
+	auth.ScopeUsersRead:  true,
+	auth.ScopeUsersWrite: true,
+	auth.ScopeUsersAdmin: true,
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: apikeys <command> [flags]
+
+Commands:
+  create -name <name> -scopes <scopes> [-expires-in <duration>]  Creates a new key and prints it (only once)
+  list                                                           Lists all the keys
+  revoke -id <id>                                                Revokes a key
+`)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.GetConfigFromFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		panic(err)
+	}
+
+	db, err := mongodb.NewMongoDatabase(cfg.Mongo)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := db.Client().Disconnect(context.TODO()); err != nil {
+			panic(err)
+		}
+	}()
+
+	repository := apikeysRepo.NewMongoDBRepository(db)
+	ctx := context.Background()
+	args := flag.Args()[1:]
+
+	var res interface{}
+	switch flag.Arg(0) {
+	case "create":
+		res, err = create(ctx, repository, args)
+	case "list":
+		res, err = repository.List(ctx)
+	case "revoke":
+		res, err = revoke(ctx, repository, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(res); err != nil {
+		panic(err)
+	}
+}
+
+// createdKey - created key along with its value, which cannot be retrieved again
+type createdKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func create(ctx context.Context, repository apikeys.Repository, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "Unique name of the service using the key (required)")
+	scopes := flags.String("scopes", "", "Comma separated list of scopes: users:read, users:write, users:admin (required)")
+	expiresIn := flags.Duration("expires-in", defaultExpiration, "Validity of the key")
+	_ = flags.Parse(args)
+
+	if *name == "" || *scopes == "" || *expiresIn <= 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	keyScopes := strings.Split(*scopes, ",")
+	for i, scope := range keyScopes {
+		keyScopes[i] = strings.TrimSpace(scope)
+		if !validScopes[keyScopes[i]] {
+			return nil, fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+
+	key, hash, err := apikeys.Generate()
+	if err != nil {
+		return nil, err
+	}
+	created, err := repository.Create(ctx, models.APIKey{
+		Name:      *name,
+		Hash:      hash,
+		Scopes:    keyScopes,
+		ExpiresAt: time.Now().Add(*expiresIn).UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return createdKey{APIKey: *created, Key: key}, nil
+}
+
+func revoke(ctx context.Context, repository apikeys.Repository, args []string) (interface{}, error) {
+	flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := flags.String("id", "", "ID of the key to revoke (required)")
+	_ = flags.Parse(args)
+
+	if *id == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err := repository.Revoke(ctx, *id); err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"revoked": *id}, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"user-microservice/config"
+	"user-microservice/internal/users/bulk"
+	userPubSub "user-microservice/internal/users/pubsub"
+	usersRepo "user-microservice/internal/users/repository/mongodb"
+	"user-microservice/pkg/db/mongodb"
+	redisDB "user-microservice/pkg/db/redis"
+)
+
+func main() {
+	file := flag.String("file", "", "NDJSON or CSV file with the users to import (required)")
+	format := flag.String("format", "", "Data format (ndjson or csv). If empty, it's taken from the file extension")
+	dryRun := flag.Bool("dry-run", false, "Only validate the users, nothing is inserted")
+	chunkSize := flag.Int("chunk-size", bulk.DefaultChunkSize, "Number of users inserted at once")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	importFormat := bulk.Format(*format)
+	if importFormat == "" {
+		importFormat = bulk.Format(strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), "."))
+	}
+	if !importFormat.Valid() {
+		panic(fmt.Sprintf("unsupported format %q", importFormat))
+	}
+
+	cfg, err := config.GetConfigFromFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		panic(err)
+	}
+
+	db, err := mongodb.NewMongoDatabase(cfg.Mongo)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := db.Client().Disconnect(context.TODO()); err != nil {
+			panic(err)
+		}
+	}()
+
+	redisClient := redisDB.MewRedisDatabase(cfg.Redis)
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			panic(err)
+		}
+	}()
+
+	if err := usersRepo.EnsureIndexes(context.Background(), db); err != nil {
+		panic(err)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	// the command exits once the users are imported, so their email verification is not sent
+	importer := bulk.NewImporter(usersRepo.NewMongoDBRepository(db), userPubSub.NewPubSub(redisClient), nil, *chunkSize)
+	report, err := importer.Import(context.Background(), f, importFormat, *dryRun)
+	if err != nil {
+		panic(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		panic(err)
+	}
+}
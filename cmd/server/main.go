@@ -13,6 +13,16 @@ import (
 // @version     1.0
 // @description Users Microservices
 // @BasePath    /api/v1
+
+// @securityDefinitions.apikey BearerAuth
+// @in                         header
+// @name                       Authorization
+// @description                Bearer token of the user ("Bearer <token>"). Not required when the auth is disabled
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in                         header
+// @name                       X-API-Key
+// @description                API key of the internal services. Not required when the auth is disabled
 func main() {
 
 	filepath := os.Getenv("CONFIG_FILE")
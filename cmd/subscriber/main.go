@@ -2,52 +2,104 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 	"user-microservice/config"
-	"user-microservice/internal/models"
+	"user-microservice/internal/consumer"
+	"user-microservice/internal/consumer/sinks"
 	userPubSub "user-microservice/internal/users/pubsub"
 	redisDB "user-microservice/pkg/db/redis"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	stdout := flag.Bool("stdout", true, "Print the events to the standard output")
+	file := flag.String("file", "", "Append the events as JSON lines to this file")
+	forward := flag.String("forward", "", "Forward the events in POST requests to this URL")
+	forwardTimeout := flag.Duration("forward-timeout", sinks.DefaultForwardTimeout, "Timeout of the forwarded requests")
+	topics := flag.String("topics", "", "Comma separated topics to consume. If empty, all the user topics are consumed")
+	concurrency := flag.Int("concurrency", consumer.DefaultConcurrency, "Number of events handled at once (the events of a user are always handled in order)")
+	maxAttempts := flag.Int("max-attempts", consumer.DefaultMaxAttempts, "Number of attempts of each event in each sink")
+	shutdownTimeout := flag.Duration("shutdown-timeout", consumer.DefaultShutdownTimeout, "Time given to the events in progress to finish on shutdown")
+	metricsAddr := flag.String("metrics-addr", "", "Address serving the Prometheus metrics in /metrics. If empty, they are not served")
+	flag.Parse()
+
 	fmt.Println("Redis listener")
 
-	filepath := os.Getenv("CONFIG_FILE")
-	cfg, err := config.GetConfigFromFile(filepath)
+	cfg, err := config.GetConfigFromFile(os.Getenv("CONFIG_FILE"))
 	if err != nil {
 		panic(err)
 	}
 
-	ctx := context.Background()
-	redisClient := redisDB.MewRedisDatabase(cfg.Redis)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	subscriber := redisClient.Subscribe(ctx, userPubSub.GetAllUsersTopics()...)
+	redisClient := redisDB.MewRedisDatabase(cfg.Redis)
+	defer func() {
+		if err := redisClient.Close(); err != nil {
+			panic(err)
+		}
+	}()
 
-	var payload interface{}
-	for {
-		msg, err := subscriber.ReceiveMessage(ctx)
+	metrics := consumer.NewMetrics(prometheus.DefaultRegisterer)
+	retry := consumer.Retry(consumer.RetryOptions{MaxAttempts: *maxAttempts})
+	var handlers []consumer.EventHandler
+	sink := func(name string, h consumer.EventHandler) {
+		handlers = append(handlers, consumer.Chain(h, consumer.Logging(name), metrics.Middleware(name), retry))
+	}
+	if *stdout {
+		sink("stdout", sinks.NewStdout())
+	}
+	if *file != "" {
+		jsonFile, err := sinks.NewJSONFile(*file)
 		if err != nil {
-			logrus.Errorf("Error receiving message: %s", err)
-		} else {
-
-			if msg.Channel == userPubSub.TopicUserDeletion {
-				payload = msg.Payload
-			} else {
-				var user models.User
-				if err := json.Unmarshal([]byte(msg.Payload), &user); err != nil {
-					logrus.Errorf("Error unmarshaling payload: %s", err)
-				} else {
-					payload = user
-				}
+			panic(err)
+		}
+		defer func() {
+			if err := jsonFile.Close(); err != nil {
+				panic(err)
 			}
+		}()
+		sink("file", jsonFile)
+	}
+	if *forward != "" {
+		sink("forward", sinks.NewForwarder(*forward, *forwardTimeout))
+	}
+	if len(handlers) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
 
-			fmt.Println("Received message from " + msg.Channel + " channel.")
-			fmt.Printf("Payload: %+v\n", payload)
-		}
+	if *metricsAddr != "" {
+		metricsServer := &http.Server{Addr: *metricsAddr, Handler: promhttp.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logrus.Errorf("Error in subscriber.main -> error serving the metrics: %s", err)
+			}
+		}()
+		defer metricsServer.Close()
+	}
+
+	var consumedTopics []string
+	if *topics != "" {
+		consumedTopics = strings.Split(*topics, ",")
 	}
+	c := consumer.NewConsumer(userPubSub.NewPubSub(redisClient), consumer.Fanout(handlers...), consumer.Options{
+		Topics:          consumedTopics,
+		Concurrency:     *concurrency,
+		ShutdownTimeout: *shutdownTimeout,
+	})
+	c.Run(ctx)
 
+	logrus.Info("Redis listener stopped")
 }
@@ -1,20 +1,39 @@
 package config
 
 import (
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server ServerConfig
-	Mongo  MongoConfig
-	Redis  RedisConfig
+	Server            ServerConfig
+	Mongo             MongoConfig
+	Redis             RedisConfig
+	Idempotency       IdempotencyConfig
+	Users             UsersConfig
+	Cache             CacheConfig
+	Auth              AuthConfig
+	Mail              MailConfig
+	PasswordReset     PasswordResetConfig
+	EmailVerification EmailVerificationConfig
+	MFA               MFAConfig
+	Lockout           LockoutConfig
+	RateLimit         RateLimitConfig
+	GRPC              GRPCConfig
+	GraphQL           GraphQLConfig
+	Feed              FeedConfig
+	Webhooks          WebhooksConfig
 }
 
 type ServerConfig struct {
 	Addr  string
 	Port  int
 	Debug bool
+	// TrustedProxies - CIDR ranges of the proxies whose X-Forwarded-For header is trusted to get the client IP,
+	// the connection IP is used if empty
+	TrustedProxies []string
 }
 
 type MongoConfig struct {
@@ -28,6 +47,108 @@ type RedisConfig struct {
 	DB       int
 }
 
+type IdempotencyConfig struct {
+	// TTL - time the responses are stored (e.g. "24h")
+	TTL time.Duration
+	// LockTimeout - maximum time a key is locked while its request is in progress (e.g. "1m")
+	LockTimeout time.Duration
+}
+
+type UsersConfig struct {
+	// DeletedRetention - time the soft deleted users are kept before purging them (e.g. "720h")
+	DeletedRetention time.Duration
+	// PurgeInterval - time between each purge of the deleted users (e.g. "1h")
+	PurgeInterval time.Duration
+	// SuspensionExpiryInterval - time between each reactivation of the users whose suspension ended (e.g. "1m")
+	SuspensionExpiryInterval time.Duration
+}
+
+type CacheConfig struct {
+	// TTL - time the users are cached (e.g. "5m")
+	TTL time.Duration
+}
+
+type AuthConfig struct {
+	// Enabled - requires a valid bearer token in the users routes, it can be disabled for local runs
+	Enabled bool
+	// Secret - shared secret of the HS256 tokens, HS256 tokens are rejected if empty
+	Secret string
+	// JWKS - URL or local file path of the JSON Web Key Set verifying the RS256 tokens, RS256 tokens are rejected if empty
+	JWKS string
+	// Issuer - expected "iss" claim of the tokens, not checked if empty
+	Issuer string
+	// Audience - expected "aud" claim of the tokens, not checked if empty
+	Audience string
+	// AccessTokenTTL - lifetime of the access tokens issued on login (e.g. "15m"), the login is disabled if there is no secret
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL - time a session is kept alive without refreshing it (e.g. "720h")
+	RefreshTokenTTL time.Duration
+}
+
+type MailConfig struct {
+	// Sender - how the mails are delivered: "log" (default) logs them and "file" writes them in the Dir
+	Sender string
+	// Dir - directory of the mails written by the "file" sender
+	Dir string
+}
+
+type PasswordResetConfig struct {
+	// TokenTTL - time a reset token is valid (e.g. "1h")
+	TokenTTL time.Duration
+	// URL - page where the users set their new password, the token is added as the "token" query param
+	URL string
+	// MaxRequests - number of resets that can be requested for an email in the Window
+	MaxRequests int
+	// Window - time window of the MaxRequests limit (e.g. "1h")
+	Window time.Duration
+}
+
+type EmailVerificationConfig struct {
+	// Secret - secret signing the verification tokens, the auth secret is used if empty
+	Secret string
+	// TokenTTL - time a verification token is valid (e.g. "24h")
+	TokenTTL time.Duration
+	// URL - page where the users verify their email, the user ID and the token are added as the "userId" and "token" query params
+	URL string
+}
+
+type MFAConfig struct {
+	// EncryptionKey - key encrypting the TOTP secrets at rest, the second factor is disabled if empty
+	EncryptionKey string
+	// Issuer - issuer shown by the authenticator apps
+	Issuer string
+}
+
+type LockoutConfig struct {
+	// MaxAttempts - number of failed logins of an account or from an IP allowed in the Window before locking them out
+	MaxAttempts int
+	// Window - time window counting the failed logins (e.g. "15m")
+	Window time.Duration
+	// BaseDuration - duration of the first lockout, doubled on every consecutive lockout (e.g. "1m")
+	BaseDuration time.Duration
+	// MaxDuration - maximum duration of a lockout (e.g. "24h")
+	MaxDuration time.Duration
+}
+
+type RateLimitConfig struct {
+	// Default - limit of each client in the route groups without a specific limit, disabled if it has no requests
+	Default LimitConfig
+	// Groups - limits of each client by route group ("users" or "auth"), overriding the default one
+	Groups map[string]LimitConfig
+	// IP - limit of each IP in all the route groups, checked before the authentication so the requests with
+	// invalid credentials are limited too, disabled if it has no requests
+	IP LimitConfig
+}
+
+type LimitConfig struct {
+	// Requests - number of requests allowed in the Period
+	Requests int
+	// Period - time period of the Requests (e.g. "1m")
+	Period time.Duration
+	// Burst - maximum number of requests allowed at once, Requests if empty
+	Burst int
+}
+
 // GetConfigFromFile - retrieves the config from the config file
 func GetConfigFromFile(filepath string) (*Config, error) {
 	v, err := LoadConfigFile(filepath)
@@ -68,3 +189,47 @@ func ParseConfig(v *viper.Viper) (*Config, error) {
 
 	return &config, nil
 }
+
+type GRPCConfig struct {
+	// Enabled - serves the gRPC UserService, with the health and reflection services
+	Enabled bool
+	Addr    string
+	// Port - port of the gRPC server, separated from the HTTP one
+	Port int
+}
+
+type GraphQLConfig struct {
+	// MaxDepth - maximum nesting of the query selections, 0 for the default one
+	MaxDepth int
+	// MaxComplexity - maximum cost of the queries, each field costs 1 and the selections
+	// of the paginated fields are multiplied by their page size. 0 for the default one
+	MaxComplexity int
+	// MaxIntrospectionDepth - maximum nesting of the introspection selections, 0 for the default one
+	MaxIntrospectionDepth int
+}
+
+type FeedConfig struct {
+	// Heartbeat - time between the heartbeats sent to the live events clients
+	Heartbeat time.Duration
+	// BufferSize - number of events buffered for each client, the clients falling behind are dropped
+	BufferSize int
+	// ReplaySize - number of recent events kept to resume the reconnected clients, negative to disable it
+	ReplaySize int
+}
+
+type WebhooksConfig struct {
+	// MaxAttempts - number of attempts of each delivery before failing it
+	MaxAttempts int
+	// InitialBackoff - time before the first retry of a delivery, doubled on each retry (e.g. "30s")
+	InitialBackoff time.Duration
+	// MaxBackoff - maximum time between the retries of a delivery (e.g. "1h")
+	MaxBackoff time.Duration
+	// Timeout - time given to the webhook URLs to reply (e.g. "10s")
+	Timeout time.Duration
+	// MaxFailures - consecutive failed attempts disabling a webhook
+	MaxFailures int
+	// PollInterval - time between each check of the due retries (e.g. "5s")
+	PollInterval time.Duration
+	// Workers - number of concurrent deliveries
+	Workers int
+}
@@ -4,11 +4,14 @@ db.users.insertMany([
     first_name: "Alice",
     last_name: "Tingo",
     nickname: "atingo",
-    password: "Already inserted user password 1",
+    // password "Already inserted user password 1"
+    password: "$2a$14$jRiAGzUnup7EW6JE4RuLzuxwQbKovyVmUWjdczZemy3NRxK2QdO/q",
     email: "alicetingo@example.com",
     country: "DE",
     created_at: new Date("2016-05-18T16:00:00Z"),
     updated_at: new Date("2016-05-18T16:00:00Z"),
+    email_verified: true,
+    email_verified_at: new Date("2016-05-18T16:00:00Z"),
   },
   {
     _id: "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739",
@@ -77,3 +80,27 @@ db.users.insertMany([
     updated_at: new Date("2018-05-18T16:00:00Z"),
   },
 ]);
+
+// the emails of the not deleted users (without deleted_at) are unique ignoring the case
+db.users.createIndex(
+  { email: 1, deleted_at: 1 },
+  {
+    name: "email_unique",
+    unique: true,
+    collation: { locale: "en", strength: 2 },
+    partialFilterExpression: { email: { $type: "string" } },
+  }
+);
+db.users_history.createIndex({ user_id: 1, timestamp: -1 });
+db.api_keys.createIndex({ hash: 1 }, { unique: true });
+db.api_keys.createIndex({ name: 1 }, { unique: true });
+db.sessions.createIndex({ token_hash: 1 }, { unique: true });
+db.sessions.createIndex({ previous_hashes: 1 });
+db.sessions.createIndex({ user_id: 1, created_at: -1 });
+db.password_resets.createIndex({ token_hash: 1 }, { unique: true });
+db.password_resets.createIndex({ user_id: 1 });
+db.password_resets.createIndex({ expires_at: 1 }, { expireAfterSeconds: 0 });
+db.webhooks.createIndex({ events: 1, enabled: 1 });
+db.webhook_deliveries.createIndex({ webhook_id: 1, created_at: -1 });
+db.webhook_deliveries.createIndex({ status: 1, next_attempt_at: 1 });
+db.webhook_deliveries.createIndex({ created_at: 1 }, { expireAfterSeconds: 2592000 });
@@ -0,0 +1,31 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// KeyPrefix - prefix of the generated keys, so they can be identified (e.g. by secret scanners)
+const KeyPrefix = "umk_"
+
+// keyBytes - random bytes of each key
+const keyBytes = 32
+
+// Generate - returns a new random key and its hash. The key is only shown once, the hash is the stored value
+func Generate() (key, hash string, err error) {
+	secret := make([]byte, keyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key = KeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, Hash(key), nil
+}
+
+// Hash - returns the hash of the key. The keys are random, so a fast hash is enough
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"user-microservice/internal/auth"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// lastUsedResolution - minimum time between the updates of the last time a key was used
+const lastUsedResolution = time.Minute
+
+// rejection reasons of the metrics
+const (
+	reasonUnknown = "unknown"
+	reasonExpired = "expired"
+	reasonRevoked = "revoked"
+)
+
+// Authenticator - authenticates the requests API keys against the repository
+type Authenticator struct {
+	repository Repository
+	metrics    *Metrics
+	mu         sync.Mutex
+	// lastUsed - last time each key ID was stored as used
+	lastUsed map[string]time.Time
+}
+
+var _ auth.KeyAuthenticator = (*Authenticator)(nil)
+
+// NewAuthenticator - returns a new Authenticator
+func NewAuthenticator(repository Repository, metrics *Metrics) *Authenticator {
+	return &Authenticator{
+		repository: repository,
+		metrics:    metrics,
+		lastUsed:   map[string]time.Time{},
+	}
+}
+
+// Authenticate - returns the principal of the key, or an auth.ErrInvalidAPIKey error if the key does not
+// exist, is expired or was revoked. The principal subject is "apikey:" followed by the key name
+func (a *Authenticator) Authenticate(ctx context.Context, key string) (auth.Principal, error) {
+	found, err := a.repository.GetByHash(ctx, Hash(key))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			a.metrics.rejections.WithLabelValues(reasonUnknown).Inc()
+			return auth.Principal{}, fmt.Errorf("%w: unknown key", auth.ErrInvalidAPIKey)
+		}
+		logrus.Errorf("Error in apikeys.Authenticate -> error getting key: %s", err)
+		return auth.Principal{}, err
+	}
+
+	now := time.Now()
+	if found.Revoked() {
+		a.metrics.rejections.WithLabelValues(reasonRevoked).Inc()
+		return auth.Principal{}, fmt.Errorf("%w: key %s revoked", auth.ErrInvalidAPIKey, found.Name)
+	}
+	if found.Expired(now) {
+		a.metrics.rejections.WithLabelValues(reasonExpired).Inc()
+		return auth.Principal{}, fmt.Errorf("%w: key %s expired", auth.ErrInvalidAPIKey, found.Name)
+	}
+
+	a.metrics.requests.WithLabelValues(found.Name).Inc()
+	a.touch(ctx, found.ID, now)
+
+	return auth.Principal{
+		Subject: "apikey:" + found.Name,
+		Scopes:  found.Scopes,
+	}, nil
+}
+
+// touch - stores the last time the key was used, at most once every lastUsedResolution.
+// The errors are only logged, the request is authenticated anyway
+func (a *Authenticator) touch(ctx context.Context, id string, now time.Time) {
+	a.mu.Lock()
+	if now.Sub(a.lastUsed[id]) < lastUsedResolution {
+		a.mu.Unlock()
+		return
+	}
+	a.lastUsed[id] = now
+	a.mu.Unlock()
+
+	if err := a.repository.UpdateLastUsed(ctx, id, now.UTC()); err != nil {
+		logrus.Errorf("Error in apikeys.touch -> error updating last used date of key %s: %s", id, err)
+	}
+}
@@ -0,0 +1,159 @@
+package apikeys_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/apikeys"
+	"user-microservice/internal/apikeys/mock"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// keyRequests - returns the value of the requests counter of the key
+func keyRequests(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoErrorf(t, err, "Expected no error when gathering metrics, but was %s", err)
+	for _, family := range families {
+		if family.GetName() != "api_key_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "key" && label.GetValue() == name {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestGenerate(t *testing.T) {
+	// When
+	key, hash, err := apikeys.Generate()
+	otherKey, _, otherErr := apikeys.Generate()
+
+	// Then
+	require.NoError(t, err)
+	require.NoError(t, otherErr)
+	assert.Truef(t, strings.HasPrefix(key, apikeys.KeyPrefix), "Expected key to start with %s, but was %s", apikeys.KeyPrefix, key)
+	assert.Equalf(t, apikeys.Hash(key), hash, "Expected hash to be the key hash")
+	assert.NotEqualf(t, key, hash, "Expected the hash not to be the key")
+	assert.NotEqualf(t, key, otherKey, "Expected the keys to be different")
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Hour)
+	active := models.APIKey{
+		ID:        "5b0e1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d",
+		Name:      "matchmaking",
+		Scopes:    []string{auth.ScopeUsersRead},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	expired := active
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	revoked := active
+	revoked.RevokedAt = &revokedAt
+
+	for _, tc := range []struct {
+		name              string
+		mockedKey         *models.APIKey
+		mockedError       error
+		expectedPrincipal auth.Principal
+		expectedInvalid   bool
+		expectedError     bool
+		expectedRequests  float64
+	}{
+		{
+			"Authenticate active key",
+			&active,
+			nil,
+			auth.Principal{Subject: "apikey:matchmaking", Scopes: []string{auth.ScopeUsersRead}},
+			false,
+			false,
+			2,
+		},
+		{
+			"Authenticate unknown key",
+			nil,
+			mongo.ErrNoDocuments,
+			auth.Principal{},
+			true,
+			true,
+			0,
+		},
+		{
+			"Authenticate expired key",
+			&expired,
+			nil,
+			auth.Principal{},
+			true,
+			true,
+			0,
+		},
+		{
+			"Authenticate revoked key",
+			&revoked,
+			nil,
+			auth.Principal{},
+			true,
+			true,
+			0,
+		},
+		{
+			"Authenticate with repository error",
+			nil,
+			errors.New("homemade error"),
+			auth.Principal{},
+			false,
+			true,
+			0,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			reg := prometheus.NewRegistry()
+			authenticator := apikeys.NewAuthenticator(repo, apikeys.NewMetrics(reg))
+			ctx := context.TODO()
+
+			repo.EXPECT().GetByHash(ctx, apikeys.Hash("umk_test-key")).Return(tc.mockedKey, tc.mockedError).Times(2)
+			updateTimes := 0
+			if tc.expectedRequests > 0 {
+				// the last used date is only stored once per minute
+				updateTimes = 1
+			}
+			repo.EXPECT().UpdateLastUsed(ctx, active.ID, gomock.Any()).Return(nil).Times(updateTimes)
+
+			// When
+			principal, err := authenticator.Authenticate(ctx, "umk_test-key")
+			_, secondErr := authenticator.Authenticate(ctx, "umk_test-key")
+
+			// Then
+			if tc.expectedError {
+				assert.Error(t, err, "Expected error")
+				assert.Equalf(t, tc.expectedInvalid, errors.Is(err, auth.ErrInvalidAPIKey), "Expected invalid key error to be %t, but was %s", tc.expectedInvalid, err)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, secondErr)
+				assert.Equalf(t, tc.expectedPrincipal, principal, "Expected principal to be %v, but was %v", tc.expectedPrincipal, principal)
+			}
+			requests := keyRequests(t, reg, active.Name)
+			assert.Equalf(t, tc.expectedRequests, requests, "Expected requests to be %v, but were %v", tc.expectedRequests, requests)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package apikeys
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics - prometheus metrics of the API keys
+type Metrics struct {
+	requests   *prometheus.CounterVec
+	rejections *prometheus.CounterVec
+}
+
+// NewMetrics - returns the API keys metrics, registered in the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_key_requests_total",
+			Help: "Number of requests authenticated by each API key",
+		}, []string{"key"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_key_rejections_total",
+			Help: "Number of requests with a rejected API key, by reason",
+		}, []string{"reason"}),
+	}
+	reg.MustRegister(m.requests, m.rejections)
+
+	return m
+}
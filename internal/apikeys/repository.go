@@ -0,0 +1,22 @@
+//go:generate mockgen -source repository.go -destination mock/repository_mock.go -package mock
+package apikeys
+
+import (
+	"context"
+	"time"
+	"user-microservice/internal/models"
+)
+
+// Repository - API keys repository
+type Repository interface {
+	// Create - inserts the key, setting its ID and creation date
+	Create(ctx context.Context, key models.APIKey) (*models.APIKey, error)
+	// GetByHash - returns the key with the given hash, or mongo.ErrNoDocuments if it does not exist
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	// List - returns all the keys, including the expired and revoked ones
+	List(ctx context.Context) ([]models.APIKey, error)
+	// Revoke - revokes the key, returning mongo.ErrNoDocuments if it does not exist or it is already revoked
+	Revoke(ctx context.Context, id string) error
+	// UpdateLastUsed - sets the last time the key was used
+	UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error
+}
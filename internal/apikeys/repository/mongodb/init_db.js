@@ -0,0 +1,24 @@
+db.api_keys.createIndex({ hash: 1 }, { unique: true });
+db.api_keys.createIndex({ name: 1 }, { unique: true });
+
+db.api_keys.insertMany([
+  {
+    // key "umk_active-test-key"
+    _id: "5b0e1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d",
+    name: "active-service",
+    hash: "104862576ef3aa72c52598588764875202ea44def1186d2f42f73059c91d7e5a",
+    scopes: ["users:read"],
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+    created_at: new Date("2022-05-18T16:00:00Z"),
+  },
+  {
+    // key "umk_revoked-test-key"
+    _id: "6c1f2d3e-4f5a-4b6c-9d7e-8f9a0b1c2d3e",
+    name: "revoked-service",
+    hash: "e58809bfb310ed44132debdef27f6501c77d69b2ed229403941bb8a48eede797",
+    scopes: ["users:write"],
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    revoked_at: new Date("2022-06-18T16:00:00Z"),
+  },
+]);
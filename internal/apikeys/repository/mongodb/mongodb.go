@@ -0,0 +1,99 @@
+package mongodb
+
+import (
+	"context"
+	"strings"
+	"time"
+	"user-microservice/internal/apikeys"
+	"user-microservice/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongodbCollection - collection of the API keys, with unique indexes on the hash and the name
+const mongodbCollection = "api_keys"
+
+type mongodbRepository struct {
+	db *mongo.Collection
+}
+
+var _ apikeys.Repository = mongodbRepository{}
+var _ apikeys.Repository = (*mongodbRepository)(nil)
+
+// NewMongoDBRepository - returns a new instance for the API keys mongodb repository
+func NewMongoDBRepository(db *mongo.Database) apikeys.Repository {
+	return &mongodbRepository{db.Collection(mongodbCollection)}
+}
+
+// Create - inserts the key into the database and returns the inserted version
+func (r mongodbRepository) Create(ctx context.Context, key models.APIKey) (*models.APIKey, error) {
+	key.ID = strings.ToLower(uuid.New().String())
+	key.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+
+	if _, err := r.db.InsertOne(ctx, &key); err != nil {
+		logrus.Errorf("Error in apikeys/repository/mongodb.Create -> error: %s", err)
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetByHash - returns the key with the given hash
+func (r mongodbRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.FindOne(ctx, bson.M{"hash": hash}).Decode(&key); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in apikeys/repository/mongodb.GetByHash -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// List - returns all the keys sorted by name
+func (r mongodbRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	cursor, err := r.db.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		logrus.Errorf("Error in apikeys/repository/mongodb.List -> error executing find command: %s", err)
+		return nil, err
+	}
+
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		logrus.Errorf("Error in apikeys/repository/mongodb.List -> error decoding cursor: %s", err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Revoke - sets the revocation date of the key
+func (r mongodbRepository) Revoke(ctx context.Context, id string) error {
+	filter := bson.M{"_id": strings.ToLower(id), "revoked_at": nil}
+	res, err := r.db.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		logrus.Errorf("Error in apikeys/repository/mongodb.Revoke -> error: %s", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// UpdateLastUsed - sets the last time the key was used
+func (r mongodbRepository) UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time) error {
+	_, err := r.db.UpdateOne(ctx, bson.M{"_id": strings.ToLower(id)}, bson.M{"$set": bson.M{"last_used_at": lastUsedAt}})
+	if err != nil {
+		logrus.Errorf("Error in apikeys/repository/mongodb.UpdateLastUsed -> error: %s", err)
+	}
+	return err
+}
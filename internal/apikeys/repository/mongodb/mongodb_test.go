@@ -0,0 +1,174 @@
+package mongodb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/apikeys"
+	"user-microservice/internal/apikeys/repository/mongodb"
+	"user-microservice/internal/models"
+	"user-microservice/internal/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var dbClientTest *mongo.Client
+
+func TestMain(m *testing.M) {
+	dbClientTest = new(mongo.Client)
+	testutils.ExecuteTestMain(m, dbClientTest)
+}
+
+func TestMongoDBRepository_Create(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		key           models.APIKey
+		expectedError bool
+	}{
+		{
+			"Create key successfully",
+			models.APIKey{
+				Name:      "billing",
+				Hash:      apikeys.Hash("umk_billing-test-key"),
+				Scopes:    []string{"users:read", "users:write"},
+				ExpiresAt: time.Now().Add(time.Hour).UTC(),
+			},
+			false,
+		},
+		{
+			"Create key with already existing name",
+			models.APIKey{
+				Name:      "active-service",
+				Hash:      apikeys.Hash("umk_duplicated-name-test-key"),
+				Scopes:    []string{"users:read"},
+				ExpiresAt: time.Now().Add(time.Hour).UTC(),
+			},
+			true,
+		},
+		{
+			"Create key with already existing hash",
+			models.APIKey{
+				Name:      "duplicated-hash",
+				Hash:      apikeys.Hash("umk_active-test-key"),
+				Scopes:    []string{"users:read"},
+				ExpiresAt: time.Now().Add(time.Hour).UTC(),
+			},
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+
+			// When
+			res, err := repo.Create(ctx, tc.key)
+
+			// Then
+			if tc.expectedError {
+				assert.Truef(t, mongo.IsDuplicateKeyError(err), "Expected duplicate key error, but was %v", err)
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, res, "Expected res not to be nil")
+				assert.NotEmpty(t, res.ID, "Expected ID not to be empty")
+
+				found, err := repo.GetByHash(ctx, tc.key.Hash)
+				require.NoError(t, err)
+				assert.Equalf(t, res.ID, found.ID, "Expected ID to be %s, but was %s", res.ID, found.ID)
+				assert.Equalf(t, tc.key.Scopes, found.Scopes, "Expected Scopes to be %v, but were %v", tc.key.Scopes, found.Scopes)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_GetByHash(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		hash          string
+		expectedName  string
+		expectedError error
+	}{
+		{
+			"Get active key",
+			apikeys.Hash("umk_active-test-key"),
+			"active-service",
+			nil,
+		},
+		{
+			"Get revoked key",
+			apikeys.Hash("umk_revoked-test-key"),
+			"revoked-service",
+			nil,
+		},
+		{
+			"Get unknown key",
+			apikeys.Hash("umk_unknown-test-key"),
+			"",
+			mongo.ErrNoDocuments,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+
+			// When
+			res, err := repo.GetByHash(context.TODO(), tc.hash)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Equalf(t, tc.expectedError, err, "Expected error to be %s, but was %s", tc.expectedError, err)
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, tc.expectedName, res.Name, "Expected Name to be %s, but was %s", tc.expectedName, res.Name)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_RevokeAndUpdateLastUsed(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	created, err := repo.Create(ctx, models.APIKey{
+		Name:      "to-revoke",
+		Hash:      apikeys.Hash("umk_to-revoke-test-key"),
+		Scopes:    []string{"users:read"},
+		ExpiresAt: time.Now().Add(time.Hour).UTC(),
+	})
+	require.NoError(t, err)
+	lastUsedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	// When
+	updateErr := repo.UpdateLastUsed(ctx, created.ID, lastUsedAt)
+	revokeErr := repo.Revoke(ctx, created.ID)
+	revokeAgainErr := repo.Revoke(ctx, created.ID)
+
+	// Then
+	require.NoError(t, updateErr)
+	require.NoError(t, revokeErr)
+	assert.Equalf(t, mongo.ErrNoDocuments, revokeAgainErr, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, revokeAgainErr)
+
+	found, err := repo.GetByHash(ctx, created.Hash)
+	require.NoError(t, err)
+	assert.Truef(t, found.Revoked(), "Expected the key to be revoked")
+	require.NotNil(t, found.LastUsedAt, "Expected LastUsedAt not to be nil")
+	assert.Truef(t, lastUsedAt.Equal(*found.LastUsedAt), "Expected LastUsedAt to be %s, but was %s", lastUsedAt, found.LastUsedAt)
+
+	keys, err := repo.List(ctx)
+	require.NoError(t, err)
+	names := []string{}
+	for _, key := range keys {
+		names = append(names, key.Name)
+	}
+	assert.Containsf(t, names, "to-revoke", "Expected the revoked keys to be listed, but were %v", names)
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderActor - request header with the actor making the request, set by the gateway
+const HeaderActor = "X-Actor"
+
+const (
+	// ActorAnonymous - actor used when the request has no actor
+	ActorAnonymous = "anonymous"
+	// ActorSystem - actor used for the changes made by the service itself (e.g. background jobs)
+	ActorSystem = "system"
+)
+
+// Info - data about who is making a change, stored in the audit entries
+type Info struct {
+	Actor     string
+	RequestID string
+}
+
+type contextKey struct{}
+
+// NewContext - returns a copy of the context carrying the audit info
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext - returns the audit info of the context.
+// If the context has no info, the ActorSystem is returned as actor
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(contextKey{}).(Info)
+	if info.Actor == "" {
+		info.Actor = ActorSystem
+	}
+	return info
+}
+
+// Detach - returns a new context keeping the audit info of the given one, but not its cancellation.
+// Useful for the work that must be finished after the request is done
+func Detach(ctx context.Context) context.Context {
+	info, isOK := ctx.Value(contextKey{}).(Info)
+	if !isOK {
+		return context.Background()
+	}
+	return NewContext(context.Background(), info)
+}
+
+// LogDenied - logs a denied request with the audit info of the context, so the denials can be tracked
+func LogDenied(ctx context.Context, action, target string, reason error) {
+	info := FromContext(ctx)
+	logrus.WithFields(logrus.Fields{
+		"audit":     "denied",
+		"actor":     info.Actor,
+		"requestId": info.RequestID,
+		"action":    action,
+		"target":    target,
+	}).Warnf("Access denied: %s", reason)
+}
+
+// LogLockout - logs a lockout of the logins of the target (e.g. "account:<email>" or "ip:<ip>") with the audit info
+// of the context, so the brute-force attacks can be tracked
+func LogLockout(ctx context.Context, target string, duration time.Duration) {
+	info := FromContext(ctx)
+	logrus.WithFields(logrus.Fields{
+		"audit":     "lockout",
+		"actor":     info.Actor,
+		"requestId": info.RequestID,
+		"target":    target,
+		"duration":  duration.String(),
+	}).Warnf("Logins of %s locked out for %s", target, duration)
+}
+
+// LogLockoutCleared - logs the clearing of the lockout of the target with the audit info of the context
+func LogLockoutCleared(ctx context.Context, target string) {
+	info := FromContext(ctx)
+	logrus.WithFields(logrus.Fields{
+		"audit":     "lockoutCleared",
+		"actor":     info.Actor,
+		"requestId": info.RequestID,
+		"target":    target,
+	}).Infof("Lockout of %s cleared", target)
+}
@@ -0,0 +1,24 @@
+package audit
+
+import "github.com/labstack/echo/v4"
+
+// Middleware - returns an echo middleware storing the audit info in the request context.
+// Must be used after the echo RequestID middleware
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			actor := req.Header.Get(HeaderActor)
+			if actor == "" {
+				actor = ActorAnonymous
+			}
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+			if requestID == "" {
+				requestID = req.Header.Get(echo.HeaderXRequestID)
+			}
+
+			c.SetRequest(req.WithContext(NewContext(req.Context(), Info{Actor: actor, RequestID: requestID})))
+			return next(c)
+		}
+	}
+}
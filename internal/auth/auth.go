@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Claims - claims of the validated bearer tokens
+type Claims struct {
+	jwt.RegisteredClaims
+	// Role - role of the user
+	Role Role `json:"role,omitempty"`
+	// Scope - space separated list of the granted scopes
+	Scope string `json:"scope,omitempty"`
+}
+
+// Principal - returns the principal identified by the claims
+func (c Claims) Principal() Principal {
+	return Principal{
+		Subject: c.Subject,
+		Role:    c.Role,
+		Scopes:  strings.Fields(c.Scope),
+	}
+}
+
+type contextKey struct{}
+
+// NewContext - returns a copy of the context carrying the token claims
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext - returns the token claims of the context, if any.
+// There are no claims when the authentication is disabled
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, isOK := ctx.Value(contextKey{}).(*Claims)
+	return claims, isOK && claims != nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"user-microservice/internal/audit"
+	httpErrors "user-microservice/internal/errors/http"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataAPIKey - gRPC metadata key with the API key of the internal services
+	MetadataAPIKey = "x-api-key"
+	// MetadataAuthorization - gRPC metadata key with the bearer token
+	MetadataAuthorization = "authorization"
+	// MetadataRequestID - gRPC metadata key with the request ID, stored in the audit entries
+	MetadataRequestID = "x-request-id"
+)
+
+// UnaryServerInterceptor - returns a gRPC unary interceptor with the same authentication as the Middleware,
+// reading the API key and the bearer token from the x-api-key and authorization metadata.
+// The methods of the public services (e.g. grpc.health.v1.Health) are not authenticated
+func UnaryServerInterceptor(validator *Validator, keys KeyAuthenticator, publicServices ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isPublicMethod(info.FullMethod, publicServices) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticateGRPC(ctx, validator, keys)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor - returns a gRPC stream interceptor with the same authentication as the UnaryServerInterceptor
+func StreamServerInterceptor(validator *Validator, keys KeyAuthenticator, publicServices ...string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isPublicMethod(info.FullMethod, publicServices) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticateGRPC(ss.Context(), validator, keys)
+		if err != nil {
+			return err
+		}
+		return handler(srv, serverStream{ss, ctx})
+	}
+}
+
+// CheckCall - Check for the gRPC calls, returning a permission denied status if the action is not allowed
+func CheckCall(ctx context.Context, action Action, target string, fields ...string) error {
+	if err := Check(ctx, action, target, fields...); err != nil {
+		return status.Error(codes.PermissionDenied, httpErrors.ErrForbidden)
+	}
+	return nil
+}
+
+// authenticateGRPC - authenticates the credentials of the call metadata. There is no audit middleware in gRPC,
+// so the audit info is initialized here with the request ID of the metadata
+func authenticateGRPC(ctx context.Context, validator *Validator, keys KeyAuthenticator) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = audit.NewContext(ctx, audit.Info{Actor: audit.ActorAnonymous, RequestID: firstMetadata(md, MetadataRequestID)})
+
+	ctx, err := authenticate(ctx, validator, keys, firstMetadata(md, MetadataAPIKey), firstMetadata(md, MetadataAuthorization))
+	if err != nil {
+		var authErr *authError
+		if errors.As(err, &authErr) {
+			return nil, status.Error(codes.Unauthenticated, authErr.message)
+		}
+		logrus.Errorf("Error in auth.authenticateGRPC -> error authenticating call: %s", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return ctx, nil
+}
+
+func isPublicMethod(fullMethod string, publicServices []string) bool {
+	for _, service := range publicServices {
+		if strings.HasPrefix(fullMethod, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// serverStream - grpc.ServerStream with the authenticated context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
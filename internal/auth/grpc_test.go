@@ -0,0 +1,111 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	keys := fakeKeys{key: "umk_valid", principal: auth.Principal{Subject: "apikey:billing", Scopes: []string{auth.ScopeUsersRead}}}
+	for _, tc := range []struct {
+		name            string
+		method          string
+		md              metadata.MD
+		keys            auth.KeyAuthenticator
+		expectedCode    codes.Code
+		expectedSubject string
+	}{
+		{
+			"Authenticate bearer token",
+			"/users.v1.UserService/GetUser",
+			metadata.Pairs(auth.MetadataAuthorization, "Bearer "+mintToken(t, jwt.SigningMethodHS256, testSecret, "", validClaims("grpc-user"))),
+			keys,
+			codes.OK,
+			"grpc-user",
+		},
+		{
+			"Authenticate API key",
+			"/users.v1.UserService/GetUser",
+			metadata.Pairs(auth.MetadataAPIKey, "umk_valid"),
+			keys,
+			codes.OK,
+			"apikey:billing",
+		},
+		{
+			"Authenticate invalid API key",
+			"/users.v1.UserService/GetUser",
+			metadata.Pairs(auth.MetadataAPIKey, "umk_invalid"),
+			keys,
+			codes.Unauthenticated,
+			"",
+		},
+		{
+			"Authenticate without credentials",
+			"/users.v1.UserService/GetUser",
+			metadata.MD{},
+			keys,
+			codes.Unauthenticated,
+			"",
+		},
+		{
+			"Authenticate API key with authenticator error",
+			"/users.v1.UserService/GetUser",
+			metadata.Pairs(auth.MetadataAPIKey, "umk_valid"),
+			fakeKeys{err: errors.New("homemade error")},
+			codes.Internal,
+			"",
+		},
+		{
+			"Public method without credentials",
+			"/grpc.health.v1.Health/Check",
+			metadata.MD{},
+			keys,
+			codes.OK,
+			"",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			validator, err := auth.NewValidator(auth.Options{
+				Secret:   testSecret,
+				Issuer:   "test-issuer",
+				Audience: "users-microservice",
+			})
+			require.NoError(t, err)
+			interceptor := auth.UnaryServerInterceptor(validator, tc.keys, "grpc.health.v1.Health")
+
+			var principal auth.Principal
+			var actor string
+			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+				principal, _ = auth.PrincipalFromContext(ctx)
+				actor = audit.FromContext(ctx).Actor
+				return nil, nil
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+
+			// When
+			_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tc.method}, handler)
+
+			// Then
+			assert.Equalf(t, tc.expectedCode, status.Code(err), "Expected code to be %s, but was %s", tc.expectedCode, status.Code(err))
+			assert.Equalf(t, tc.expectedSubject, principal.Subject, "Expected subject to be %s, but was %s", tc.expectedSubject, principal.Subject)
+			if tc.expectedSubject != "" {
+				assert.Equalf(t, tc.expectedSubject, actor, "Expected actor to be %s, but was %s", tc.expectedSubject, actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwksCacheTTL - time the keys of a remote JWKS are used before fetching them again
+	jwksCacheTTL = time.Hour
+	// jwksMinRefreshInterval - minimum time between fetches of a remote JWKS when a key is not found or a fetch failed
+	jwksMinRefreshInterval = 10 * time.Second
+	// jwksFetchTimeout - maximum time fetching a remote JWKS
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// KeySource - source of the RSA public keys verifying the RS256 tokens
+type KeySource interface {
+	// Key - returns the public key with the given key ID ("kid" header).
+	// An empty key ID is only valid if the source has a single key
+	Key(ctx context.Context, kid string) (*rsa.PublicKey, error)
+}
+
+// NewKeySource - returns the key source of the JWKS location, which can be a URL or a local file path
+func NewKeySource(location string) (KeySource, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return NewRemoteJWKS(location, &http.Client{Timeout: jwksFetchTimeout}, RemoteJWKSOptions{}), nil
+	}
+	return LoadJWKSFile(location)
+}
+
+// JWKS - static JSON Web Key Set with the RSA public keys by key ID
+type JWKS map[string]*rsa.PublicKey
+
+// ParseJWKS - parses a JSON Web Key Set (RFC 7517), ignoring the non RSA keys
+func ParseJWKS(data []byte) (JWKS, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := JWKS{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %w", key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+// LoadJWKSFile - reads and parses the JSON Web Key Set file
+func LoadJWKSFile(path string) (JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// Key - returns the key with the given key ID
+func (keys JWKS) Key(_ context.Context, kid string) (*rsa.PublicKey, error) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	key, isOK := keys[kid]
+	if !isOK {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// RemoteJWKSOptions - RemoteJWKS options
+type RemoteJWKSOptions struct {
+	// CacheTTL - time the keys are used before fetching them again, one hour if it's not positive
+	CacheTTL time.Duration
+	// MinRefreshInterval - minimum time between fetches when a key is not found or a fetch failed,
+	// 10 seconds if it's not positive
+	MinRefreshInterval time.Duration
+}
+
+// RemoteJWKS - JSON Web Key Set fetched from a URL. The keys are cached and fetched again when
+// they expire or a token is signed with an unknown key (e.g. after a key rotation). The cached keys
+// are still used while the fetches fail, so the tokens are validated while the URL is unavailable
+type RemoteJWKS struct {
+	url    string
+	client *http.Client
+	opts   RemoteJWKSOptions
+	group  singleflight.Group
+
+	mu        sync.RWMutex
+	keys      JWKS
+	fetchedAt time.Time
+	checkedAt time.Time
+	// fetchErr - error of the last fetch, returned without fetching again until MinRefreshInterval passes
+	fetchErr error
+}
+
+// NewRemoteJWKS - returns a new RemoteJWKS fetching the keys with the client, which should have a timeout.
+// The keys are fetched on the first use
+func NewRemoteJWKS(url string, client *http.Client, opts RemoteJWKSOptions) *RemoteJWKS {
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = jwksCacheTTL
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = jwksMinRefreshInterval
+	}
+	return &RemoteJWKS{url: url, client: client, opts: opts}
+}
+
+// Key - returns the key with the given key ID, fetching the keys if needed
+func (r *RemoteJWKS) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	r.mu.RLock()
+	cached, fetchedAt, checkedAt, fetchErr := r.keys, r.fetchedAt, r.checkedAt, r.fetchErr
+	r.mu.RUnlock()
+
+	isThrottled := time.Since(checkedAt) < r.opts.MinRefreshInterval
+	if cached == nil && isThrottled {
+		return nil, fetchErr
+	}
+	if cached != nil {
+		key, err := cached.Key(ctx, kid)
+		isFresh := time.Since(fetchedAt) < r.opts.CacheTTL
+		if (err == nil && isFresh) || isThrottled {
+			return key, err
+		}
+	}
+
+	keys, err := r.refresh()
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		logrus.Errorf("Error in auth.RemoteJWKS.Key -> error fetching %s, using the cached keys: %s", r.url, err)
+		return cached.Key(ctx, kid)
+	}
+
+	return keys.Key(ctx, kid)
+}
+
+// refresh - fetches the keys and caches them. The concurrent calls share a single fetch, which is not cancelled
+// with the request of any of them
+func (r *RemoteJWKS) refresh() (JWKS, error) {
+	keys, err, _ := r.group.Do(r.url, func() (interface{}, error) {
+		keys, err := r.fetch(context.Background())
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.checkedAt = time.Now()
+		r.fetchErr = err
+		if err != nil {
+			return nil, err
+		}
+		r.keys = keys
+		r.fetchedAt = r.checkedAt
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.(JWKS), nil
+}
+
+func (r *RemoteJWKS) fetch(ctx context.Context) (JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS response status %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseJWKS(data)
+}
@@ -0,0 +1,207 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeJWKS - returns the JSON Web Key Set with the public keys by key ID
+func encodeJWKS(keys map[string]*rsa.PublicKey) []byte {
+	encoded := `{"keys": [`
+	first := true
+	for kid, key := range keys {
+		if !first {
+			encoded += ","
+		}
+		first = false
+		encoded += fmt.Sprintf(`{"kty": "RSA", "use": "sig", "alg": "RS256", "kid": %q, "n": %q, "e": %q}`,
+			kid,
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		)
+	}
+	return []byte(encoded + `, {"kty": "EC", "kid": "ec-kid", "crv": "P-256"}]}`)
+}
+
+func TestLoadJWKSFile(t *testing.T) {
+	// Given
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, encodeJWKS(map[string]*rsa.PublicKey{"file-kid": &rsaKey.PublicKey}), 0o600))
+
+	// When
+	keys, err := auth.NewKeySource(path)
+
+	// Then
+	require.NoError(t, err)
+	key, err := keys.Key(context.TODO(), "file-kid")
+	require.NoError(t, err)
+	assert.Truef(t, rsaKey.PublicKey.Equal(key), "Expected the key to be the file one")
+	_, err = keys.Key(context.TODO(), "ec-kid")
+	assert.Error(t, err, "Expected the non RSA keys to be ignored")
+}
+
+func TestRemoteJWKS(t *testing.T) {
+	// Given
+	firstKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := map[string]*rsa.PublicKey{"first-kid": &firstKey.PublicKey}
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			keys["rotated-kid"] = &rotatedKey.PublicKey
+		}
+		_, _ = w.Write(encodeJWKS(keys))
+	}))
+	defer server.Close()
+
+	keys, err := auth.NewKeySource(server.URL)
+	require.NoError(t, err)
+
+	// When
+	first, firstErr := keys.Key(context.TODO(), "first-kid")
+	cached, cachedErr := keys.Key(context.TODO(), "first-kid")
+	// the unknown keys are not fetched again until the minimum refresh interval
+	rotated, rotatedErr := keys.Key(context.TODO(), "rotated-kid")
+
+	// Then
+	require.NoError(t, firstErr)
+	require.NoError(t, cachedErr)
+	assert.Truef(t, firstKey.PublicKey.Equal(first), "Expected the key to be the first one")
+	assert.Truef(t, firstKey.PublicKey.Equal(cached), "Expected the key to be the first one")
+	assert.Error(t, rotatedErr, "Expected error")
+	assert.Nilf(t, rotated, "Expected rotated key to be nil, but was %v", rotated)
+	assert.Equalf(t, int32(1), atomic.LoadInt32(&fetches), "Expected the keys to be fetched once, but were fetched %d times", fetches)
+}
+
+func TestRemoteJWKSWithFailingFetch(t *testing.T) {
+	// Given
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(encodeJWKS(map[string]*rsa.PublicKey{"kid": &key.PublicKey}))
+	}))
+	defer server.Close()
+
+	keys := auth.NewRemoteJWKS(server.URL, server.Client(), auth.RemoteJWKSOptions{CacheTTL: 10 * time.Millisecond, MinRefreshInterval: 20 * time.Millisecond})
+
+	// When
+	first, firstErr := keys.Key(context.TODO(), "kid")
+	time.Sleep(30 * time.Millisecond)
+	// the keys expired, but the failed fetch is not retried until the minimum refresh interval
+	stale, staleErr := keys.Key(context.TODO(), "kid")
+	staleAgain, staleAgainErr := keys.Key(context.TODO(), "kid")
+
+	// Then
+	require.NoError(t, firstErr)
+	require.NoError(t, staleErr)
+	require.NoError(t, staleAgainErr)
+	assert.Truef(t, key.PublicKey.Equal(first), "Expected the fetched key")
+	assert.Truef(t, key.PublicKey.Equal(stale), "Expected the cached key to be used while the fetch fails")
+	assert.Truef(t, key.PublicKey.Equal(staleAgain), "Expected the cached key to be used while the fetch fails")
+	assert.Equalf(t, int32(2), atomic.LoadInt32(&fetches), "Expected the keys to be fetched twice, but were fetched %d times", fetches)
+}
+
+func TestRemoteJWKSWithUnavailableURL(t *testing.T) {
+	// Given
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	keys := auth.NewRemoteJWKS(server.URL, server.Client(), auth.RemoteJWKSOptions{MinRefreshInterval: 50 * time.Millisecond})
+
+	// When
+	_, firstErr := keys.Key(context.TODO(), "kid")
+	// without cached keys, the failed fetch is not retried until the minimum refresh interval either
+	_, throttledErr := keys.Key(context.TODO(), "kid")
+	_, throttledAgainErr := keys.Key(context.TODO(), "kid")
+	throttledFetches := atomic.LoadInt32(&fetches)
+	time.Sleep(60 * time.Millisecond)
+	_, retriedErr := keys.Key(context.TODO(), "kid")
+
+	// Then
+	require.Error(t, firstErr, "Expected error")
+	assert.Equalf(t, firstErr, throttledErr, "Expected the error of the last fetch, but was %v", throttledErr)
+	assert.Equalf(t, firstErr, throttledAgainErr, "Expected the error of the last fetch, but was %v", throttledAgainErr)
+	assert.Equalf(t, int32(1), throttledFetches, "Expected the keys to be fetched once, but were fetched %d times", throttledFetches)
+	require.Error(t, retriedErr, "Expected error")
+	assert.Equalf(t, int32(2), atomic.LoadInt32(&fetches), "Expected the keys to be fetched again after the minimum refresh interval, but were fetched %d times", fetches)
+}
+
+func TestRemoteJWKSWithConcurrentFetches(t *testing.T) {
+	// Given
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		_, _ = w.Write(encodeJWKS(map[string]*rsa.PublicKey{"kid": &key.PublicKey}))
+	}))
+	defer server.Close()
+
+	keys := auth.NewRemoteJWKS(server.URL, server.Client(), auth.RemoteJWKSOptions{})
+
+	// When
+	const requests = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, requests+1)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(context.TODO(), "kid")
+			errs <- err
+		}()
+	}
+	// a cancelled request does not cancel the shared fetch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := keys.Key(ctx, "kid")
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	// Then
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equalf(t, int32(1), atomic.LoadInt32(&fetches), "Expected the keys to be fetched once, but were fetched %d times", fetches)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"user-microservice/internal/audit"
+	httpErrors "user-microservice/internal/errors/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// bearerScheme - authorization scheme of the tokens
+const bearerScheme = "Bearer"
+
+// HeaderAPIKey - request header with the API key of the internal services
+const HeaderAPIKey = "X-API-Key"
+
+// ErrInvalidAPIKey - the API key does not exist, is expired or was revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// KeyAuthenticator - authenticates the API keys
+type KeyAuthenticator interface {
+	// Authenticate - returns the principal of the key, or an ErrInvalidAPIKey error if it's not valid
+	Authenticate(ctx context.Context, key string) (Principal, error)
+}
+
+// Middleware - returns an echo middleware requiring a valid API key in the X-API-Key header or a valid
+// bearer token in the Authorization header. The tokens are rejected if the validator is nil, and the
+// API keys if the keys authenticator is nil.
+// The principal (and the token claims) are stored in the request context, and its subject is used as the audit actor.
+// Must be used after the audit middleware
+func Middleware(validator *Validator, keys KeyAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx, err := authenticate(req.Context(), validator, keys, req.Header.Get(HeaderAPIKey), req.Header.Get(echo.HeaderAuthorization))
+			if err != nil {
+				var authErr *authError
+				if errors.As(err, &authErr) {
+					return unauthorized(c, authErr.message)
+				}
+				return err
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// CheckRequest - Check for the echo requests, returning a forbidden HTTP error if the action is not allowed
+func CheckRequest(c echo.Context, action Action, target string, fields ...string) error {
+	if err := Check(c.Request().Context(), action, target, fields...); err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden)
+	}
+	return nil
+}
+
+// authError - rejection of the credentials, with the message returned to the client
+type authError struct {
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
+// authenticate - authenticates the API key, or the bearer token of the authorization value if there is no key.
+// Returns a copy of the context carrying the principal (and the token claims) with its subject as the audit actor,
+// or an authError if the credentials are rejected
+func authenticate(ctx context.Context, validator *Validator, keys KeyAuthenticator, key, authorization string) (context.Context, error) {
+	var principal Principal
+	if key != "" {
+		if keys == nil {
+			return nil, &authError{httpErrors.ErrInvalidAPIKey}
+		}
+		var err error
+		principal, err = keys.Authenticate(ctx, key)
+		if err != nil {
+			if errors.Is(err, ErrInvalidAPIKey) {
+				logrus.Infof("Error in auth.authenticate -> error authenticating API key: %s", err)
+				return nil, &authError{httpErrors.ErrInvalidAPIKey}
+			}
+			return nil, err
+		}
+	} else {
+		token, isOK := bearerToken(authorization)
+		if !isOK {
+			return nil, &authError{httpErrors.ErrMissingToken}
+		}
+		if validator == nil {
+			return nil, &authError{httpErrors.ErrInvalidToken}
+		}
+
+		claims, err := validator.Validate(ctx, token)
+		if err != nil {
+			logrus.Infof("Error in auth.authenticate -> error validating token: %s", err)
+			return nil, &authError{httpErrors.ErrInvalidToken}
+		}
+		ctx = NewContext(ctx, claims)
+		principal = claims.Principal()
+	}
+
+	// the actor header is ignored for the authenticated requests
+	info := audit.FromContext(ctx)
+	info.Actor = principal.Subject
+	if info.Actor == "" {
+		info.Actor = audit.ActorAnonymous
+	}
+
+	return audit.NewContext(NewPrincipalContext(ctx, principal), info), nil
+}
+
+// bearerToken - returns the token of the Authorization header value
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, bearerScheme) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(c echo.Context, message string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, bearerScheme)
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
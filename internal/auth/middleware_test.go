@@ -0,0 +1,290 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte("test-secret")
+
+// mintToken - returns a new token signed with the given method and key
+func mintToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoErrorf(t, err, "Expected no error when signing token, but was %s", err)
+	return signed
+}
+
+func validClaims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "test-issuer",
+		Audience:  jwt.ClaimStrings{"users-microservice"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys := auth.JWKS{"test-kid": &rsaKey.PublicKey}
+
+	expired := validClaims("expired-user")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	withoutExpiration := validClaims("no-exp-user")
+	withoutExpiration.ExpiresAt = nil
+	otherIssuer := validClaims("other-issuer-user")
+	otherIssuer.Issuer = "other-issuer"
+	otherAudience := validClaims("other-audience-user")
+	otherAudience.Audience = jwt.ClaimStrings{"other-service"}
+
+	for _, tc := range []struct {
+		name            string
+		authorization   string
+		expectedCode    int
+		expectedSubject string
+	}{
+		{
+			"Authenticate HS256 token",
+			"Bearer " + mintToken(t, jwt.SigningMethodHS256, testSecret, "", validClaims("hs256-user")),
+			http.StatusOK,
+			"hs256-user",
+		},
+		{
+			"Authenticate RS256 token",
+			"Bearer " + mintToken(t, jwt.SigningMethodRS256, rsaKey, "test-kid", validClaims("rs256-user")),
+			http.StatusOK,
+			"rs256-user",
+		},
+		{
+			"Authenticate with lowercase scheme",
+			"bearer " + mintToken(t, jwt.SigningMethodHS256, testSecret, "", validClaims("lowercase-user")),
+			http.StatusOK,
+			"lowercase-user",
+		},
+		{
+			"Missing token",
+			"",
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Not bearer token",
+			"Basic dXNlcjpwYXNzd29yZA==",
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Malformed token",
+			"Bearer not-a-token",
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"HS256 token with wrong secret",
+			"Bearer " + mintToken(t, jwt.SigningMethodHS256, []byte("wrong-secret"), "", validClaims("wrong-secret-user")),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"RS256 token with unknown key",
+			"Bearer " + mintToken(t, jwt.SigningMethodRS256, otherRSAKey, "other-kid", validClaims("unknown-key-user")),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"RS256 token signed with other key",
+			"Bearer " + mintToken(t, jwt.SigningMethodRS256, otherRSAKey, "test-kid", validClaims("other-key-user")),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Unsigned token",
+			"Bearer " + mintToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims("none-user")),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Expired token",
+			"Bearer " + mintToken(t, jwt.SigningMethodHS256, testSecret, "", expired),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Token without expiration",
+			"Bearer " + mintToken(t, jwt.SigningMethodHS256, testSecret, "", withoutExpiration),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Token with other issuer",
+			"Bearer " + mintToken(t, jwt.SigningMethodHS256, testSecret, "", otherIssuer),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"Token with other audience",
+			"Bearer " + mintToken(t, jwt.SigningMethodHS256, testSecret, "", otherAudience),
+			http.StatusUnauthorized,
+			"",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			validator, err := auth.NewValidator(auth.Options{
+				Secret:   testSecret,
+				Keys:     keys,
+				Issuer:   "test-issuer",
+				Audience: "users-microservice",
+			})
+			require.NoError(t, err)
+
+			var claims *auth.Claims
+			var actor string
+			e := echo.New()
+			e.GET("/users", func(c echo.Context) error {
+				claims, _ = auth.FromContext(c.Request().Context())
+				actor = audit.FromContext(c.Request().Context()).Actor
+				return c.NoContent(http.StatusOK)
+			}, audit.Middleware(), auth.Middleware(validator, nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set(audit.HeaderActor, "spoofed-actor")
+			if tc.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			// When
+			e.ServeHTTP(rec, req)
+
+			// Then
+			assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+			if tc.expectedCode == http.StatusOK {
+				require.NotNil(t, claims, "Expected claims not to be nil")
+				assert.Equalf(t, tc.expectedSubject, claims.Subject, "Expected subject to be %s, but was %s", tc.expectedSubject, claims.Subject)
+				assert.Equalf(t, tc.expectedSubject, actor, "Expected actor to be %s, but was %s", tc.expectedSubject, actor)
+			} else {
+				assert.Equalf(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate), "Expected WWW-Authenticate to be Bearer, but was %s", rec.Header().Get(echo.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
+
+func TestNewValidatorWithoutKeys(t *testing.T) {
+	// When
+	validator, err := auth.NewValidator(auth.Options{})
+
+	// Then
+	assert.Error(t, err, "Expected error")
+	assert.Nilf(t, validator, "Expected validator to be nil, but was %v", validator)
+}
+
+// fakeKeys - KeyAuthenticator accepting a single key
+type fakeKeys struct {
+	key       string
+	principal auth.Principal
+	err       error
+}
+
+func (f fakeKeys) Authenticate(_ context.Context, key string) (auth.Principal, error) {
+	if f.err != nil {
+		return auth.Principal{}, f.err
+	}
+	if key != f.key {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+	return f.principal, nil
+}
+
+func TestMiddlewareWithAPIKey(t *testing.T) {
+	keys := fakeKeys{key: "umk_valid", principal: auth.Principal{Subject: "apikey:billing", Scopes: []string{auth.ScopeUsersRead}}}
+	for _, tc := range []struct {
+		name              string
+		apiKey            string
+		keys              auth.KeyAuthenticator
+		expectedCode      int
+		expectedPrincipal auth.Principal
+	}{
+		{
+			"Authenticate valid API key",
+			"umk_valid",
+			keys,
+			http.StatusOK,
+			keys.principal,
+		},
+		{
+			"Authenticate invalid API key",
+			"umk_invalid",
+			keys,
+			http.StatusUnauthorized,
+			auth.Principal{},
+		},
+		{
+			"Authenticate API key when they are not accepted",
+			"umk_valid",
+			nil,
+			http.StatusUnauthorized,
+			auth.Principal{},
+		},
+		{
+			"Authenticate API key with authenticator error",
+			"umk_valid",
+			fakeKeys{err: errors.New("homemade error")},
+			http.StatusInternalServerError,
+			auth.Principal{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			var principal auth.Principal
+			var actor string
+			e := echo.New()
+			e.GET("/users", func(c echo.Context) error {
+				principal, _ = auth.PrincipalFromContext(c.Request().Context())
+				actor = audit.FromContext(c.Request().Context()).Actor
+				return c.NoContent(http.StatusOK)
+			}, audit.Middleware(), auth.Middleware(nil, tc.keys))
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set(auth.HeaderAPIKey, tc.apiKey)
+			rec := httptest.NewRecorder()
+
+			// When
+			e.ServeHTTP(rec, req)
+
+			// Then
+			assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+			assert.Equalf(t, tc.expectedPrincipal, principal, "Expected principal to be %v, but was %v", tc.expectedPrincipal, principal)
+			if tc.expectedCode == http.StatusOK {
+				assert.Equalf(t, tc.expectedPrincipal.Subject, actor, "Expected actor to be %s, but was %s", tc.expectedPrincipal.Subject, actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"user-microservice/internal/audit"
+)
+
+// ErrForbidden - the principal is not allowed to perform the action
+var ErrForbidden = errors.New("forbidden")
+
+// Role - role of the users, from the tokens "role" claim
+type Role string
+
+const (
+	// RoleUser - regular user, can only read, update (except the password) and verify the email of its own record and manage its own sessions and second factor
+	RoleUser Role = "user"
+	// RoleSupport - support staff, can read all the users and update some fields of them
+	RoleSupport Role = "support"
+	// RoleAdmin - administrator, can do everything
+	RoleAdmin Role = "admin"
+)
+
+// Scopes of the API keys and tokens
+const (
+	// ScopeUsersRead - allows reading all the users
+	ScopeUsersRead = "users:read"
+	// ScopeUsersWrite - allows reading, creating, updating and deleting all the users
+	ScopeUsersWrite = "users:write"
+	// ScopeUsersAdmin - allows everything
+	ScopeUsersAdmin = "users:admin"
+)
+
+// Action - operation over the users
+type Action string
+
+const (
+	ActionRead        Action = "read"
+	ActionList        Action = "list"
+	ActionCreate      Action = "create"
+	ActionUpdate      Action = "update"
+	ActionDelete      Action = "delete"
+	ActionRestore     Action = "restore"
+	ActionReadHistory Action = "readHistory"
+	// ActionListSessions - list the active sessions (refresh tokens) of the user
+	ActionListSessions Action = "listSessions"
+	// ActionRevokeSession - revoke a session of the user
+	ActionRevokeSession Action = "revokeSession"
+	// ActionVerifyEmail - verify the email of the user with a verification token
+	ActionVerifyEmail Action = "verifyEmail"
+	// ActionManageMFA - enroll the second factor of the user
+	ActionManageMFA Action = "manageMFA"
+	// ActionModerate - suspend, ban and reactivate the user, only allowed to the administrators
+	ActionModerate Action = "moderate"
+	// ActionClearLockout - clear the login lockout of the user, only allowed to the administrators
+	ActionClearLockout Action = "clearLockout"
+	// ActionManageWebhooks - register, update and delete the webhooks of the user events, only allowed to the administrators
+	ActionManageWebhooks Action = "manageWebhooks"
+)
+
+// SupportUpdatableFields - user fields (json names) the support role can update
+var SupportUpdatableFields = map[string]bool{
+	"firstName": true,
+	"lastName":  true,
+	"nickname":  true,
+	"country":   true,
+}
+
+// SelfNotUpdatableFields - user fields (json names) the users cannot update in their own record.
+// The password is changed with the password reset, which proves the user still owns the email
+var SelfNotUpdatableFields = map[string]bool{
+	"password": true,
+}
+
+// scopeActions - actions allowed by each scope (ScopeUsersAdmin allows everything)
+var scopeActions = map[string]map[Action]bool{
+	ScopeUsersRead: {
+		ActionRead: true,
+		ActionList: true,
+	},
+	ScopeUsersWrite: {
+		ActionRead:        true,
+		ActionList:        true,
+		ActionCreate:      true,
+		ActionUpdate:      true,
+		ActionDelete:      true,
+		ActionRestore:     true,
+		ActionVerifyEmail: true,
+	},
+}
+
+// Principal - authenticated caller, identified by a token or an API key
+type Principal struct {
+	// Subject - user ID for the tokens, key ID for the API keys
+	Subject string
+	// Role - role of the user, empty for the API keys
+	Role Role
+	// Scopes - granted scopes
+	Scopes []string
+}
+
+type principalContextKey struct{}
+
+// NewPrincipalContext - returns a copy of the context carrying the principal
+func NewPrincipalContext(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext - returns the principal of the context, if any.
+// There is no principal when the authentication is disabled
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, isOK := ctx.Value(principalContextKey{}).(Principal)
+	return principal, isOK
+}
+
+// Authorize - returns an ErrForbidden error if the principal is not allowed to perform the action over the
+// target user. The target is empty for the actions over several users, and the fields are the user
+// fields (json names) changed by the updates
+func (p Principal) Authorize(action Action, target string, fields ...string) error {
+	if p.Role == RoleAdmin || p.HasScope(ScopeUsersAdmin) {
+		return nil
+	}
+	for _, scope := range p.Scopes {
+		if scopeActions[scope][action] {
+			return nil
+		}
+	}
+
+	switch p.Role {
+	case RoleSupport:
+		switch action {
+		case ActionRead, ActionList, ActionReadHistory, ActionListSessions:
+			return nil
+		case ActionUpdate:
+			for _, field := range fields {
+				if !SupportUpdatableFields[field] {
+					return fmt.Errorf("%w: role %s cannot update field %s", ErrForbidden, p.Role, field)
+				}
+			}
+			return nil
+		}
+	case RoleUser:
+		switch action {
+		case ActionUpdate:
+			for _, field := range fields {
+				if SelfNotUpdatableFields[field] {
+					return fmt.Errorf("%w: role %s cannot update field %s", ErrForbidden, p.Role, field)
+				}
+			}
+			if p.isSelf(target) {
+				return nil
+			}
+		case ActionRead, ActionListSessions, ActionRevokeSession, ActionVerifyEmail, ActionManageMFA:
+			if p.isSelf(target) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s cannot %s user %q", ErrForbidden, p, action, target)
+}
+
+// Check - returns an ErrForbidden error if the principal of the context is not allowed to perform the action over
+// the target user, logging the denial. The contexts without principal are allowed (auth disabled)
+func Check(ctx context.Context, action Action, target string, fields ...string) error {
+	principal, isOK := PrincipalFromContext(ctx)
+	if !isOK {
+		return nil
+	}
+	if err := principal.Authorize(action, target, fields...); err != nil {
+		audit.LogDenied(ctx, string(action), target, err)
+		return err
+	}
+
+	return nil
+}
+
+// HasScope - returns true if the principal has the scope
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// String - returns the principal description for the logs
+func (p Principal) String() string {
+	if p.Role != "" {
+		return fmt.Sprintf("%s (role %s)", p.Subject, p.Role)
+	}
+	return fmt.Sprintf("%s (scopes %s)", p.Subject, strings.Join(p.Scopes, " "))
+}
+
+func (p Principal) isSelf(target string) bool {
+	return target != "" && strings.EqualFold(p.Subject, target)
+}
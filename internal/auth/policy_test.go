@@ -0,0 +1,147 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPrincipal_Authorize(t *testing.T) {
+	const (
+		selfID  = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+		otherID = "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739"
+	)
+	user := auth.Principal{Subject: selfID, Role: auth.RoleUser}
+	support := auth.Principal{Subject: "support-id", Role: auth.RoleSupport}
+	admin := auth.Principal{Subject: "admin-id", Role: auth.RoleAdmin}
+	readKey := auth.Principal{Subject: "read-key", Scopes: []string{auth.ScopeUsersRead}}
+	writeKey := auth.Principal{Subject: "write-key", Scopes: []string{auth.ScopeUsersWrite}}
+	adminKey := auth.Principal{Subject: "admin-key", Scopes: []string{auth.ScopeUsersAdmin}}
+
+	for _, tc := range []struct {
+		name      string
+		principal auth.Principal
+		action    auth.Action
+		target    string
+		fields    []string
+		allowed   bool
+	}{
+		{"User reads itself", user, auth.ActionRead, selfID, nil, true},
+		{"User reads itself with uppercase ID", user, auth.ActionRead, "DDD50D89-0CF4-4D35-B8E8-51A2B5A06CE4", nil, true},
+		{"User updates itself", user, auth.ActionUpdate, selfID, []string{"nickname", "email"}, true},
+		{"User updates its password", user, auth.ActionUpdate, selfID, []string{"email", "password"}, false},
+		{"User reads other user", user, auth.ActionRead, otherID, nil, false},
+		{"User updates other user", user, auth.ActionUpdate, otherID, []string{"nickname"}, false},
+		{"User deletes itself", user, auth.ActionDelete, selfID, nil, false},
+		{"User lists users", user, auth.ActionList, "", nil, false},
+		{"User reads its history", user, auth.ActionReadHistory, selfID, nil, false},
+		{"User lists its sessions", user, auth.ActionListSessions, selfID, nil, true},
+		{"User revokes its session", user, auth.ActionRevokeSession, selfID, nil, true},
+		{"User lists other user sessions", user, auth.ActionListSessions, otherID, nil, false},
+		{"User verifies its email", user, auth.ActionVerifyEmail, selfID, nil, true},
+		{"User verifies other user email", user, auth.ActionVerifyEmail, otherID, nil, false},
+		{"User enrolls its second factor", user, auth.ActionManageMFA, selfID, nil, true},
+		{"User enrolls other user second factor", user, auth.ActionManageMFA, otherID, nil, false},
+		{"User reactivates itself", user, auth.ActionModerate, selfID, nil, false},
+		{"Support reads other user", support, auth.ActionRead, otherID, nil, true},
+		{"Support lists users", support, auth.ActionList, "", nil, true},
+		{"Support reads history", support, auth.ActionReadHistory, otherID, nil, true},
+		{"Support updates allowed fields", support, auth.ActionUpdate, otherID, []string{"nickname", "country"}, true},
+		{"Support updates password", support, auth.ActionUpdate, otherID, []string{"nickname", "password"}, false},
+		{"Support lists sessions", support, auth.ActionListSessions, otherID, nil, true},
+		{"Support revokes session", support, auth.ActionRevokeSession, otherID, nil, false},
+		{"Support deletes user", support, auth.ActionDelete, otherID, nil, false},
+		{"Support creates user", support, auth.ActionCreate, "", nil, false},
+		{"Support verifies email", support, auth.ActionVerifyEmail, otherID, nil, false},
+		{"Support suspends user", support, auth.ActionModerate, otherID, nil, false},
+		{"Support clears lockout", support, auth.ActionClearLockout, otherID, nil, false},
+		{"Support manages webhooks", support, auth.ActionManageWebhooks, "", nil, false},
+		{"Admin deletes user", admin, auth.ActionDelete, otherID, nil, true},
+		{"Admin updates password", admin, auth.ActionUpdate, otherID, []string{"password"}, true},
+		{"Admin revokes session", admin, auth.ActionRevokeSession, otherID, nil, true},
+		{"Admin suspends user", admin, auth.ActionModerate, otherID, nil, true},
+		{"Admin clears lockout", admin, auth.ActionClearLockout, otherID, nil, true},
+		{"Admin manages webhooks", admin, auth.ActionManageWebhooks, "", nil, true},
+		{"Read key lists users", readKey, auth.ActionList, "", nil, true},
+		{"Read key updates user", readKey, auth.ActionUpdate, otherID, []string{"nickname"}, false},
+		{"Write key deletes user", writeKey, auth.ActionDelete, otherID, nil, true},
+		{"Write key reads history", writeKey, auth.ActionReadHistory, otherID, nil, false},
+		{"Write key verifies email", writeKey, auth.ActionVerifyEmail, otherID, nil, true},
+		{"Write key enrolls second factor", writeKey, auth.ActionManageMFA, otherID, nil, false},
+		{"Write key bans user", writeKey, auth.ActionModerate, otherID, nil, false},
+		{"Admin key bans user", adminKey, auth.ActionModerate, otherID, nil, true},
+		{"Write key manages webhooks", writeKey, auth.ActionManageWebhooks, "", nil, false},
+		{"Admin key reads history", adminKey, auth.ActionReadHistory, otherID, nil, true},
+		{"Principal without role nor scopes", auth.Principal{Subject: "nobody"}, auth.ActionRead, "nobody", nil, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			err := tc.principal.Authorize(tc.action, tc.target, tc.fields...)
+
+			// Then
+			if tc.allowed {
+				assert.NoErrorf(t, err, "Expected %s to be allowed to %s, but was %s", tc.principal, tc.action, err)
+			} else {
+				assert.Truef(t, errors.Is(err, auth.ErrForbidden), "Expected forbidden error, but was %v", err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const selfID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	user := auth.Principal{Subject: selfID, Role: auth.RoleUser}
+
+	for _, tc := range []struct {
+		name      string
+		principal *auth.Principal
+		action    auth.Action
+		target    string
+		allowed   bool
+	}{
+		{"Check without principal", nil, auth.ActionDelete, selfID, true},
+		{"Check allowed action", &user, auth.ActionRead, selfID, true},
+		{"Check forbidden action", &user, auth.ActionDelete, selfID, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctx := context.Background()
+			if tc.principal != nil {
+				ctx = auth.NewPrincipalContext(ctx, *tc.principal)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			// When
+			err := auth.Check(ctx, tc.action, tc.target)
+			requestErr := auth.CheckRequest(c, tc.action, tc.target)
+			callErr := auth.CheckCall(ctx, tc.action, tc.target)
+
+			// Then
+			if tc.allowed {
+				assert.NoErrorf(t, err, "Expected the action to be allowed, but was %s", err)
+				assert.NoErrorf(t, requestErr, "Expected the request to be allowed, but was %s", requestErr)
+				assert.NoErrorf(t, callErr, "Expected the call to be allowed, but was %s", callErr)
+			} else {
+				assert.Truef(t, errors.Is(err, auth.ErrForbidden), "Expected forbidden error, but was %v", err)
+				assert.Equalf(t, echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden), requestErr, "Expected forbidden HTTP error, but was %v", requestErr)
+				assert.Equalf(t, codes.PermissionDenied, status.Code(callErr), "Expected permission denied status, but was %v", callErr)
+			}
+		})
+	}
+}
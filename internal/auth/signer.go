@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// DefaultAccessTokenTTL - default lifetime of the issued access tokens
+const DefaultAccessTokenTTL = 15 * time.Minute
+
+// Signer - signs the access tokens issued by the service with the shared secret (HS256),
+// so they are accepted by a Validator with the same secret, issuer and audience
+type Signer struct {
+	secret   []byte
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+// NewSigner - returns a new Signer of tokens valid for the given ttl (DefaultAccessTokenTTL if it's not positive),
+// or an error if the secret is empty
+func NewSigner(secret []byte, issuer, audience string, ttl time.Duration) (*Signer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("auth needs a secret to sign the tokens")
+	}
+	if ttl <= 0 {
+		ttl = DefaultAccessTokenTTL
+	}
+
+	return &Signer{secret: secret, issuer: issuer, audience: audience, ttl: ttl}, nil
+}
+
+// TTL - returns the lifetime of the signed tokens
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign - returns a new signed access token for the principal and its expiration date
+func (s *Signer) Sign(principal Principal) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   principal.Subject,
+			Issuer:    s.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Role:  principal.Role,
+		Scope: strings.Join(principal.Scopes, " "),
+	}
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
@@ -0,0 +1,75 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_Sign(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		signerSecret  []byte
+		validatorOpts auth.Options
+		expectedError error
+	}{
+		{
+			"Sign token accepted by the validator",
+			testSecret,
+			auth.Options{Secret: testSecret, Issuer: "test-issuer", Audience: "users-microservice"},
+			nil,
+		},
+		{
+			"Sign token with another secret",
+			[]byte("other-secret"),
+			auth.Options{Secret: testSecret, Issuer: "test-issuer", Audience: "users-microservice"},
+			auth.ErrInvalidToken,
+		},
+		{
+			"Sign token for another audience",
+			testSecret,
+			auth.Options{Secret: testSecret, Issuer: "test-issuer", Audience: "other-service"},
+			auth.ErrInvalidToken,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			signer, err := auth.NewSigner(tc.signerSecret, "test-issuer", "users-microservice", time.Minute)
+			require.NoError(t, err)
+			validator, err := auth.NewValidator(tc.validatorOpts)
+			require.NoError(t, err)
+			principal := auth.Principal{Subject: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Role: auth.RoleUser, Scopes: []string{auth.ScopeUsersRead}}
+
+			// When
+			token, expiresAt, err := signer.Sign(principal)
+			require.NoError(t, err)
+			claims, err := validator.Validate(context.Background(), token)
+
+			// Then
+			assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 5*time.Second)
+			if tc.expectedError != nil {
+				assert.ErrorIsf(t, err, tc.expectedError, "Expected error to be %s, but was %v", tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, principal, claims.Principal())
+			assert.NotEmpty(t, claims.ID, "Expected the token to have an ID")
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	// When
+	signer, err := auth.NewSigner(nil, "", "", 0)
+
+	// Then
+	assert.Error(t, err)
+	assert.Nil(t, signer)
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrInvalidToken - the token is malformed, expired or its signature is not valid
+var ErrInvalidToken = errors.New("invalid token")
+
+// Options - Validator options. At least one of Secret or Keys must be set
+type Options struct {
+	// Secret - shared secret of the HS256 tokens. HS256 tokens are rejected if it's empty
+	Secret []byte
+	// Keys - source of the RS256 public keys. RS256 tokens are rejected if it's nil
+	Keys KeySource
+	// Issuer - expected "iss" claim, not checked if empty
+	Issuer string
+	// Audience - expected "aud" claim, not checked if empty
+	Audience string
+}
+
+// Validator - validates the signed bearer tokens
+type Validator struct {
+	opts   Options
+	parser *jwt.Parser
+}
+
+// NewValidator - returns a new Validator, or an error if there is no way to verify the tokens
+func NewValidator(opts Options) (*Validator, error) {
+	if len(opts.Secret) == 0 && opts.Keys == nil {
+		return nil, errors.New("auth needs a secret or a JWKS to validate the tokens")
+	}
+
+	return &Validator{
+		opts:   opts,
+		parser: jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()})),
+	}, nil
+}
+
+// Validate - returns the claims of the token if its signature, expiration, issuer and audience are valid.
+// The tokens without expiration are not valid
+func (v *Validator) Validate(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFunc(ctx)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: missing expiration", ErrInvalidToken)
+	}
+	if v.opts.Issuer != "" && !claims.VerifyIssuer(v.opts.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+	if v.opts.Audience != "" && !claims.VerifyAudience(v.opts.Audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience %v", ErrInvalidToken, claims.Audience)
+	}
+
+	return claims, nil
+}
+
+// keyFunc - returns the key verifying the token, depending on its signing method
+func (v *Validator) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.Alg() {
+		case jwt.SigningMethodHS256.Alg():
+			if len(v.opts.Secret) == 0 {
+				return nil, errors.New("HS256 tokens are not accepted")
+			}
+			return v.opts.Secret, nil
+		case jwt.SigningMethodRS256.Alg():
+			if v.opts.Keys == nil {
+				return nil, errors.New("RS256 tokens are not accepted")
+			}
+			kid, _ := token.Header["kid"].(string)
+			return v.opts.Keys.Key(ctx, kid)
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+	}
+}
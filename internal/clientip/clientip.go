@@ -0,0 +1,29 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NewExtractor - returns the echo.IPExtractor of the client IPs. The X-Forwarded-For header is only trusted when the
+// request comes from one of the trusted proxies (CIDR ranges), otherwise the IP of the connection is used, so the
+// clients cannot spoof their IP to avoid the rate limits and lockouts
+func NewExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	// only the given ranges are trusted, not the loopback, link-local and private ones trusted by default
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		opts = append(opts, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(opts...), nil
+}
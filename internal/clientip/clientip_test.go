@@ -0,0 +1,54 @@
+package clientip_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-microservice/internal/clientip"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExtractor(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		expectedIP     string
+	}{
+		{"Extract connection IP without trusted proxies", nil, "203.0.113.10:1234", "198.51.100.1", "203.0.113.10"},
+		{"Extract connection IP of a private proxy not trusted", nil, "10.0.0.2:1234", "198.51.100.1", "10.0.0.2"},
+		{"Extract forwarded IP from a trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.2:1234", "198.51.100.1", "198.51.100.1"},
+		{"Extract last not trusted forwarded IP", []string{"10.0.0.0/8"}, "10.0.0.2:1234", "192.0.2.1, 198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"Extract connection IP from a not trusted proxy", []string{"10.0.0.0/8"}, "203.0.113.10:1234", "198.51.100.1", "203.0.113.10"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			extractor, err := clientip.NewExtractor(tc.trustedProxies)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, tc.forwardedFor)
+
+			// When
+			ip := extractor(req)
+
+			// Then
+			assert.Equalf(t, tc.expectedIP, ip, "Expected IP to be %s, but was %s", tc.expectedIP, ip)
+		})
+	}
+}
+
+func TestNewExtractorWithInvalidProxy(t *testing.T) {
+	// When
+	_, err := clientip.NewExtractor([]string{"not a range"})
+
+	// Then
+	assert.Error(t, err)
+}
@@ -0,0 +1,211 @@
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultConcurrency - number of events handled at once if not set in the options
+	DefaultConcurrency = 1
+	// DefaultShutdownTimeout - time given to the events in progress to finish on shutdown if not set in the options
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Handler - typed handler of the user events, with a method per topic. NopHandler can be embedded
+// to only implement some of them
+type Handler interface {
+	OnUserCreated(ctx context.Context, user models.User) error
+	OnUserUpdated(ctx context.Context, user models.User) error
+	OnUserDeleted(ctx context.Context, userID string) error
+	OnUserRestored(ctx context.Context, user models.User) error
+	OnUserPurged(ctx context.Context, userID string) error
+	OnUserEmailVerified(ctx context.Context, user models.User) error
+	OnUserStatusChanged(ctx context.Context, user models.User) error
+}
+
+// NopHandler - Handler ignoring all the events
+type NopHandler struct{}
+
+var _ Handler = NopHandler{}
+
+func (NopHandler) OnUserCreated(context.Context, models.User) error       { return nil }
+func (NopHandler) OnUserUpdated(context.Context, models.User) error       { return nil }
+func (NopHandler) OnUserDeleted(context.Context, string) error            { return nil }
+func (NopHandler) OnUserRestored(context.Context, models.User) error      { return nil }
+func (NopHandler) OnUserPurged(context.Context, string) error             { return nil }
+func (NopHandler) OnUserEmailVerified(context.Context, models.User) error { return nil }
+func (NopHandler) OnUserStatusChanged(context.Context, models.User) error { return nil }
+
+// EventHandler - handler of any user event, wrapped by the middlewares
+type EventHandler interface {
+	Handle(ctx context.Context, event userPS.Event) error
+}
+
+// EventHandlerFunc - function implementing EventHandler
+type EventHandlerFunc func(ctx context.Context, event userPS.Event) error
+
+// Handle - calls the function with the event
+func (f EventHandlerFunc) Handle(ctx context.Context, event userPS.Event) error {
+	return f(ctx, event)
+}
+
+// Typed - returns the EventHandler calling the method of the handler for the event topic. The events
+// of unknown topics, or without user when the topic needs one, are logged and skipped
+func Typed(h Handler) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+		switch event.Topic {
+		case userPS.TopicUserDeletion:
+			return h.OnUserDeleted(ctx, event.UserID)
+		case userPS.TopicUserPurge:
+			return h.OnUserPurged(ctx, event.UserID)
+		}
+
+		var on func(context.Context, models.User) error
+		switch event.Topic {
+		case userPS.TopicUserCreation:
+			on = h.OnUserCreated
+		case userPS.TopicUserUpdate:
+			on = h.OnUserUpdated
+		case userPS.TopicUserRestore:
+			on = h.OnUserRestored
+		case userPS.TopicUserEmailVerified:
+			on = h.OnUserEmailVerified
+		case userPS.TopicUserStatusChanged:
+			on = h.OnUserStatusChanged
+		default:
+			logrus.Warnf("Skipping the event of unknown topic %s", event.Topic)
+			return nil
+		}
+		if event.User == nil {
+			logrus.Warnf("Skipping the %s event of user %s without user", event.Topic, event.UserID)
+			return nil
+		}
+		return on(ctx, *event.User)
+	})
+}
+
+// Fanout - returns the EventHandler sending the event to all the handlers, one after another. All of them
+// receive the event even if some fail, and the first error is returned
+func Fanout(handlers ...EventHandler) EventHandler {
+	return EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+		var firstErr error
+		for _, h := range handlers {
+			if err := h.Handle(ctx, event); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	})
+}
+
+// Options - consumer options
+type Options struct {
+	// Topics - topics consumed, all the user topics if empty
+	Topics []string
+	// Concurrency - number of events handled at once. The events of the same user are always handled
+	// one after another, in the order they were received
+	Concurrency int
+	// ShutdownTimeout - time given to the events in progress to finish once the consumer is stopped,
+	// their context is cancelled afterwards
+	ShutdownTimeout time.Duration
+}
+
+// Consumer - consumes the user events of a subscriber, handling them with an EventHandler
+type Consumer struct {
+	subscriber userPS.Subscriber
+	handler    EventHandler
+	opts       Options
+}
+
+// NewConsumer - returns a new Consumer of the subscriber events, which must be started with Run.
+// The zero options are replaced by their default values
+func NewConsumer(subscriber userPS.Subscriber, handler EventHandler, opts Options) *Consumer {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	return &Consumer{
+		subscriber: subscriber,
+		handler:    handler,
+		opts:       opts,
+	}
+}
+
+// Run - handles the events until the context is done, subscribing again with an exponential backoff if the
+// subscription fails or is lost. Once the context is done, no more events are received and it waits for the
+// events in progress, up to the shutdown timeout, before returning
+func (c *Consumer) Run(ctx context.Context) {
+	handleCtx, cancelHandle := context.WithCancel(context.Background())
+	defer cancelHandle()
+
+	var workers sync.WaitGroup
+	queues := make([]chan userPS.Event, c.opts.Concurrency)
+	for i := range queues {
+		queues[i] = make(chan userPS.Event)
+		workers.Add(1)
+		go func(queue <-chan userPS.Event) {
+			defer workers.Done()
+			for event := range queue {
+				c.handle(handleCtx, event)
+			}
+		}(queues[i])
+	}
+
+	c.subscribe(ctx, queues)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		workers.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(c.opts.ShutdownTimeout):
+		logrus.Warnf("The events in progress did not finish in %s, cancelling them", c.opts.ShutdownTimeout)
+		cancelHandle()
+		<-done
+	}
+}
+
+// subscribe - sends the subscription events to the queue of their user until the context is done
+func (c *Consumer) subscribe(ctx context.Context, queues []chan userPS.Event) {
+	userPS.Listen(ctx, c.subscriber, "consumer", func(event userPS.Event) {
+		select {
+		case queues[partition(event.UserID, len(queues))] <- event:
+		case <-ctx.Done():
+		}
+	}, c.opts.Topics...)
+}
+
+// handle - handles the event, logging the panics so a single event cannot stop the consumer
+func (c *Consumer) handle(ctx context.Context, event userPS.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("Error in consumer.handle -> panic handling %s event of user %s: %v", event.Topic, event.UserID, r)
+		}
+	}()
+
+	if err := c.handler.Handle(ctx, event); err != nil {
+		logrus.Errorf("Error in consumer.handle -> error handling %s event of user %s: %s", event.Topic, event.UserID, err)
+	}
+}
+
+// partition - returns the queue of the user events, so the events of a user are handled in order
+func partition(userID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,234 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/consumer"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	aliceID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	bobID   = "5cace01f-45c3-49f0-a725-c22866874095"
+)
+
+// recorder - typed handler recording the calls as "<method>:<user ID>"
+type recorder struct {
+	consumer.NopHandler
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+	return nil
+}
+
+func (r *recorder) OnUserCreated(_ context.Context, user models.User) error {
+	return r.record("created:" + user.ID)
+}
+
+func (r *recorder) OnUserDeleted(_ context.Context, userID string) error {
+	return r.record("deleted:" + userID)
+}
+
+func (r *recorder) OnUserStatusChanged(_ context.Context, user models.User) error {
+	return r.record("statusChanged:" + user.ID)
+}
+
+func (r *recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// fakeSubscriber - subscriber returning the given subscriptions one after another, failing when there are no more.
+// As the redis one, the events channel is closed when the context is done
+type fakeSubscriber struct {
+	mu            sync.Mutex
+	subscriptions []chan userPS.Event
+	topics        [][]string
+}
+
+func (s *fakeSubscriber) Subscribe(ctx context.Context, topics ...string) (<-chan userPS.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topics = append(s.topics, topics)
+	if len(s.subscriptions) == 0 {
+		return nil, errors.New("connection refused")
+	}
+	subscription := s.subscriptions[0]
+	s.subscriptions = s.subscriptions[1:]
+
+	events := make(chan userPS.Event)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, isOpen := <-subscription:
+				if !isOpen {
+					return
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func userEvent(topic, id string) userPS.Event {
+	return userPS.Event{Topic: topic, UserID: id, User: &models.User{ID: id}}
+}
+
+func TestTyped(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		event         userPS.Event
+		expectedCalls []string
+	}{
+		{"Route user creation", userEvent(userPS.TopicUserCreation, aliceID), []string{"created:" + aliceID}},
+		{"Route user status change", userEvent(userPS.TopicUserStatusChanged, aliceID), []string{"statusChanged:" + aliceID}},
+		{"Route user deletion", userPS.Event{Topic: userPS.TopicUserDeletion, UserID: aliceID}, []string{"deleted:" + aliceID}},
+		{"Skip not implemented method", userEvent(userPS.TopicUserUpdate, aliceID), nil},
+		{"Skip event without user", userPS.Event{Topic: userPS.TopicUserCreation, UserID: aliceID}, nil},
+		{"Skip unknown topic", userEvent("homemade", aliceID), nil},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			r := &recorder{}
+
+			// When
+			err := consumer.Typed(r).Handle(context.Background(), tc.event)
+
+			// Then
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedCalls, r.Calls(), "Expected calls to be %v, but were %v", tc.expectedCalls, r.Calls())
+		})
+	}
+}
+
+func TestFanout(t *testing.T) {
+	// Given
+	first, last := &recorder{}, &recorder{}
+	failing := consumer.EventHandlerFunc(func(context.Context, userPS.Event) error {
+		return errors.New("sink unavailable")
+	})
+
+	// When
+	err := consumer.Fanout(consumer.Typed(first), failing, consumer.Typed(last)).Handle(context.Background(), userEvent(userPS.TopicUserCreation, aliceID))
+
+	// Then
+	assert.EqualError(t, err, "sink unavailable")
+	assert.Equal(t, []string{"created:" + aliceID}, first.Calls())
+	assert.Equal(t, []string{"created:" + aliceID}, last.Calls(), "Expected the handlers after the failing one to receive the event")
+}
+
+func TestConsumer_Run(t *testing.T) {
+	// Given
+	first, second := make(chan userPS.Event), make(chan userPS.Event)
+	subscriber := &fakeSubscriber{subscriptions: []chan userPS.Event{first, second}}
+	r := &recorder{}
+	var inFlight, maxInFlight int
+	var mu sync.Mutex
+	handler := consumer.EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+		mu.Lock()
+		if inFlight++; inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return consumer.Typed(r).Handle(ctx, event)
+	})
+	topics := []string{userPS.TopicUserCreation, userPS.TopicUserDeletion}
+	c := consumer.NewConsumer(subscriber, handler, consumer.Options{Topics: topics, Concurrency: 4})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	// When
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	first <- userEvent(userPS.TopicUserCreation, aliceID)
+	first <- userEvent(userPS.TopicUserCreation, bobID)
+	first <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: aliceID}
+	close(first) // subscription lost
+	second <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: bobID}
+	require.Eventually(t, func() bool { return len(r.Calls()) == 4 }, 5*time.Second, 5*time.Millisecond, "Expected all the events to be handled")
+	cancel()
+	<-done
+
+	// Then
+	calls := r.Calls()
+	assert.ElementsMatch(t, []string{"created:" + aliceID, "created:" + bobID, "deleted:" + aliceID, "deleted:" + bobID}, calls)
+	assert.Less(t, indexOf(calls, "created:"+aliceID), indexOf(calls, "deleted:"+aliceID), "Expected the events of a user to be handled in order")
+	assert.Less(t, indexOf(calls, "created:"+bobID), indexOf(calls, "deleted:"+bobID), "Expected the events of a user to be handled in order")
+	assert.LessOrEqual(t, maxInFlight, 4, "Expected at most 4 events handled at once")
+	subscriber.mu.Lock()
+	defer subscriber.mu.Unlock()
+	require.Len(t, subscriber.topics, 2, "Expected to subscribe again after losing the subscription")
+	assert.Equal(t, topics, subscriber.topics[1])
+}
+
+func TestConsumer_RunShutdownTimeout(t *testing.T) {
+	// Given
+	events := make(chan userPS.Event)
+	started := make(chan struct{})
+	var cancelled bool
+	handler := consumer.EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+		close(started)
+		<-ctx.Done()
+		cancelled = true
+		return ctx.Err()
+	})
+	c := consumer.NewConsumer(&fakeSubscriber{subscriptions: []chan userPS.Event{events}}, handler, consumer.Options{ShutdownTimeout: 50 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	// When
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	events <- userEvent(userPS.TopicUserCreation, aliceID)
+	<-started
+	cancel()
+
+	// Then
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Run to return after the shutdown timeout")
+	}
+	assert.True(t, cancelled, "Expected the context of the event in progress to be cancelled")
+}
+
+func indexOf(calls []string, call string) int {
+	for i, c := range calls {
+		if c == call {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,51 @@
+package consumer
+
+import (
+	"context"
+	"time"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics - prometheus metrics of the consumed events
+type Metrics struct {
+	events   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics - returns the consumer metrics, registered in the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "consumer_events_total",
+			Help: "Number of user events handled, by handler, topic and result (succeeded or failed)",
+		}, []string{"handler", "topic", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "consumer_event_duration_seconds",
+			Help:    "Time spent handling the user events, by handler and topic",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"handler", "topic"}),
+	}
+	reg.MustRegister(m.events, m.duration)
+
+	return m
+}
+
+// Middleware - returns the middleware recording the events handled by the named handler
+func (m *Metrics) Middleware(name string) Middleware {
+	return func(next EventHandler) EventHandler {
+		return EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+			start := time.Now()
+			err := next.Handle(ctx, event)
+			m.duration.WithLabelValues(name, event.Topic).Observe(time.Since(start).Seconds())
+
+			result := "succeeded"
+			if err != nil {
+				result = "failed"
+			}
+			m.events.WithLabelValues(name, event.Topic, result).Inc()
+			return err
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"time"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxAttempts - number of attempts of an event if not set in the retry options
+	DefaultMaxAttempts = 3
+	// DefaultInitialBackoff - delay before the first retry if not set in the retry options
+	DefaultInitialBackoff = time.Second
+	// DefaultMaxBackoff - maximum delay between the retries if not set in the retry options
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Middleware - wraps an EventHandler adding some behaviour
+type Middleware func(next EventHandler) EventHandler
+
+// Chain - returns the handler wrapped by the middlewares, the first one being the outermost
+func Chain(h EventHandler, middlewares ...Middleware) EventHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Logging - returns the middleware logging the events handled by the named handler and their errors
+func Logging(name string) Middleware {
+	return func(next EventHandler) EventHandler {
+		return EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+			start := time.Now()
+			err := next.Handle(ctx, event)
+			if err != nil {
+				logrus.Errorf("Error in consumer.Logging -> %s failed handling %s event of user %s: %s", name, event.Topic, event.UserID, err)
+				return err
+			}
+			logrus.Debugf("%s handled %s event of user %s in %s", name, event.Topic, event.UserID, time.Since(start))
+			return nil
+		})
+	}
+}
+
+// permanentError - error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent - returns the error marked as permanent, so the Retry middleware does not retry it
+// (e.g. an event rejected by its destination)
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent - returns whether the error was marked as permanent
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// RetryOptions - options of the Retry middleware
+type RetryOptions struct {
+	// MaxAttempts - number of attempts of an event, including the first one
+	MaxAttempts int
+	// InitialBackoff - delay before the first retry, doubled after each one
+	InitialBackoff time.Duration
+	// MaxBackoff - maximum delay between the retries
+	MaxBackoff time.Duration
+}
+
+// Retry - returns the middleware retrying the failed events with an exponential backoff, until they are handled,
+// their error is permanent, the attempts are exhausted or the context is done. The last error is returned.
+// The zero options are replaced by their default values
+func Retry(opts RetryOptions) Middleware {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+
+	return func(next EventHandler) EventHandler {
+		return EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+			delay := opts.InitialBackoff
+			for attempt := 1; ; attempt++ {
+				err := next.Handle(ctx, event)
+				if err == nil || IsPermanent(err) || attempt >= opts.MaxAttempts {
+					return err
+				}
+				logrus.Warnf("Attempt %d of %s event of user %s failed, retrying in %s: %s", attempt, event.Topic, event.UserID, delay, err)
+
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return err
+				case <-timer.C:
+				}
+				if delay *= 2; delay > opts.MaxBackoff {
+					delay = opts.MaxBackoff
+				}
+			}
+		})
+	}
+}
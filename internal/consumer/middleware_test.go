@@ -0,0 +1,110 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/consumer"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var errSink = errors.New("sink unavailable")
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		errs             []error
+		cancelled        bool
+		expectedAttempts int
+		expectedError    error
+	}{
+		{"Handle at first attempt", []error{nil}, false, 1, nil},
+		{"Handle after retries", []error{errSink, errSink, nil}, false, 3, nil},
+		{"Fail after max attempts", []error{errSink, errSink, errSink, nil}, false, 3, errSink},
+		{"Fail with permanent error", []error{consumer.Permanent(errSink), nil}, false, 1, errSink},
+		{"Fail with context done", []error{errSink, nil}, true, 1, errSink},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			attempts := 0
+			handler := consumer.EventHandlerFunc(func(context.Context, userPS.Event) error {
+				err := tc.errs[attempts]
+				attempts++
+				return err
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+			retry := consumer.Retry(consumer.RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+
+			// When
+			err := retry(handler).Handle(ctx, userEvent(userPS.TopicUserCreation, aliceID))
+
+			// Then
+			assert.ErrorIsf(t, err, tc.expectedError, "Expected error to be %v, but was %v", tc.expectedError, err)
+			assert.Equalf(t, tc.expectedAttempts, attempts, "Expected %d attempts, but were %d", tc.expectedAttempts, attempts)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	// Given
+	var calls []string
+	middleware := func(name string) consumer.Middleware {
+		return func(next consumer.EventHandler) consumer.EventHandler {
+			return consumer.EventHandlerFunc(func(ctx context.Context, event userPS.Event) error {
+				calls = append(calls, name)
+				return next.Handle(ctx, event)
+			})
+		}
+	}
+	handler := consumer.EventHandlerFunc(func(context.Context, userPS.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	// When
+	err := consumer.Chain(handler, middleware("outer"), middleware("inner")).Handle(context.Background(), userEvent(userPS.TopicUserCreation, aliceID))
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner", "handler"}, calls)
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	// Given
+	reg := prometheus.NewRegistry()
+	metrics := consumer.NewMetrics(reg)
+	handler := consumer.EventHandlerFunc(func(_ context.Context, event userPS.Event) error {
+		if event.UserID == bobID {
+			return errSink
+		}
+		return nil
+	})
+	h := consumer.Chain(handler, metrics.Middleware("file"))
+
+	// When
+	h.Handle(context.Background(), userEvent(userPS.TopicUserCreation, aliceID))
+	h.Handle(context.Background(), userEvent(userPS.TopicUserCreation, aliceID))
+	h.Handle(context.Background(), userEvent(userPS.TopicUserCreation, bobID))
+
+	// Then
+	expected := `
+# HELP consumer_events_total Number of user events handled, by handler, topic and result (succeeded or failed)
+# TYPE consumer_events_total counter
+consumer_events_total{handler="file",result="failed",topic="user-created"} 1
+consumer_events_total{handler="file",result="succeeded",topic="user-created"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "consumer_events_total"))
+}
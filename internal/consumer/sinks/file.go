@@ -0,0 +1,51 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+	"user-microservice/internal/consumer"
+	userPS "user-microservice/internal/users/pubsub"
+)
+
+// JSONFile - sink appending the events to a file as JSON lines (one Record per line)
+type JSONFile struct {
+	mu      sync.Mutex
+	f       *os.File
+	encoder *json.Encoder
+}
+
+var _ consumer.EventHandler = (*JSONFile)(nil)
+
+// NewJSONFile - returns a new JSONFile sink appending the events to the file at path, created if it does not exist.
+// It must be closed with Close
+func NewJSONFile(path string) (*JSONFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONFile{f: f, encoder: json.NewEncoder(f)}, nil
+}
+
+// Handle - appends the event record to the file
+func (s *JSONFile) Handle(_ context.Context, event userPS.Event) error {
+	record := newRecord(event, time.Now().UTC())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(record)
+}
+
+// Close - flushes and closes the file
+func (s *JSONFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.f.Sync(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
@@ -0,0 +1,69 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"user-microservice/internal/consumer"
+	userPS "user-microservice/internal/users/pubsub"
+)
+
+const (
+	// DefaultForwardTimeout - timeout of the forwarded requests if not set
+	DefaultForwardTimeout = 10 * time.Second
+
+	// HeaderEventTopic - header with the topic of the forwarded event
+	HeaderEventTopic = "X-Event-Topic"
+)
+
+// Forwarder - sink sending each event Record in a POST request to a URL
+type Forwarder struct {
+	url    string
+	client *http.Client
+}
+
+var _ consumer.EventHandler = (*Forwarder)(nil)
+
+// NewForwarder - returns a new Forwarder sink sending the events to the URL, with the given requests timeout
+// (DefaultForwardTimeout if zero)
+func NewForwarder(url string, timeout time.Duration) *Forwarder {
+	if timeout <= 0 {
+		timeout = DefaultForwardTimeout
+	}
+	return &Forwarder{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Handle - sends the event record. The responses other than 2xx are errors, which are permanent for the
+// 4xx responses except 408 and 429, as sending the same event again would fail the same way
+func (s *Forwarder) Handle(ctx context.Context, event userPS.Event) error {
+	body, err := json.Marshal(newRecord(event, time.Now().UTC()))
+	if err != nil {
+		return consumer.Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return consumer.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventTopic, event.Topic)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("unexpected response status %d from %s", res.StatusCode, s.url)
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusRequestTimeout && res.StatusCode != http.StatusTooManyRequests {
+		return consumer.Permanent(err)
+	}
+	return err
+}
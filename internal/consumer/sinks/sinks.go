@@ -0,0 +1,21 @@
+package sinks
+
+import (
+	"time"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+)
+
+// Record - user event as written by the JSON file sink and sent by the HTTP forwarder
+type Record struct {
+	Topic      string    `json:"topic"`
+	UserID     string    `json:"userId"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	// User - the event user (its password is never serialised), missing in the deletion and purge events
+	User *models.User `json:"user,omitempty"`
+}
+
+// newRecord - returns the record of the event received at the given time
+func newRecord(event userPS.Event, now time.Time) Record {
+	return Record{Topic: event.Topic, UserID: event.UserID, ReceivedAt: now, User: event.User}
+}
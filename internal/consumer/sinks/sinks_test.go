@@ -0,0 +1,144 @@
+package sinks_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"user-microservice/internal/consumer"
+	"user-microservice/internal/consumer/sinks"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+
+func createdEvent() userPS.Event {
+	user := models.User{ID: userID, Nickname: "alicetingo", Email: "alicetingo@example.com", Password: "$2a$10$hashed"}
+	return userPS.Event{Topic: userPS.TopicUserCreation, UserID: userID, User: &user}
+}
+
+func deletedEvent() userPS.Event {
+	return userPS.Event{Topic: userPS.TopicUserDeletion, UserID: userID}
+}
+
+func TestWriter(t *testing.T) {
+	// Given
+	var buf bytes.Buffer
+	sink := sinks.NewWriter(&buf)
+
+	// When
+	require.NoError(t, sink.Handle(context.Background(), createdEvent()))
+	require.NoError(t, sink.Handle(context.Background(), deletedEvent()))
+
+	// Then
+	out := buf.String()
+	assert.Contains(t, out, "Received message from user-created channel.")
+	assert.Contains(t, out, `"nickname":"alicetingo"`)
+	assert.Contains(t, out, "Received message from user-deleted channel.\nPayload: "+userID+"\n")
+	assert.NotContains(t, out, "hashed", "Expected the password not to be printed")
+}
+
+func TestJSONFile(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"topic":"user-updated","userId":"`+userID+`"}`+"\n"), 0o600))
+	sink, err := sinks.NewJSONFile(path)
+	require.NoError(t, err)
+
+	// When
+	require.NoError(t, sink.Handle(context.Background(), createdEvent()))
+	require.NoError(t, sink.Handle(context.Background(), deletedEvent()))
+	require.NoError(t, sink.Close())
+
+	// Then
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var records []sinks.Record
+	scanner := bufio.NewScanner(bytes.NewReader(written))
+	for scanner.Scan() {
+		var record sinks.Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 3, "Expected the events to be appended to the existing ones")
+	assert.Equal(t, userPS.TopicUserUpdate, records[0].Topic)
+	assert.Equal(t, userPS.TopicUserCreation, records[1].Topic)
+	require.NotNil(t, records[1].User)
+	assert.Equal(t, "alicetingo", records[1].User.Nickname)
+	assert.NotContains(t, string(written), "password", "Expected the password not to be written")
+	assert.False(t, records[1].ReceivedAt.IsZero(), "Expected the reception date to be set")
+	assert.Equal(t, userPS.TopicUserDeletion, records[2].Topic)
+	assert.Equal(t, userID, records[2].UserID)
+	assert.Nil(t, records[2].User)
+}
+
+func TestForwarder(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		status            int
+		expectedError     bool
+		expectedPermanent bool
+	}{
+		{"Forward event successfully", http.StatusOK, false, false},
+		{"Forward event accepted", http.StatusAccepted, false, false},
+		{"Forward event rejected", http.StatusBadRequest, true, true},
+		{"Forward event rate limited", http.StatusTooManyRequests, true, false},
+		{"Forward event with server error", http.StatusServiceUnavailable, true, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			var topic, contentType string
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				topic = r.Header.Get(sinks.HeaderEventTopic)
+				contentType = r.Header.Get("Content-Type")
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+			sink := sinks.NewForwarder(server.URL, 0)
+
+			// When
+			err := sink.Handle(context.Background(), createdEvent())
+
+			// Then
+			assert.Equalf(t, tc.expectedError, err != nil, "Expected error: %t, but was %v", tc.expectedError, err)
+			assert.Equalf(t, tc.expectedPermanent, consumer.IsPermanent(err), "Expected permanent error: %t, but was %v", tc.expectedPermanent, err)
+			assert.Equal(t, userPS.TopicUserCreation, topic)
+			assert.True(t, strings.HasPrefix(contentType, "application/json"))
+			var record sinks.Record
+			require.NoError(t, json.Unmarshal(body, &record))
+			assert.Equal(t, userID, record.UserID)
+			assert.NotContains(t, string(body), "password", "Expected the password not to be sent")
+		})
+	}
+}
+
+func TestForwarder_Unreachable(t *testing.T) {
+	// Given
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	sink := sinks.NewForwarder(url, 0)
+
+	// When
+	err := sink.Handle(context.Background(), deletedEvent())
+
+	// Then
+	assert.Error(t, err)
+	assert.False(t, consumer.IsPermanent(err), "Expected the connection errors to be retried")
+}
@@ -0,0 +1,48 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"user-microservice/internal/consumer"
+	userPS "user-microservice/internal/users/pubsub"
+)
+
+// Writer - sink printing the events in a human readable format
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ consumer.EventHandler = (*Writer)(nil)
+
+// NewWriter - returns a new Writer sink printing the events to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// NewStdout - returns a new Writer sink printing the events to the standard output
+func NewStdout() *Writer {
+	return NewWriter(os.Stdout)
+}
+
+// Handle - prints the event topic and user as JSON (without its password), or only the user ID
+// for the deletion and purge events
+func (s *Writer) Handle(_ context.Context, event userPS.Event) error {
+	payload := event.UserID
+	if event.User != nil {
+		encoded, err := json.Marshal(event.User)
+		if err != nil {
+			return consumer.Permanent(err)
+		}
+		payload = string(encoded)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintf(s.w, "Received message from %s channel.\nPayload: %s\n", event.Topic, payload)
+	return err
+}
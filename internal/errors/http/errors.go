@@ -5,3 +5,102 @@ const ErrInvalidBody = "invalidBody"
 
 // ErrInvalidParams - request params are invalid
 const ErrInvalidParams = "invalidParams"
+
+// ErrInvalidFields - requested fields are not valid
+const ErrInvalidFields = "invalidFields"
+
+// ErrBatchTooLarge - the request exceeds the maximum batch size
+const ErrBatchTooLarge = "batchTooLarge"
+
+// ErrInvalidFormat - the requested data format is not supported
+const ErrInvalidFormat = "invalidFormat"
+
+// ErrMissingFields - required fields are missing in the request body
+const ErrMissingFields = "missingFields"
+
+// ErrImmutableFields - the request tries to modify immutable fields
+const ErrImmutableFields = "immutableFields"
+
+// ErrInvalidPatch - the patch document is not valid or could not be applied
+const ErrInvalidPatch = "invalidPatch"
+
+// ErrUnsupportedMediaType - the request content type is not supported
+const ErrUnsupportedMediaType = "unsupportedMediaType"
+
+// ErrPreconditionFailed - the If-Match precondition does not match the current resource version
+const ErrPreconditionFailed = "preconditionFailed"
+
+// ErrVersionConflict - the resource was modified by someone else since it was read
+const ErrVersionConflict = "versionConflict"
+
+// ErrDuplicateEmail - another user has the same email
+const ErrDuplicateEmail = "duplicateEmail"
+
+// ErrInvalidIdempotencyKey - the Idempotency-Key header is not valid
+const ErrInvalidIdempotencyKey = "invalidIdempotencyKey"
+
+// ErrIdempotencyKeyReused - the Idempotency-Key was already used with a different request
+const ErrIdempotencyKeyReused = "idempotencyKeyReused"
+
+// ErrIdempotencyKeyInProgress - a request with the same Idempotency-Key is still being processed
+const ErrIdempotencyKeyInProgress = "idempotencyKeyInProgress"
+
+// ErrMissingToken - the request has no bearer token
+const ErrMissingToken = "missingToken"
+
+// ErrInvalidToken - the bearer token is not valid or expired
+const ErrInvalidToken = "invalidToken"
+
+// ErrForbidden - the caller is not allowed to perform the request
+const ErrForbidden = "forbidden"
+
+// ErrInvalidAPIKey - the API key does not exist, is expired or was revoked
+const ErrInvalidAPIKey = "invalidApiKey"
+
+// ErrInvalidCredentials - the email does not exist or the password does not match
+const ErrInvalidCredentials = "invalidCredentials"
+
+// ErrInvalidRefreshToken - the refresh token does not exist, is expired, was revoked or was already used
+const ErrInvalidRefreshToken = "invalidRefreshToken"
+
+// ErrInvalidResetToken - the password reset token does not exist, is expired or was already used
+const ErrInvalidResetToken = "invalidResetToken"
+
+// ErrTooManyRequests - the caller exceeded the requests limit
+const ErrTooManyRequests = "tooManyRequests"
+
+// ErrInvalidVerificationToken - the email verification token is not valid, is expired or its email is not waiting for verification
+const ErrInvalidVerificationToken = "invalidVerificationToken"
+
+// ErrMFARequired - the user enrolled a second factor and the login has no code
+const ErrMFARequired = "mfaRequired"
+
+// ErrInvalidMFACode - the second factor code is not valid
+const ErrInvalidMFACode = "invalidMFACode"
+
+// ErrMFAAlreadyEnrolled - the user already enrolled a second factor
+const ErrMFAAlreadyEnrolled = "mfaAlreadyEnrolled"
+
+// ErrMFANotEnrolling - there is no second factor enrollment to confirm
+const ErrMFANotEnrolling = "mfaNotEnrolling"
+
+// ErrInvalidStatus - the status filter is not a known user status
+const ErrInvalidStatus = "invalidStatus"
+
+// ErrInvalidStatusTransition - the user status cannot be changed from the current one to the requested one
+const ErrInvalidStatusTransition = "invalidStatusTransition"
+
+// ErrUserNotActive - the user is suspended or banned
+const ErrUserNotActive = "userNotActive"
+
+// ErrLoginLocked - the logins of the account or the IP are locked out after too many failures
+const ErrLoginLocked = "loginLocked"
+
+// ErrQueryTooDeep - the GraphQL query selections are nested deeper than the maximum depth
+const ErrQueryTooDeep = "queryTooDeep"
+
+// ErrQueryTooComplex - the GraphQL query complexity is greater than the maximum one
+const ErrQueryTooComplex = "queryTooComplex"
+
+// ErrWebhookDisabled - the webhook is disabled, it must be enabled before sending its deliveries again
+const ErrWebhookDisabled = "webhookDisabled"
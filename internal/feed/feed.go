@@ -0,0 +1,263 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultBufferSize - default number of events buffered for each client, the clients falling behind are dropped
+	DefaultBufferSize = 64
+	// DefaultReplaySize - default number of recent events kept to resume the reconnected clients
+	DefaultReplaySize = 1000
+	// DefaultHeartbeatInterval - default time between the heartbeats sent to the idle clients
+	DefaultHeartbeatInterval = 15 * time.Second
+)
+
+var (
+	// ErrInvalidTopic - the topic is not one of the user topics
+	ErrInvalidTopic = errors.New("invalid topic")
+	// ErrInvalidUserID - the user ID is not a UUID
+	ErrInvalidUserID = errors.New("invalid user ID")
+)
+
+// Options - hub options, the zero values are replaced by the defaults
+type Options struct {
+	// BufferSize - number of events buffered for each client
+	BufferSize int
+	// ReplaySize - number of recent events kept to resume the clients, 0 for the default and negative to disable it
+	ReplaySize int
+}
+
+// Event - user event with its feed ID, which can be used to resume the feed after it
+type Event struct {
+	ID string
+	userPS.Event
+	seq uint64
+}
+
+// Filter - events sent to a client, empty lists mean all the topics or all the users
+type Filter struct {
+	Topics  []string
+	UserIDs []string
+}
+
+// NewFilter - returns the filter of the topics and the user IDs, validating them
+func NewFilter(topics, userIDs []string) (Filter, error) {
+	var filter Filter
+	valid := map[string]bool{}
+	for _, topic := range userPS.GetAllUsersTopics() {
+		valid[topic] = true
+	}
+	for _, topic := range topics {
+		if !valid[topic] {
+			return Filter{}, fmt.Errorf("%w %q", ErrInvalidTopic, topic)
+		}
+		filter.Topics = append(filter.Topics, topic)
+	}
+	for _, id := range userIDs {
+		userID, err := uuid.Parse(id)
+		if err != nil {
+			return Filter{}, fmt.Errorf("%w %q", ErrInvalidUserID, id)
+		}
+		filter.UserIDs = append(filter.UserIDs, userID.String())
+	}
+
+	return filter, nil
+}
+
+// Match - returns true if the event is one of the filter topics and users
+func (f Filter) Match(event userPS.Event) bool {
+	return (len(f.Topics) == 0 || contains(f.Topics, event.Topic)) &&
+		(len(f.UserIDs) == 0 || contains(f.UserIDs, event.UserID))
+}
+
+// Hub - relays the user events of a single pubsub subscription to the connected clients.
+// The events have an ID made of the hub instance and a sequence, and the recent ones are kept
+// so the clients reconnecting to the same instance can resume the feed after the last event they received
+type Hub struct {
+	subscriber userPS.Subscriber
+	bufferSize int
+	replaySize int
+	// instance - random ID of the hub, the events of other instances or of a previous run cannot be resumed
+	instance string
+
+	mu      sync.Mutex
+	seq     uint64
+	replay  []Event
+	clients map[*Client]bool
+}
+
+// Client - connected client receiving the events of its filter
+type Client struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+	// dropped - true if the client was dropped because it was not receiving the events fast enough
+	dropped bool
+}
+
+// NewHub - returns a new Hub relaying the events of the subscriber, which must be started with Run
+func NewHub(subscriber userPS.Subscriber, opts Options) *Hub {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.ReplaySize == 0 {
+		opts.ReplaySize = DefaultReplaySize
+	}
+	if opts.ReplaySize < 0 {
+		opts.ReplaySize = 0
+	}
+
+	return &Hub{
+		subscriber: subscriber,
+		bufferSize: opts.BufferSize,
+		replaySize: opts.ReplaySize,
+		instance:   strings.Split(uuid.NewString(), "-")[0],
+		clients:    map[*Client]bool{},
+	}
+}
+
+// Run - relays the events of all the user topics until the context is done, subscribing again with an
+// exponential backoff if the subscription fails or is lost. The clients are closed when it returns.
+// Should be run in its own goroutine
+func (h *Hub) Run(ctx context.Context) {
+	defer h.closeClients()
+
+	userPS.Listen(ctx, h.subscriber, "feed", h.publish)
+}
+
+// Subscribe - connects a new client receiving the events of the filter. If the last event ID is one of the recent
+// events of the hub, the missed events matching the filter are returned and resumed is true
+func (h *Hub) Subscribe(filter Filter, lastEventID string) (client *Client, missed []Event, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client = &Client{hub: h, filter: filter, events: make(chan Event, h.bufferSize)}
+	h.clients[client] = true
+
+	if lastEventID == "" {
+		return client, nil, false
+	}
+	seq, isOK := h.parseID(lastEventID)
+	if !isOK || !h.canResume(seq) {
+		logrus.Infof("Info in feed.Subscribe -> cannot resume the feed after event %s", lastEventID)
+		return client, nil, false
+	}
+	for _, event := range h.replay {
+		if event.seq > seq && filter.Match(event.Event) {
+			missed = append(missed, event)
+		}
+	}
+
+	return client, missed, true
+}
+
+// Clients - returns the number of connected clients
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// publish - sends the event to the clients of its filter, dropping the ones whose buffer is full
+func (h *Hub) publish(event userPS.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e := Event{ID: fmt.Sprintf("%s-%d", h.instance, h.seq), Event: event, seq: h.seq}
+	if h.replaySize > 0 {
+		if len(h.replay) == h.replaySize {
+			h.replay = h.replay[1:]
+		}
+		h.replay = append(h.replay, e)
+	}
+
+	for client := range h.clients {
+		if !client.filter.Match(event) {
+			continue
+		}
+		select {
+		case client.events <- e:
+		default:
+			logrus.Infof("Info in feed.publish -> dropping client with %d pending events", len(client.events))
+			client.dropped = true
+			h.remove(client)
+		}
+	}
+}
+
+// parseID - returns the sequence of the event ID if it's an event of this hub instance
+func (h *Hub) parseID(id string) (uint64, bool) {
+	instance, seqStr, found := strings.Cut(id, "-")
+	if !found || instance != h.instance {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil || seq > h.seq {
+		return 0, false
+	}
+	return seq, true
+}
+
+// canResume - returns true if all the events after the sequence are kept, must be called holding the lock
+func (h *Hub) canResume(seq uint64) bool {
+	if seq == h.seq {
+		return true
+	}
+	return len(h.replay) > 0 && h.replay[0].seq <= seq+1
+}
+
+// remove - disconnects the client, must be called holding the lock
+func (h *Hub) remove(client *Client) {
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.events)
+	}
+}
+
+func (h *Hub) closeClients() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		h.remove(client)
+	}
+}
+
+// Events - returns the events of the client, closed when the client is dropped or closed, or the hub stops
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Dropped - returns true if the client was dropped because it was not receiving the events fast enough
+func (c *Client) Dropped() bool {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	return c.dropped
+}
+
+// Close - disconnects the client from the hub
+func (c *Client) Close() {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.hub.remove(c)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package feed_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-microservice/internal/feed"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users/mock"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startHub - runs a hub relaying the events sent to the returned channel until the test ends
+func startHub(t *testing.T, opts feed.Options) (*feed.Hub, chan<- userPS.Event) {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	subscriber := mock.NewMockSubscriber(ctrl)
+	source := make(chan userPS.Event)
+	subscriber.EXPECT().Subscribe(gomock.Any()).Return((<-chan userPS.Event)(source), nil)
+
+	hub := feed.NewHub(subscriber, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		close(source)
+		<-stopped
+	})
+
+	return hub, source
+}
+
+// receive - returns the next event of the client, failing if there is none
+func receive(t *testing.T, client *feed.Client) feed.Event {
+	t.Helper()
+	select {
+	case event, isOpen := <-client.Events():
+		require.Truef(t, isOpen, "Expected client to be open")
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Expected an event, but there was none")
+	}
+	return feed.Event{}
+}
+
+func TestNewFilter(t *testing.T) {
+	userID := uuid.New()
+	for _, tc := range []struct {
+		name          string
+		topics        []string
+		userIDs       []string
+		expected      feed.Filter
+		expectedError error
+	}{
+		{
+			"New filter with all the events",
+			nil,
+			nil,
+			feed.Filter{},
+			nil,
+		},
+		{
+			"New filter with topics and users",
+			[]string{userPS.TopicUserCreation},
+			[]string{userID.String()},
+			feed.Filter{Topics: []string{userPS.TopicUserCreation}, UserIDs: []string{userID.String()}},
+			nil,
+		},
+		{
+			"New filter with invalid topic",
+			[]string{"homemade"},
+			nil,
+			feed.Filter{},
+			feed.ErrInvalidTopic,
+		},
+		{
+			"New filter with invalid user ID",
+			nil,
+			[]string{"homemade"},
+			feed.Filter{},
+			feed.ErrInvalidUserID,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			filter, err := feed.NewFilter(tc.topics, tc.userIDs)
+
+			// Then
+			assert.ErrorIsf(t, err, tc.expectedError, "Expected error to be %v, but was %v", tc.expectedError, err)
+			assert.Equalf(t, tc.expected, filter, "Expected filter to be %v, but was %v", tc.expected, filter)
+		})
+	}
+}
+
+func TestHubFilters(t *testing.T) {
+	user := models.User{ID: uuid.New().String(), Nickname: "atingo"}
+	otherID := uuid.New().String()
+	for _, tc := range []struct {
+		name            string
+		filter          feed.Filter
+		expectedUserIDs []string
+	}{
+		{
+			"Receive all the events",
+			feed.Filter{},
+			[]string{user.ID, otherID, user.ID},
+		},
+		{
+			"Receive the events of the topics",
+			feed.Filter{Topics: []string{userPS.TopicUserDeletion}},
+			[]string{otherID},
+		},
+		{
+			"Receive the events of the users",
+			feed.Filter{UserIDs: []string{user.ID}},
+			[]string{user.ID, user.ID},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			hub, source := startHub(t, feed.Options{})
+			client, _, _ := hub.Subscribe(tc.filter, "")
+			defer client.Close()
+			// receives all the events, so they are processed once it receives the last one
+			all, _, _ := hub.Subscribe(feed.Filter{}, "")
+			defer all.Close()
+
+			// When
+			source <- userPS.Event{Topic: userPS.TopicUserCreation, UserID: user.ID, User: &user}
+			source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: otherID}
+			source <- userPS.Event{Topic: userPS.TopicUserUpdate, UserID: user.ID, User: &user}
+			for i := 0; i < 3; i++ {
+				receive(t, all)
+			}
+
+			// Then
+			var userIDs []string
+			for len(client.Events()) > 0 {
+				userIDs = append(userIDs, receive(t, client).UserID)
+			}
+			assert.Equalf(t, tc.expectedUserIDs, userIDs, "Expected user IDs to be %v, but were %v", tc.expectedUserIDs, userIDs)
+		})
+	}
+}
+
+func TestHubDropsSlowClients(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	hub, source := startHub(t, feed.Options{BufferSize: 2})
+	slow, _, _ := hub.Subscribe(feed.Filter{}, "")
+	defer slow.Close()
+	fast, _, _ := hub.Subscribe(feed.Filter{}, "")
+	defer fast.Close()
+
+	// When
+	for i := 0; i < 3; i++ {
+		source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: uuid.New().String()}
+		receive(t, fast)
+	}
+
+	// Then
+	assert.Truef(t, slow.Dropped(), "Expected slow client to be dropped")
+	assert.Falsef(t, fast.Dropped(), "Expected fast client not to be dropped")
+	assert.Equalf(t, 1, hub.Clients(), "Expected 1 client, but there were %d", hub.Clients())
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equalf(t, 2, received, "Expected the slow client to receive the 2 buffered events, but received %d", received)
+}
+
+func TestHubResume(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		replaySize      int
+		lastEvent       int
+		expectedResumed bool
+		expectedMissed  int
+	}{
+		{
+			"Resume after a recent event",
+			10,
+			1,
+			true,
+			3,
+		},
+		{
+			"Resume after the last event",
+			10,
+			3,
+			true,
+			1,
+		},
+		{
+			"Resume after an event no longer kept",
+			2,
+			0,
+			false,
+			0,
+		},
+		{
+			"Resume after an unknown event",
+			10,
+			-1,
+			false,
+			0,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			hub, source := startHub(t, feed.Options{ReplaySize: tc.replaySize})
+			all, _, _ := hub.Subscribe(feed.Filter{}, "")
+			defer all.Close()
+			var ids []string
+			for i := 0; i < 5; i++ {
+				source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: uuid.New().String()}
+				ids = append(ids, receive(t, all).ID)
+			}
+			lastEventID := "homemade-3"
+			if tc.lastEvent >= 0 {
+				lastEventID = ids[tc.lastEvent]
+			}
+
+			// When
+			client, missed, resumed := hub.Subscribe(feed.Filter{}, lastEventID)
+			defer client.Close()
+
+			// Then
+			assert.Equalf(t, tc.expectedResumed, resumed, "Expected resumed to be %t, but was %t", tc.expectedResumed, resumed)
+			require.Lenf(t, missed, tc.expectedMissed, "Expected %d missed events, but were %d", tc.expectedMissed, len(missed))
+			if tc.expectedMissed > 0 {
+				assert.Equalf(t, ids[len(ids)-1], missed[len(missed)-1].ID, "Expected the last missed event to be %s, but was %s", ids[len(ids)-1], missed[len(missed)-1].ID)
+			}
+		})
+	}
+}
+
+func TestHubResubscribes(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	ctrl := gomock.NewController(t)
+	subscriber := mock.NewMockSubscriber(ctrl)
+	lost := make(chan userPS.Event)
+	source := make(chan userPS.Event)
+	gomock.InOrder(
+		subscriber.EXPECT().Subscribe(gomock.Any()).Return(nil, errors.New("homemade error")),
+		subscriber.EXPECT().Subscribe(gomock.Any()).Return((<-chan userPS.Event)(lost), nil),
+		subscriber.EXPECT().Subscribe(gomock.Any()).Return((<-chan userPS.Event)(source), nil),
+	)
+	hub := feed.NewHub(subscriber, feed.Options{})
+	client, _, _ := hub.Subscribe(feed.Filter{}, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+
+	// When
+	close(lost)
+	userID := uuid.New().String()
+	select {
+	case source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: userID}:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the hub to subscribe again")
+	}
+
+	// Then
+	assert.Equalf(t, userID, receive(t, client).UserID, "Expected the event of user %s", userID)
+	cancel()
+	close(source)
+	<-stopped
+	_, isOpen := <-client.Events()
+	assert.Falsef(t, isOpen, "Expected the client to be closed when the hub stops")
+}
@@ -0,0 +1,10 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package feed
+
+import "github.com/labstack/echo/v4"
+
+// Handler - live user events feed handlers
+type Handler interface {
+	StreamEvents(c echo.Context) error
+	WatchEvents(c echo.Context) error
+}
@@ -0,0 +1,247 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/feed"
+	"user-microservice/internal/models"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// HeaderLastEventID - SSE header with the ID of the last event received, sent by the reconnecting clients
+	HeaderLastEventID = "Last-Event-ID"
+
+	// writeTimeout - time given to each WebSocket write, the connection is closed afterwards
+	writeTimeout = 10 * time.Second
+)
+
+type httpHandler struct {
+	hub       *feed.Hub
+	heartbeat time.Duration
+	upgrader  websocket.Upgrader
+}
+
+// message - event sent to the clients, the user password is never serialised
+type message struct {
+	ID     string       `json:"id"`
+	Type   string       `json:"type"`
+	UserID string       `json:"userId"`
+	User   *models.User `json:"user,omitempty"`
+}
+
+// NewHttpHandler - returns a new feed.Handler relaying the hub events, with a heartbeat every interval
+// (feed.DefaultHeartbeatInterval if it's not positive)
+func NewHttpHandler(hub *feed.Hub, heartbeat time.Duration) feed.Handler {
+	if heartbeat <= 0 {
+		heartbeat = feed.DefaultHeartbeatInterval
+	}
+	return httpHandler{hub: hub, heartbeat: heartbeat}
+}
+
+// StreamEvents godoc
+//
+// @Summary     Streams the user events
+// @Description Streams the user events as they are published with Server-Sent Events. The event ID can be sent
+// @Description in the Last-Event-ID header when reconnecting to resume the stream after it, if the event is recent.
+// @Description The clients not receiving the events fast enough are disconnected
+// @Tags        Users
+// @Produce     text/event-stream
+// @Param       topics        query    string false "Comma separated list of topics to receive, all of them by default" example(user-created,user-deleted)
+// @Param       ids           query    string false "Comma separated list of user IDs to receive, all of them by default"
+// @Param       Last-Event-ID header   string false "ID of the last event received"
+// @Success     200           {string} string "Stream of events"
+// @Failure     400           {object} echo.HTTPError
+// @Failure     401           {object} echo.HTTPError
+// @Failure     403           {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/events [get]
+func (h httpHandler) StreamEvents(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionList, ""); err != nil {
+		return err
+	}
+	filter, err := parseFilter(c)
+	if err != nil {
+		return err
+	}
+
+	client, missed, _ := h.hub.Subscribe(filter, c.Request().Header.Get(HeaderLastEventID))
+	defer client.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	// disables the response buffering of the nginx proxies
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	for _, event := range missed {
+		if err := writeSSE(res, event); err != nil {
+			return nil
+		}
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, isOpen := <-client.Events():
+			if !isOpen {
+				logDropped(c, client)
+				return nil
+			}
+			if err := writeSSE(res, event); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// WatchEvents godoc
+//
+// @Summary     Streams the user events over WebSocket
+// @Description Same as the Server-Sent Events stream but over a WebSocket, sending each event as a JSON text message.
+// @Description The ID of the last event received can be sent in the lastEventId query param to resume the stream after it.
+// @Description The clients not receiving the events fast enough are disconnected with the 1013 (try again later) close code
+// @Tags        Users
+// @Param       topics      query    string false "Comma separated list of topics to receive, all of them by default" example(user-created,user-deleted)
+// @Param       ids         query    string false "Comma separated list of user IDs to receive, all of them by default"
+// @Param       lastEventId query    string false "ID of the last event received"
+// @Success     101         {string} string "Switching protocols"
+// @Failure     400         {object} echo.HTTPError
+// @Failure     401         {object} echo.HTTPError
+// @Failure     403         {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/events/ws [get]
+func (h httpHandler) WatchEvents(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionList, ""); err != nil {
+		return err
+	}
+	filter, err := parseFilter(c)
+	if err != nil {
+		return err
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// the upgrader already replied with the error
+		logrus.Infof("Info in feed/http.WatchEvents -> error upgrading connection: %s", err)
+		return nil
+	}
+	defer conn.Close()
+
+	client, missed, _ := h.hub.Subscribe(filter, c.QueryParam("lastEventId"))
+	defer client.Close()
+
+	// the messages of the client are discarded, but they must be read to process the control frames
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range missed {
+		if err := writeWS(conn, event); err != nil {
+			return nil
+		}
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return nil
+		case event, isOpen := <-client.Events():
+			if !isOpen {
+				logDropped(c, client)
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow")
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeTimeout))
+				return nil
+			}
+			if err := writeWS(conn, event); err != nil {
+				return nil
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// writeSSE - writes the event to the stream, with its ID and its topic as event type
+func writeSSE(res *echo.Response, event feed.Event) error {
+	data, err := json.Marshal(toMessage(event))
+	if err != nil {
+		logrus.Errorf("Error in feed/http.writeSSE -> error encoding event %s: %s", event.ID, err)
+		return nil
+	}
+	if _, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Topic, data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+func writeWS(conn *websocket.Conn, event feed.Event) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(toMessage(event))
+}
+
+func toMessage(event feed.Event) message {
+	return message{ID: event.ID, Type: event.Topic, UserID: event.UserID, User: event.User}
+}
+
+// parseFilter - returns the filter of the topics and ids query params, or a bad request error if they are not valid
+func parseFilter(c echo.Context) (feed.Filter, error) {
+	filter, err := feed.NewFilter(splitParam(c.QueryParam("topics")), splitParam(c.QueryParam("ids")))
+	if err != nil {
+		logrus.Infof("Info in feed/http.parseFilter -> %s", err)
+		return feed.Filter{}, echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+	}
+	return filter, nil
+}
+
+func splitParam(value string) []string {
+	var res []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func logDropped(c echo.Context, client *feed.Client) {
+	if client.Dropped() {
+		logrus.Infof("Info in feed/http -> client of request %s dropped for being too slow", audit.FromContext(c.Request().Context()).RequestID)
+	}
+}
@@ -0,0 +1,279 @@
+package http_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/feed"
+	feedHttp "user-microservice/internal/feed/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users/mock"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent - event read from the stream
+type sseEvent struct {
+	id    string
+	event string
+	data  map[string]interface{}
+}
+
+// startServer - serves the feed routes of a running hub relaying the events sent to the returned channel.
+// The requests are authenticated as the principal, if it's not nil
+func startServer(t *testing.T, principal *auth.Principal) (*httptest.Server, *feed.Hub, chan<- userPS.Event) {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	subscriber := mock.NewMockSubscriber(ctrl)
+	source := make(chan userPS.Event)
+	subscriber.EXPECT().Subscribe(gomock.Any()).Return((<-chan userPS.Event)(source), nil)
+	hub := feed.NewHub(subscriber, feed.Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(stopped)
+	}()
+
+	e := echo.New()
+	group := e.Group("/users", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if principal != nil {
+				c.SetRequest(c.Request().WithContext(auth.NewPrincipalContext(c.Request().Context(), *principal)))
+			}
+			return next(c)
+		}
+	})
+	feedHttp.AppendFeedRoutes(group, feedHttp.NewHttpHandler(hub, 50*time.Millisecond))
+	srv := httptest.NewServer(e)
+	t.Cleanup(func() {
+		cancel()
+		close(source)
+		<-stopped
+		srv.Close()
+	})
+
+	return srv, hub, source
+}
+
+// waitClients - waits until the hub has the given number of clients
+func waitClients(t *testing.T, hub *feed.Hub, clients int) {
+	t.Helper()
+	require.Eventuallyf(t, func() bool { return hub.Clients() == clients }, time.Second, 5*time.Millisecond, "Expected %d clients", clients)
+}
+
+// readEvent - reads the next event of the stream, skipping the heartbeats
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoErrorf(t, err, "Expected no error reading the stream, but was %s", err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && event.id != "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data)
+			require.NoErrorf(t, err, "Expected no error decoding the data, but was %s", err)
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	user := models.User{ID: uuid.New().String(), Nickname: "atingo", Password: "hashed password"}
+	deletedID := uuid.New().String()
+	for _, tc := range []struct {
+		name            string
+		query           string
+		principal       *auth.Principal
+		expectedStatus  int
+		expectedTopics  []string
+		expectedUserIDs []string
+	}{
+		{
+			"Stream all the events",
+			"",
+			nil,
+			http.StatusOK,
+			[]string{userPS.TopicUserCreation, userPS.TopicUserDeletion},
+			[]string{user.ID, deletedID},
+		},
+		{
+			"Stream the events of the topics",
+			"?topics=" + userPS.TopicUserDeletion + "," + userPS.TopicUserPurge,
+			&auth.Principal{Subject: "apikey:reader", Scopes: []string{auth.ScopeUsersRead}},
+			http.StatusOK,
+			[]string{userPS.TopicUserDeletion},
+			[]string{deletedID},
+		},
+		{
+			"Stream the events of the users",
+			"?ids=" + user.ID,
+			nil,
+			http.StatusOK,
+			[]string{userPS.TopicUserCreation},
+			[]string{user.ID},
+		},
+		{
+			"Stream the events of invalid topics",
+			"?topics=homemade",
+			nil,
+			http.StatusBadRequest,
+			nil,
+			nil,
+		},
+		{
+			"Stream the events without permission",
+			"",
+			&auth.Principal{Subject: "user-id", Role: auth.RoleUser},
+			http.StatusForbidden,
+			nil,
+			nil,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			srv, hub, source := startServer(t, tc.principal)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/users/events"+tc.query, nil)
+			require.NoError(t, err)
+
+			// When
+			res, err := http.DefaultClient.Do(req)
+			require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			defer res.Body.Close()
+
+			// Then
+			require.Equalf(t, tc.expectedStatus, res.StatusCode, "Expected status code to be %d, but was %d", tc.expectedStatus, res.StatusCode)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equalf(t, "text/event-stream", res.Header.Get(echo.HeaderContentType), "Expected event stream content type")
+
+			waitClients(t, hub, 1)
+			source <- userPS.Event{Topic: userPS.TopicUserCreation, UserID: user.ID, User: &user}
+			source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: deletedID}
+
+			reader := bufio.NewReader(res.Body)
+			for i, topic := range tc.expectedTopics {
+				event := readEvent(t, reader)
+				assert.Equalf(t, topic, event.event, "Expected event type to be %s, but was %s", topic, event.event)
+				assert.Equalf(t, tc.expectedUserIDs[i], event.data["userId"], "Expected user ID to be %s, but was %v", tc.expectedUserIDs[i], event.data["userId"])
+				if userData, ok := event.data["user"].(map[string]interface{}); ok {
+					assert.NotContainsf(t, userData, "password", "Expected the user not to contain the password")
+					assert.Equalf(t, user.Nickname, userData["nickname"], "Expected nickname to be %s, but was %v", user.Nickname, userData["nickname"])
+				}
+			}
+		})
+	}
+}
+
+func TestStreamEventsResume(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	srv, hub, source := startServer(t, nil)
+	first, _, _ := hub.Subscribe(feed.Filter{}, "")
+	var ids []string
+	for i := 0; i < 3; i++ {
+		source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: uuid.New().String()}
+		ids = append(ids, (<-first.Events()).ID)
+	}
+	first.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/users/events", nil)
+	require.NoError(t, err)
+	req.Header.Set(feedHttp.HeaderLastEventID, ids[0])
+
+	// When
+	res, err := http.DefaultClient.Do(req)
+	require.NoErrorf(t, err, "Expected no error, but was %s", err)
+	defer res.Body.Close()
+
+	// Then
+	reader := bufio.NewReader(res.Body)
+	for _, id := range ids[1:] {
+		event := readEvent(t, reader)
+		assert.Equalf(t, id, event.id, "Expected event ID to be %s, but was %s", id, event.id)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	user := models.User{ID: uuid.New().String(), Nickname: "atingo", Password: "hashed password"}
+	for _, tc := range []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{
+			"Watch the events of the users",
+			"?ids=" + user.ID,
+			http.StatusSwitchingProtocols,
+		},
+		{
+			"Watch the events of invalid users",
+			"?ids=homemade",
+			http.StatusBadRequest,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			srv, hub, source := startServer(t, nil)
+			url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/users/events/ws" + tc.query
+
+			// When
+			conn, res, err := websocket.DefaultDialer.Dial(url, nil)
+
+			// Then
+			require.NotNilf(t, res, "Expected a response, but there was none (%v)", err)
+			require.Equalf(t, tc.expectedStatus, res.StatusCode, "Expected status code to be %d, but was %d", tc.expectedStatus, res.StatusCode)
+			if tc.expectedStatus != http.StatusSwitchingProtocols {
+				return
+			}
+			require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			defer conn.Close()
+
+			waitClients(t, hub, 1)
+			source <- userPS.Event{Topic: userPS.TopicUserDeletion, UserID: uuid.New().String()}
+			source <- userPS.Event{Topic: userPS.TopicUserUpdate, UserID: user.ID, User: &user}
+
+			var msg map[string]interface{}
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+			err = conn.ReadJSON(&msg)
+			require.NoErrorf(t, err, "Expected no error reading the message, but was %s", err)
+			assert.Equalf(t, userPS.TopicUserUpdate, msg["type"], "Expected type to be %s, but was %v", userPS.TopicUserUpdate, msg["type"])
+			assert.Equalf(t, user.ID, msg["userId"], "Expected user ID to be %s, but was %v", user.ID, msg["userId"])
+			userData, _ := msg["user"].(map[string]interface{})
+			assert.NotContainsf(t, userData, "password", "Expected the user not to contain the password")
+
+			conn.Close()
+			waitClients(t, hub, 0)
+		})
+	}
+}
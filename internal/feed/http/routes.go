@@ -0,0 +1,13 @@
+package http
+
+import (
+	"user-microservice/internal/feed"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendFeedRoutes - Sets the live user events routes for the given users echo group
+func AppendFeedRoutes(e *echo.Group, h feed.Handler) {
+	e.GET("/events", h.StreamEvents)
+	e.GET("/events/ws", h.WatchEvents)
+}
@@ -0,0 +1,59 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// HeaderIdempotencyKey - request header with the client generated idempotency key
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed - response header set when the response is a replay of a previous one
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// MaxKeyLength - maximum length of the idempotency keys
+const MaxKeyLength = 255
+
+const (
+	// DefaultTTL - default time the responses are stored
+	DefaultTTL = 24 * time.Hour
+	// DefaultLockTimeout - default time a key is locked while its request is in progress
+	DefaultLockTimeout = time.Minute
+)
+
+// Record - stored result of a request made with an idempotency key
+type Record struct {
+	// Fingerprint - hash of the request, used to detect a key reused with another request
+	Fingerprint string `json:"fingerprint"`
+	// Completed - false while the original request is being processed
+	Completed bool        `json:"completed"`
+	Status    int         `json:"status,omitempty"`
+	Header    http.Header `json:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty"`
+}
+
+// Store - storage for the idempotency records
+type Store interface {
+	// Lock - stores an in progress record for the key if there is no record yet.
+	// Returns the existing record, or nil if the key has been locked by the current call
+	Lock(ctx context.Context, key, fingerprint string) (*Record, error)
+	// Save - stores the completed record for the key
+	Save(ctx context.Context, key string, record Record) error
+	// Unlock - removes the record for the key, so the request can be retried
+	Unlock(ctx context.Context, key string) error
+}
+
+// Fingerprint - returns the hash identifying the request method, uri and body
+func Fingerprint(method, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(uri))
+	hash.Write([]byte{0})
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
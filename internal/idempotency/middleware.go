@@ -0,0 +1,154 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// replayedHeaders - response headers stored with the responses and replayed. The rest of them belong to the
+// original request (e.g. the request ID, the rate limit or the cookies) and are not stored
+var replayedHeaders = []string{echo.HeaderContentType, echo.HeaderLocation, "ETag", "Deprecation", "Sunset", "Link"}
+
+// Middleware - returns an echo middleware handling the Idempotency-Key header for the non safe methods.
+// The first request with a key is processed and its response is stored, the following ones with the same key
+// and request get the stored response without executing the handler. Reusing a key with a different request
+// returns 422, and 409 if the first request is still in progress. Server errors are not stored, so they can be retried.
+// The keys are scoped by client, see ScopedKey, so it must be used after the auth middlewares
+func Middleware(store Store) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(HeaderIdempotencyKey)
+			if key == "" || !isMutation(req.Method) {
+				return next(c)
+			}
+			if len(key) > MaxKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidIdempotencyKey)
+			}
+
+			key = ScopedKey(req.Context(), key)
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				logrus.Errorf("Error in idempotency.Middleware -> error reading body: %s", err)
+				return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := Fingerprint(req.Method, req.URL.RequestURI(), body)
+
+			ctx := req.Context()
+			existing, err := store.Lock(ctx, key, fingerprint)
+			if err != nil {
+				logrus.Errorf("Error in idempotency.Middleware -> error locking key: %s", err)
+				return err
+			}
+			if existing != nil {
+				if existing.Fingerprint != fingerprint {
+					return echo.NewHTTPError(http.StatusUnprocessableEntity, httpErrors.ErrIdempotencyKeyReused)
+				}
+				if !existing.Completed {
+					return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrIdempotencyKeyInProgress)
+				}
+				return replay(c, *existing)
+			}
+
+			res := c.Response()
+			rec := &responseRecorder{ResponseWriter: res.Writer}
+			res.Writer = rec
+			defer func() { res.Writer = rec.ResponseWriter }()
+
+			if err := next(c); err != nil {
+				// write the error response here, so it can be stored
+				c.Error(err)
+			}
+
+			// the handler could have been cancelled with the request, but the result must be stored anyway
+			storeCtx := context.Background()
+			if !res.Committed || res.Status >= http.StatusInternalServerError {
+				if err := store.Unlock(storeCtx, key); err != nil {
+					logrus.Errorf("Error in idempotency.Middleware -> error unlocking key: %s", err)
+				}
+				return nil
+			}
+
+			record := Record{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				Status:      res.Status,
+				Header:      storedHeader(res.Header()),
+				Body:        rec.body.Bytes(),
+			}
+			if err := store.Save(storeCtx, key, record); err != nil {
+				logrus.Errorf("Error in idempotency.Middleware -> error saving response: %s", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// ScopedKey - returns the key prefixed with the client of the request: the API key name or the token subject of
+// the authenticated requests, so a client cannot replay the responses of another one using the same key
+func ScopedKey(ctx context.Context, key string) string {
+	principal, isOK := auth.PrincipalFromContext(ctx)
+	if !isOK {
+		return "anonymous:" + key
+	}
+	// the API keys subjects are already prefixed with "apikey:"
+	if strings.HasPrefix(principal.Subject, "apikey:") {
+		return principal.Subject + ":" + key
+	}
+	return "user:" + principal.Subject + ":" + key
+}
+
+// storedHeader - returns the replayedHeaders of the response header
+func storedHeader(header http.Header) http.Header {
+	stored := http.Header{}
+	for _, name := range replayedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			stored[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	return stored
+}
+
+// replay - writes the stored response
+func replay(c echo.Context, record Record) error {
+	header := c.Response().Header()
+	for name, values := range record.Header {
+		header[name] = values
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+
+	c.Response().WriteHeader(record.Status)
+	_, err := c.Response().Write(record.Body)
+	return err
+}
+
+// isMutation - returns true for the methods that are not safe to retry
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder - http.ResponseWriter keeping a copy of the written body
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
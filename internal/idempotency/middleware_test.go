@@ -0,0 +1,267 @@
+package idempotency_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/idempotency"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		method          string
+		key             string
+		firstBody       string
+		secondBody      string
+		handlerError    error
+		preLocked       bool
+		expectedCalls   int
+		expectedCode    int
+		expectedReplay  bool
+		expectedSameRes bool
+	}{
+		{
+			"Replay request with the same key and body",
+			http.MethodPost,
+			"same-key",
+			`{"nickname": "first"}`,
+			`{"nickname": "first"}`,
+			nil,
+			false,
+			1,
+			http.StatusCreated,
+			true,
+			true,
+		},
+		{
+			"Reuse key with a different body",
+			http.MethodPost,
+			"reused-key",
+			`{"nickname": "first"}`,
+			`{"nickname": "second"}`,
+			nil,
+			false,
+			1,
+			http.StatusUnprocessableEntity,
+			false,
+			false,
+		},
+		{
+			"Request without key",
+			http.MethodPost,
+			"",
+			`{"nickname": "first"}`,
+			`{"nickname": "first"}`,
+			nil,
+			false,
+			2,
+			http.StatusCreated,
+			false,
+			false,
+		},
+		{
+			"Request with safe method",
+			http.MethodGet,
+			"safe-key",
+			"",
+			"",
+			nil,
+			false,
+			2,
+			http.StatusCreated,
+			false,
+			false,
+		},
+		{
+			"Request with too long key",
+			http.MethodPost,
+			strings.Repeat("k", idempotency.MaxKeyLength+1),
+			`{"nickname": "first"}`,
+			`{"nickname": "first"}`,
+			nil,
+			false,
+			0,
+			http.StatusBadRequest,
+			false,
+			true,
+		},
+		{
+			"Request while the key is in progress",
+			http.MethodPost,
+			"locked-key",
+			`{"nickname": "first"}`,
+			`{"nickname": "first"}`,
+			nil,
+			true,
+			0,
+			http.StatusConflict,
+			false,
+			true,
+		},
+		{
+			"Replay client error",
+			http.MethodPost,
+			"client-error-key",
+			`{"nickname": "first"}`,
+			`{"nickname": "first"}`,
+			echo.NewHTTPError(http.StatusBadRequest, "invalidBody"),
+			false,
+			1,
+			http.StatusBadRequest,
+			true,
+			true,
+		},
+		{
+			"Retry after server error",
+			http.MethodPost,
+			"server-error-key",
+			`{"nickname": "first"}`,
+			`{"nickname": "first"}`,
+			errors.New("homemade error"),
+			false,
+			2,
+			http.StatusInternalServerError,
+			false,
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mr := miniredis.RunT(t)
+			rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			store := idempotency.NewRedisStore(rc, time.Hour, time.Minute)
+			if tc.preLocked {
+				fingerprint := idempotency.Fingerprint(tc.method, "/users", []byte(tc.firstBody))
+				_, err := store.Lock(context.TODO(), idempotency.ScopedKey(context.TODO(), tc.key), fingerprint)
+				require.NoErrorf(t, err, "Expected no error when locking the key, but was %s", err)
+			}
+
+			calls := 0
+			e := echo.New()
+			e.Add(tc.method, "/users", func(c echo.Context) error {
+				calls++
+				if tc.handlerError != nil {
+					return tc.handlerError
+				}
+				c.Response().Header().Set("Location", "/users/created")
+				return c.JSON(http.StatusCreated, map[string]int{"call": calls})
+			}, idempotency.Middleware(store))
+
+			doRequest := func(body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(tc.method, "/users", strings.NewReader(body))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				if tc.key != "" {
+					req.Header.Set(idempotency.HeaderIdempotencyKey, tc.key)
+				}
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				return rec
+			}
+
+			// When
+			first := doRequest(tc.firstBody)
+			second := doRequest(tc.secondBody)
+
+			// Then
+			assert.Equalf(t, tc.expectedCalls, calls, "Expected the handler to be called %d times, but was %d", tc.expectedCalls, calls)
+			assert.Equalf(t, tc.expectedCode, second.Code, "Expected status code to be %d, but was %d", tc.expectedCode, second.Code)
+			if tc.expectedReplay {
+				assert.Equalf(t, "true", second.Header().Get(idempotency.HeaderIdempotentReplayed), "Expected the response to be replayed")
+				assert.Equalf(t, first.Header().Get("Location"), second.Header().Get("Location"), "Expected Location to be %s, but was %s", first.Header().Get("Location"), second.Header().Get("Location"))
+			} else {
+				assert.Emptyf(t, second.Header().Get(idempotency.HeaderIdempotentReplayed), "Expected the response not to be replayed")
+			}
+			if tc.expectedSameRes {
+				assert.Equalf(t, first.Code, second.Code, "Expected status code to be %d, but was %d", first.Code, second.Code)
+				assert.Equalf(t, first.Body.String(), second.Body.String(), "Expected body to be %s, but was %s", first.Body.String(), second.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareWithPrincipals(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	store := idempotency.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour, time.Minute)
+	calls := 0
+	e := echo.New()
+	e.POST("/users", func(c echo.Context) error {
+		calls++
+		return c.JSON(http.StatusCreated, map[string]int{"call": calls})
+	}, idempotency.Middleware(store))
+
+	doRequest := func(principal auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"nickname": "first"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(idempotency.HeaderIdempotencyKey, "shared-key")
+		req = req.WithContext(auth.NewPrincipalContext(req.Context(), principal))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	alice := auth.Principal{Subject: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Role: auth.RoleUser}
+	bob := auth.Principal{Subject: "3ec0c5ab-a9d3-4d2f-a4e5-8fc4e2b9d3f6", Role: auth.RoleUser}
+
+	// When
+	first := doRequest(alice)
+	other := doRequest(bob)
+	replayed := doRequest(alice)
+
+	// Then
+	assert.Equal(t, 2, calls, "Expected the handler to be called once by principal")
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get(idempotency.HeaderIdempotentReplayed), "Expected the response of another principal not to be replayed")
+	assert.NotEqual(t, first.Body.String(), other.Body.String())
+	assert.Equal(t, "true", replayed.Header().Get(idempotency.HeaderIdempotentReplayed), "Expected the response to be replayed to the same principal")
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+}
+
+func TestMiddlewareReplayedHeaders(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	store := idempotency.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour, time.Minute)
+	e := echo.New()
+	e.POST("/users", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderLocation, "/users/created")
+		c.Response().Header().Set("ETag", `"1"`)
+		c.Response().Header().Set("Set-Cookie", "session=secret")
+		c.Response().Header().Set(echo.HeaderXRequestID, "first-request")
+		return c.JSON(http.StatusCreated, map[string]string{"nickname": "first"})
+	}, idempotency.Middleware(store))
+
+	doRequest := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"nickname": "first"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(idempotency.HeaderIdempotencyKey, "headers-key")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// When
+	doRequest()
+	replayed := doRequest()
+
+	// Then
+	assert.Equal(t, "true", replayed.Header().Get(idempotency.HeaderIdempotentReplayed), "Expected the response to be replayed")
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, replayed.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "/users/created", replayed.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, `"1"`, replayed.Header().Get("ETag"))
+	assert.Empty(t, replayed.Header().Get("Set-Cookie"), "Expected the cookies not to be replayed")
+	assert.Empty(t, replayed.Header().Get(echo.HeaderXRequestID), "Expected the request ID not to be replayed")
+}
@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// keyPrefix - prefix of the redis keys used to store the records
+const keyPrefix = "idempotency:"
+
+type redisStore struct {
+	rc          *redis.Client
+	ttl         time.Duration
+	lockTimeout time.Duration
+}
+
+var _ Store = redisStore{}
+var _ Store = (*redisStore)(nil)
+
+// NewRedisStore - returns a new Store that keeps the completed records for the ttl.
+// The in progress records expire after the lockTimeout, so a crashed request does not lock the key forever
+func NewRedisStore(rc *redis.Client, ttl, lockTimeout time.Duration) *redisStore {
+	return &redisStore{rc, ttl, lockTimeout}
+}
+
+// Lock - sets an in progress record with SETNX, returning the existing one if it could not be set
+func (rs redisStore) Lock(ctx context.Context, key, fingerprint string) (*Record, error) {
+	encoded, err := json.Marshal(Record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	// retry once in case the existing record expires between the SETNX and the GET
+	for i := 0; i < 2; i++ {
+		locked, err := rs.rc.SetNX(ctx, keyPrefix+key, encoded, rs.lockTimeout).Result()
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return nil, nil
+		}
+
+		stored, err := rs.rc.Get(ctx, keyPrefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var record Record
+		if err := json.Unmarshal(stored, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+
+	return nil, errors.New("could not lock the idempotency key")
+}
+
+// Save - overrides the record, keeping it for the store ttl
+func (rs redisStore) Save(ctx context.Context, key string, record Record) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return rs.rc.Set(ctx, keyPrefix+key, encoded, rs.ttl).Err()
+}
+
+// Unlock - deletes the record
+func (rs redisStore) Unlock(ctx context.Context, key string) error {
+	return rs.rc.Del(ctx, keyPrefix+key).Err()
+}
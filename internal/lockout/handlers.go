@@ -0,0 +1,9 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package lockout
+
+import "github.com/labstack/echo/v4"
+
+// Handler - login lockout handlers
+type Handler interface {
+	ClearLockout(c echo.Context) error
+}
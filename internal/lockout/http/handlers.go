@@ -0,0 +1,79 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/lockout"
+	"user-microservice/internal/users"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpHandler struct {
+	limiter *lockout.Limiter
+	users   users.Repository
+}
+
+var _ lockout.Handler = httpHandler{}
+var _ lockout.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new lockout http handler initialized with the limiter and the users repository
+func NewHttpHandler(limiter *lockout.Limiter, usersRepository users.Repository) lockout.Handler {
+	return &httpHandler{limiter, usersRepository}
+}
+
+// ClearLockout godoc
+//
+// @Summary     Clears the login lockout of a user
+// @Description Removes the lockout and the failed logins of the user account, and of the given IP if any, so the user can log in again
+// @Tags        Users
+// @Produce     json
+// @Param       userId path  string true  "User id"                          format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       ip     query string false "IP whose lockout is also cleared" example(203.0.113.7)
+// @Success     204
+// @Failure     400 {object} echo.HTTPError
+// @Failure     401 {object} echo.HTTPError
+// @Failure     403 {object} echo.HTTPError
+// @Failure     404 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/lockout [delete]
+func (h httpHandler) ClearLockout(c echo.Context) error {
+	userIDStr := c.Param("userId")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
+	}
+	ip := c.QueryParam("ip")
+	if ip != "" && net.ParseIP(ip) == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid IP %s", ip))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionClearLockout, userID.String()); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	user, err := h.users.GetById(ctx, userID.String(), "id", "email")
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		}
+		return err
+	}
+	if err := h.limiter.Clear(ctx, lockout.KindAccount, user.Email); err != nil {
+		return err
+	}
+	if ip != "" {
+		if err := h.limiter.Clear(ctx, lockout.KindIP, ip); err != nil {
+			return err
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/lockout"
+	lockoutHttp "user-microservice/internal/lockout/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/testutils"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	email  = "alicetingo@example.com"
+	ip     = "192.0.2.1"
+)
+
+func TestClearLockout(t *testing.T) {
+	admin := auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleAdmin}
+	support := auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleSupport}
+
+	for _, tc := range []struct {
+		name            string
+		id              string
+		query           string
+		principal       *auth.Principal
+		mockedError     error
+		expectedCode    int
+		expectedError   error
+		expectedIPClear bool
+	}{
+		{"Clear account lockout successfully", userID, "", &admin, nil, http.StatusNoContent, nil, false},
+		{"Clear account and IP lockout successfully", userID, "?ip=" + ip, &admin, nil, http.StatusNoContent, nil, true},
+		{"Clear lockout without auth", userID, "", nil, nil, http.StatusNoContent, nil, false},
+		{
+			"Clear lockout without admin role",
+			userID,
+			"",
+			&support,
+			nil,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+			false,
+		},
+		{
+			"Clear lockout with wrong id",
+			"wrong-id",
+			"",
+			&admin,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID wrong-id"),
+			false,
+		},
+		{
+			"Clear lockout with wrong IP",
+			userID,
+			"?ip=wrong-ip",
+			&admin,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid IP wrong-ip"),
+			false,
+		},
+		{
+			"Clear lockout of not found user",
+			userID,
+			"",
+			&admin,
+			mongo.ErrNoDocuments,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID)),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			mr := miniredis.RunT(t)
+			limiter := lockout.NewLimiter(
+				redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+				lockout.Options{MaxAttempts: 1},
+				lockout.NewMetrics(prometheus.NewRegistry()),
+			)
+			handler := lockoutHttp.NewHttpHandler(limiter, usersRepo)
+			ctx := context.Background()
+			limiter.Fail(ctx, email, ip)
+
+			var mockedUser *models.User
+			if tc.mockedError == nil {
+				mockedUser = &models.User{ID: userID, Email: email}
+			}
+			usersRepo.EXPECT().GetById(gomock.Any(), userID, "id", "email").Return(mockedUser, tc.mockedError).MaxTimes(1)
+
+			req := httptest.NewRequest(http.MethodDelete, "/"+tc.query, nil)
+			if tc.principal != nil {
+				req = req.WithContext(auth.NewPrincipalContext(req.Context(), *tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("userId")
+			c.SetParamValues(tc.id)
+
+			// When
+			err := handler.ClearLockout(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				assert.ErrorIs(t, limiter.Check(ctx, email, ""), lockout.ErrLocked, "Expected the lockout to be kept")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.NoError(t, limiter.Check(ctx, email, ""))
+			if tc.expectedIPClear {
+				assert.NoError(t, limiter.Check(ctx, "", ip))
+			} else {
+				assert.ErrorIs(t, limiter.Check(ctx, "", ip), lockout.ErrLocked)
+			}
+		})
+	}
+}
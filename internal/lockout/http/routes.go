@@ -0,0 +1,12 @@
+package http
+
+import (
+	"user-microservice/internal/lockout"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendLockoutRoutes - Sets the login lockout routes for the given users echo group
+func AppendLockoutRoutes(e *echo.Group, h lockout.Handler) {
+	e.DELETE("/:userId/lockout", h.ClearLockout)
+}
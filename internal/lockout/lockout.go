@@ -0,0 +1,240 @@
+package lockout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user-microservice/internal/audit"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxAttempts - default number of failed logins allowed before locking out
+	DefaultMaxAttempts = 5
+	// DefaultWindow - default time window counting the failed logins
+	DefaultWindow = 15 * time.Minute
+	// DefaultBaseDuration - default duration of the first lockout, doubled on every consecutive lockout
+	DefaultBaseDuration = time.Minute
+	// DefaultMaxDuration - default maximum duration of a lockout
+	DefaultMaxDuration = 24 * time.Hour
+)
+
+// keyPrefix - prefix of the redis keys of the lockouts
+const keyPrefix = "lockout:"
+
+// failScript - counts a failure and starts the window of the failures if it has no expiration, atomically,
+// so the failures are never left without expiration. Returns the failures count
+var failScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Kind - kind of the locked out logins
+type Kind string
+
+const (
+	// KindAccount - logins of an account (email)
+	KindAccount Kind = "account"
+	// KindIP - logins from an IP address
+	KindIP Kind = "ip"
+)
+
+// ErrLocked - the logins are locked out, see LockedError
+var ErrLocked = errors.New("locked out")
+
+// LockedError - error returned when the logins of an account or an IP are locked out
+type LockedError struct {
+	Kind Kind
+	// RetryAfter - remaining time of the lockout
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s %s for %s", e.Kind, ErrLocked, e.RetryAfter)
+}
+
+// Is - returns true for ErrLocked
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Options - Limiter options, the zero values are replaced by the default ones
+type Options struct {
+	// MaxAttempts - number of failed logins allowed in the window before locking out
+	MaxAttempts int
+	// Window - time window counting the failed logins
+	Window time.Duration
+	// BaseDuration - duration of the first lockout, doubled on every consecutive lockout
+	BaseDuration time.Duration
+	// MaxDuration - maximum duration of a lockout, also the time the consecutive lockouts are remembered
+	MaxDuration time.Duration
+}
+
+// Limiter - counts the failed logins of each account and IP in redis and locks them out when they exceed the
+// max attempts, for a duration growing exponentially with the consecutive lockouts.
+// The redis errors are logged and ignored, so the logins keep working without redis
+type Limiter struct {
+	rc      *redis.Client
+	opts    Options
+	metrics *Metrics
+}
+
+// NewLimiter - returns a new Limiter with the given options
+func NewLimiter(rc *redis.Client, opts Options, metrics *Metrics) *Limiter {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.BaseDuration <= 0 {
+		opts.BaseDuration = DefaultBaseDuration
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = DefaultMaxDuration
+	}
+
+	return &Limiter{rc: rc, opts: opts, metrics: metrics}
+}
+
+// Check - returns a LockedError if the logins of the email or the IP are locked out
+func (l *Limiter) Check(ctx context.Context, email, ip string) error {
+	for _, subject := range subjects(email, ip) {
+		kind, value := subject.kind, subject.value
+		ttl, err := l.rc.PTTL(ctx, key(kind, value, "locked")).Result()
+		if err != nil {
+			logrus.Errorf("Error in lockout.Check -> error getting %s lockout: %s", kind, err)
+			continue
+		}
+		if ttl > 0 {
+			l.metrics.rejected.WithLabelValues(string(kind)).Inc()
+			return &LockedError{Kind: kind, RetryAfter: ttl}
+		}
+	}
+
+	return nil
+}
+
+// Fail - counts a failed login of the email from the IP, locking them out if they exceed the max attempts
+func (l *Limiter) Fail(ctx context.Context, email, ip string) {
+	l.metrics.failures.Inc()
+	for _, subject := range subjects(email, ip) {
+		if err := l.fail(ctx, subject.kind, subject.value); err != nil {
+			logrus.Errorf("Error in lockout.Fail -> error counting %s failure: %s", subject.kind, err)
+		}
+	}
+}
+
+// Succeed - resets the failed logins and the consecutive lockouts of the email. The failures of the IP are kept,
+// so an attacker trying many accounts from the same IP is still locked out
+func (l *Limiter) Succeed(ctx context.Context, email string) {
+	email = normalize(email)
+	if err := l.rc.Del(ctx, key(KindAccount, email, "failures"), key(KindAccount, email, "level")).Err(); err != nil {
+		logrus.Errorf("Error in lockout.Succeed -> error resetting account failures: %s", err)
+	}
+}
+
+// Clear - removes the lockout, the failed logins and the consecutive lockouts of the account or IP
+func (l *Limiter) Clear(ctx context.Context, kind Kind, value string) error {
+	value = normalize(value)
+	err := l.rc.Del(ctx, key(kind, value, "locked"), key(kind, value, "failures"), key(kind, value, "level")).Err()
+	if err != nil {
+		logrus.Errorf("Error in lockout.Clear -> error clearing %s lockout: %s", kind, err)
+		return err
+	}
+	l.metrics.cleared.WithLabelValues(string(kind)).Inc()
+	audit.LogLockoutCleared(ctx, target(kind, value))
+
+	return nil
+}
+
+// fail - counts a failure of the subject and locks it out when it reaches the max attempts
+func (l *Limiter) fail(ctx context.Context, kind Kind, value string) error {
+	failures := key(kind, value, "failures")
+	count, err := failScript.Run(ctx, l.rc, []string{failures}, l.opts.Window.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if count < int64(l.opts.MaxAttempts) {
+		return nil
+	}
+
+	levelKey := key(kind, value, "level")
+	var levelCmd *redis.IntCmd
+	_, err = l.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		levelCmd = pipe.Incr(ctx, levelKey)
+		pipe.Expire(ctx, levelKey, l.opts.MaxDuration)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	level := levelCmd.Val()
+	duration := l.duration(level)
+	_, err = l.rc.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key(kind, value, "locked"), level, duration)
+		pipe.Del(ctx, failures)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	l.metrics.lockouts.WithLabelValues(string(kind)).Inc()
+	audit.LogLockout(ctx, target(kind, value), duration)
+
+	return nil
+}
+
+// duration - returns the duration of the lockout for the number of consecutive lockouts (level),
+// doubling the base duration on each one up to the max duration
+func (l *Limiter) duration(level int64) time.Duration {
+	duration := l.opts.BaseDuration
+	for i := int64(1); i < level && duration < l.opts.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > l.opts.MaxDuration {
+		duration = l.opts.MaxDuration
+	}
+	return duration
+}
+
+// subject - normalized account or IP whose logins are counted
+type subject struct {
+	kind  Kind
+	value string
+}
+
+// subjects - returns the not empty normalized email and IP, the account first
+func subjects(email, ip string) []subject {
+	res := make([]subject, 0, 2)
+	if email = normalize(email); email != "" {
+		res = append(res, subject{KindAccount, email})
+	}
+	if ip = normalize(ip); ip != "" {
+		res = append(res, subject{KindIP, ip})
+	}
+	return res
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// key - returns the redis key of the subject, hashing the value so the emails and IPs are not stored in redis
+func key(kind Kind, value, suffix string) string {
+	sum := sha256.Sum256([]byte(value))
+	return keyPrefix + string(kind) + ":" + hex.EncodeToString(sum[:]) + ":" + suffix
+}
+
+func target(kind Kind, value string) string {
+	return string(kind) + ":" + value
+}
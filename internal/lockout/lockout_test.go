@@ -0,0 +1,229 @@
+package lockout_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-microservice/internal/lockout"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	email = "alicetingo@example.com"
+	ip    = "192.0.2.1"
+)
+
+func newLimiter(t *testing.T, opts lockout.Options) (*lockout.Limiter, *miniredis.Miniredis, *prometheus.Registry) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	reg := prometheus.NewRegistry()
+	return lockout.NewLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), opts, lockout.NewMetrics(reg)), mr, reg
+}
+
+// counterValue - returns the sum of the values of the registered counter with the given name, filtered by
+// the kind label if it's not empty
+func counterValue(t *testing.T, reg *prometheus.Registry, name string, kind lockout.Kind) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoErrorf(t, err, "Expected no error when gathering metrics, but was %s", err)
+	var res float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matches := kind == ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "kind" && label.GetValue() == string(kind) {
+					matches = true
+				}
+			}
+			if matches {
+				res += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return res
+}
+
+// fail - counts n failed logins of the email from the IP
+func fail(limiter *lockout.Limiter, n int, email, ip string) {
+	for i := 0; i < n; i++ {
+		limiter.Fail(context.Background(), email, ip)
+	}
+}
+
+// retryAfter - returns the remaining time of the lockout error, failing if it's not a lockout of the given kind
+func retryAfter(t *testing.T, err error, kind lockout.Kind) time.Duration {
+	t.Helper()
+	var lockedErr *lockout.LockedError
+	require.Truef(t, errors.As(err, &lockedErr), "Expected a lockout error, but was %v", err)
+	assert.ErrorIs(t, err, lockout.ErrLocked)
+	assert.Equal(t, kind, lockedErr.Kind)
+	return lockedErr.RetryAfter
+}
+
+func TestLimiter_Fail(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		failures      int
+		checkedEmail  string
+		checkedIP     string
+		expectedKind  lockout.Kind
+		expectedRetry time.Duration
+	}{
+		{"Fail less than the max attempts", 2, email, ip, "", 0},
+		{"Fail the max attempts locks the account", 3, email, "", lockout.KindAccount, time.Minute},
+		{"Fail the max attempts locks the account with other email case", 3, " AliceTingo@Example.com", "", lockout.KindAccount, time.Minute},
+		{"Fail the max attempts locks the IP for other accounts", 3, "other@example.com", ip, lockout.KindIP, time.Minute},
+		{"Fail the max attempts does not lock other accounts and IPs", 3, "other@example.com", "192.0.2.2", "", 0},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			limiter, mr, reg := newLimiter(t, lockout.Options{MaxAttempts: 3})
+
+			// When
+			fail(limiter, tc.failures, email, ip)
+			err := limiter.Check(context.Background(), tc.checkedEmail, tc.checkedIP)
+
+			// Then
+			assert.Equal(t, float64(tc.failures), counterValue(t, reg, "login_failures_total", ""))
+			for _, key := range mr.Keys() {
+				assert.NotContains(t, key, "example.com", "Expected the emails not to be stored in redis")
+			}
+			if tc.expectedKind == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.expectedRetry, retryAfter(t, err, tc.expectedKind))
+			assert.Equal(t, 1.0, counterValue(t, reg, "login_lockouts_total", tc.expectedKind))
+			assert.Equal(t, 1.0, counterValue(t, reg, "login_locked_attempts_total", tc.expectedKind))
+		})
+	}
+}
+
+func TestLimiter_FailWithoutExpiration(t *testing.T) {
+	// Given
+	limiter, mr, _ := newLimiter(t, lockout.Options{MaxAttempts: 3, Window: time.Minute})
+	fail(limiter, 1, email, "")
+	// the failures lost their expiration, e.g. they were counted by a replica stopped before setting it
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	require.NoError(t, rc.Persist(context.Background(), keys[0]).Err())
+
+	// When
+	fail(limiter, 1, email, "")
+
+	// Then
+	assert.Equalf(t, time.Minute, mr.TTL(keys[0]), "Expected the failures to expire in 1m, but was %s", mr.TTL(keys[0]))
+	mr.FastForward(time.Minute)
+	fail(limiter, 1, email, "")
+	assert.NoError(t, limiter.Check(context.Background(), email, ""), "Expected the expired failures not to be counted")
+}
+
+func TestLimiter_ExponentialLockout(t *testing.T) {
+	// Given
+	limiter, mr, _ := newLimiter(t, lockout.Options{MaxAttempts: 2, BaseDuration: time.Minute, MaxDuration: 5 * time.Minute})
+	ctx := context.Background()
+
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		// When
+		fail(limiter, 2, email, "")
+		err := limiter.Check(ctx, email, "")
+
+		// Then
+		assert.Equal(t, expected, retryAfter(t, err, lockout.KindAccount), "Expected the lockout to double up to the max duration")
+
+		// When the lockout is over
+		mr.FastForward(expected)
+
+		// Then
+		assert.NoError(t, limiter.Check(ctx, email, ""))
+	}
+}
+
+func TestLimiter_Succeed(t *testing.T) {
+	// Given
+	limiter, mr, _ := newLimiter(t, lockout.Options{MaxAttempts: 2, BaseDuration: time.Minute})
+	ctx := context.Background()
+	fail(limiter, 2, email, ip)
+	mr.FastForward(time.Minute)
+
+	// When
+	limiter.Succeed(ctx, email)
+	fail(limiter, 2, email, "")
+
+	// Then
+	assert.Equal(t, time.Minute, retryAfter(t, limiter.Check(ctx, email, ""), lockout.KindAccount),
+		"Expected the success to reset the consecutive lockouts of the account")
+
+	// When
+	fail(limiter, 2, "other@example.com", ip)
+
+	// Then
+	assert.Equal(t, 2*time.Minute, retryAfter(t, limiter.Check(ctx, "", ip), lockout.KindIP),
+		"Expected the success not to reset the IP failures")
+}
+
+func TestLimiter_Clear(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		kind       lockout.Kind
+		value      string
+		lockedKind lockout.Kind
+	}{
+		{"Clear account lockout", lockout.KindAccount, "AliceTingo@example.com", lockout.KindIP},
+		{"Clear IP lockout", lockout.KindIP, ip, lockout.KindAccount},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			limiter, _, reg := newLimiter(t, lockout.Options{MaxAttempts: 2})
+			ctx := context.Background()
+			fail(limiter, 2, email, ip)
+
+			// When
+			err := limiter.Clear(ctx, tc.kind, tc.value)
+
+			// Then
+			require.NoError(t, err)
+			assert.Equal(t, 1.0, counterValue(t, reg, "login_lockouts_cleared_total", tc.kind))
+			// the other kind of lockout is kept
+			if tc.kind == lockout.KindAccount {
+				assert.NoError(t, limiter.Check(ctx, email, ""))
+				retryAfter(t, limiter.Check(ctx, "", ip), tc.lockedKind)
+			} else {
+				assert.NoError(t, limiter.Check(ctx, "", ip))
+				retryAfter(t, limiter.Check(ctx, email, ""), tc.lockedKind)
+			}
+		})
+	}
+}
+
+func TestLimiter_RedisDown(t *testing.T) {
+	// Given
+	limiter, mr, _ := newLimiter(t, lockout.Options{MaxAttempts: 1})
+	ctx := context.Background()
+	mr.Close()
+
+	// When
+	fail(limiter, 2, email, ip)
+	err := limiter.Check(ctx, email, ip)
+	clearErr := limiter.Clear(ctx, lockout.KindAccount, email)
+
+	// Then
+	assert.NoError(t, err, "Expected the logins to be allowed without redis")
+	assert.Error(t, clearErr)
+}
@@ -0,0 +1,36 @@
+package lockout
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics - prometheus metrics of the login lockouts
+type Metrics struct {
+	failures prometheus.Counter
+	lockouts *prometheus.CounterVec
+	rejected *prometheus.CounterVec
+	cleared  *prometheus.CounterVec
+}
+
+// NewMetrics - returns the lockout metrics, registered in the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "login_failures_total",
+			Help: "Number of failed logins counted for the lockouts",
+		}),
+		lockouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "login_lockouts_total",
+			Help: "Number of login lockouts, by kind (account or ip)",
+		}, []string{"kind"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "login_locked_attempts_total",
+			Help: "Number of logins rejected because of a lockout, by kind (account or ip)",
+		}, []string{"kind"}),
+		cleared: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "login_lockouts_cleared_total",
+			Help: "Number of lockouts cleared by the admins, by kind (account or ip)",
+		}, []string{"kind"}),
+	}
+	reg.MustRegister(m.failures, m.lockouts, m.rejected, m.cleared)
+
+	return m
+}
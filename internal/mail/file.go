@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// unsafeFileChars - characters replaced in the recipient when naming the message files
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+// fileMailer - Mailer writing each message in a file of a directory, for local runs and tests
+type fileMailer struct {
+	dir string
+}
+
+// NewFileMailer - returns a new Mailer writing the messages in the dir, creating it if needed
+func NewFileMailer(dir string) (Mailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("the file mail sender needs a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return fileMailer{dir}, nil
+}
+
+// Send - writes the message in a new file named after the current time and the recipient
+func (m fileMailer) Send(_ context.Context, msg Message) error {
+	name := fmt.Sprintf("%s-%s.txt", time.Now().UTC().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if err := os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o600); err != nil {
+		logrus.Errorf("Error in mail.Send -> error writing message: %s", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package mail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"user-microservice/internal/mail"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer_Send(t *testing.T) {
+	// Given
+	dir := filepath.Join(t.TempDir(), "mails")
+	mailer, err := mail.NewFileMailer(dir)
+	require.NoError(t, err)
+
+	// When
+	err = mailer.Send(context.Background(), mail.Message{To: "alice/tingo@example.com", Subject: "Hello", Body: "Hello Alice"})
+
+	// Then
+	require.NoError(t, err)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Contains(t, files[0].Name(), "alice_tingo@example.com")
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, "To: alice/tingo@example.com\nSubject: Hello\n\nHello Alice\n", string(content))
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		sender      string
+		dir         string
+		expectError bool
+	}{
+		{"New default mailer", "", "", false},
+		{"New log mailer", mail.SenderLog, "", false},
+		{"New file mailer", mail.SenderFile, t.TempDir(), false},
+		{"New file mailer without dir", mail.SenderFile, "", true},
+		{"New unknown mailer", "smtp", "", true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			mailer, err := mail.New(tc.sender, tc.dir)
+
+			// Then
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, mailer)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, mailer)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package mail
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logMailer - Mailer logging the messages instead of sending them, for local runs
+type logMailer struct{}
+
+// NewLogMailer - returns a new Mailer logging the messages
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+// Send - logs the message
+func (logMailer) Send(_ context.Context, msg Message) error {
+	logrus.WithFields(logrus.Fields{"to": msg.To, "subject": msg.Subject}).Infof("Mail sent:\n%s", msg.Body)
+	return nil
+}
@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sender names of the configuration
+const (
+	SenderLog  = "log"
+	SenderFile = "file"
+)
+
+// Message - email sent to a user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer - delivers the emails sent to the users
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New - returns the Mailer of the given sender name: SenderLog (default if empty) or SenderFile,
+// which writes the messages in the dir
+func New(sender, dir string) (Mailer, error) {
+	switch sender {
+	case "", SenderLog:
+		return NewLogMailer(), nil
+	case SenderFile:
+		return NewFileMailer(dir)
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", sender)
+	}
+}
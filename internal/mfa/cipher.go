@@ -0,0 +1,63 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Cipher - encrypts the TOTP secrets at rest with AES-256-GCM. The user ID is authenticated along
+// with each secret, so an encrypted secret cannot be copied to another user
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher - returns a new Cipher with the AES-256 key derived from the given key,
+// or an error if the key is empty
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) == 0 {
+		return nil, errors.New("mfa needs a key to encrypt the secrets")
+	}
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead}, nil
+}
+
+// Encrypt - returns the base64 encoded nonce and encrypted secret of the user
+func (c *Cipher) Encrypt(userID, secret string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), []byte(userID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt - returns the secret of the user encrypted by Encrypt
+func (c *Cipher) Decrypt(userID, encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("encrypted secret too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, []byte(userID))
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
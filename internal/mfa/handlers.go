@@ -0,0 +1,10 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package mfa
+
+import "github.com/labstack/echo/v4"
+
+// Handler - second factor enrollment handlers
+type Handler interface {
+	Enroll(c echo.Context) error
+	ConfirmEnrollment(c echo.Context) error
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/mfa"
+	"user-microservice/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpHandler struct {
+	manager *mfa.Manager
+}
+
+var _ mfa.Handler = httpHandler{}
+var _ mfa.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new second factor http handler initialized with the manager
+func NewHttpHandler(manager *mfa.Manager) mfa.Handler {
+	return &httpHandler{manager}
+}
+
+// Enroll godoc
+//
+// @Summary     Starts a second factor enrollment
+// @Description Generates a new TOTP secret for the user and returns it along with its otpauth URI, to be added to an authenticator app. The second factor is not required until the enrollment is confirmed
+// @Tags        Users
+// @Produce     json
+// @Param       userId path     string true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Success     200    {object} models.MFAEnrollment
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     404    {object} echo.HTTPError
+// @Failure     409    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/mfa/enrollment [post]
+func (h httpHandler) Enroll(c echo.Context) error {
+	userID, err := parseUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := auth.CheckRequest(c, auth.ActionManageMFA, userID); err != nil {
+		return err
+	}
+
+	res, err := h.manager.Enroll(c.Request().Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrAlreadyEnrolled):
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrMFAAlreadyEnrolled)
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// ConfirmEnrollment godoc
+//
+// @Summary     Confirms a second factor enrollment
+// @Description Enables the pending second factor of the user with its first TOTP code and returns the recovery codes, which are only shown once. From then on the login requires a code
+// @Tags        Users
+// @Accept      json
+// @Produce     json
+// @Param       userId path     string         true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       body   body     models.MFACode true "First TOTP code"
+// @Success     200    {object} models.MFARecoveryCodes
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     404    {object} echo.HTTPError
+// @Failure     409    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/mfa/enrollment/confirm [post]
+func (h httpHandler) ConfirmEnrollment(c echo.Context) error {
+	userID, err := parseUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := auth.CheckRequest(c, auth.ActionManageMFA, userID); err != nil {
+		return err
+	}
+
+	var body models.MFACode
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in mfa/http.ConfirmEnrollment -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.Code == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	res, err := h.manager.Confirm(c.Request().Context(), userID, body.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidCode):
+			return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidMFACode)
+		case errors.Is(err, mfa.ErrNotEnrolling):
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrMFANotEnrolling)
+		case errors.Is(err, mfa.ErrAlreadyEnrolled):
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrMFAAlreadyEnrolled)
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// parseUserID - returns the user ID path param, or a bad request error if it's not valid
+func parseUserID(c echo.Context) (string, error) {
+	userIDStr := c.Param("userId")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
+	}
+	return userID.String(), nil
+}
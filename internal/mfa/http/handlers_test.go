@@ -0,0 +1,271 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/mfa"
+	mfaHttp "user-microservice/internal/mfa/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/testutils"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	key    = "mfa-key"
+)
+
+func newContext(method, body, id string, principal *auth.Principal) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "application/json")
+	if principal != nil {
+		req = req.WithContext(auth.NewPrincipalContext(req.Context(), *principal))
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("userId")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+// pendingMFA - returns a pending second factor of the user and a valid code for it
+func pendingMFA(t *testing.T) (models.MFA, string) {
+	t.Helper()
+	secret, err := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: "alicetingo@example.com"})
+	require.NoError(t, err)
+	cipher, err := mfa.NewCipher([]byte(key))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(userID, secret.Secret())
+	require.NoError(t, err)
+	code, err := totp.GenerateCode(secret.Secret(), time.Now())
+	require.NoError(t, err)
+
+	return models.MFA{Secret: encrypted, RecoveryCodes: []string{}}, code
+}
+
+func TestEnroll(t *testing.T) {
+	user := models.User{ID: userID, Email: "alicetingo@example.com"}
+	enabled := models.MFA{Secret: "secret", Enabled: true}
+	self := auth.Principal{Subject: userID, Role: auth.RoleUser}
+	other := auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleUser}
+
+	for _, tc := range []struct {
+		name          string
+		id            string
+		principal     *auth.Principal
+		mockedMFA     *models.MFA
+		mockedError   error
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Enroll successfully",
+			userID,
+			&self,
+			nil,
+			nil,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"Enroll already enrolled user",
+			userID,
+			&self,
+			&enabled,
+			nil,
+			http.StatusConflict,
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrMFAAlreadyEnrolled),
+		},
+		{
+			"Enroll with wrong id",
+			"wrong-id",
+			&self,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID wrong-id"),
+		},
+		{
+			"Enroll other user",
+			userID,
+			&other,
+			nil,
+			nil,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Enroll not found user",
+			userID,
+			nil,
+			nil,
+			mongo.ErrNoDocuments,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID)),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			manager, err := mfa.NewManager(usersRepo, []byte(key), "")
+			require.NoError(t, err)
+			handler := mfaHttp.NewHttpHandler(manager)
+
+			var mockedUser *models.User
+			if tc.mockedError == nil {
+				current := user
+				mockedUser = &current
+			}
+			usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(mockedUser, tc.mockedError).MaxTimes(1)
+			usersRepo.EXPECT().GetMFA(gomock.Any(), userID).Return(tc.mockedMFA, nil).MaxTimes(1)
+			var stored *models.MFA
+			usersRepo.EXPECT().SetMFA(gomock.Any(), userID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, m *models.MFA) error {
+				stored = m
+				return nil
+			}).MaxTimes(1)
+			c, rec := newContext(http.MethodPost, "", tc.id, tc.principal)
+
+			// When
+			err = handler.Enroll(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			var body models.MFAEnrollment
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.NotEmpty(t, body.Secret)
+			assert.True(t, strings.HasPrefix(body.URI, "otpauth://totp/"))
+			require.NotNil(t, stored)
+			assert.False(t, stored.Enabled)
+		})
+	}
+}
+
+func TestConfirmEnrollment(t *testing.T) {
+	pending, code := pendingMFA(t)
+	enabled := pending
+	enabled.Enabled = true
+	self := auth.Principal{Subject: userID, Role: auth.RoleUser}
+
+	for _, tc := range []struct {
+		name          string
+		body          string
+		mockedMFA     *models.MFA
+		mockedError   error
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Confirm enrollment successfully",
+			fmt.Sprintf(`{"code": %q}`, code),
+			&pending,
+			nil,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"Confirm enrollment with invalid code",
+			`{"code": "000000x"}`,
+			&pending,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidMFACode),
+		},
+		{
+			"Confirm enrollment without code",
+			`{}`,
+			&pending,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Confirm enrollment not started",
+			fmt.Sprintf(`{"code": %q}`, code),
+			nil,
+			nil,
+			http.StatusConflict,
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrMFANotEnrolling),
+		},
+		{
+			"Confirm enrollment already confirmed",
+			fmt.Sprintf(`{"code": %q}`, code),
+			&enabled,
+			nil,
+			http.StatusConflict,
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrMFAAlreadyEnrolled),
+		},
+		{
+			"Confirm enrollment of not found user",
+			fmt.Sprintf(`{"code": %q}`, code),
+			nil,
+			mongo.ErrNoDocuments,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID)),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			manager, err := mfa.NewManager(usersRepo, []byte(key), "")
+			require.NoError(t, err)
+			handler := mfaHttp.NewHttpHandler(manager)
+
+			var mockedMFA *models.MFA
+			if tc.mockedMFA != nil {
+				current := *tc.mockedMFA
+				mockedMFA = &current
+			}
+			usersRepo.EXPECT().GetMFA(gomock.Any(), userID).Return(mockedMFA, tc.mockedError).MaxTimes(1)
+			var stored *models.MFA
+			usersRepo.EXPECT().SetMFA(gomock.Any(), userID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, m *models.MFA) error {
+				stored = m
+				return nil
+			}).MaxTimes(1)
+			c, rec := newContext(http.MethodPost, tc.body, userID, &self)
+
+			// When
+			err = handler.ConfirmEnrollment(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			var body models.MFARecoveryCodes
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Len(t, body.RecoveryCodes, mfa.RecoveryCodesCount)
+			require.NotNil(t, stored)
+			assert.True(t, stored.Enabled)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package http
+
+import (
+	"user-microservice/internal/mfa"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendMFARoutes - Sets the second factor enrollment routes for the given users echo group
+func AppendMFARoutes(e *echo.Group, h mfa.Handler) {
+	e.POST("/:userId/mfa/enrollment", h.Enroll)
+	e.POST("/:userId/mfa/enrollment/confirm", h.ConfirmEnrollment)
+}
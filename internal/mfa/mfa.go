@@ -0,0 +1,232 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultIssuer - default issuer shown by the authenticator apps
+	DefaultIssuer = "user-microservice"
+	// RecoveryCodesCount - number of recovery codes generated on enrollment
+	RecoveryCodesCount = 10
+	// period - seconds each TOTP code is valid
+	period = 30
+	// skew - number of periods before and after the current one whose codes are accepted
+	skew = 1
+)
+
+var (
+	// ErrAlreadyEnrolled - the user already confirmed a second factor
+	ErrAlreadyEnrolled = errors.New("second factor already enrolled")
+	// ErrNotEnrolling - the user has no pending enrollment to confirm
+	ErrNotEnrolling = errors.New("no second factor enrollment to confirm")
+	// ErrCodeRequired - the user enrolled a second factor and no code was given
+	ErrCodeRequired = errors.New("second factor code required")
+	// ErrInvalidCode - the code is not a valid TOTP code nor an unused recovery code
+	ErrInvalidCode = errors.New("invalid second factor code")
+)
+
+// Manager - enrolls and checks the TOTP (RFC 6238) second factor of the users
+type Manager struct {
+	users  users.Repository
+	cipher *Cipher
+	issuer string
+}
+
+// NewManager - returns a new Manager encrypting the secrets with the key, or an error if the key is empty.
+// The issuer is DefaultIssuer if empty
+func NewManager(usersRepository users.Repository, key []byte, issuer string) (*Manager, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+
+	return &Manager{users: usersRepository, cipher: c, issuer: issuer}, nil
+}
+
+// Enroll - generates a new TOTP secret for the user, replacing any pending enrollment, and returns it along with
+// its otpauth URI. The second factor is not required until the enrollment is confirmed.
+// Returns ErrAlreadyEnrolled if the user already confirmed one
+func (m *Manager) Enroll(ctx context.Context, userID string) (*models.MFAEnrollment, error) {
+	user, err := m.users.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := m.users.GetMFA(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.Enabled {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: m.issuer, AccountName: user.Email, Period: period})
+	if err != nil {
+		logrus.Errorf("Error in mfa.Enroll -> error generating secret: %s", err)
+		return nil, err
+	}
+	encrypted, err := m.cipher.Encrypt(user.ID, key.Secret())
+	if err != nil {
+		logrus.Errorf("Error in mfa.Enroll -> error encrypting secret: %s", err)
+		return nil, err
+	}
+	if err := m.users.SetMFA(ctx, user.ID, &models.MFA{Secret: encrypted, RecoveryCodes: []string{}}); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollment{Secret: key.Secret(), URI: key.URL()}, nil
+}
+
+// Confirm - enables the pending second factor of the user with its first code, and returns the new recovery codes.
+// Returns ErrNotEnrolling if there is no pending enrollment and ErrInvalidCode if the code is not valid
+func (m *Manager) Confirm(ctx context.Context, userID, code string) (*models.MFARecoveryCodes, error) {
+	current, err := m.users.GetMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrNotEnrolling
+	}
+	if current.Enabled {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	step, err := m.validateTOTP(userID, *current, code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		logrus.Errorf("Error in mfa.Confirm -> error generating recovery codes: %s", err)
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	current.Enabled = true
+	current.EnrolledAt = &now
+	current.LastUsedStep = step
+	current.RecoveryCodes = hashes
+	if err := m.users.SetMFA(ctx, userID, current); err != nil {
+		return nil, err
+	}
+	logrus.WithField("user", userID).Info("Second factor enrolled")
+
+	return &models.MFARecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// Check - returns nil if the user did not enroll a second factor or the code is a valid TOTP code or an unused
+// recovery code, which is consumed. Returns ErrCodeRequired if the code is empty and ErrInvalidCode if it's not valid
+func (m *Manager) Check(ctx context.Context, userID, code string) error {
+	current, err := m.users.GetMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if current == nil || !current.Enabled {
+		return nil
+	}
+	if code == "" {
+		return ErrCodeRequired
+	}
+
+	if step, err := m.validateTOTP(userID, *current, code); err == nil {
+		// the step is only used if no concurrent check used it meanwhile, so the code cannot be replayed
+		used, err := m.users.UseMFAStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidCode
+		}
+		return nil
+	} else if !errors.Is(err, ErrInvalidCode) {
+		return err
+	}
+
+	hash := HashRecoveryCode(code)
+	for _, stored := range current.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			// the code is only consumed if no concurrent check consumed it meanwhile
+			used, err := m.users.UseMFARecoveryCode(ctx, userID, hash)
+			if err != nil {
+				return err
+			}
+			if !used {
+				return ErrInvalidCode
+			}
+			logrus.WithFields(logrus.Fields{"user": userID, "remaining": len(current.RecoveryCodes) - 1}).Info("Recovery code used")
+			return nil
+		}
+	}
+
+	return ErrInvalidCode
+}
+
+// validateTOTP - returns the time step of the code if it's valid for the secret and it's newer than the last used one
+func (m *Manager) validateTOTP(userID string, current models.MFA, code string) (int64, error) {
+	secret, err := m.cipher.Decrypt(userID, current.Secret)
+	if err != nil {
+		logrus.Errorf("Error in mfa.validateTOTP -> error decrypting secret of user %s: %s", userID, err)
+		return 0, err
+	}
+
+	now := time.Now()
+	opts := totp.ValidateOpts{Period: period, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	for i := -skew; i <= skew; i++ {
+		t := now.Add(time.Duration(i*period) * time.Second)
+		step := t.Unix() / period
+		if step <= current.LastUsedStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(secret, t, opts)
+		if err != nil {
+			return 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+
+	return 0, ErrInvalidCode
+}
+
+// newRecoveryCodes - returns new random recovery codes (e.g. "k7d2m-q9x4t") and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodesCount)
+	hashes := make([]string, 0, RecoveryCodesCount)
+	for i := 0; i < RecoveryCodesCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))[:10]
+		code := encoded[:5] + "-" + encoded[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode - returns the hex encoded SHA-256 hash of the normalized recovery code, the only thing stored
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
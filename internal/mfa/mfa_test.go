@@ -0,0 +1,300 @@
+package mfa_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/mfa"
+	"user-microservice/internal/models"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	key    = "mfa-key"
+)
+
+// store - in memory second factor of a user, backing the GetMFA, SetMFA, UseMFAStep and UseMFARecoveryCode mocks
+type store struct {
+	mu  sync.Mutex
+	mfa *models.MFA
+}
+
+func newManager(t *testing.T, s *store) *mfa.Manager {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	usersRepo := usersMock.NewMockRepository(ctrl)
+	usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(&models.User{ID: userID, Email: "alicetingo@example.com"}, nil).AnyTimes()
+	usersRepo.EXPECT().GetMFA(gomock.Any(), userID).DoAndReturn(func(context.Context, string) (*models.MFA, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.mfa == nil {
+			return nil, nil
+		}
+		current := *s.mfa
+		current.RecoveryCodes = append([]string{}, s.mfa.RecoveryCodes...)
+		return &current, nil
+	}).AnyTimes()
+	usersRepo.EXPECT().SetMFA(gomock.Any(), userID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, m *models.MFA) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.mfa = m
+		return nil
+	}).AnyTimes()
+	usersRepo.EXPECT().UseMFAStep(gomock.Any(), userID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, step int64) (bool, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.mfa == nil || !s.mfa.Enabled || s.mfa.LastUsedStep >= step {
+			return false, nil
+		}
+		s.mfa.LastUsedStep = step
+		return true, nil
+	}).AnyTimes()
+	usersRepo.EXPECT().UseMFARecoveryCode(gomock.Any(), userID, gomock.Any()).DoAndReturn(func(_ context.Context, _ string, hash string) (bool, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.mfa == nil || !s.mfa.Enabled {
+			return false, nil
+		}
+		for i, stored := range s.mfa.RecoveryCodes {
+			if stored == hash {
+				s.mfa.RecoveryCodes = append(s.mfa.RecoveryCodes[:i:i], s.mfa.RecoveryCodes[i+1:]...)
+				return true, nil
+			}
+		}
+		return false, nil
+	}).AnyTimes()
+
+	manager, err := mfa.NewManager(usersRepo, []byte(key), "")
+	require.NoError(t, err)
+	return manager
+}
+
+// enroll - enrolls and confirms the second factor of the user, returning its secret and recovery codes
+func enroll(t *testing.T, manager *mfa.Manager) (string, []string) {
+	t.Helper()
+	enrollment, err := manager.Enroll(context.Background(), userID)
+	require.NoError(t, err)
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now().Add(-30*time.Second))
+	require.NoError(t, err)
+	recovery, err := manager.Confirm(context.Background(), userID, code)
+	require.NoError(t, err)
+	return enrollment.Secret, recovery.RecoveryCodes
+}
+
+func TestNewManager(t *testing.T) {
+	_, err := mfa.NewManager(usersMock.NewMockRepository(gomock.NewController(t)), nil, "")
+
+	assert.Error(t, err, "Expected an error without encryption key")
+}
+
+func TestCipher(t *testing.T) {
+	cipher, err := mfa.NewCipher([]byte(key))
+	require.NoError(t, err)
+	otherCipher, err := mfa.NewCipher([]byte("other-key"))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(userID, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name           string
+		cipher         *mfa.Cipher
+		userID         string
+		encrypted      string
+		expectedSecret string
+		shouldFail     bool
+	}{
+		{"Decrypt secret", cipher, userID, encrypted, "JBSWY3DPEHPK3PXP", false},
+		{"Decrypt secret of other user", cipher, "5cace01f-45c3-49f0-a725-c22866874095", encrypted, "", true},
+		{"Decrypt secret with other key", otherCipher, userID, encrypted, "", true},
+		{"Decrypt malformed secret", cipher, userID, "malformed", "", true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			secret, err := tc.cipher.Decrypt(tc.userID, tc.encrypted)
+
+			// Then
+			if tc.shouldFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSecret, secret)
+		})
+	}
+}
+
+func TestManager_Enroll(t *testing.T) {
+	// Given
+	s := &store{}
+	manager := newManager(t, s)
+
+	// When
+	enrollment, err := manager.Enroll(context.Background(), userID)
+
+	// Then
+	require.NoError(t, err)
+	uri, err := url.Parse(enrollment.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, enrollment.Secret, uri.Query().Get("secret"))
+	assert.Equal(t, mfa.DefaultIssuer, uri.Query().Get("issuer"))
+	require.NotNil(t, s.mfa)
+	assert.False(t, s.mfa.Enabled, "Expected the second factor to be pending until confirmed")
+	assert.NotContains(t, s.mfa.Secret, enrollment.Secret, "Expected the secret to be encrypted")
+
+	assert.NoError(t, manager.Check(context.Background(), userID, ""), "Expected no code required before the confirmation")
+}
+
+func TestManager_Confirm(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		enroll        bool
+		confirmed     bool
+		validCode     bool
+		expectedError error
+	}{
+		{"Confirm enrollment", true, false, true, nil},
+		{"Confirm with invalid code", true, false, false, mfa.ErrInvalidCode},
+		{"Confirm without enrollment", false, false, true, mfa.ErrNotEnrolling},
+		{"Confirm already enrolled", true, true, true, mfa.ErrAlreadyEnrolled},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			s := &store{}
+			manager := newManager(t, s)
+			code := "123456"
+			if tc.enroll {
+				enrollment, err := manager.Enroll(context.Background(), userID)
+				require.NoError(t, err)
+				if tc.validCode {
+					code, err = totp.GenerateCode(enrollment.Secret, time.Now())
+					require.NoError(t, err)
+				}
+			}
+			if tc.confirmed {
+				s.mfa.Enabled = true
+			}
+
+			// When
+			res, err := manager.Confirm(context.Background(), userID, code)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, res.RecoveryCodes, mfa.RecoveryCodesCount)
+			assert.True(t, s.mfa.Enabled)
+			assert.NotNil(t, s.mfa.EnrolledAt)
+			for i, code := range res.RecoveryCodes {
+				assert.Equal(t, mfa.HashRecoveryCode(code), s.mfa.RecoveryCodes[i], "Expected only the hashes to be stored")
+			}
+		})
+	}
+}
+
+func TestManager_Check(t *testing.T) {
+	// Given
+	manager := newManager(t, &store{})
+	secret, recoveryCodes := enroll(t, manager)
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+
+	for _, step := range []struct {
+		name          string
+		code          string
+		expectedError error
+	}{
+		{"Check without code", "", mfa.ErrCodeRequired},
+		{"Check invalid code", "000000x", mfa.ErrInvalidCode},
+		{"Check valid code", code, nil},
+		{"Check replayed code", code, mfa.ErrInvalidCode},
+		{"Check recovery code", recoveryCodes[0], nil},
+		{"Check used recovery code", recoveryCodes[0], mfa.ErrInvalidCode},
+		{"Check recovery code without dash and uppercase", strings.ToUpper(strings.ReplaceAll(recoveryCodes[1], "-", "")), nil},
+	} {
+		// When
+		err := manager.Check(context.Background(), userID, step.code)
+
+		// Then
+		if step.expectedError != nil {
+			assert.ErrorIs(t, err, step.expectedError, step.name)
+		} else {
+			assert.NoError(t, err, step.name)
+		}
+	}
+}
+
+func TestManager_CheckConcurrently(t *testing.T) {
+	// Given
+	manager := newManager(t, &store{})
+	secret, recoveryCodes := enroll(t, manager)
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		code string
+	}{
+		{"Check the same TOTP code concurrently", code},
+		{"Check the same recovery code concurrently", recoveryCodes[0]},
+	} {
+		// When
+		const checks = 10
+		var wg sync.WaitGroup
+		errs := make(chan error, checks)
+		for i := 0; i < checks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- manager.Check(context.Background(), userID, tc.code)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// Then
+		accepted := 0
+		for err := range errs {
+			if err == nil {
+				accepted++
+				continue
+			}
+			assert.ErrorIs(t, err, mfa.ErrInvalidCode, tc.name)
+		}
+		assert.Equal(t, 1, accepted, "%s: expected the code to be accepted only once", tc.name)
+	}
+}
+
+func TestManager_NotFoundUser(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	usersRepo := usersMock.NewMockRepository(ctrl)
+	usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(nil, mongo.ErrNoDocuments)
+	manager, err := mfa.NewManager(usersRepo, []byte(key), "")
+	require.NoError(t, err)
+
+	// When
+	_, err = manager.Enroll(context.Background(), userID)
+
+	// Then
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// APIKey - key used by the internal services to call the API. Only the key hash is stored
+type APIKey struct {
+	ID     string   `json:"id" bson:"_id" example:"b1e3c1a4-2a4e-4d5b-9c1e-2f3a4b5c6d7e"`
+	Name   string   `json:"name" bson:"name" example:"matchmaking"`
+	Hash   string   `json:"-" bson:"hash"`
+	Scopes []string `json:"scopes" bson:"scopes" example:"users:read"`
+	// ExpiresAt - the key is not valid after this time
+	ExpiresAt  time.Time  `json:"expiresAt" bson:"expires_at" example:"2024-05-18T16:00:00Z"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"created_at" example:"2023-05-18T16:00:00Z"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" bson:"last_used_at,omitempty" example:"2023-05-19T16:00:00Z"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" bson:"revoked_at,omitempty" example:"2023-06-18T16:00:00Z"`
+}
+
+// Expired - returns true if the key is expired at the given time
+func (k APIKey) Expired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
+
+// Revoked - returns true if the key was revoked
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
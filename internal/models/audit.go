@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+	"user-microservice/internal/pagination"
+)
+
+// MaskedValue - value stored in the audit entries instead of the sensitive fields
+const MaskedValue = "********"
+
+// AuditAction - kind of change recorded in an AuditEntry
+type AuditAction string
+
+const (
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDelete  AuditAction = "delete"
+	AuditActionRestore AuditAction = "restore"
+	AuditActionPurge   AuditAction = "purge"
+)
+
+// AuditEntry - append-only record of a change made to a user
+type AuditEntry struct {
+	ID        string        `json:"id" bson:"_id" example:"3f0bd5a4-44d1-4a4c-8d0c-7f1ad4fbd1a4"`
+	UserID    string        `json:"userId" bson:"user_id" example:"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"`
+	Action    AuditAction   `json:"action" bson:"action" example:"update"`
+	Actor     string        `json:"actor" bson:"actor" example:"admin@example.com"`
+	RequestID string        `json:"requestId" bson:"request_id" example:"2Xl0XQJ8xYmYn1z1uJh2Ww1X6yd4nJXZ"`
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+	Timestamp time.Time     `json:"timestamp" bson:"timestamp" example:"2016-05-18T16:00:00Z"`
+}
+
+// FieldChange - change of a single user field (json name). Old is nil for the created fields
+type FieldChange struct {
+	Field string      `json:"field" bson:"field" example:"email"`
+	Old   interface{} `json:"old" bson:"old"`
+	New   interface{} `json:"new" bson:"new"`
+}
+
+// PaginatedAuditEntries - audit entries pagination data
+type PaginatedAuditEntries struct {
+	pagination.Paginated
+	Entries []AuditEntry `json:"entries"`
+}
+
+// auditedFields - getters for the user fields (json names) recorded in the audit entries
+var auditedFields = []struct {
+	name   string
+	masked bool
+	get    func(User) interface{}
+}{
+	{"firstName", false, func(u User) interface{} { return u.FirstName }},
+	{"lastName", false, func(u User) interface{} { return u.LastName }},
+	{"nickname", false, func(u User) interface{} { return u.Nickname }},
+	{"password", true, func(u User) interface{} { return u.Password }},
+	{"email", false, func(u User) interface{} { return u.Email }},
+	{"country", false, func(u User) interface{} { return u.Country }},
+	{"emailVerifiedAt", false, func(u User) interface{} {
+		if u.EmailVerifiedAt == nil {
+			return nil
+		}
+		return *u.EmailVerifiedAt
+	}},
+	{"pendingEmail", false, func(u User) interface{} {
+		if u.PendingEmail == "" {
+			return nil
+		}
+		return u.PendingEmail
+	}},
+	{"status", false, func(u User) interface{} {
+		if u.Status == "" {
+			return nil
+		}
+		return string(u.Status)
+	}},
+	{"statusReason", false, func(u User) interface{} {
+		if u.StatusReason == "" {
+			return nil
+		}
+		return u.StatusReason
+	}},
+	{"suspendedUntil", false, func(u User) interface{} {
+		if u.SuspendedUntil == nil {
+			return nil
+		}
+		return *u.SuspendedUntil
+	}},
+	{"deletedAt", false, func(u User) interface{} {
+		if u.DeletedAt == nil {
+			return nil
+		}
+		return *u.DeletedAt
+	}},
+}
+
+// DiffUsers - returns the changes between the old and the new user. A nil old user means the user
+// is created, so all its fields are returned. The sensitive fields values are masked
+func DiffUsers(old *User, new User) []FieldChange {
+	changes := []FieldChange{}
+	for _, field := range auditedFields {
+		newValue := field.get(new)
+		var oldValue interface{}
+		if old != nil {
+			oldValue = field.get(*old)
+			if equalValues(oldValue, newValue) {
+				continue
+			}
+		} else if newValue == nil {
+			continue
+		}
+
+		if field.masked {
+			if oldValue != nil {
+				oldValue = MaskedValue
+			}
+			newValue = MaskedValue
+		}
+		changes = append(changes, FieldChange{Field: field.name, Old: oldValue, New: newValue})
+	}
+
+	return changes
+}
+
+func equalValues(a, b interface{}) bool {
+	aTime, aIsTime := a.(time.Time)
+	bTime, bIsTime := b.(time.Time)
+	if aIsTime && bIsTime {
+		return aTime.Equal(bTime)
+	}
+	return a == b
+}
@@ -0,0 +1,140 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffUsers(t *testing.T) {
+	deletedAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	verifiedAt := time.Date(2022, 10, 2, 12, 0, 0, 0, time.UTC)
+	suspendedUntil := time.Date(2022, 10, 9, 12, 0, 0, 0, time.UTC)
+	user := models.User{
+		FirstName: "FirstName",
+		LastName:  "LastName",
+		Nickname:  "Nickname",
+		Password:  "Password",
+		Email:     "email@example.com",
+		Country:   "ES",
+	}
+	for _, tc := range []struct {
+		name            string
+		old             *models.User
+		new             models.User
+		expectedChanges []models.FieldChange
+	}{
+		{
+			"Diff created user",
+			nil,
+			user,
+			[]models.FieldChange{
+				{Field: "firstName", Old: nil, New: "FirstName"},
+				{Field: "lastName", Old: nil, New: "LastName"},
+				{Field: "nickname", Old: nil, New: "Nickname"},
+				{Field: "password", Old: nil, New: models.MaskedValue},
+				{Field: "email", Old: nil, New: "email@example.com"},
+				{Field: "country", Old: nil, New: "ES"},
+			},
+		},
+		{
+			"Diff updated user",
+			&user,
+			models.User{
+				FirstName: "FirstName",
+				LastName:  "LastName",
+				Nickname:  "New Nickname",
+				Password:  "New Password",
+				Email:     "email@example.com",
+				Country:   "ES",
+			},
+			[]models.FieldChange{
+				{Field: "nickname", Old: "Nickname", New: "New Nickname"},
+				{Field: "password", Old: models.MaskedValue, New: models.MaskedValue},
+			},
+		},
+		{
+			"Diff deleted user",
+			&user,
+			models.User{
+				FirstName: "FirstName",
+				LastName:  "LastName",
+				Nickname:  "Nickname",
+				Password:  "Password",
+				Email:     "email@example.com",
+				Country:   "ES",
+				DeletedAt: &deletedAt,
+			},
+			[]models.FieldChange{
+				{Field: "deletedAt", Old: nil, New: deletedAt},
+			},
+		},
+		{
+			"Diff verified pending email",
+			&models.User{
+				FirstName:    "FirstName",
+				LastName:     "LastName",
+				Nickname:     "Nickname",
+				Password:     "Password",
+				Email:        "email@example.com",
+				Country:      "ES",
+				PendingEmail: "new@example.com",
+			},
+			models.User{
+				FirstName:       "FirstName",
+				LastName:        "LastName",
+				Nickname:        "Nickname",
+				Password:        "Password",
+				Email:           "new@example.com",
+				Country:         "ES",
+				EmailVerified:   true,
+				EmailVerifiedAt: &verifiedAt,
+			},
+			[]models.FieldChange{
+				{Field: "email", Old: "email@example.com", New: "new@example.com"},
+				{Field: "emailVerifiedAt", Old: nil, New: verifiedAt},
+				{Field: "pendingEmail", Old: "new@example.com", New: nil},
+			},
+		},
+		{
+			"Diff suspended user",
+			&user,
+			models.User{
+				FirstName:       "FirstName",
+				LastName:        "LastName",
+				Nickname:        "Nickname",
+				Password:        "Password",
+				Email:           "email@example.com",
+				Country:         "ES",
+				Status:          models.StatusSuspended,
+				StatusReason:    "Cheating",
+				StatusChangedAt: &verifiedAt,
+				SuspendedUntil:  &suspendedUntil,
+			},
+			[]models.FieldChange{
+				{Field: "status", Old: nil, New: "suspended"},
+				{Field: "statusReason", Old: nil, New: "Cheating"},
+				{Field: "suspendedUntil", Old: nil, New: suspendedUntil},
+			},
+		},
+		{
+			"Diff unchanged user",
+			&user,
+			user,
+			[]models.FieldChange{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//When
+			changes := models.DiffUsers(tc.old, tc.new)
+
+			//Then
+			assert.Equalf(t, tc.expectedChanges, changes, "Expected changes to be %v, but were %v", tc.expectedChanges, changes)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// MFA - TOTP second factor of a user, stored in its document of the users collection
+type MFA struct {
+	// Secret - TOTP secret, encrypted with AES-GCM
+	Secret string `bson:"secret"`
+	// Enabled - false until the enrollment is confirmed with a first code
+	Enabled    bool       `bson:"enabled"`
+	EnrolledAt *time.Time `bson:"enrolled_at,omitempty"`
+	// RecoveryCodes - SHA-256 hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recovery_codes"`
+	// LastUsedStep - time step of the last accepted code, so the codes cannot be reused
+	LastUsedStep int64 `bson:"last_used_step"`
+}
+
+// MFAEnrollment - TOTP secret generated on enrollment, to be added to an authenticator app
+type MFAEnrollment struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/user-microservice:atingo@example.com?algorithm=SHA1&digits=6&issuer=user-microservice&period=30&secret=JBSWY3DPEHPK3PXP"`
+}
+
+// MFACode - TOTP code body
+type MFACode struct {
+	Code string `json:"code" example:"123456" validate:"required"`
+}
+
+// MFARecoveryCodes - single-use recovery codes, accepted instead of the TOTP codes. They are only returned once
+type MFARecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes" example:"k7d2m-q9x4t"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// PasswordReset - single-use token allowing a user to set a new password. Only the token hash is stored
+type PasswordReset struct {
+	ID        string    `json:"id" bson:"_id"`
+	UserID    string    `json:"userId" bson:"user_id"`
+	TokenHash string    `json:"-" bson:"token_hash"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+	// ExpiresAt - the token is not valid after this time
+	ExpiresAt time.Time `json:"expiresAt" bson:"expires_at"`
+	// UsedAt - set when the token is used or invalidated by a newer reset
+	UsedAt *time.Time `json:"usedAt,omitempty" bson:"used_at,omitempty"`
+}
+
+// PasswordResetRequest - password reset request body
+type PasswordResetRequest struct {
+	Email string `json:"email" example:"atingo@example.com" validate:"required"`
+}
+
+// PasswordResetConfirmation - password reset confirmation body
+type PasswordResetConfirmation struct {
+	Token    string `json:"token" example:"Jq1m2Q4yRZ0p9sUu3l6d8kM1A3q2-7wAAnhTYhQZ9b0" validate:"required"`
+	Password string `json:"password" example:"new secret" validate:"required"`
+}
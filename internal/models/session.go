@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Session - login session of a user, kept alive by rotating refresh tokens. All the refresh tokens of
+// a session are the same token family: only the hash of the current one and the previous ones are stored
+type Session struct {
+	ID     string `json:"id" bson:"_id" example:"7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a"`
+	UserID string `json:"userId" bson:"user_id" example:"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"`
+	// TokenHash - hash of the current refresh token
+	TokenHash string `json:"-" bson:"token_hash"`
+	// PreviousHashes - hashes of the rotated refresh tokens, used to detect their reuse
+	PreviousHashes []string  `json:"-" bson:"previous_hashes"`
+	UserAgent      string    `json:"userAgent" bson:"user_agent" example:"Mozilla/5.0"`
+	IP             string    `json:"ip" bson:"ip" example:"192.0.2.1"`
+	CreatedAt      time.Time `json:"createdAt" bson:"created_at" example:"2023-05-18T16:00:00Z"`
+	LastUsedAt     time.Time `json:"lastUsedAt" bson:"last_used_at" example:"2023-05-19T16:00:00Z"`
+	// ExpiresAt - the refresh token is not valid after this time, it's extended on every rotation
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expires_at" example:"2023-06-18T16:00:00Z"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty" bson:"revoked_at,omitempty" example:"2023-05-20T16:00:00Z"`
+}
+
+// Active - returns true if the session is not revoked nor expired at the given time
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// Credentials - login request body
+type Credentials struct {
+	Email    string `json:"email" example:"atingo@example.com" validate:"required"`
+	Password string `json:"password" example:"secret" validate:"required"`
+	// Code - TOTP or recovery code, required for the users who enrolled a second factor
+	Code string `json:"code,omitempty" example:"123456"`
+}
+
+// RefreshTokenRequest - refresh and logout request body
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" example:"3q2-7wAAnhTYhQZ9b0Jq1m2Q4yRZ0p9sUu3l6d8kM1A" validate:"required"`
+}
+
+// Tokens - tokens issued on login and refresh
+type Tokens struct {
+	AccessToken  string `json:"accessToken" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.e30.Et9HFtf9R3GEMA0IICOfFMVXY7kkTX1wr4qCyhIf58U"`
+	RefreshToken string `json:"refreshToken" example:"3q2-7wAAnhTYhQZ9b0Jq1m2Q4yRZ0p9sUu3l6d8kM1A"`
+	TokenType    string `json:"tokenType" example:"Bearer"`
+	// ExpiresIn - seconds until the access token expires
+	ExpiresIn int64 `json:"expiresIn" example:"900"`
+}
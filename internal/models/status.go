@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UserStatus - account status of the users, changed by the moderation
+type UserStatus string
+
+const (
+	// StatusActive - the user can log in, the status of the users without status
+	StatusActive UserStatus = "active"
+	// StatusSuspended - the user cannot log in until the suspension ends
+	StatusSuspended UserStatus = "suspended"
+	// StatusBanned - the user cannot log in until it's reactivated
+	StatusBanned UserStatus = "banned"
+)
+
+// ErrInvalidStatusTransition - the status cannot be changed from the current one to the requested one
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// statusTransitions - allowed status changes. A suspended user can be suspended again to change the suspension end
+var statusTransitions = map[UserStatus]map[UserStatus]bool{
+	StatusActive:    {StatusSuspended: true, StatusBanned: true},
+	StatusSuspended: {StatusSuspended: true, StatusBanned: true, StatusActive: true},
+	StatusBanned:    {StatusActive: true},
+}
+
+// Valid - returns true if the status is one of the known statuses
+func (s UserStatus) Valid() bool {
+	_, isOK := statusTransitions[s]
+	return isOK
+}
+
+// CanTransition - returns true if the status can be changed to the given one
+func (s UserStatus) CanTransition(to UserStatus) bool {
+	return statusTransitions[s][to]
+}
+
+// StatusChange - reason of a status change and the end of the suspensions
+type StatusChange struct {
+	Reason string `json:"reason" example:"Cheating in ranked matches"`
+	// Until - end of the suspension, required to suspend and ignored otherwise
+	Until *time.Time `json:"until,omitempty" example:"2016-05-25T16:00:00Z"`
+}
+
+// CurrentStatus - returns the status of the user at the given time. The users whose suspension
+// ended and the ones without status are active
+func (u User) CurrentStatus(now time.Time) UserStatus {
+	switch {
+	case u.Status == "":
+		return StatusActive
+	case u.Status == StatusSuspended && u.SuspendedUntil != nil && !now.Before(*u.SuspendedUntil):
+		return StatusActive
+	}
+	return u.Status
+}
+
+// ChangeStatus - changes the status of the user, returning ErrInvalidStatusTransition if it's not allowed
+// from the current one. The until time is only kept for the suspensions
+func (u *User) ChangeStatus(to UserStatus, reason string, until *time.Time, now time.Time) error {
+	from := u.CurrentStatus(now)
+	if !from.CanTransition(to) {
+		return fmt.Errorf("%w: from %s to %s", ErrInvalidStatusTransition, from, to)
+	}
+
+	u.Status = to
+	u.StatusReason = reason
+	u.StatusChangedAt = &now
+	u.SuspendedUntil = nil
+	if to == StatusSuspended {
+		u.SuspendedUntil = until
+	}
+
+	return nil
+}
+
+// ExpireSuspension - reactivates the user if its suspension ended at the given time, returning true if so
+func (u *User) ExpireSuspension(now time.Time) bool {
+	if u.Status != StatusSuspended || u.CurrentStatus(now) != StatusActive {
+		return false
+	}
+
+	u.Status = StatusActive
+	u.StatusReason = "Suspension ended"
+	u.StatusChangedAt = &now
+	u.SuspendedUntil = nil
+	return true
+}
+
+// ResetStatus - sets the user as active, used when creating users so the callers cannot set the status themselves
+func (u *User) ResetStatus() {
+	u.Status = StatusActive
+	u.StatusReason = ""
+	u.StatusChangedAt = nil
+	u.SuspendedUntil = nil
+}
+
+// KeepStatus - keeps the status fields of the current user, which are only changed by the moderation
+func (u *User) KeepStatus(current User) {
+	u.Status = current.Status
+	u.StatusReason = current.StatusReason
+	u.StatusChangedAt = current.StatusChangedAt
+	u.SuspendedUntil = current.SuspendedUntil
+}
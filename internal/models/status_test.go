@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUser_ChangeStatus(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(24 * time.Hour)
+	past := now.Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name          string
+		user          models.User
+		to            models.UserStatus
+		until         *time.Time
+		expectedUntil *time.Time
+		expectedError error
+	}{
+		{"Suspend active user", models.User{Status: models.StatusActive}, models.StatusSuspended, &future, &future, nil},
+		{"Suspend user without status", models.User{}, models.StatusSuspended, &future, &future, nil},
+		{"Ban active user", models.User{Status: models.StatusActive}, models.StatusBanned, &future, nil, nil},
+		{"Reactivate active user", models.User{Status: models.StatusActive}, models.StatusActive, nil, nil, models.ErrInvalidStatusTransition},
+		{"Extend suspension", models.User{Status: models.StatusSuspended, SuspendedUntil: &future}, models.StatusSuspended, &past, &past, nil},
+		{"Ban suspended user", models.User{Status: models.StatusSuspended, SuspendedUntil: &future}, models.StatusBanned, nil, nil, nil},
+		{"Reactivate suspended user", models.User{Status: models.StatusSuspended, SuspendedUntil: &future}, models.StatusActive, nil, nil, nil},
+		{"Reactivate user whose suspension ended", models.User{Status: models.StatusSuspended, SuspendedUntil: &past}, models.StatusActive, nil, nil, models.ErrInvalidStatusTransition},
+		{"Reactivate banned user", models.User{Status: models.StatusBanned}, models.StatusActive, nil, nil, nil},
+		{"Suspend banned user", models.User{Status: models.StatusBanned}, models.StatusSuspended, &future, nil, models.ErrInvalidStatusTransition},
+		{"Ban banned user", models.User{Status: models.StatusBanned}, models.StatusBanned, nil, nil, models.ErrInvalidStatusTransition},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			user := tc.user
+			user.StatusReason = "Previous reason"
+
+			// When
+			err := user.ChangeStatus(tc.to, "Reason", tc.until, now)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				assert.Equal(t, "Previous reason", user.StatusReason, "Expected the user not to be changed")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.to, user.Status)
+			assert.Equal(t, "Reason", user.StatusReason)
+			assert.Equal(t, tc.expectedUntil, user.SuspendedUntil)
+			require.NotNil(t, user.StatusChangedAt)
+			assert.Equal(t, now, *user.StatusChangedAt)
+		})
+	}
+}
+
+func TestUser_ExpireSuspension(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+
+	for _, tc := range []struct {
+		name           string
+		user           models.User
+		expected       bool
+		expectedStatus models.UserStatus
+	}{
+		{"Expire ended suspension", models.User{Status: models.StatusSuspended, SuspendedUntil: &now}, true, models.StatusActive},
+		{"Expire ongoing suspension", models.User{Status: models.StatusSuspended, SuspendedUntil: &future}, false, models.StatusSuspended},
+		{"Expire banned user", models.User{Status: models.StatusBanned}, false, models.StatusBanned},
+		{"Expire active user", models.User{Status: models.StatusActive}, false, models.StatusActive},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			user := tc.user
+
+			// When
+			res := user.ExpireSuspension(now)
+
+			// Then
+			assert.Equal(t, tc.expected, res)
+			assert.Equal(t, tc.expectedStatus, user.Status)
+			if tc.expected {
+				assert.Nil(t, user.SuspendedUntil)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 	"time"
 	"user-microservice/internal/pagination"
 
@@ -14,26 +16,112 @@ type User struct {
 	FirstName string `json:"firstName" bson:"first_name" example:"Alice" validate:"required"`
 	LastName  string `json:"lastName" bson:"last_name" example:"Tingo" validate:"required"`
 	Nickname  string `json:"nickname" bson:"nickname" example:"atingo" validate:"required"`
-	// Should be json:"-" in order to hide the password
-	Password  string    `json:"password" bson:"password"`
+	// Password - bcrypt hash of the password, never serialised to JSON (it's only received in a UserRequest).
+	// Empty in the updates to keep the stored one
+	Password  string    `json:"-" bson:"password"`
 	Email     string    `json:"email" bson:"email" example:"atingo@example.com" validate:"required"`
 	Country   string    `json:"country" bson:"country" example:"DE" validate:"required"`
 	CreatedAt time.Time `json:"createdAt" bson:"created_at" example:"2016-05-18T16:00:00Z"`
 	UpdatedAt time.Time `json:"updatedAt" bson:"updated_at" example:"2016-05-18T16:00:00Z"`
+	// EmailVerified - true once the user confirmed the email with the mailed verification token
+	EmailVerified bool `json:"emailVerified" bson:"email_verified" example:"true"`
+	// EmailVerifiedAt - set when the email is verified
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" bson:"email_verified_at,omitempty" example:"2016-05-18T16:00:00Z"`
+	// PendingEmail - new email requested by an update, it replaces the email once it is verified
+	PendingEmail string `json:"pendingEmail,omitempty" bson:"pending_email,omitempty" example:"alice.tingo@example.com"`
+	// Status - account status, changed by the moderation
+	Status UserStatus `json:"status" bson:"status" example:"active" enums:"active,suspended,banned"`
+	// StatusReason - reason of the last status change
+	StatusReason string `json:"statusReason,omitempty" bson:"status_reason,omitempty" example:"Cheating in ranked matches"`
+	// StatusChangedAt - set when the status changes
+	StatusChangedAt *time.Time `json:"statusChangedAt,omitempty" bson:"status_changed_at,omitempty" example:"2016-05-18T16:00:00Z"`
+	// SuspendedUntil - end of the suspension, the user is active again afterwards
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty" bson:"suspended_until,omitempty" example:"2016-05-25T16:00:00Z"`
+	// Version - incremented on every update, used for the optimistic concurrency control
+	Version int64 `json:"version" bson:"version" example:"1"`
+	// DeletedAt - set when the user is soft deleted, the user is purged after the retention period
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deleted_at,omitempty" example:"2016-05-18T16:00:00Z"`
+}
+
+// UserRequest - user body of the create and update requests, the only one with the password
+type UserRequest struct {
+	User
+	// Password - new plain password, hashed before storing it. Empty in the updates to keep the current one
+	Password string `json:"password" example:"S3cr3t P4ssw0rd"`
+}
+
+// ToUser - returns the requested user with its password
+func (r UserRequest) ToUser() User {
+	user := r.User
+	user.Password = r.Password
+	return user
 }
 
 // Valid - returns true if the user is valid.
 // Valid means the main fields are not empty
 func (u User) Valid() bool {
-	return u.FirstName != "" &&
-		u.LastName != "" &&
-		u.Nickname != "" &&
-		u.Password != "" &&
-		u.Email != "" &&
-		u.Country != ""
+	return len(u.MissingFields()) == 0
+}
+
+// MissingFields - returns the required fields (json names) that are empty
+func (u User) MissingFields() []string {
+	missing := []string{}
+	for field, value := range map[string]string{
+		"firstName": u.FirstName,
+		"lastName":  u.LastName,
+		"nickname":  u.Nickname,
+		"password":  u.Password,
+		"email":     u.Email,
+		"country":   u.Country,
+	} {
+		if value == "" {
+			missing = append(missing, field)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// MissingUpdateFields - returns the required fields (json names) that are empty, except the password
+// as the updates keep the stored one when it is empty
+func (u User) MissingUpdateFields() []string {
+	missing := []string{}
+	for _, field := range u.MissingFields() {
+		if field != "password" {
+			missing = append(missing, field)
+		}
+	}
+
+	return missing
+}
+
+// ETag - returns the strong entity tag for the current user version
+func (u User) ETag() string {
+	return fmt.Sprintf(`"%d"`, u.Version)
+}
+
+// ChangedImmutableFields - returns the immutable fields (json names) that are different in the modified user.
+// The immutable fields are the ones managed by the service: id, createdAt, updatedAt and deletedAt
+func (u User) ChangedImmutableFields(modified User) []string {
+	changed := []string{}
+	if u.ID != modified.ID {
+		changed = append(changed, "id")
+	}
+	if !u.CreatedAt.Equal(modified.CreatedAt) {
+		changed = append(changed, "createdAt")
+	}
+	if !u.UpdatedAt.Equal(modified.UpdatedAt) {
+		changed = append(changed, "updatedAt")
+	}
+	if (u.DeletedAt == nil) != (modified.DeletedAt == nil) || (u.DeletedAt != nil && !u.DeletedAt.Equal(*modified.DeletedAt)) {
+		changed = append(changed, "deletedAt")
+	}
+
+	return changed
 }
 
-// Modify - sets the values from the given user to the current one
+// Modify - sets the values from the given user to the current one. An empty password keeps the current one
 func (u *User) Modify(mod User) {
 	if u.FirstName != mod.FirstName {
 		u.FirstName = mod.FirstName
@@ -50,11 +138,51 @@ func (u *User) Modify(mod User) {
 	if u.Country != mod.Country {
 		u.Country = mod.Country
 	}
-	if u.Password != mod.Password {
+	if mod.Password != "" && u.Password != mod.Password {
 		u.Password = mod.Password
 	}
 }
 
+// PrepareNew - resets the fields managed by the service (the email verification and the status), used by
+// every way of creating users so the callers cannot set them
+func (u *User) PrepareNew() {
+	u.ResetEmailVerification()
+	u.ResetStatus()
+}
+
+// ResetEmailVerification - marks the email as not verified and discards the pending email,
+// used when creating users so the callers cannot verify the emails themselves
+func (u *User) ResetEmailVerification() {
+	u.EmailVerified = false
+	u.EmailVerifiedAt = nil
+	u.PendingEmail = ""
+}
+
+// KeepEmailVerification - keeps the email verification fields of the current user, managed by the service.
+// A changed email is not applied but kept as the pending email until it is verified
+func (u *User) KeepEmailVerification(current User) {
+	if u.Email != current.Email {
+		u.PendingEmail = u.Email
+		u.Email = current.Email
+	} else {
+		u.PendingEmail = current.PendingEmail
+	}
+	u.EmailVerified = current.EmailVerified
+	u.EmailVerifiedAt = current.EmailVerifiedAt
+}
+
+// VerificationEmail - returns the email waiting for verification: the pending email if any,
+// the email if it's not verified yet, or an empty string if there is nothing to verify
+func (u User) VerificationEmail() string {
+	if u.PendingEmail != "" {
+		return u.PendingEmail
+	}
+	if !u.EmailVerified {
+		return u.Email
+	}
+	return ""
+}
+
 // MarshalBinary - custom encoding.BinaryMarshaler implementation
 // We need this to encode and decode when publishing into redis
 func (u User) MarshalBinary() ([]byte, error) {
@@ -67,6 +195,12 @@ type PaginatedUsers struct {
 	Users []User `json:"users"`
 }
 
+// BatchUsers - users retrieved by their IDs, in the requested order
+type BatchUsers struct {
+	Users    []User   `json:"users"`
+	NotFound []string `json:"notFound"`
+}
+
 // UserFilters - used when filtering users
 type UserFilters struct {
 	FirstName string `query:"firstName" bson:"first_name,omitempty"`
@@ -74,6 +208,10 @@ type UserFilters struct {
 	Nickname  string `query:"nickname" bson:"nickname,omitempty"`
 	Email     string `query:"email" bson:"email,omitempty"`
 	Country   string `query:"country" bson:"country,omitempty"`
+	// Status - current status of the users (active, suspended or banned), the ended suspensions are active
+	Status UserStatus `query:"status" bson:"-"`
+	// IncludeDeleted - includes the soft deleted users
+	IncludeDeleted bool `query:"includeDeleted" bson:"-"`
 }
 
 // ToBsonM - converts the current UserFilters into bson.M in order to use it in mongodb
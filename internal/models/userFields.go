@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"user-microservice/internal/pagination"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UserSelectableFields - whitelist of the user fields (json name) that can be requested
+// using sparse fieldsets, mapped to their bson name
+var UserSelectableFields = map[string]string{
+	"id":             "_id",
+	"firstName":      "first_name",
+	"lastName":       "last_name",
+	"nickname":       "nickname",
+	"email":          "email",
+	"country":        "country",
+	"createdAt":      "created_at",
+	"updatedAt":      "updated_at",
+	"version":        "version",
+	"emailVerified":  "email_verified",
+	"status":         "status",
+	"suspendedUntil": "suspended_until",
+}
+
+// UserFields - list of user fields (json names) to retrieve.
+// An empty list means all the fields
+type UserFields []string
+
+// ParseUserFields - parses a comma separated list of fields (e.g. "id,nickname,country")
+// and validates each one of them against UserSelectableFields
+func ParseUserFields(value string) (UserFields, error) {
+	var fields UserFields
+	if strings.TrimSpace(value) == "" {
+		return fields, nil
+	}
+
+	seen := map[string]bool{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := UserSelectableFields[field]; !ok {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+// Projection - returns the mongodb projection for the current fields.
+// The version is always projected so the ETag can be computed.
+// Returns nil if there are no fields, meaning the whole document
+func (uf UserFields) Projection() bson.M {
+	if len(uf) == 0 {
+		return nil
+	}
+
+	res := bson.M{"version": 1}
+	for _, field := range uf {
+		if bsonName, ok := UserSelectableFields[field]; ok {
+			res[bsonName] = 1
+		}
+	}
+
+	return res
+}
+
+// Select - returns a map containing only the given fields of the user (the id is always included).
+// If there are no fields, all the user fields are returned
+func (u User) Select(fields UserFields) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return all, nil
+	}
+
+	res := map[string]interface{}{"id": all["id"]}
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			res[field] = value
+		}
+	}
+
+	return res, nil
+}
+
+// SparsePaginatedUsers - users pagination data containing only the requested fields
+type SparsePaginatedUsers struct {
+	pagination.Paginated
+	Users []map[string]interface{} `json:"users"`
+}
+
+// Select - returns the paginated users containing only the given fields
+func (pu PaginatedUsers) Select(fields UserFields) (SparsePaginatedUsers, error) {
+	res := SparsePaginatedUsers{
+		Paginated: pu.Paginated,
+		Users:     make([]map[string]interface{}, 0, len(pu.Users)),
+	}
+	for _, user := range pu.Users {
+		selected, err := user.Select(fields)
+		if err != nil {
+			return res, err
+		}
+		res.Users = append(res.Users, selected)
+	}
+
+	return res, nil
+}
+
+// SparseBatchUsers - users retrieved by their IDs containing only the requested fields
+type SparseBatchUsers struct {
+	Users    []map[string]interface{} `json:"users"`
+	NotFound []string                 `json:"notFound"`
+}
+
+// Select - returns the batch users containing only the given fields
+func (bu BatchUsers) Select(fields UserFields) (SparseBatchUsers, error) {
+	res := SparseBatchUsers{
+		Users:    make([]map[string]interface{}, 0, len(bu.Users)),
+		NotFound: bu.NotFound,
+	}
+	for _, user := range bu.Users {
+		selected, err := user.Select(fields)
+		if err != nil {
+			return res, err
+		}
+		res.Users = append(res.Users, selected)
+	}
+
+	return res, nil
+}
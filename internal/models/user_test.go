@@ -0,0 +1,52 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"user-microservice/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUser_PasswordIsNotSerialised(t *testing.T) {
+	// Given
+	user := models.User{ID: "id", Nickname: "Nickname", Password: "$2a$14$hash"}
+
+	// When
+	encoded, err := json.Marshal(user)
+
+	// Then
+	require.NoError(t, err)
+	assert.NotContainsf(t, string(encoded), "password", "Expected the password not to be encoded, but was %s", encoded)
+	assert.NotContainsf(t, string(encoded), user.Password, "Expected the hash not to be encoded, but was %s", encoded)
+}
+
+func TestUserRequest_ToUser(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		body             string
+		expectedNickname string
+		expectedPassword string
+	}{
+		{"Decode request with password", `{"nickname": "Nickname", "password": "Plain Password"}`, "Nickname", "Plain Password"},
+		{"Decode request without password", `{"nickname": "Nickname"}`, "Nickname", ""},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			var req models.UserRequest
+
+			// When
+			err := json.Unmarshal([]byte(tc.body), &req)
+			user := req.ToUser()
+
+			// Then
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedNickname, user.Nickname, "Expected Nickname to be %s, but was %s", tc.expectedNickname, user.Nickname)
+			assert.Equalf(t, tc.expectedPassword, user.Password, "Expected Password to be %s, but was %s", tc.expectedPassword, user.Password)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// DeliveryStatus - status of a webhook delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending - the delivery is waiting for its first attempt or a retry
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded - the webhook URL replied with a 2xx status
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed - all the attempts failed, or the webhook was disabled or deleted before delivering it
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Webhook - URL receiving the user events of its types, signed with its secret
+type Webhook struct {
+	ID  string `json:"id" bson:"_id" example:"3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b"`
+	URL string `json:"url" bson:"url" example:"https://partner.example.com/hooks/users"`
+	// Events - user topics delivered to the webhook
+	Events []string `json:"events" bson:"events" example:"user-created,user-deleted"`
+	// Secret - key of the payloads HMAC signature, it's never returned
+	Secret  string `json:"-" bson:"secret"`
+	Enabled bool   `json:"enabled" bson:"enabled" example:"true"`
+	// Failures - consecutive failed delivery attempts, the webhook is disabled when there are too many
+	Failures   int        `json:"failures" bson:"failures" example:"0"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty" bson:"disabled_at,omitempty" example:"2023-05-19T16:00:00Z"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"created_at" example:"2023-05-18T16:00:00Z"`
+	UpdatedAt  time.Time  `json:"updatedAt" bson:"updated_at" example:"2023-05-18T16:00:00Z"`
+}
+
+// Subscribed - returns true if the webhook receives the events of the topic
+func (w Webhook) Subscribed(topic string) bool {
+	for _, event := range w.Events {
+		if event == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookRequest - webhook creation and update request body
+type WebhookRequest struct {
+	URL    string   `json:"url" example:"https://partner.example.com/hooks/users" validate:"required"`
+	Events []string `json:"events" example:"user-created,user-deleted" validate:"required"`
+	// Secret - key of the payloads HMAC signature, required on creation and kept if it's empty on update
+	Secret string `json:"secret" example:"8f2b7c4e1a9d6e3f0b5c8a7d4e1f2a3b"`
+	// Enabled - disables the webhook, or enables it again resetting its failures. Ignored on creation
+	Enabled *bool `json:"enabled,omitempty" example:"true"`
+}
+
+// WebhookDelivery - delivery of an event to a webhook, with the result of its last attempt
+type WebhookDelivery struct {
+	ID        string `json:"id" bson:"_id" example:"9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"`
+	WebhookID string `json:"webhookId" bson:"webhook_id" example:"3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b"`
+	// EventID - ID of the event, the same in all its deliveries and redeliveries
+	EventID string `json:"eventId" bson:"event_id" example:"2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d"`
+	Event   string `json:"event" bson:"event" example:"user-created"`
+	// Payload - JSON body sent to the webhook
+	Payload  string         `json:"payload" bson:"payload"`
+	Status   DeliveryStatus `json:"status" bson:"status" example:"succeeded"`
+	Attempts int            `json:"attempts" bson:"attempts" example:"1"`
+	// ResponseStatus - HTTP status code of the last attempt, 0 if there was no response
+	ResponseStatus int `json:"responseStatus,omitempty" bson:"response_status,omitempty" example:"200"`
+	// Error - error of the last attempt, empty if it succeeded
+	Error string `json:"error,omitempty" bson:"error,omitempty" example:"unexpected status 503"`
+	// RedeliveryOf - ID of the delivery sent again by this one
+	RedeliveryOf  string     `json:"redeliveryOf,omitempty" bson:"redelivery_of,omitempty" example:"8b7a6d5e-4f3a-4b2c-9d1e-0f9a8b7c6d5e"`
+	CreatedAt     time.Time  `json:"createdAt" bson:"created_at" example:"2023-05-18T16:00:00Z"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty" bson:"last_attempt_at,omitempty" example:"2023-05-18T16:00:01Z"`
+	// NextAttemptAt - time of the next attempt of the pending deliveries
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty" bson:"next_attempt_at,omitempty" example:"2023-05-18T16:00:31Z"`
+}
@@ -0,0 +1,11 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package moderation
+
+import "github.com/labstack/echo/v4"
+
+// Handler - user status moderation handlers
+type Handler interface {
+	SuspendUser(c echo.Context) error
+	BanUser(c echo.Context) error
+	ReactivateUser(c echo.Context) error
+}
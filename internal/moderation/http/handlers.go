@@ -0,0 +1,152 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/moderation"
+	"user-microservice/internal/users"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpHandler struct {
+	manager *moderation.Manager
+}
+
+var _ moderation.Handler = httpHandler{}
+var _ moderation.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new moderation http handler initialized with the manager
+func NewHttpHandler(manager *moderation.Manager) moderation.Handler {
+	return &httpHandler{manager}
+}
+
+// SuspendUser godoc
+//
+// @Summary     Suspends a user
+// @Description Suspends the user until the given time, when it's reactivated automatically. The suspended users cannot log in and their sessions are ended. A suspended user can be suspended again to change the suspension end
+// @Tags        Users
+// @Accept      json
+// @Produce     json
+// @Param       userId path     string              true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       body   body     models.StatusChange true "Suspension reason and end"
+// @Success     200    {object} models.User
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     404    {object} echo.HTTPError
+// @Failure     409    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/suspend [post]
+func (h httpHandler) SuspendUser(c echo.Context) error {
+	return h.change(c, func(ctx context.Context, userID string, body models.StatusChange) (*models.User, error) {
+		if body.Until == nil || !body.Until.After(time.Now()) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+		}
+		return h.manager.Suspend(ctx, userID, body.Reason, *body.Until)
+	})
+}
+
+// BanUser godoc
+//
+// @Summary     Bans a user
+// @Description Bans the user until it's reactivated. The banned users cannot log in and their sessions are ended
+// @Tags        Users
+// @Accept      json
+// @Produce     json
+// @Param       userId path     string              true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       body   body     models.StatusChange true "Ban reason"
+// @Success     200    {object} models.User
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     404    {object} echo.HTTPError
+// @Failure     409    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/ban [post]
+func (h httpHandler) BanUser(c echo.Context) error {
+	return h.change(c, func(ctx context.Context, userID string, body models.StatusChange) (*models.User, error) {
+		return h.manager.Ban(ctx, userID, body.Reason)
+	})
+}
+
+// ReactivateUser godoc
+//
+// @Summary     Reactivates a user
+// @Description Ends the suspension or the ban of the user
+// @Tags        Users
+// @Accept      json
+// @Produce     json
+// @Param       userId path     string              true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       body   body     models.StatusChange true "Reactivation reason"
+// @Success     200    {object} models.User
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     404    {object} echo.HTTPError
+// @Failure     409    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/reactivate [post]
+func (h httpHandler) ReactivateUser(c echo.Context) error {
+	return h.change(c, func(ctx context.Context, userID string, body models.StatusChange) (*models.User, error) {
+		return h.manager.Reactivate(ctx, userID, body.Reason)
+	})
+}
+
+// change - parses and authorizes the status change request, applies it with the given function and writes the response
+func (h httpHandler) change(c echo.Context, apply func(ctx context.Context, userID string, body models.StatusChange) (*models.User, error)) error {
+	userIDStr := c.Param("userId")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionModerate, userID.String()); err != nil {
+		return err
+	}
+
+	var body models.StatusChange
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in moderation/http.change -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if strings.TrimSpace(body.Reason) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	user, err := apply(c.Request().Context(), userID.String(), body)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		switch {
+		case errors.As(err, &httpErr):
+			return httpErr
+		case errors.Is(err, models.ErrInvalidStatusTransition):
+			logrus.Infof("Info in moderation/http.change -> %s", err)
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrInvalidStatusTransition)
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		case errors.Is(err, users.ErrVersionConflict):
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrVersionConflict)
+		}
+		return err
+	}
+
+	c.Response().Header().Set("ETag", user.ETag())
+	return c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,219 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/moderation"
+	moderationHttp "user-microservice/internal/moderation/http"
+	"user-microservice/internal/testutils"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+
+func TestStatusChanges(t *testing.T) {
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	active := models.User{ID: userID, Status: models.StatusActive, Version: 1}
+	banned := models.User{ID: userID, Status: models.StatusBanned, Version: 1}
+	admin := auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleAdmin}
+	support := auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleSupport}
+
+	for _, tc := range []struct {
+		name           string
+		handler        func(h moderation.Handler) echo.HandlerFunc
+		id             string
+		body           string
+		principal      *auth.Principal
+		mockedUser     models.User
+		mockedError    error
+		expectedCode   int
+		expectedStatus models.UserStatus
+		expectedError  error
+	}{
+		{
+			"Suspend user successfully",
+			func(h moderation.Handler) echo.HandlerFunc { return h.SuspendUser },
+			userID,
+			fmt.Sprintf(`{"reason": "Cheating", "until": %q}`, until),
+			&admin,
+			active,
+			nil,
+			http.StatusOK,
+			models.StatusSuspended,
+			nil,
+		},
+		{
+			"Suspend user without end",
+			func(h moderation.Handler) echo.HandlerFunc { return h.SuspendUser },
+			userID,
+			`{"reason": "Cheating"}`,
+			&admin,
+			active,
+			nil,
+			http.StatusBadRequest,
+			"",
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Suspend user until a past time",
+			func(h moderation.Handler) echo.HandlerFunc { return h.SuspendUser },
+			userID,
+			fmt.Sprintf(`{"reason": "Cheating", "until": %q}`, past),
+			&admin,
+			active,
+			nil,
+			http.StatusBadRequest,
+			"",
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Suspend banned user",
+			func(h moderation.Handler) echo.HandlerFunc { return h.SuspendUser },
+			userID,
+			fmt.Sprintf(`{"reason": "Cheating", "until": %q}`, until),
+			&admin,
+			banned,
+			nil,
+			http.StatusConflict,
+			"",
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrInvalidStatusTransition),
+		},
+		{
+			"Ban user successfully",
+			func(h moderation.Handler) echo.HandlerFunc { return h.BanUser },
+			userID,
+			`{"reason": "Cheating"}`,
+			&admin,
+			active,
+			nil,
+			http.StatusOK,
+			models.StatusBanned,
+			nil,
+		},
+		{
+			"Ban user without reason",
+			func(h moderation.Handler) echo.HandlerFunc { return h.BanUser },
+			userID,
+			`{"reason": " "}`,
+			&admin,
+			active,
+			nil,
+			http.StatusBadRequest,
+			"",
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Ban user without admin role",
+			func(h moderation.Handler) echo.HandlerFunc { return h.BanUser },
+			userID,
+			`{"reason": "Cheating"}`,
+			&support,
+			active,
+			nil,
+			http.StatusForbidden,
+			"",
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Ban user with wrong id",
+			func(h moderation.Handler) echo.HandlerFunc { return h.BanUser },
+			"wrong-id",
+			`{"reason": "Cheating"}`,
+			&admin,
+			active,
+			nil,
+			http.StatusBadRequest,
+			"",
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID wrong-id"),
+		},
+		{
+			"Reactivate banned user successfully",
+			func(h moderation.Handler) echo.HandlerFunc { return h.ReactivateUser },
+			userID,
+			`{"reason": "Appeal accepted"}`,
+			nil,
+			banned,
+			nil,
+			http.StatusOK,
+			models.StatusActive,
+			nil,
+		},
+		{
+			"Reactivate not found user",
+			func(h moderation.Handler) echo.HandlerFunc { return h.ReactivateUser },
+			userID,
+			`{"reason": "Appeal accepted"}`,
+			nil,
+			models.User{},
+			mongo.ErrNoDocuments,
+			http.StatusNotFound,
+			"",
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID)),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			pubsubRepo := usersMock.NewMockPubSub(ctrl)
+			handler := moderationHttp.NewHttpHandler(moderation.NewManager(usersRepo, pubsubRepo, 0))
+
+			var mockedUser *models.User
+			if tc.mockedError == nil {
+				current := tc.mockedUser
+				mockedUser = &current
+			}
+			usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(mockedUser, tc.mockedError).MaxTimes(1)
+			usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, u models.User) (*models.User, error) {
+				u.Version++
+				return &u, nil
+			}).MaxTimes(1)
+			pubsubRepo.EXPECT().NotifyStatusChanged(gomock.Any(), gomock.Any()).Return(nil).MaxTimes(1)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			if tc.principal != nil {
+				req = req.WithContext(auth.NewPrincipalContext(req.Context(), *tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("userId")
+			c.SetParamValues(tc.id)
+
+			// When
+			err := tc.handler(handler)(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+			var body models.User
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedStatus, body.Status)
+			assert.NotEmpty(t, body.StatusReason)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package http
+
+import (
+	"user-microservice/internal/moderation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendModerationRoutes - Sets the user status moderation routes for the given users echo group
+func AppendModerationRoutes(e *echo.Group, h moderation.Handler) {
+	e.POST("/:userId/suspend", h.SuspendUser)
+	e.POST("/:userId/ban", h.BanUser)
+	e.POST("/:userId/reactivate", h.ReactivateUser)
+}
@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"context"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultExpiryInterval - default time between each check of the ended suspensions
+const DefaultExpiryInterval = time.Minute
+
+// Manager - changes the status of the users (suspensions, bans and reactivations) and reactivates
+// the suspended users once their suspension ends
+type Manager struct {
+	users    users.Repository
+	pubsub   userPS.PubSub
+	interval time.Duration
+}
+
+// NewManager - returns a new Manager checking the ended suspensions every interval (DefaultExpiryInterval if it's not positive)
+func NewManager(usersRepository users.Repository, pubsubRepository userPS.PubSub, interval time.Duration) *Manager {
+	if interval <= 0 {
+		interval = DefaultExpiryInterval
+	}
+
+	return &Manager{users: usersRepository, pubsub: pubsubRepository, interval: interval}
+}
+
+// Suspend - suspends the user until the given time
+func (m *Manager) Suspend(ctx context.Context, userID, reason string, until time.Time) (*models.User, error) {
+	until = until.UTC().Truncate(time.Millisecond)
+	return m.change(ctx, userID, models.StatusSuspended, reason, &until)
+}
+
+// Ban - bans the user until it's reactivated
+func (m *Manager) Ban(ctx context.Context, userID, reason string) (*models.User, error) {
+	return m.change(ctx, userID, models.StatusBanned, reason, nil)
+}
+
+// Reactivate - ends the suspension or the ban of the user
+func (m *Manager) Reactivate(ctx context.Context, userID, reason string) (*models.User, error) {
+	return m.change(ctx, userID, models.StatusActive, reason, nil)
+}
+
+// Run - reactivates the users whose suspension ended every interval until the context is done.
+// Should be run in its own goroutine
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.ExpireSuspensions(ctx); err != nil {
+			logrus.Errorf("Error in moderation.Run -> error expiring suspensions: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireSuspensions - reactivates the users whose suspension ended, notifies it and returns how many were reactivated.
+// The users changed concurrently are skipped, they are reactivated by the next run if their suspension still ended
+func (m *Manager) ExpireSuspensions(ctx context.Context) (int, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expired, err := m.users.GetExpiredSuspensions(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	reactivated := 0
+	for _, user := range expired {
+		if !user.ExpireSuspension(now) {
+			continue
+		}
+		res, err := m.users.Update(ctx, user)
+		if err != nil {
+			logrus.Errorf("Error in moderation.ExpireSuspensions -> error reactivating user %s: %s", user.ID, err)
+			continue
+		}
+		m.notify(ctx, *res)
+		reactivated++
+	}
+	if reactivated > 0 {
+		logrus.Infof("Reactivated %d users whose suspension ended", reactivated)
+	}
+
+	return reactivated, nil
+}
+
+// change - changes the status of the user and notifies it. Returns models.ErrInvalidStatusTransition if the
+// status cannot be changed from the current one
+func (m *Manager) change(ctx context.Context, userID string, status models.UserStatus, reason string, until *time.Time) (*models.User, error) {
+	user, err := m.users.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := user.ChangeStatus(status, reason, until, time.Now().UTC().Truncate(time.Millisecond)); err != nil {
+		return nil, err
+	}
+
+	res, err := m.users.Update(ctx, *user)
+	if err != nil {
+		logrus.Errorf("Error in moderation.change -> error updating user %s: %s", userID, err)
+		return nil, err
+	}
+	m.notify(ctx, *res)
+	logrus.WithFields(logrus.Fields{"user": res.ID, "status": res.Status}).Info("User status changed")
+
+	return res, nil
+}
+
+func (m *Manager) notify(ctx context.Context, user models.User) {
+	if err := m.pubsub.NotifyStatusChanged(ctx, user); err != nil {
+		logrus.Errorf("Error in moderation.notify -> could not notify status change of user %s: %s", user.ID, err)
+	}
+}
@@ -0,0 +1,213 @@
+package moderation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/moderation"
+	"user-microservice/internal/users"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+
+func TestManager_Change(t *testing.T) {
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Millisecond)
+	active := models.User{ID: userID, Status: models.StatusActive, Version: 2}
+	banned := models.User{ID: userID, Status: models.StatusBanned, StatusReason: "Cheating", Version: 2}
+
+	for _, tc := range []struct {
+		name           string
+		change         func(m *moderation.Manager) (*models.User, error)
+		mockedUser     models.User
+		mockedError    error
+		updateError    error
+		expectedStatus models.UserStatus
+		expectedUntil  *time.Time
+		expectedError  error
+	}{
+		{
+			"Suspend active user",
+			func(m *moderation.Manager) (*models.User, error) {
+				return m.Suspend(context.Background(), userID, "Reason", until)
+			},
+			active,
+			nil,
+			nil,
+			models.StatusSuspended,
+			&until,
+			nil,
+		},
+		{
+			"Ban active user",
+			func(m *moderation.Manager) (*models.User, error) {
+				return m.Ban(context.Background(), userID, "Reason")
+			},
+			active,
+			nil,
+			nil,
+			models.StatusBanned,
+			nil,
+			nil,
+		},
+		{
+			"Reactivate banned user",
+			func(m *moderation.Manager) (*models.User, error) {
+				return m.Reactivate(context.Background(), userID, "Reason")
+			},
+			banned,
+			nil,
+			nil,
+			models.StatusActive,
+			nil,
+			nil,
+		},
+		{
+			"Suspend banned user",
+			func(m *moderation.Manager) (*models.User, error) {
+				return m.Suspend(context.Background(), userID, "Reason", until)
+			},
+			banned,
+			nil,
+			nil,
+			"",
+			nil,
+			models.ErrInvalidStatusTransition,
+		},
+		{
+			"Ban not found user",
+			func(m *moderation.Manager) (*models.User, error) {
+				return m.Ban(context.Background(), userID, "Reason")
+			},
+			models.User{},
+			mongo.ErrNoDocuments,
+			nil,
+			"",
+			nil,
+			mongo.ErrNoDocuments,
+		},
+		{
+			"Ban concurrently updated user",
+			func(m *moderation.Manager) (*models.User, error) {
+				return m.Ban(context.Background(), userID, "Reason")
+			},
+			active,
+			nil,
+			users.ErrVersionConflict,
+			"",
+			nil,
+			users.ErrVersionConflict,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			pubsubRepo := usersMock.NewMockPubSub(ctrl)
+			manager := moderation.NewManager(usersRepo, pubsubRepo, time.Minute)
+
+			var mockedUser *models.User
+			if tc.mockedError == nil {
+				current := tc.mockedUser
+				mockedUser = &current
+			}
+			usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(mockedUser, tc.mockedError)
+			var updated models.User
+			usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (*models.User, error) {
+				updated = user
+				if tc.updateError != nil {
+					return nil, tc.updateError
+				}
+				user.Version++
+				return &user, nil
+			}).MaxTimes(1)
+			notifyTimes := 0
+			if tc.expectedError == nil {
+				notifyTimes = 1
+			}
+			pubsubRepo.EXPECT().NotifyStatusChanged(gomock.Any(), gomock.Any()).Return(nil).Times(notifyTimes)
+
+			// When
+			res, err := tc.change(manager)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				assert.Nil(t, res)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, res.Status)
+			assert.Equal(t, "Reason", res.StatusReason)
+			assert.Equal(t, tc.expectedUntil, res.SuspendedUntil)
+			assert.Equal(t, tc.mockedUser.Version, updated.Version, "Expected the update to check the read version")
+		})
+	}
+}
+
+func TestManager_ExpireSuspensions(t *testing.T) {
+	ended := time.Now().Add(-time.Minute)
+	ongoing := time.Now().Add(time.Hour)
+	endedUser := models.User{ID: userID, Status: models.StatusSuspended, SuspendedUntil: &ended}
+	conflictUser := models.User{ID: "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739", Status: models.StatusSuspended, SuspendedUntil: &ended}
+	ongoingUser := models.User{ID: "5cace01f-45c3-49f0-a725-c22866874095", Status: models.StatusSuspended, SuspendedUntil: &ongoing}
+
+	for _, tc := range []struct {
+		name          string
+		mockedUsers   []models.User
+		mockedError   error
+		expectedCount int
+		expectedError error
+	}{
+		{"Expire ended suspensions", []models.User{endedUser}, nil, 1, nil},
+		{"Expire skipping the concurrently changed users", []models.User{endedUser, conflictUser, ongoingUser}, nil, 1, nil},
+		{"Expire without ended suspensions", []models.User{}, nil, 0, nil},
+		{"Expire with repository error", nil, errors.New("homemade error"), 0, errors.New("homemade error")},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			pubsubRepo := usersMock.NewMockPubSub(ctrl)
+			manager := moderation.NewManager(usersRepo, pubsubRepo, 0)
+
+			usersRepo.EXPECT().GetExpiredSuspensions(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, before time.Time) ([]models.User, error) {
+				assert.WithinDuration(t, time.Now(), before, 5*time.Second)
+				return tc.mockedUsers, tc.mockedError
+			})
+			usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (*models.User, error) {
+				if user.ID == conflictUser.ID {
+					return nil, users.ErrVersionConflict
+				}
+				assert.Equal(t, models.StatusActive, user.Status)
+				assert.Nil(t, user.SuspendedUntil)
+				return &user, nil
+			}).AnyTimes()
+			pubsubRepo.EXPECT().NotifyStatusChanged(gomock.Any(), gomock.Any()).Return(nil).Times(tc.expectedCount)
+
+			// When
+			count, err := manager.ExpireSuspensions(context.Background())
+
+			// Then
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCount, count)
+		})
+	}
+}
@@ -14,10 +14,10 @@ const (
 
 // PaginationOptions struct with the pagination data
 type PaginationOptions struct {
-	Size int `json:"size" query:"size"`
-	Page int `json:"page" query:"page"`
-	// OrderBy   string    `json:"orderBy"`   // OrderBy property to order by
-	// SortOrder SortOrder `json:"sortOrdet"` // Sort order (asc or desc)
+	Size      int       `json:"size" query:"size"`
+	Page      int       `json:"page" query:"page"`
+	OrderBy   string    `json:"orderBy" query:"orderBy"` // OrderBy property to order by, none by default
+	SortOrder SortOrder `json:"sortOrder" query:"sort"`  // Sort order (asc or desc)
 }
 
 // Paginated is used as response and has more information
@@ -62,7 +62,7 @@ func FromEchoContext(c echo.Context) (*PaginationOptions, error) {
 		}
 	}
 
-	// orderBy := c.QueryParam("orderBy")
+	orderBy := c.QueryParam("orderBy")
 
 	sortOrder := SortOrder(c.QueryParam("sort"))
 	if !sortOrder.Valid() {
@@ -70,9 +70,9 @@ func FromEchoContext(c echo.Context) (*PaginationOptions, error) {
 	}
 
 	return &PaginationOptions{
-		Page: page,
-		Size: size,
-		// OrderBy:   orderBy,
-		// SortOrder: sortOrder,
+		Page:      page,
+		Size:      size,
+		OrderBy:   orderBy,
+		SortOrder: sortOrder,
 	}, nil
 }
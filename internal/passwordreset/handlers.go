@@ -0,0 +1,10 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package passwordreset
+
+import "github.com/labstack/echo/v4"
+
+// Handler - password reset handlers
+type Handler interface {
+	RequestReset(c echo.Context) error
+	ConfirmReset(c echo.Context) error
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/passwordreset"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type httpHandler struct {
+	manager *passwordreset.Manager
+}
+
+var _ passwordreset.Handler = httpHandler{}
+var _ passwordreset.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new password reset http handler initialized with the manager
+func NewHttpHandler(manager *passwordreset.Manager) passwordreset.Handler {
+	return &httpHandler{manager}
+}
+
+// RequestReset godoc
+//
+// @Summary     Requests a password reset
+// @Description Mails a single-use password reset token to the user with the email in background. The response is the same whether the email exists or not
+// @Tags        Auth
+// @Accept      json
+// @Param       body body models.PasswordResetRequest true "User email"
+// @Success     202
+// @Failure     400 {object} echo.HTTPError
+// @Failure     429 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Router      /auth/password-reset/request [post]
+func (h httpHandler) RequestReset(c echo.Context) error {
+	var body models.PasswordResetRequest
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in passwordreset/http.RequestReset -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	if err := h.manager.Request(c.Request().Context(), body.Email); err != nil {
+		if errors.Is(err, passwordreset.ErrTooManyRequests) {
+			return echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrTooManyRequests)
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ConfirmReset godoc
+//
+// @Summary     Confirms a password reset
+// @Description Sets the new password of the user of the reset token and revokes all its sessions. The token can only be used once
+// @Tags        Auth
+// @Accept      json
+// @Param       body body models.PasswordResetConfirmation true "Reset token and new password"
+// @Success     204
+// @Failure     400 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Router      /auth/password-reset/confirm [post]
+func (h httpHandler) ConfirmReset(c echo.Context) error {
+	var body models.PasswordResetConfirmation
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in passwordreset/http.ConfirmReset -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.Token == "" || body.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	if err := h.manager.Confirm(c.Request().Context(), body.Token, body.Password); err != nil {
+		if errors.Is(err, passwordreset.ErrInvalidResetToken) {
+			logrus.Infof("Info in passwordreset/http.ConfirmReset -> %s", err)
+			return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidResetToken)
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,175 @@
+package http_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/models"
+	"user-microservice/internal/passwordreset"
+	passwordresetHttp "user-microservice/internal/passwordreset/http"
+	"user-microservice/internal/passwordreset/mock"
+	sessionsMock "user-microservice/internal/sessions/mock"
+	"user-microservice/internal/testutils"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newHandler(t *testing.T, ctrl *gomock.Controller) (passwordreset.Handler, *usersMock.MockRepository, *mock.MockRepository, *sessionsMock.MockRepository) {
+	t.Helper()
+	usersRepo := usersMock.NewMockRepository(ctrl)
+	repo := mock.NewMockRepository(ctrl)
+	sessionsRepo := sessionsMock.NewMockRepository(ctrl)
+	mr := miniredis.RunT(t)
+	limiter := passwordreset.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), 1, time.Hour)
+	manager := passwordreset.NewManager(usersRepo, repo, sessionsRepo, mail.NewLogMailer(), limiter, passwordreset.Options{URL: "https://example.com/reset-password"})
+	// the reset requests are processed in background, so they are waited before checking the mocks calls
+	t.Cleanup(manager.Wait)
+	return passwordresetHttp.NewHttpHandler(manager), usersRepo, repo, sessionsRepo
+}
+
+func TestRequestReset(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		body          string
+		requests      int
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Request reset for unknown email",
+			`{"email": "unknown@example.com"}`,
+			1,
+			http.StatusAccepted,
+			nil,
+		},
+		{
+			"Request reset exceeding the limit",
+			`{"email": "unknown@example.com"}`,
+			2,
+			http.StatusTooManyRequests,
+			echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrTooManyRequests),
+		},
+		{
+			"Request reset without email",
+			`{}`,
+			1,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			handler, usersRepo, _, _ := newHandler(t, ctrl)
+			usersRepo.EXPECT().GetByEmail(gomock.Any(), "unknown@example.com").Return(nil, mongo.ErrNoDocuments).MaxTimes(1)
+
+			// When
+			var rec *httptest.ResponseRecorder
+			var err error
+			for i := 0; i < tc.requests; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password-reset/request", strings.NewReader(tc.body))
+				req.Header.Set(echo.HeaderContentType, "application/json")
+				rec = httptest.NewRecorder()
+				err = handler.RequestReset(echo.New().NewContext(req, rec))
+			}
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestConfirmReset(t *testing.T) {
+	user := models.User{ID: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Password: "Old Password"}
+	reset := models.PasswordReset{ID: "2c6d7e8f-9a0b-4c1d-8e2f-4a5b6c7d8e9f", UserID: user.ID}
+
+	for _, tc := range []struct {
+		name          string
+		body          string
+		consumeTimes  int
+		consumeError  error
+		updateTimes   int
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Confirm reset successfully",
+			`{"token": "reset-token", "password": "New Password"}`,
+			1,
+			nil,
+			1,
+			http.StatusNoContent,
+			nil,
+		},
+		{
+			"Confirm reset with invalid token",
+			`{"token": "reset-token", "password": "New Password"}`,
+			1,
+			mongo.ErrNoDocuments,
+			0,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidResetToken),
+		},
+		{
+			"Confirm reset without password",
+			`{"token": "reset-token"}`,
+			0,
+			nil,
+			0,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			handler, usersRepo, repo, sessionsRepo := newHandler(t, ctrl)
+			repo.EXPECT().Consume(gomock.Any(), passwordreset.HashToken("reset-token"), gomock.Any()).Return(&reset, tc.consumeError).Times(tc.consumeTimes)
+			usersRepo.EXPECT().GetById(gomock.Any(), user.ID).Return(&user, nil).Times(tc.updateTimes)
+			usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, updated models.User) (*models.User, error) {
+				return &updated, nil
+			}).Times(tc.updateTimes)
+			repo.EXPECT().InvalidateAll(gomock.Any(), user.ID).Return(nil).Times(tc.updateTimes)
+			sessionsRepo.EXPECT().RevokeAll(gomock.Any(), user.ID).Return(nil).Times(tc.updateTimes)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/password-reset/confirm", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			rec := httptest.NewRecorder()
+
+			// When
+			err := handler.ConfirmReset(echo.New().NewContext(req, rec))
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package http
+
+import (
+	"user-microservice/internal/passwordreset"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendPasswordResetRoutes - Sets the password reset routes for the given auth echo group, they must not require authentication
+func AppendPasswordResetRoutes(e *echo.Group, h passwordreset.Handler) {
+	e.POST("/password-reset/request", h.RequestReset)
+	e.POST("/password-reset/confirm", h.ConfirmReset)
+}
@@ -0,0 +1,70 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// DefaultMaxRequests - default number of reset requests allowed for each email per window
+	DefaultMaxRequests = 3
+	// DefaultWindow - default time window of the reset requests limit
+	DefaultWindow = time.Hour
+)
+
+// limiterKeyPrefix - prefix of the redis keys counting the reset requests of each email
+const limiterKeyPrefix = "password-reset:limit:"
+
+// countScript - increments the counter and starts its window if it has no expiration, atomically,
+// so a counter is never left without expiration. Returns the count
+var countScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Limiter - limits the number of reset requests of each email
+type Limiter interface {
+	// Allow - counts a new request for the email and returns false if it exceeds the limit
+	Allow(ctx context.Context, email string) (bool, error)
+}
+
+// redisLimiter - fixed window Limiter counting the requests in redis
+type redisLimiter struct {
+	rc     *redis.Client
+	max    int64
+	window time.Duration
+}
+
+// NewRedisLimiter - returns a new Limiter allowing max requests of each email per window
+// (DefaultMaxRequests and DefaultWindow if they are not positive)
+func NewRedisLimiter(rc *redis.Client, max int, window time.Duration) Limiter {
+	if max <= 0 {
+		max = DefaultMaxRequests
+	}
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &redisLimiter{rc: rc, max: int64(max), window: window}
+}
+
+// Allow - increments the email counter with the count script, starting the window on its first request.
+// The emails are hashed so they are not stored in redis
+func (l *redisLimiter) Allow(ctx context.Context, email string) (bool, error) {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	key := limiterKeyPrefix + hex.EncodeToString(sum[:])
+
+	count, err := countScript.Run(ctx, l.rc, []string{key}, l.window.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return count <= l.max, nil
+}
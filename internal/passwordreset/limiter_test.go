@@ -0,0 +1,64 @@
+package passwordreset_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/passwordreset"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLimiter_Allow(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	limiter := passwordreset.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), 2, time.Hour)
+	ctx := context.Background()
+
+	// When
+	var allowed []bool
+	for _, email := range []string{"alicetingo@example.com", " AliceTingo@example.com", "alicetingo@example.com", "other@example.com"} {
+		isAllowed, err := limiter.Allow(ctx, email)
+		require.NoError(t, err)
+		allowed = append(allowed, isAllowed)
+	}
+
+	// Then
+	assert.Equal(t, []bool{true, true, false, true}, allowed, "Expected the third request of the same email to exceed the limit")
+
+	// When the window is over
+	mr.FastForward(time.Hour)
+	isAllowed, err := limiter.Allow(ctx, "alicetingo@example.com")
+
+	// Then
+	require.NoError(t, err)
+	assert.True(t, isAllowed, "Expected the email to be allowed again")
+	for _, key := range mr.Keys() {
+		assert.NotContains(t, key, "example.com", "Expected the emails not to be stored in redis")
+	}
+}
+
+func TestRedisLimiter_AllowWithoutExpiration(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	limiter := passwordreset.NewRedisLimiter(rc, 2, time.Hour)
+	ctx := context.Background()
+	_, err := limiter.Allow(ctx, "alicetingo@example.com")
+	require.NoError(t, err)
+	// the counter lost its expiration, e.g. it was set by a replica stopped before setting it
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	require.NoError(t, rc.Persist(ctx, keys[0]).Err())
+
+	// When
+	isAllowed, err := limiter.Allow(ctx, "alicetingo@example.com")
+
+	// Then
+	require.NoError(t, err)
+	assert.True(t, isAllowed, "Expected the second request to be allowed")
+	assert.Equalf(t, time.Hour, mr.TTL(keys[0]), "Expected the counter to expire in 1h, but was %s", mr.TTL(keys[0]))
+}
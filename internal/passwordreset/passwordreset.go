@@ -0,0 +1,217 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/models"
+	"user-microservice/internal/sessions"
+	"user-microservice/internal/users"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultTokenTTL - default time a reset token is valid
+const DefaultTokenTTL = time.Hour
+
+var (
+	// ErrInvalidResetToken - the reset token does not exist, is expired or was already used
+	ErrInvalidResetToken = errors.New("invalid reset token")
+	// ErrTooManyRequests - too many resets were requested for the email
+	ErrTooManyRequests = errors.New("too many password reset requests")
+)
+
+// Options - Manager options
+type Options struct {
+	// TokenTTL - time a reset token is valid, DefaultTokenTTL if it's not positive
+	TokenTTL time.Duration
+	// URL - page where the users set their new password, the token is added as the "token" query param
+	URL string
+}
+
+// Manager - sends the password reset tokens and resets the passwords
+type Manager struct {
+	users      users.Repository
+	repository Repository
+	sessions   sessions.Repository
+	mailer     mail.Mailer
+	limiter    Limiter
+	opts       Options
+	// pending - reset requests being processed in background
+	pending sync.WaitGroup
+}
+
+// NewManager - returns a new Manager
+func NewManager(usersRepository users.Repository, repository Repository, sessionsRepository sessions.Repository, mailer mail.Mailer, limiter Limiter, opts Options) *Manager {
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = DefaultTokenTTL
+	}
+	return &Manager{
+		users:      usersRepository,
+		repository: repository,
+		sessions:   sessionsRepository,
+		mailer:     mailer,
+		limiter:    limiter,
+		opts:       opts,
+	}
+}
+
+// Request - mails a new reset token to the user with the email in background, invalidating the previous ones.
+// Returns ErrTooManyRequests if the email exceeds the requests limit. In order not to reveal whether the
+// email exists, nothing else is checked before returning, so the response time is the same whether there is
+// a user with the email or not, and the errors of the background work are only logged
+func (m *Manager) Request(ctx context.Context, email string) error {
+	allowed, err := m.limiter.Allow(ctx, email)
+	if err != nil {
+		logrus.Errorf("Error in passwordreset.Request -> error checking the requests limit: %s", err)
+		return err
+	}
+	if !allowed {
+		return ErrTooManyRequests
+	}
+
+	detached := audit.Detach(ctx)
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		m.sendToken(detached, email)
+	}()
+
+	return nil
+}
+
+// Wait - waits for the reset requests being processed in background
+func (m *Manager) Wait() {
+	m.pending.Wait()
+}
+
+// sendToken - creates a new reset token for the user with the email, invalidating the previous ones, and mails it
+func (m *Manager) sendToken(ctx context.Context, email string) {
+	user, err := m.users.GetByEmail(ctx, email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			logrus.Infof("Info in passwordreset.sendToken -> password reset requested for unknown email")
+			return
+		}
+		logrus.Errorf("Error in passwordreset.sendToken -> error getting user: %s", err)
+		return
+	}
+
+	if err := m.repository.InvalidateAll(ctx, user.ID); err != nil {
+		return
+	}
+	token, err := newToken()
+	if err != nil {
+		logrus.Errorf("Error in passwordreset.sendToken -> error generating token: %s", err)
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if _, err := m.repository.Create(ctx, models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.opts.TokenTTL),
+	}); err != nil {
+		return
+	}
+
+	if err := m.mailer.Send(ctx, m.message(*user, token)); err != nil {
+		logrus.Errorf("Error in passwordreset.sendToken -> error sending mail to user %s: %s", user.ID, err)
+	}
+}
+
+// Confirm - sets the new password of the user of the reset token and revokes all its sessions.
+// Returns ErrInvalidResetToken if the token does not exist, is expired or was already used.
+// The token is released if the password cannot be set, so it can be retried
+func (m *Manager) Confirm(ctx context.Context, token, password string) error {
+	// the context keeps the audit info of the request, but it is not cancelled with it so the reset is completed
+	ctx = audit.Detach(ctx)
+	reset, err := m.repository.Consume(ctx, HashToken(token), time.Now())
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	user, err := m.users.GetById(ctx, reset.UserID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return fmt.Errorf("%w: user %s not found", ErrInvalidResetToken, reset.UserID)
+		}
+		logrus.Errorf("Error in passwordreset.Confirm -> error getting user: %s", err)
+		m.release(ctx, *reset)
+		return err
+	}
+
+	// the change is audited as made by the user itself
+	info := audit.FromContext(ctx)
+	info.Actor = user.ID
+	ctx = audit.NewContext(ctx, info)
+
+	user.Password = password
+	if _, err := m.users.Update(ctx, *user); err != nil {
+		logrus.Errorf("Error in passwordreset.Confirm -> error updating user %s: %s", user.ID, err)
+		m.release(ctx, *reset)
+		return err
+	}
+	if err := m.repository.InvalidateAll(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := m.sessions.RevokeAll(ctx, user.ID); err != nil {
+		return err
+	}
+	logrus.WithField("user", user.ID).Info("Password reset")
+
+	return nil
+}
+
+// release - releases the consumed reset, the errors are only logged
+func (m *Manager) release(ctx context.Context, reset models.PasswordReset) {
+	if err := m.repository.Release(ctx, reset); err != nil {
+		logrus.Errorf("Error in passwordreset.release -> error releasing reset %s: %s", reset.ID, err)
+	}
+}
+
+// message - returns the mail with the reset link
+func (m *Manager) message(user models.User, token string) mail.Message {
+	link := m.opts.URL
+	if parsed, err := url.Parse(m.opts.URL); err == nil {
+		query := parsed.Query()
+		query.Set("token", token)
+		parsed.RawQuery = query.Encode()
+		link = parsed.String()
+	}
+
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the following link to set a new password, it expires in %s:\n\n%s\n\nIf you did not request it, you can ignore this email.",
+			user.FirstName, m.opts.TokenTTL, link),
+	}
+}
+
+// newToken - returns a new random reset token
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// HashToken - returns the hex encoded SHA-256 hash of the reset token, the only thing stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,236 @@
+package passwordreset_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/models"
+	"user-microservice/internal/passwordreset"
+	"user-microservice/internal/passwordreset/mock"
+	sessionsMock "user-microservice/internal/sessions/mock"
+	usersMock "user-microservice/internal/users/mock"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+
+// fakeMailer - mail.Mailer recording the sent messages
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return m.err
+}
+
+type mocks struct {
+	users    *usersMock.MockRepository
+	repo     *mock.MockRepository
+	sessions *sessionsMock.MockRepository
+	mailer   *fakeMailer
+}
+
+func newManager(t *testing.T, maxRequests int) (*passwordreset.Manager, mocks) {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	m := mocks{
+		users:    usersMock.NewMockRepository(ctrl),
+		repo:     mock.NewMockRepository(ctrl),
+		sessions: sessionsMock.NewMockRepository(ctrl),
+		mailer:   &fakeMailer{},
+	}
+	mr := miniredis.RunT(t)
+	limiter := passwordreset.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), maxRequests, time.Hour)
+	opts := passwordreset.Options{TokenTTL: 30 * time.Minute, URL: "https://example.com/reset-password?lang=en"}
+	return passwordreset.NewManager(m.users, m.repo, m.sessions, m.mailer, limiter, opts), m
+}
+
+func TestManager_Request(t *testing.T) {
+	user := models.User{ID: userID, FirstName: "Alice", Email: "alicetingo@example.com"}
+
+	for _, tc := range []struct {
+		name          string
+		mockedUser    *models.User
+		mockedError   error
+		mailError     error
+		requests      int
+		expectedMails int
+		expectedError error
+	}{
+		{"Request reset successfully", &user, nil, nil, 1, 1, nil},
+		{"Request reset for unknown email", nil, mongo.ErrNoDocuments, nil, 1, 0, nil},
+		{"Request reset with users error", nil, errors.New("homemade error"), nil, 1, 0, nil},
+		{"Request reset with mail error", &user, nil, errors.New("homemade error"), 1, 1, nil},
+		{"Request reset exceeding the limit", &user, nil, nil, 3, 2, passwordreset.ErrTooManyRequests},
+		{"Request reset for unknown email exceeding the limit", nil, mongo.ErrNoDocuments, nil, 3, 0, passwordreset.ErrTooManyRequests},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			manager, m := newManager(t, 2)
+			m.mailer.err = tc.mailError
+			ctx := context.Background()
+			allowedRequests := tc.requests
+			if allowedRequests > 2 {
+				allowedRequests = 2
+			}
+			createTimes := 0
+			if tc.mockedUser != nil {
+				createTimes = allowedRequests
+			}
+
+			// the tokens are created and mailed in background, without the request cancellation
+			m.users.EXPECT().GetByEmail(gomock.Any(), user.Email).Return(tc.mockedUser, tc.mockedError).Times(allowedRequests)
+			m.repo.EXPECT().InvalidateAll(gomock.Any(), userID).Return(nil).Times(createTimes)
+			var created []models.PasswordReset
+			m.repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, reset models.PasswordReset) (*models.PasswordReset, error) {
+				created = append(created, reset)
+				return &reset, nil
+			}).Times(createTimes)
+
+			// When
+			var err error
+			for i := 0; i < tc.requests; i++ {
+				err = manager.Request(ctx, user.Email)
+				manager.Wait()
+			}
+
+			// Then
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, m.mailer.sent, tc.expectedMails)
+			for i, msg := range m.mailer.sent {
+				assert.Equal(t, user.Email, msg.To)
+				assert.Equal(t, userID, created[i].UserID)
+				assert.WithinDuration(t, time.Now().Add(30*time.Minute), created[i].ExpiresAt, 5*time.Second)
+
+				start := strings.Index(msg.Body, "https://")
+				require.NotEqual(t, -1, start, "Expected the mail to have the reset link")
+				link, err := url.Parse(strings.Fields(msg.Body[start:])[0])
+				require.NoError(t, err)
+				assert.Equal(t, "en", link.Query().Get("lang"), "Expected the URL query params to be kept")
+				assert.Equal(t, created[i].TokenHash, passwordreset.HashToken(link.Query().Get("token")), "Expected only the token hash to be stored")
+			}
+		})
+	}
+}
+
+func TestManager_Confirm(t *testing.T) {
+	usedAt := time.Now().UTC().Truncate(time.Millisecond)
+	reset := models.PasswordReset{ID: "2c6d7e8f-9a0b-4c1d-8e2f-4a5b6c7d8e9f", UserID: userID, UsedAt: &usedAt}
+	user := models.User{ID: userID, Email: "alicetingo@example.com", Password: "Old Password", Version: 3}
+
+	for _, tc := range []struct {
+		name          string
+		consumeError  error
+		userError     error
+		shouldUpdate  bool
+		updateError   error
+		shouldRelease bool
+		expectedError error
+	}{
+		{"Confirm reset successfully", nil, nil, true, nil, false, nil},
+		{"Confirm reset with invalid token", mongo.ErrNoDocuments, nil, false, nil, false, passwordreset.ErrInvalidResetToken},
+		{"Confirm reset of deleted user", nil, mongo.ErrNoDocuments, false, nil, false, passwordreset.ErrInvalidResetToken},
+		{"Confirm reset with error getting the user", nil, errors.New("homemade error"), false, nil, true, errors.New("homemade error")},
+		{"Confirm reset with error updating the user", nil, nil, true, errors.New("homemade error"), true, errors.New("homemade error")},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			manager, m := newManager(t, 2)
+			ctx := audit.NewContext(context.Background(), audit.Info{Actor: audit.ActorAnonymous, RequestID: "reset-request-id"})
+			updateTimes := 0
+			if tc.shouldUpdate {
+				updateTimes = 1
+			}
+			getTimes := 0
+			if tc.consumeError == nil {
+				getTimes = 1
+			}
+			releaseTimes := 0
+			if tc.shouldRelease {
+				releaseTimes = 1
+			}
+			completeTimes := 0
+			if tc.shouldUpdate && tc.updateError == nil {
+				completeTimes = 1
+			}
+
+			m.repo.EXPECT().Consume(gomock.Any(), passwordreset.HashToken("reset-token"), gomock.Any()).Return(&reset, tc.consumeError)
+			m.users.EXPECT().GetById(gomock.Any(), userID).Return(&user, tc.userError).Times(getTimes)
+			m.users.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, updated models.User) (*models.User, error) {
+				assert.Equal(t, "New Password", updated.Password)
+				assert.Equal(t, user.Version, updated.Version)
+				assert.Equal(t, audit.Info{Actor: userID, RequestID: "reset-request-id"}, audit.FromContext(ctx), "Expected the change to be audited as made by the user")
+				return &updated, tc.updateError
+			}).Times(updateTimes)
+			m.repo.EXPECT().Release(gomock.Any(), reset).Return(nil).Times(releaseTimes)
+			m.repo.EXPECT().InvalidateAll(gomock.Any(), userID).Return(nil).Times(completeTimes)
+			m.sessions.EXPECT().RevokeAll(gomock.Any(), userID).Return(nil).Times(completeTimes)
+
+			// When
+			err := manager.Confirm(ctx, "reset-token", "New Password")
+
+			// Then
+			if tc.expectedError != nil {
+				if errors.Is(tc.expectedError, passwordreset.ErrInvalidResetToken) {
+					assert.ErrorIs(t, err, tc.expectedError)
+				} else {
+					assert.Equal(t, tc.expectedError, err)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestManager_ConfirmWithCancelledRequest(t *testing.T) {
+	// Given
+	manager, m := newManager(t, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	user := models.User{ID: userID, Email: "alicetingo@example.com", Password: "Old Password", Version: 3}
+	m.repo.EXPECT().Consume(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.PasswordReset{ID: "2c6d7e8f-9a0b-4c1d-8e2f-4a5b6c7d8e9f", UserID: userID}, nil)
+	// the request is cancelled while the reset is in progress
+	m.users.EXPECT().GetById(gomock.Any(), userID).DoAndReturn(func(context.Context, string, ...string) (*models.User, error) {
+		cancel()
+		return &user, nil
+	})
+	m.users.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, updated models.User) (*models.User, error) {
+		assert.NoError(t, ctx.Err(), "Expected the update not to be cancelled with the request")
+		return &updated, nil
+	})
+	m.repo.EXPECT().InvalidateAll(gomock.Any(), userID).Return(nil)
+	m.sessions.EXPECT().RevokeAll(gomock.Any(), userID).Return(nil)
+
+	// When
+	err := manager.Confirm(ctx, "reset-token", "New Password")
+
+	// Then
+	assert.NoError(t, err)
+}
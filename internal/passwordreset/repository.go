@@ -0,0 +1,22 @@
+//go:generate mockgen -source repository.go -destination mock/repository_mock.go -package mock
+package passwordreset
+
+import (
+	"context"
+	"time"
+	"user-microservice/internal/models"
+)
+
+// Repository - password reset tokens repository
+type Repository interface {
+	// Create - inserts the reset, setting its ID
+	Create(ctx context.Context, reset models.PasswordReset) (*models.PasswordReset, error)
+	// Consume - marks the not used nor expired reset with the token hash as used and returns it,
+	// or mongo.ErrNoDocuments if there is none. Only one of the concurrent consumptions succeeds
+	Consume(ctx context.Context, hash string, now time.Time) (*models.PasswordReset, error)
+	// Release - marks the consumed reset as not used again, unless it was used or invalidated afterwards,
+	// so its token can be retried when the password could not be set
+	Release(ctx context.Context, reset models.PasswordReset) error
+	// InvalidateAll - marks all the not used resets of the user as used
+	InvalidateAll(ctx context.Context, userID string) error
+}
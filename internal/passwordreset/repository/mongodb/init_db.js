@@ -0,0 +1,39 @@
+db.password_resets.createIndex({ token_hash: 1 }, { unique: true });
+db.password_resets.createIndex({ user_id: 1 });
+db.password_resets.createIndex({ expires_at: 1 }, { expireAfterSeconds: 0 });
+
+db.password_resets.insertMany([
+  {
+    // token "valid-reset-token"
+    _id: "2c6d7e8f-9a0b-4c1d-8e2f-4a5b6c7d8e9f",
+    user_id: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+    token_hash: "79902197833df66c53a7e9a88601f58cb91f4ec72bd113b8b5d686e6ca1dc3bc",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+  },
+  {
+    // token "used-reset-token"
+    _id: "3d7e8f9a-0b1c-4d2e-9f3a-5b6c7d8e9f0a",
+    user_id: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+    token_hash: "8205601e8152da142931880206bcb4e93ed22ee960be08ea3f19adf76fcb47e1",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+    used_at: new Date("2022-05-18T16:10:00Z"),
+  },
+  {
+    // token "expired-reset-token"
+    _id: "4e8f9a0b-1c2d-4e3f-8a4b-6c7d8e9f0a1b",
+    user_id: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+    token_hash: "5bb79ac95be343e8bb144fc1d2d97ca3703a3ef41f5a91f28c301ec62401e9f4",
+    created_at: new Date("2020-05-18T16:00:00Z"),
+    expires_at: new Date("2020-05-18T17:00:00Z"),
+  },
+  {
+    // token "invalidated-reset-token"
+    _id: "5f9a0b1c-2d3e-4f4a-9b5c-7d8e9f0a1b2c",
+    user_id: "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739",
+    token_hash: "30b22a6aa2641934a428b6b731315b2aa304409173edefd44e8483240d12790a",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+  },
+]);
@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"context"
+	"strings"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/passwordreset"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongodbCollection - collection of the password resets, with a unique index on the token hash
+// and a TTL index removing the expired ones
+const mongodbCollection = "password_resets"
+
+type mongodbRepository struct {
+	db *mongo.Collection
+}
+
+var _ passwordreset.Repository = mongodbRepository{}
+var _ passwordreset.Repository = (*mongodbRepository)(nil)
+
+// NewMongoDBRepository - returns a new instance for the password resets mongodb repository
+func NewMongoDBRepository(db *mongo.Database) passwordreset.Repository {
+	return &mongodbRepository{db.Collection(mongodbCollection)}
+}
+
+// Create - inserts the reset into the database and returns the inserted version
+func (r mongodbRepository) Create(ctx context.Context, reset models.PasswordReset) (*models.PasswordReset, error) {
+	reset.ID = strings.ToLower(uuid.New().String())
+	reset.UserID = strings.ToLower(reset.UserID)
+	reset.UsedAt = nil
+
+	if _, err := r.db.InsertOne(ctx, &reset); err != nil {
+		logrus.Errorf("Error in passwordreset/repository/mongodb.Create -> error: %s", err)
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// Consume - atomically sets the usage date of the valid reset with the token hash
+func (r mongodbRepository) Consume(ctx context.Context, hash string, now time.Time) (*models.PasswordReset, error) {
+	filter := bson.M{"token_hash": hash, "used_at": nil, "expires_at": bson.M{"$gt": now}}
+	update := bson.M{"$set": bson.M{"used_at": now.UTC().Truncate(time.Millisecond)}}
+
+	var reset models.PasswordReset
+	err := r.db.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&reset)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in passwordreset/repository/mongodb.Consume -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// Release - unsets the usage date of the reset if it's still the one set when it was consumed
+func (r mongodbRepository) Release(ctx context.Context, reset models.PasswordReset) error {
+	if reset.UsedAt == nil {
+		return nil
+	}
+	filter := bson.M{"_id": reset.ID, "used_at": reset.UsedAt.UTC().Truncate(time.Millisecond)}
+	if _, err := r.db.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"used_at": ""}}); err != nil {
+		logrus.Errorf("Error in passwordreset/repository/mongodb.Release -> error: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// InvalidateAll - sets the usage date of all the not used resets of the user
+func (r mongodbRepository) InvalidateAll(ctx context.Context, userID string) error {
+	filter := bson.M{"user_id": strings.ToLower(userID), "used_at": nil}
+	if _, err := r.db.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"used_at": time.Now().UTC()}}); err != nil {
+		logrus.Errorf("Error in passwordreset/repository/mongodb.InvalidateAll -> error: %s", err)
+		return err
+	}
+
+	return nil
+}
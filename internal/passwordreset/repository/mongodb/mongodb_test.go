@@ -0,0 +1,133 @@
+package mongodb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/passwordreset"
+	"user-microservice/internal/passwordreset/repository/mongodb"
+	"user-microservice/internal/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var dbClientTest *mongo.Client
+
+func TestMain(m *testing.M) {
+	dbClientTest = new(mongo.Client)
+	testutils.ExecuteTestMain(m, dbClientTest)
+}
+
+func TestMongoDBRepository_Create(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	reset := models.PasswordReset{
+		UserID:    uuid.New().String(),
+		TokenHash: passwordreset.HashToken("created-reset-token"),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	// When
+	res, err := repo.Create(ctx, reset)
+
+	// Then
+	require.NoError(t, err)
+	require.NotNil(t, res, "Expected res not to be nil")
+	assert.NotEmpty(t, res.ID, "Expected ID not to be empty")
+
+	// When the same token hash is inserted again
+	_, err = repo.Create(ctx, reset)
+
+	// Then
+	assert.Truef(t, mongo.IsDuplicateKeyError(err), "Expected duplicate key error, but was %v", err)
+}
+
+func TestMongoDBRepository_Consume(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		token         string
+		expectedID    string
+		expectedError error
+	}{
+		{"Consume used token", "used-reset-token", "", mongo.ErrNoDocuments},
+		{"Consume expired token", "expired-reset-token", "", mongo.ErrNoDocuments},
+		{"Consume unknown token", "unknown-reset-token", "", mongo.ErrNoDocuments},
+		{"Consume valid token", "valid-reset-token", "2c6d7e8f-9a0b-4c1d-8e2f-4a5b6c7d8e9f", nil},
+		{"Consume valid token twice", "valid-reset-token", "", mongo.ErrNoDocuments},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+
+			// When
+			res, err := repo.Consume(context.TODO(), passwordreset.HashToken(tc.token), time.Now())
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+				assert.Equalf(t, tc.expectedError, err, "Expected error to be %s, but was %v", tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, res, "Expected res not to be nil")
+				assert.Equal(t, tc.expectedID, res.ID)
+				assert.NotNil(t, res.UsedAt, "Expected UsedAt to be set")
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_Release(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	reset, err := repo.Create(ctx, models.PasswordReset{
+		UserID:    "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+		TokenHash: passwordreset.HashToken("released-reset-token"),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	consumed, err := repo.Consume(ctx, reset.TokenHash, time.Now())
+	require.NoError(t, err)
+
+	// When
+	err = repo.Release(ctx, *consumed)
+
+	// Then
+	require.NoError(t, err)
+	res, err := repo.Consume(ctx, reset.TokenHash, time.Now().Add(time.Second))
+	require.NoErrorf(t, err, "Expected the released token to be consumed again, but was %v", err)
+	assert.Equal(t, reset.ID, res.ID)
+
+	// When the reset was used again after the first consumption
+	err = repo.Release(ctx, *consumed)
+
+	// Then
+	require.NoError(t, err)
+	res, err = repo.Consume(ctx, reset.TokenHash, time.Now().Add(2*time.Second))
+	assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+	assert.Equalf(t, mongo.ErrNoDocuments, err, "Expected the token used again not to be released, but was %v", err)
+}
+
+func TestMongoDBRepository_InvalidateAll(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+
+	// When
+	err := repo.InvalidateAll(ctx, "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739")
+
+	// Then
+	require.NoError(t, err)
+	res, err := repo.Consume(ctx, passwordreset.HashToken("invalidated-reset-token"), time.Now())
+	assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+	assert.Equalf(t, mongo.ErrNoDocuments, err, "Expected the invalidated token not to be consumed, but was %v", err)
+}
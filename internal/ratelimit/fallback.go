@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type fallbackStore struct {
+	primary  Store
+	fallback Store
+}
+
+var _ Store = fallbackStore{}
+var _ Store = (*fallbackStore)(nil)
+
+// NewFallbackStore - returns a new Store taking the tokens from the primary store, and from the fallback one
+// when the primary fails (e.g. redis is unavailable), so the clients are still limited
+func NewFallbackStore(primary, fallback Store) Store {
+	return &fallbackStore{primary, fallback}
+}
+
+// Take - takes a token from the primary store, or from the fallback one if the primary fails
+func (fs fallbackStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := fs.primary.Take(ctx, key, limit)
+	if err == nil {
+		return res, nil
+	}
+	logrus.Errorf("Error in ratelimit.Take -> error taking token from primary store, using the fallback: %s", err)
+
+	return fs.fallback.Take(ctx, key, limit)
+}
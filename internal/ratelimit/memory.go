@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// maxMemoryBuckets - number of buckets kept in memory before removing the least recently used ones
+const maxMemoryBuckets = 10000
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// tokensAt - returns the tokens of the bucket at the given time, refilled since its last take
+func (b bucket) tokensAt(now time.Time) float64 {
+	refilled := float64(now.Sub(b.last)) / float64(time.Millisecond) * b.limit.ratePerMs()
+	return math.Min(float64(b.limit.Capacity()), b.tokens+refilled)
+}
+
+type memoryStore struct {
+	mu         sync.Mutex
+	maxBuckets int
+	buckets    map[string]*list.Element
+	// recent - buckets by last take, the most recent first
+	recent *list.List
+}
+
+var _ Store = (*memoryStore)(nil)
+
+// NewMemoryStore - returns a new Store keeping the buckets in memory, so each replica limits the clients on its own
+func NewMemoryStore() Store {
+	return NewMemoryStoreWithSize(maxMemoryBuckets)
+}
+
+// NewMemoryStoreWithSize - returns a new Store keeping at most maxBuckets buckets in memory. When it is full,
+// the least recently used bucket is removed, as it is the one that has been refilling the longest
+func NewMemoryStoreWithSize(maxBuckets int) Store {
+	return &memoryStore{maxBuckets: maxBuckets, buckets: map[string]*list.Element{}, recent: list.New()}
+}
+
+// Take - takes a token from the bucket of the key, creating it full if it does not exist
+func (ms *memoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	element, isOK := ms.buckets[key]
+	if isOK {
+		ms.recent.MoveToFront(element)
+	} else {
+		if ms.recent.Len() >= ms.maxBuckets {
+			ms.removeOldest()
+		}
+		element = ms.recent.PushFront(&bucket{key: key, tokens: float64(limit.Capacity()), last: now})
+		ms.buckets[key] = element
+	}
+	b := element.Value.(*bucket)
+	b.limit = limit
+	b.tokens = b.tokensAt(now)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(allowed, b.tokens, limit), nil
+}
+
+// removeOldest - removes the least recently used bucket
+func (ms *memoryStore) removeOldest() {
+	oldest := ms.recent.Back()
+	if oldest == nil {
+		return
+	}
+	ms.recent.Remove(oldest)
+	delete(ms.buckets, oldest.Value.(*bucket).key)
+}
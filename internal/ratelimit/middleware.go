@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Middleware - returns an echo middleware limiting the requests of each client to the group routes with a token
+// bucket, see ClientKey. The responses have the RateLimit-* headers, and the requests over the limit get 429 with
+// the Retry-After header. The requests are not limited if the limit is not enabled or the store fails.
+// It must be used after the auth middlewares, so the authenticated clients are limited by their identity
+func Middleware(store Store, group string, limit Limit) echo.MiddlewareFunc {
+	return middleware(store, group, limit, ClientKey)
+}
+
+// IPMiddleware - returns an echo middleware limiting the requests of each IP to the group routes like Middleware.
+// It must be used before the auth middlewares, so the requests with invalid tokens or API keys are limited too
+func IPMiddleware(store Store, group string, limit Limit) echo.MiddlewareFunc {
+	return middleware(store, group, limit, ipKey)
+}
+
+// middleware - returns an echo middleware limiting the requests to the group routes by the key of the request
+func middleware(store Store, group string, limit Limit, key func(c echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if !limit.Enabled() {
+			return next
+		}
+		return func(c echo.Context) error {
+			res, err := store.Take(c.Request().Context(), group+":"+key(c), limit)
+			if err != nil {
+				logrus.Errorf("Error in ratelimit.Middleware -> error taking token: %s", err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderLimit, strconv.Itoa(limit.Capacity()))
+			header.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+			header.Set(HeaderReset, strconv.Itoa(seconds(res.Reset)))
+			if !res.Allowed {
+				header.Set(echo.HeaderRetryAfter, strconv.Itoa(seconds(res.RetryAfter)))
+				return echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrTooManyRequests)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// ClientKey - returns the key identifying the client of the request: the API key name or the token subject of the
+// authenticated requests, and the IP of the rest. The IP is the one of the echo IPExtractor, so the forwarded
+// headers are only trusted from the configured proxies
+func ClientKey(c echo.Context) string {
+	if principal, isOK := auth.PrincipalFromContext(c.Request().Context()); isOK {
+		// the API keys subjects are already prefixed with "apikey:"
+		if strings.HasPrefix(principal.Subject, "apikey:") {
+			return principal.Subject
+		}
+		return "user:" + principal.Subject
+	}
+	return ipKey(c)
+}
+
+// ipKey - returns the key identifying the IP of the request
+func ipKey(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// seconds - returns the duration in whole seconds, rounded up
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
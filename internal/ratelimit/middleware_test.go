@@ -0,0 +1,157 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/clientip"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/ratelimit"
+	"user-microservice/internal/testutils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore - ratelimit.Store failing on every take
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("homemade error")
+}
+
+func TestMiddleware(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 2, Period: time.Minute}
+	user := auth.Principal{Subject: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Role: auth.RoleUser}
+
+	for _, tc := range []struct {
+		name              string
+		store             ratelimit.Store
+		limit             ratelimit.Limit
+		requests          int
+		expectedCalls     int
+		expectedRemaining string
+		expectedError     error
+	}{
+		{"Requests under the limit", ratelimit.NewMemoryStore(), limit, 2, 2, "0", nil},
+		{
+			"Requests over the limit",
+			ratelimit.NewMemoryStore(),
+			limit,
+			3,
+			2,
+			"0",
+			echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrTooManyRequests),
+		},
+		{"Requests without limit", ratelimit.NewMemoryStore(), ratelimit.Limit{}, 3, 3, "", nil},
+		{"Requests with failing store", failingStore{}, limit, 3, 3, "", nil},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			e := echo.New()
+			calls := 0
+			handler := ratelimit.Middleware(tc.store, "users", tc.limit)(func(c echo.Context) error {
+				calls++
+				return c.NoContent(http.StatusOK)
+			})
+
+			// When
+			var rec *httptest.ResponseRecorder
+			var err error
+			for i := 0; i < tc.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req = req.WithContext(auth.NewPrincipalContext(req.Context(), user))
+				rec = httptest.NewRecorder()
+				err = handler(e.NewContext(req, rec))
+			}
+
+			// Then
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Equal(t, tc.expectedRemaining, rec.Header().Get(ratelimit.HeaderRemaining))
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, http.StatusTooManyRequests, rec.Code, tc.expectedError, err)
+				assert.Equal(t, "2", rec.Header().Get(ratelimit.HeaderLimit))
+				assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+				assert.Equal(t, "60", rec.Header().Get(ratelimit.HeaderReset))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+}
+
+func TestIPMiddleware(t *testing.T) {
+	// Given
+	e := echo.New()
+	calls := 0
+	handler := ratelimit.IPMiddleware(ratelimit.NewMemoryStore(), "ip", ratelimit.Limit{Requests: 2, Period: time.Minute})(
+		func(c echo.Context) error {
+			calls++
+			return c.NoContent(http.StatusOK)
+		},
+	)
+
+	// When each request has a different subject
+	var rec *httptest.ResponseRecorder
+	var err error
+	for _, subject := range []string{"apikey:garbage", "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", "3ec0c5ab-a9d3-4d2f-a4e5-8fc4e2b9d3f6"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:4321"
+		req = req.WithContext(auth.NewPrincipalContext(req.Context(), auth.Principal{Subject: subject}))
+		rec = httptest.NewRecorder()
+		err = handler(e.NewContext(req, rec))
+	}
+
+	// Then
+	assert.Equal(t, 2, calls)
+	testutils.AssertExpectedErrorsHttpReponse(t, http.StatusTooManyRequests, rec.Code,
+		echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrTooManyRequests), err)
+}
+
+func TestClientKey(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		principal    *auth.Principal
+		forwardedFor string
+		expectedKey  string
+	}{
+		{"Key of token", &auth.Principal{Subject: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Role: auth.RoleUser}, "", "user:ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"},
+		{"Key of API key", &auth.Principal{Subject: "apikey:matchmaking", Scopes: []string{auth.ScopeUsersRead}}, "", "apikey:matchmaking"},
+		{"Key of anonymous request", nil, "", "ip:192.0.2.1"},
+		{"Key of anonymous request with spoofed X-Forwarded-For", nil, "198.51.100.7", "ip:192.0.2.1"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			e := echo.New()
+			extractor, err := clientip.NewExtractor(nil)
+			require.NoError(t, err)
+			e.IPExtractor = extractor
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:4321"
+			if tc.forwardedFor != "" {
+				req.Header.Set(echo.HeaderXForwardedFor, tc.forwardedFor)
+			}
+			if tc.principal != nil {
+				req = req.WithContext(auth.NewPrincipalContext(req.Context(), *tc.principal))
+			}
+
+			// When
+			key := ratelimit.ClientKey(e.NewContext(req, httptest.NewRecorder()))
+
+			// Then
+			assert.Equal(t, tc.expectedKey, key)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rate limit response headers, from the IETF RateLimit header fields draft
+const (
+	// HeaderLimit - maximum number of requests the client can make at once (bucket capacity)
+	HeaderLimit = "RateLimit-Limit"
+	// HeaderRemaining - number of requests the client can still make at once
+	HeaderRemaining = "RateLimit-Remaining"
+	// HeaderReset - seconds until the client can make the maximum number of requests again
+	HeaderReset = "RateLimit-Reset"
+)
+
+// Limit - token bucket limit: each client can make Requests per Period, with bursts of up to Burst requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	// Burst - capacity of the bucket, Requests if it's not positive
+	Burst int
+}
+
+// Enabled - returns false if the limit has no requests or period, then the requests are not limited
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// Capacity - returns the maximum number of requests a client can make at once
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// ratePerMs - returns the tokens added to the bucket each millisecond
+func (l Limit) ratePerMs() float64 {
+	return float64(l.Requests) / (float64(l.Period) / float64(time.Millisecond))
+}
+
+// Result - result of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// Remaining - whole tokens left in the bucket
+	Remaining int
+	// RetryAfter - time until the next token, zero if the request is allowed
+	RetryAfter time.Duration
+	// Reset - time until the bucket is full again
+	Reset time.Duration
+}
+
+// Store - storage of the token buckets
+type Store interface {
+	// Take - takes a token from the bucket of the key, refilling it first with the tokens added since the last take
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// newResult - returns the result of a take leaving the given tokens in the bucket
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	rate := limit.ratePerMs()
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(limit.Capacity())-tokens)/rate)) * time.Millisecond,
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1-tokens)/rate)) * time.Millisecond
+	}
+	return res
+}
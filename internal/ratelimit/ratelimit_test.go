@@ -0,0 +1,129 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/ratelimit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore_Take(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	now := time.Date(2023, 5, 25, 16, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+	store := ratelimit.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	limit := ratelimit.Limit{Requests: 2, Period: time.Second}
+	ctx := context.Background()
+
+	// When
+	var results []ratelimit.Result
+	for i := 0; i < 3; i++ {
+		res, err := store.Take(ctx, "users:ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		results = append(results, res)
+	}
+	other, err := store.Take(ctx, "users:ip:192.0.2.2", limit)
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, []ratelimit.Result{
+		{Allowed: true, Remaining: 1, Reset: 500 * time.Millisecond},
+		{Allowed: true, Remaining: 0, Reset: time.Second},
+		{Allowed: false, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: time.Second},
+	}, results)
+	assert.True(t, other.Allowed, "Expected the buckets to be independent")
+
+	// When a token is added
+	mr.SetTime(now.Add(500 * time.Millisecond))
+	res, err := store.Take(ctx, "users:ip:192.0.2.1", limit)
+
+	// Then
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	// Given
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Requests: 1, Period: 50 * time.Millisecond, Burst: 2}
+	ctx := context.Background()
+
+	// When
+	var allowed []bool
+	for i := 0; i < 3; i++ {
+		res, err := store.Take(ctx, "users:ip:192.0.2.1", limit)
+		require.NoError(t, err)
+		allowed = append(allowed, res.Allowed)
+	}
+	other, err := store.Take(ctx, "users:ip:192.0.2.2", limit)
+	require.NoError(t, err)
+
+	// Then
+	assert.Equal(t, []bool{true, true, false}, allowed, "Expected the burst to be allowed")
+	assert.True(t, other.Allowed, "Expected the buckets to be independent")
+
+	// When a token is added
+	time.Sleep(60 * time.Millisecond)
+	res, err := store.Take(ctx, "users:ip:192.0.2.1", limit)
+
+	// Then
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_TakeWhenFull(t *testing.T) {
+	// Given
+	store := ratelimit.NewMemoryStoreWithSize(2)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Hour}
+	ctx := context.Background()
+	for _, key := range []string{"users:ip:192.0.2.1", "users:ip:192.0.2.2"} {
+		_, err := store.Take(ctx, key, limit)
+		require.NoError(t, err)
+	}
+	// the first bucket is used again, so the second one is the least recently used
+	first, err := store.Take(ctx, "users:ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	require.False(t, first.Allowed)
+
+	// When
+	third, err := store.Take(ctx, "users:ip:192.0.2.3", limit)
+	require.NoError(t, err)
+	first, err = store.Take(ctx, "users:ip:192.0.2.1", limit)
+	require.NoError(t, err)
+	second, err := store.Take(ctx, "users:ip:192.0.2.2", limit)
+	require.NoError(t, err)
+
+	// Then
+	assert.True(t, third.Allowed)
+	assert.False(t, first.Allowed, "Expected the recently used bucket to be kept")
+	assert.True(t, second.Allowed, "Expected the least recently used bucket to be removed")
+}
+
+func TestFallbackStore_Take(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	store := ratelimit.NewFallbackStore(
+		ratelimit.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		ratelimit.NewMemoryStore(),
+	)
+	limit := ratelimit.Limit{Requests: 1, Period: time.Hour}
+	ctx := context.Background()
+	mr.Close()
+
+	// When
+	first, firstErr := store.Take(ctx, "users:ip:192.0.2.1", limit)
+	second, secondErr := store.Take(ctx, "users:ip:192.0.2.1", limit)
+
+	// Then
+	require.NoError(t, firstErr)
+	require.NoError(t, secondErr)
+	assert.True(t, first.Allowed)
+	assert.False(t, second.Allowed, "Expected the clients to be limited in memory without redis")
+}
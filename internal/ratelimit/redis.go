@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// keyPrefix - prefix of the redis keys of the buckets
+const keyPrefix = "ratelimit:"
+
+// takeScript - refills the bucket with the tokens added since its last take and takes one if possible, atomically.
+// The redis time is used, so the replicas share the same clock. Returns whether the token was taken and the tokens left.
+// The buckets expire once they would be full again, since a missing bucket is a full one
+var takeScript = redis.NewScript(`
+redis.replicate_commands()
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+type redisStore struct {
+	rc *redis.Client
+}
+
+var _ Store = redisStore{}
+var _ Store = (*redisStore)(nil)
+
+// NewRedisStore - returns a new Store keeping the buckets in redis, so they are shared by all the replicas
+func NewRedisStore(rc *redis.Client) Store {
+	return &redisStore{rc}
+}
+
+// Take - takes a token from the bucket of the key with the take script
+func (rs redisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := takeScript.Run(ctx, rs.rc, []string{keyPrefix + key}, limit.Capacity(), limit.ratePerMs()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := res[0].(int64)
+	tokens, err := strconv.ParseFloat(res[1].(string), 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(allowed == 1, tokens, limit), nil
+}
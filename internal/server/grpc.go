@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"time"
+	usersv1 "user-microservice/api/users/v1"
+	"user-microservice/internal/auth"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// grpcStopTimeout - time given to the gRPC calls to finish when stopping, the open streams are closed afterwards
+const grpcStopTimeout = 10 * time.Second
+
+// newGRPCServer - returns a gRPC server with the UserService, the health and the reflection services.
+// The UserService calls are authenticated like the HTTP API if the validator or the keys are not nil
+func newGRPCServer(service usersv1.UserServiceServer, validator *auth.Validator, keys auth.KeyAuthenticator) (*grpc.Server, *health.Server) {
+	var opts []grpc.ServerOption
+	if validator != nil || keys != nil {
+		publicServices := []string{healthpb.Health_ServiceDesc.ServiceName, reflectionpb.ServerReflection_ServiceDesc.ServiceName}
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(auth.UnaryServerInterceptor(validator, keys, publicServices...)),
+			grpc.ChainStreamInterceptor(auth.StreamServerInterceptor(validator, keys, publicServices...)),
+		)
+	}
+
+	srv := grpc.NewServer(opts...)
+	usersv1.RegisterUserServiceServer(srv, service)
+
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus(usersv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	reflection.Register(srv)
+
+	return srv, healthSrv
+}
+
+// startGRPC - serves the gRPC server in background on its own port, if it's enabled
+func (s *Server) startGRPC(service usersv1.UserServiceServer, validator *auth.Validator, keys auth.KeyAuthenticator) error {
+	if !s.config.GRPC.Enabled {
+		logrus.Info("gRPC server is disabled")
+		return nil
+	}
+
+	addr := "0.0.0.0"
+	port := 4041
+	if s.config.GRPC.Addr != "" {
+		addr = s.config.GRPC.Addr
+	}
+	if s.config.GRPC.Port != 0 {
+		port = s.config.GRPC.Port
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", addr, port))
+	if err != nil {
+		logrus.Errorf("Error in server.startGRPC -> error listening on %s:%d: %s", addr, port, err)
+		return err
+	}
+
+	s.grpc, s.grpcHealth = newGRPCServer(service, validator, keys)
+	go func() {
+		if err := s.grpc.Serve(lis); err != nil {
+			logrus.Errorf("Error in server.startGRPC -> error serving gRPC: %s", err)
+		}
+	}()
+	logrus.Infof("gRPC server started on %s", lis.Addr())
+
+	return nil
+}
+
+// stopGRPC - stops the gRPC server, waiting for the running calls up to the grpcStopTimeout
+func (s *Server) stopGRPC() {
+	if s.grpc == nil {
+		return
+	}
+	s.grpcHealth.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grpcStopTimeout):
+		s.grpc.Stop()
+	}
+}
@@ -4,24 +4,64 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 	"user-microservice/config"
 	"user-microservice/docs"
 	_ "user-microservice/docs"
+	"user-microservice/internal/apikeys"
+	apikeysRepo "user-microservice/internal/apikeys/repository/mongodb"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/clientip"
+	"user-microservice/internal/feed"
+	feedHttp "user-microservice/internal/feed/http"
+	"user-microservice/internal/idempotency"
+	"user-microservice/internal/lockout"
+	lockoutHttp "user-microservice/internal/lockout/http"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/mfa"
+	mfaHttp "user-microservice/internal/mfa/http"
+	"user-microservice/internal/moderation"
+	moderationHttp "user-microservice/internal/moderation/http"
+	"user-microservice/internal/passwordreset"
+	passwordresetHttp "user-microservice/internal/passwordreset/http"
+	passwordresetRepo "user-microservice/internal/passwordreset/repository/mongodb"
+	"user-microservice/internal/ratelimit"
+	"user-microservice/internal/sessions"
+	sessionsHttp "user-microservice/internal/sessions/http"
+	sessionsRepo "user-microservice/internal/sessions/repository/mongodb"
+	usersGraphql "user-microservice/internal/users/graphql"
+	usersGrpc "user-microservice/internal/users/grpc"
 	usersHttp "user-microservice/internal/users/http"
 	usersPS "user-microservice/internal/users/pubsub"
+	usersPurge "user-microservice/internal/users/purge"
+	usersCache "user-microservice/internal/users/repository/cache"
+	usersHashing "user-microservice/internal/users/repository/hashing"
 	usersRepo "user-microservice/internal/users/repository/mongodb"
+	"user-microservice/internal/verification"
+	verificationHttp "user-microservice/internal/verification/http"
+	"user-microservice/internal/webhooks"
+	webhooksHttp "user-microservice/internal/webhooks/http"
+	webhooksRepo "user-microservice/internal/webhooks/repository/mongodb"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 const (
 	CurrentApiVersion = "/api/v1"
 	UsersPath         = "/users"
+	AuthPath          = "/auth"
+	GraphQLPath       = "/graphql"
+	WebhooksPath      = "/webhooks"
 )
 
 // Server - server main struct
@@ -30,6 +70,11 @@ type Server struct {
 	echo    *echo.Echo
 	redisDB *redis.Client
 	config  *config.Config
+	// cancel - stops the background jobs
+	cancel context.CancelFunc
+	// grpc - gRPC server, nil if it's disabled
+	grpc       *grpc.Server
+	grpcHealth *health.Server
 }
 
 // New - returns a newly initialized server
@@ -39,12 +84,18 @@ func New(db *mongo.Database, redisDB *redis.Client, cfg *config.Config) *Server
 
 // NewWithEcho - same as New but with a given echo.Echo
 func NewWithEcho(db *mongo.Database, e *echo.Echo, redisDB *redis.Client, cfg *config.Config) *Server {
-	return &Server{db, e, redisDB, cfg}
+	return &Server{db: db, echo: e, redisDB: redisDB, config: cfg}
 }
 
 // Run - Executes the server and starts it
 func (s *Server) Run() error {
 	s.echo.Debug = s.config.Server.Debug
+	ipExtractor, err := clientip.NewExtractor(s.config.Server.TrustedProxies)
+	if err != nil {
+		logrus.Errorf("Error in server.Run -> error creating the IP extractor: %s", err)
+		return err
+	}
+	s.echo.IPExtractor = ipExtractor
 
 	router := s.echo.Group(CurrentApiVersion)
 
@@ -52,11 +103,14 @@ func (s *Server) Run() error {
 	// router.Use(middleware.RemoveTrailingSlash())
 	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		// AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
-		AllowHeaders: []string{echo.HeaderContentType},
+		AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowHeaders:  []string{echo.HeaderContentType, "If-Match", "If-None-Match", idempotency.HeaderIdempotencyKey, audit.HeaderActor, echo.HeaderAuthorization, auth.HeaderAPIKey},
+		ExposeHeaders: []string{"ETag", idempotency.HeaderIdempotentReplayed, echo.HeaderXRequestID, ratelimit.HeaderLimit, ratelimit.HeaderRemaining, ratelimit.HeaderReset, echo.HeaderRetryAfter},
 	}))
 	router.Use(middleware.Recover())
 	router.Use(middleware.Secure())
+	router.Use(middleware.RequestID())
+	router.Use(audit.Middleware())
 
 	//Health check route
 	router.GET("/health", func(c echo.Context) error {
@@ -70,15 +124,174 @@ func (s *Server) Run() error {
 	docs.SwaggerInfo.Title = "Users microservice API"
 	router.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	// Metrics route
+	s.echo.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
 	// Initialize repositories
-	usersR := usersRepo.NewMongoDBRepository(s.db)
+	indexesCtx, cancelIndexes := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelIndexes()
+	if err := usersRepo.EnsureIndexes(indexesCtx, s.db); err != nil {
+		logrus.Errorf("Error in server.Run -> error ensuring the users indexes: %s", err)
+		return err
+	}
+	sessionsR := sessionsRepo.NewMongoDBRepository(s.db)
+	usersR := usersCache.NewCachedRepository(
+		usersHashing.NewHashingRepository(usersRepo.NewMongoDBRepository(s.db), sessionsR),
+		s.redisDB,
+		s.config.Cache.TTL,
+		usersCache.NewMetrics(prometheus.DefaultRegisterer),
+	)
 	usersPubSub := usersPS.NewPubSub(s.redisDB)
 
+	idempotencyTTL := idempotency.DefaultTTL
+	if s.config.Idempotency.TTL > 0 {
+		idempotencyTTL = s.config.Idempotency.TTL
+	}
+	idempotencyLockTimeout := idempotency.DefaultLockTimeout
+	if s.config.Idempotency.LockTimeout > 0 {
+		idempotencyLockTimeout = s.config.Idempotency.LockTimeout
+	}
+	idempotencyStore := idempotency.NewRedisStore(s.redisDB, idempotencyTTL, idempotencyLockTimeout)
+
+	validator, apiKeys, err := s.authenticators()
+	if err != nil {
+		return err
+	}
+	authMiddlewares := s.authMiddlewares(validator, apiKeys)
+	rateLimitStore := ratelimit.NewFallbackStore(ratelimit.NewRedisStore(s.redisDB), ratelimit.NewMemoryStore())
+
+	mailer, err := mail.New(s.config.Mail.Sender, s.config.Mail.Dir)
+	if err != nil {
+		logrus.Errorf("Error in server.Run -> error creating mailer: %s", err)
+		return err
+	}
+
+	// the email verification tokens are signed with the auth secret if there is no specific one
+	verificationSecret := s.config.EmailVerification.Secret
+	if verificationSecret == "" {
+		verificationSecret = s.config.Auth.Secret
+	}
+	var verifier verification.Sender
+	var verificationManager *verification.Manager
+	if verificationSecret != "" {
+		verificationManager, err = verification.NewManager(
+			usersR,
+			usersPubSub,
+			mailer,
+			[]byte(verificationSecret),
+			verification.Options{TokenTTL: s.config.EmailVerification.TokenTTL, URL: s.config.EmailVerification.URL},
+		)
+		if err != nil {
+			logrus.Errorf("Error in server.Run -> error creating email verification manager: %s", err)
+			return err
+		}
+		verifier = verificationManager
+	} else {
+		logrus.Warn("Email verification is disabled, there is no secret to sign the verification tokens")
+	}
+
+	var secondFactor sessions.SecondFactor
+	var mfaManager *mfa.Manager
+	if s.config.MFA.EncryptionKey != "" {
+		mfaManager, err = mfa.NewManager(usersR, []byte(s.config.MFA.EncryptionKey), s.config.MFA.Issuer)
+		if err != nil {
+			logrus.Errorf("Error in server.Run -> error creating mfa manager: %s", err)
+			return err
+		}
+		secondFactor = mfaManager
+	} else {
+		logrus.Warn("Second factor is disabled, there is no key to encrypt the TOTP secrets")
+	}
+
 	//Initialize http handlers
-	usersHandler := usersHttp.NewHttpHandler(usersR, usersPubSub)
+	usersHandler := usersHttp.NewHttpHandler(usersR, usersPubSub, verifier)
+	graphqlHandler, err := usersGraphql.NewHttpHandler(usersR, usersPubSub, verifier, usersGraphql.Limits{
+		MaxDepth:              s.config.GraphQL.MaxDepth,
+		MaxComplexity:         s.config.GraphQL.MaxComplexity,
+		MaxIntrospectionDepth: s.config.GraphQL.MaxIntrospectionDepth,
+	})
+	if err != nil {
+		logrus.Errorf("Error in server.Run -> error creating graphql handler: %s", err)
+		return err
+	}
+
+	passwordResetManager := passwordreset.NewManager(
+		usersR,
+		passwordresetRepo.NewMongoDBRepository(s.db),
+		sessionsR,
+		mailer,
+		passwordreset.NewRedisLimiter(s.redisDB, s.config.PasswordReset.MaxRequests, s.config.PasswordReset.Window),
+		passwordreset.Options{TokenTTL: s.config.PasswordReset.TokenTTL, URL: s.config.PasswordReset.URL},
+	)
+	passwordResetHandler := passwordresetHttp.NewHttpHandler(passwordResetManager)
+
+	eventsHub := feed.NewHub(usersPubSub, feed.Options{BufferSize: s.config.Feed.BufferSize, ReplaySize: s.config.Feed.ReplaySize})
+
+	webhooksR := webhooksRepo.NewMongoDBRepository(s.db)
+	webhooksDispatcher := webhooks.NewDispatcher(webhooksR, usersPubSub, s.redisDB, webhooks.Options{
+		MaxAttempts:    s.config.Webhooks.MaxAttempts,
+		InitialBackoff: s.config.Webhooks.InitialBackoff,
+		MaxBackoff:     s.config.Webhooks.MaxBackoff,
+		Timeout:        s.config.Webhooks.Timeout,
+		MaxFailures:    s.config.Webhooks.MaxFailures,
+		PollInterval:   s.config.Webhooks.PollInterval,
+		Workers:        s.config.Webhooks.Workers,
+	}, webhooks.NewMetrics(prometheus.DefaultRegisterer))
+
+	moderationManager := moderation.NewManager(usersR, usersPubSub, s.config.Users.SuspensionExpiryInterval)
+
+	loginLockout := lockout.NewLimiter(s.redisDB, lockout.Options{
+		MaxAttempts:  s.config.Lockout.MaxAttempts,
+		Window:       s.config.Lockout.Window,
+		BaseDuration: s.config.Lockout.BaseDuration,
+		MaxDuration:  s.config.Lockout.MaxDuration,
+	}, lockout.NewMetrics(prometheus.DefaultRegisterer))
 
 	// Append routes
-	usersHttp.AppendUsersRoutes(router.Group(UsersPath), usersHandler)
+	usersGroup := router.Group(UsersPath, s.rateLimitedMiddlewares(rateLimitStore, "users", authMiddlewares)...)
+	usersHttp.AppendUsersRoutes(usersGroup, usersHandler, idempotency.Middleware(idempotencyStore))
+	feedHttp.AppendFeedRoutes(usersGroup, feedHttp.NewHttpHandler(eventsHub, s.config.Feed.Heartbeat))
+	if verificationManager != nil {
+		verificationHttp.AppendVerificationRoutes(usersGroup, verificationHttp.NewHttpHandler(verificationManager))
+	}
+	if mfaManager != nil {
+		mfaHttp.AppendMFARoutes(usersGroup, mfaHttp.NewHttpHandler(mfaManager))
+	}
+	moderationHttp.AppendModerationRoutes(usersGroup, moderationHttp.NewHttpHandler(moderationManager))
+	lockoutHttp.AppendLockoutRoutes(usersGroup, lockoutHttp.NewHttpHandler(loginLockout, usersR))
+	graphqlGroup := router.Group(GraphQLPath, s.rateLimitedMiddlewares(rateLimitStore, "graphql", authMiddlewares)...)
+	usersGraphql.AppendGraphQLRoutes(graphqlGroup, graphqlHandler)
+	webhooksGroup := router.Group(WebhooksPath, s.rateLimitedMiddlewares(rateLimitStore, "webhooks", authMiddlewares)...)
+	webhooksHttp.AppendWebhooksRoutes(webhooksGroup, webhooksHttp.NewHttpHandler(webhooks.NewManager(webhooksR, webhooksDispatcher)))
+	authGroup := router.Group(AuthPath, s.rateLimitedMiddlewares(rateLimitStore, "auth", nil)...)
+	passwordresetHttp.AppendPasswordResetRoutes(authGroup, passwordResetHandler)
+	if s.config.Auth.Secret != "" {
+		signer, err := auth.NewSigner([]byte(s.config.Auth.Secret), s.config.Auth.Issuer, s.config.Auth.Audience, s.config.Auth.AccessTokenTTL)
+		if err != nil {
+			logrus.Errorf("Error in server.Run -> error creating signer: %s", err)
+			return err
+		}
+		sessionsManager := sessions.NewManager(usersR, sessionsR, signer, s.config.Auth.RefreshTokenTTL, secondFactor, loginLockout)
+		sessionsHandler := sessionsHttp.NewHttpHandler(sessionsManager)
+		sessionsHttp.AppendAuthRoutes(authGroup, sessionsHandler)
+		sessionsHttp.AppendSessionsRoutes(usersGroup, sessionsHandler)
+	} else {
+		logrus.Warn("Login is disabled, there is no auth secret to sign the access tokens")
+	}
+
+	// Start background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go usersR.ListenInvalidations(ctx)
+	go usersPurge.NewPurger(usersR, usersPubSub, s.config.Users.DeletedRetention, s.config.Users.PurgeInterval).Run(ctx)
+	go moderationManager.Run(ctx)
+	go eventsHub.Run(ctx)
+	go webhooksDispatcher.Run(ctx)
+	go hashPlainPasswords(ctx, s.db)
+
+	if err := s.startGRPC(usersGrpc.NewGrpcServer(usersR, usersPubSub, usersPubSub, verifier), validator, apiKeys); err != nil {
+		return err
+	}
 
 	//Start the server
 	addr := "0.0.0.0"
@@ -96,9 +309,90 @@ func (s *Server) Run() error {
 	return nil
 }
 
+// authenticators - returns the token validator and the API keys authenticator, both nil if the auth is disabled.
+// The API keys are always accepted, and the bearer tokens only if there is a secret or a JWKS to validate them
+func (s *Server) authenticators() (*auth.Validator, auth.KeyAuthenticator, error) {
+	if !s.config.Auth.Enabled {
+		logrus.Warn("Authentication is disabled, the users routes are not protected")
+		return nil, nil, nil
+	}
+
+	var validator *auth.Validator
+	if s.config.Auth.Secret != "" || s.config.Auth.JWKS != "" {
+		opts := auth.Options{
+			Secret:   []byte(s.config.Auth.Secret),
+			Issuer:   s.config.Auth.Issuer,
+			Audience: s.config.Auth.Audience,
+		}
+		if s.config.Auth.JWKS != "" {
+			keys, err := auth.NewKeySource(s.config.Auth.JWKS)
+			if err != nil {
+				logrus.Errorf("Error in server.authenticators -> error loading JWKS %s: %s", s.config.Auth.JWKS, err)
+				return nil, nil, err
+			}
+			opts.Keys = keys
+		}
+		var err error
+		validator, err = auth.NewValidator(opts)
+		if err != nil {
+			logrus.Errorf("Error in server.authenticators -> error creating validator: %s", err)
+			return nil, nil, err
+		}
+	}
+
+	apiKeys := apikeys.NewAuthenticator(apikeysRepo.NewMongoDBRepository(s.db), apikeys.NewMetrics(prometheus.DefaultRegisterer))
+
+	return validator, apiKeys, nil
+}
+
+// authMiddlewares - returns the middlewares authenticating the requests, none if the auth is disabled
+func (s *Server) authMiddlewares(validator *auth.Validator, keys auth.KeyAuthenticator) []echo.MiddlewareFunc {
+	if !s.config.Auth.Enabled {
+		return nil
+	}
+	return []echo.MiddlewareFunc{auth.Middleware(validator, keys)}
+}
+
+// rateLimitedMiddlewares - returns the middlewares of a route group: the IP rate limit before the auth middlewares,
+// so the requests with invalid credentials are limited too, and the client rate limit of the group after them
+func (s *Server) rateLimitedMiddlewares(store ratelimit.Store, group string, authMiddlewares []echo.MiddlewareFunc) []echo.MiddlewareFunc {
+	ip := s.config.RateLimit.IP
+	middlewares := []echo.MiddlewareFunc{
+		ratelimit.IPMiddleware(store, "ip", ratelimit.Limit{Requests: ip.Requests, Period: ip.Period, Burst: ip.Burst}),
+	}
+	middlewares = append(middlewares, authMiddlewares...)
+	return append(middlewares, s.rateLimitMiddleware(store, group))
+}
+
+// rateLimitMiddleware - returns the middleware limiting the requests of each client to the route group,
+// with the group limit of the config or the default one
+func (s *Server) rateLimitMiddleware(store ratelimit.Store, group string) echo.MiddlewareFunc {
+	limit, isOK := s.config.RateLimit.Groups[group]
+	if !isOK {
+		limit = s.config.RateLimit.Default
+	}
+	return ratelimit.Middleware(store, group, ratelimit.Limit{Requests: limit.Requests, Period: limit.Period, Burst: limit.Burst})
+}
+
+// hashPlainPasswords - hashes the passwords stored in plain text by the previous versions
+func hashPlainPasswords(ctx context.Context, db *mongo.Database) {
+	hashed, err := usersRepo.HashPlainPasswords(ctx, db)
+	if err != nil {
+		logrus.Errorf("Error in server.hashPlainPasswords -> error hashing plain passwords: %s", err)
+		return
+	}
+	if hashed > 0 {
+		logrus.Infof("Info in server.hashPlainPasswords -> hashed %d plain passwords", hashed)
+	}
+}
+
 // Cleanup - performs the needed cleanups for the server.
 // Should be sed as a defered function
 func (s *Server) Cleanup() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stopGRPC()
 	if s.db != nil {
 		if err := s.db.Client().Disconnect(context.TODO()); err != nil {
 			logrus.Errorf("Error in server.Cleanup -> error disconnecting MongoDB: %s", err)
@@ -0,0 +1,13 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package sessions
+
+import "github.com/labstack/echo/v4"
+
+// Handler - authentication and sessions handlers
+type Handler interface {
+	Login(c echo.Context) error
+	Refresh(c echo.Context) error
+	Logout(c echo.Context) error
+	ListSessions(c echo.Context) error
+	RevokeSession(c echo.Context) error
+}
@@ -0,0 +1,222 @@
+// @tag.name        Auth
+// @tag.description Login and sessions API
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/lockout"
+	"user-microservice/internal/mfa"
+	"user-microservice/internal/models"
+	"user-microservice/internal/sessions"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpHandler struct {
+	manager *sessions.Manager
+}
+
+var _ sessions.Handler = httpHandler{}
+var _ sessions.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new sessions http handler initialized with the manager
+func NewHttpHandler(manager *sessions.Manager) sessions.Handler {
+	return &httpHandler{manager}
+}
+
+// Login godoc
+//
+// @Summary     Logs in a user
+// @Description Verifies the credentials and starts a new session, returning a short-lived access token and a refresh token. The users who enrolled a second factor must send a TOTP or recovery code too, and the suspended and banned users cannot log in. Too many failed logins of an account or from an IP lock them out for an exponentially growing time, see the Retry-After header
+// @Tags        Auth
+// @Accept      json
+// @Produce     json
+// @Param       body body     models.Credentials true "User credentials"
+// @Success     200  {object} models.Tokens
+// @Failure     400  {object} echo.HTTPError
+// @Failure     401  {object} echo.HTTPError
+// @Failure     403  {object} echo.HTTPError
+// @Failure     429  {object} echo.HTTPError
+// @Failure     500  {object} echo.HTTPError
+// @Router      /auth/login [post]
+func (h httpHandler) Login(c echo.Context) error {
+	var body models.Credentials
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in sessions/http.Login -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.Email == "" || body.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	res, err := h.manager.Login(c.Request().Context(), body, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		var lockedErr *lockout.LockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			logrus.Infof("Info in sessions/http.Login -> %s", err)
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			return echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrLoginLocked)
+		case errors.Is(err, sessions.ErrInvalidCredentials):
+			return echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidCredentials)
+		case errors.Is(err, sessions.ErrUserNotActive):
+			logrus.Infof("Info in sessions/http.Login -> %s", err)
+			return echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrUserNotActive)
+		case errors.Is(err, mfa.ErrCodeRequired):
+			return echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrMFARequired)
+		case errors.Is(err, mfa.ErrInvalidCode):
+			return echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidMFACode)
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// Refresh godoc
+//
+// @Summary     Refreshes the tokens
+// @Description Rotates the refresh token, returning a new access token and a new refresh token. The refresh tokens can only be used once, reusing one revokes its whole session
+// @Tags        Auth
+// @Accept      json
+// @Produce     json
+// @Param       body body     models.RefreshTokenRequest true "Current refresh token"
+// @Success     200  {object} models.Tokens
+// @Failure     400  {object} echo.HTTPError
+// @Failure     401  {object} echo.HTTPError
+// @Failure     500  {object} echo.HTTPError
+// @Router      /auth/refresh [post]
+func (h httpHandler) Refresh(c echo.Context) error {
+	var body models.RefreshTokenRequest
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in sessions/http.Refresh -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	res, err := h.manager.Refresh(c.Request().Context(), body.RefreshToken)
+	if err != nil {
+		if errors.Is(err, sessions.ErrInvalidRefreshToken) {
+			logrus.Infof("Info in sessions/http.Refresh -> %s", err)
+			return echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidRefreshToken)
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// Logout godoc
+//
+// @Summary     Logs out a user
+// @Description Revokes the session of the refresh token. The issued access tokens are valid until they expire
+// @Tags        Auth
+// @Accept      json
+// @Param       body body models.RefreshTokenRequest true "Current refresh token"
+// @Success     204
+// @Failure     400 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Router      /auth/logout [post]
+func (h httpHandler) Logout(c echo.Context) error {
+	var body models.RefreshTokenRequest
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in sessions/http.Logout -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	if err := h.manager.Logout(c.Request().Context(), body.RefreshToken); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListSessions godoc
+//
+// @Summary     Lists the user sessions
+// @Description Lists the active sessions of a user, the newest first
+// @Tags        Auth
+// @Produce     json
+// @Param       userId path     string true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Success     200    {array}  models.Session
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/sessions [get]
+func (h httpHandler) ListSessions(c echo.Context) error {
+	idStr := c.Param("userId")
+	userID, err := uuid.Parse(idStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", idStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionListSessions, userID.String()); err != nil {
+		return err
+	}
+
+	res, err := h.manager.List(c.Request().Context(), userID.String())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// RevokeSession godoc
+//
+// @Summary     Revokes a user session
+// @Description Revokes an active session of a user, so its refresh token can no longer be used
+// @Tags        Auth
+// @Param       userId    path string true "User id"    format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       sessionId path string true "Session id" format(uuid) example(7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a)
+// @Success     204
+// @Failure     400 {object} echo.HTTPError
+// @Failure     401 {object} echo.HTTPError
+// @Failure     403 {object} echo.HTTPError
+// @Failure     404 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/sessions/{sessionId} [delete]
+func (h httpHandler) RevokeSession(c echo.Context) error {
+	idStr := c.Param("userId")
+	userID, err := uuid.Parse(idStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", idStr))
+	}
+	sessionIDStr := c.Param("sessionId")
+	sessionID, err := uuid.Parse(sessionIDStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", sessionIDStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionRevokeSession, userID.String()); err != nil {
+		return err
+	}
+
+	if err := h.manager.Revoke(c.Request().Context(), userID.String(), sessionID.String()); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Session not found for ID %s", sessionID))
+		}
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
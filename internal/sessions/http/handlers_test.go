@@ -0,0 +1,466 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/clientip"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/lockout"
+	"user-microservice/internal/mfa"
+	"user-microservice/internal/models"
+	"user-microservice/internal/sessions"
+	sessionsHttp "user-microservice/internal/sessions/http"
+	"user-microservice/internal/sessions/mock"
+	"user-microservice/internal/testutils"
+	usersMock "user-microservice/internal/users/mock"
+	"user-microservice/internal/users/sec"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/pquerna/otp/totp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const mfaKey = "test-mfa-key"
+
+func TestMain(m *testing.M) {
+	sec.Cost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+func newHandler(t *testing.T, ctrl *gomock.Controller) (sessions.Handler, *usersMock.MockRepository, *mock.MockRepository) {
+	t.Helper()
+	usersRepo := usersMock.NewMockRepository(ctrl)
+	repo := mock.NewMockRepository(ctrl)
+	signer, err := auth.NewSigner([]byte("test-secret"), "", "", time.Minute)
+	require.NoError(t, err)
+	mfaManager, err := mfa.NewManager(usersRepo, []byte(mfaKey), "")
+	require.NoError(t, err)
+	return sessionsHttp.NewHttpHandler(sessions.NewManager(usersRepo, repo, signer, time.Hour, mfaManager, nil)), usersRepo, repo
+}
+
+// enrolledMFA - returns an enabled second factor of the user and a valid code for it
+func enrolledMFA(t *testing.T, userID string) (models.MFA, string) {
+	t.Helper()
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: "test", AccountName: "alicetingo@example.com"})
+	require.NoError(t, err)
+	cipher, err := mfa.NewCipher([]byte(mfaKey))
+	require.NoError(t, err)
+	encrypted, err := cipher.Encrypt(userID, key.Secret())
+	require.NoError(t, err)
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	require.NoError(t, err)
+
+	return models.MFA{Secret: encrypted, Enabled: true, RecoveryCodes: []string{}}, code
+}
+
+func TestLogin(t *testing.T) {
+	hashed, err := sec.HashPassword("Login Password")
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New().String(), Email: "alicetingo@example.com", Password: hashed}
+	userMFA, code := enrolledMFA(t, user.ID)
+
+	for _, tc := range []struct {
+		name          string
+		body          string
+		mockedMFA     *models.MFA
+		getTimes      int
+		createTimes   int
+		expectedError error
+	}{
+		{
+			"Login successfully",
+			`{"email": "alicetingo@example.com", "password": "Login Password"}`,
+			nil,
+			1,
+			1,
+			nil,
+		},
+		{
+			"Login with wrong password",
+			`{"email": "alicetingo@example.com", "password": "Wrong Password"}`,
+			nil,
+			1,
+			0,
+			echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidCredentials),
+		},
+		{
+			"Login without password",
+			`{"email": "alicetingo@example.com"}`,
+			nil,
+			0,
+			0,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Login with invalid body",
+			`invalid body`,
+			nil,
+			0,
+			0,
+			echo.NewHTTPError(http.StatusBadRequest, nil),
+		},
+		{
+			"Login with second factor code successfully",
+			fmt.Sprintf(`{"email": "alicetingo@example.com", "password": "Login Password", "code": %q}`, code),
+			&userMFA,
+			1,
+			1,
+			nil,
+		},
+		{
+			"Login without the second factor code",
+			`{"email": "alicetingo@example.com", "password": "Login Password"}`,
+			&userMFA,
+			1,
+			0,
+			echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrMFARequired),
+		},
+		{
+			"Login with invalid second factor code",
+			`{"email": "alicetingo@example.com", "password": "Login Password", "code": "000000x"}`,
+			&userMFA,
+			1,
+			0,
+			echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidMFACode),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			handler, usersRepo, repo := newHandler(t, ctrl)
+
+			usersRepo.EXPECT().GetByEmail(gomock.Any(), user.Email).Return(&user, nil).Times(tc.getTimes)
+			var mockedMFA *models.MFA
+			if tc.mockedMFA != nil {
+				current := *tc.mockedMFA
+				mockedMFA = &current
+			}
+			usersRepo.EXPECT().GetMFA(gomock.Any(), user.ID).Return(mockedMFA, nil).AnyTimes()
+			usersRepo.EXPECT().UseMFAStep(gomock.Any(), user.ID, gomock.Any()).Return(true, nil).AnyTimes()
+			repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, session models.Session) (*models.Session, error) {
+				session.ID = uuid.New().String()
+				return &session, nil
+			}).Times(tc.createTimes)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			rec := httptest.NewRecorder()
+			echoCtx := echo.New().NewContext(req, rec)
+
+			// When
+			err := handler.Login(echoCtx)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedError.(*echo.HTTPError).Code, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+			var body models.Tokens
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.NotEmpty(t, body.AccessToken)
+			assert.NotEmpty(t, body.RefreshToken)
+			assert.Equal(t, "Bearer", body.TokenType)
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	// Given
+	hashed, err := sec.HashPassword("Login Password")
+	require.NoError(t, err)
+	user := models.User{ID: uuid.New().String(), Email: "alicetingo@example.com", Password: hashed}
+	ctrl := gomock.NewController(t)
+	usersRepo := usersMock.NewMockRepository(ctrl)
+	repo := mock.NewMockRepository(ctrl)
+	signer, err := auth.NewSigner([]byte("test-secret"), "", "", time.Minute)
+	require.NoError(t, err)
+	mr := miniredis.RunT(t)
+	limiter := lockout.NewLimiter(
+		redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		lockout.Options{MaxAttempts: 2, BaseDuration: time.Minute},
+		lockout.NewMetrics(prometheus.NewRegistry()),
+	)
+	handler := sessionsHttp.NewHttpHandler(sessions.NewManager(usersRepo, repo, signer, time.Hour, nil, limiter))
+	usersRepo.EXPECT().GetByEmail(gomock.Any(), user.Email).Return(&user, nil).Times(2)
+	login := func(password string) (*httptest.ResponseRecorder, error) {
+		body := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, password)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, "application/json")
+		rec := httptest.NewRecorder()
+		return rec, handler.Login(echo.New().NewContext(req, rec))
+	}
+
+	// When
+	for i := 0; i < 2; i++ {
+		rec, err := login("Wrong Password")
+		testutils.AssertExpectedErrorsHttpReponse(t, http.StatusUnauthorized, rec.Code,
+			echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidCredentials), err)
+	}
+	rec, err := login("Login Password")
+
+	// Then
+	testutils.AssertExpectedErrorsHttpReponse(t, http.StatusTooManyRequests, rec.Code,
+		echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrLoginLocked), err)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
+
+func TestLoginLockoutWithSpoofedForwardedFor(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	usersRepo := usersMock.NewMockRepository(ctrl)
+	repo := mock.NewMockRepository(ctrl)
+	signer, err := auth.NewSigner([]byte("test-secret"), "", "", time.Minute)
+	require.NoError(t, err)
+	mr := miniredis.RunT(t)
+	limiter := lockout.NewLimiter(
+		redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		lockout.Options{MaxAttempts: 2, BaseDuration: time.Minute},
+		lockout.NewMetrics(prometheus.NewRegistry()),
+	)
+	handler := sessionsHttp.NewHttpHandler(sessions.NewManager(usersRepo, repo, signer, time.Hour, nil, limiter))
+	usersRepo.EXPECT().GetByEmail(gomock.Any(), gomock.Any()).Return(nil, mongo.ErrNoDocuments).Times(2)
+	e := echo.New()
+	e.IPExtractor, err = clientip.NewExtractor(nil)
+	require.NoError(t, err)
+	// each login tries another account and forwards another IP from the same connection
+	login := func(i int) (*httptest.ResponseRecorder, error) {
+		body := fmt.Sprintf(`{"email": "user%d@example.com", "password": "Wrong Password"}`, i)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, "application/json")
+		req.Header.Set(echo.HeaderXForwardedFor, fmt.Sprintf("198.51.100.%d", i))
+		req.RemoteAddr = "203.0.113.10:1234"
+		rec := httptest.NewRecorder()
+		return rec, handler.Login(e.NewContext(req, rec))
+	}
+
+	// When
+	for i := 0; i < 2; i++ {
+		rec, err := login(i)
+		testutils.AssertExpectedErrorsHttpReponse(t, http.StatusUnauthorized, rec.Code,
+			echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidCredentials), err)
+	}
+	rec, err := login(2)
+
+	// Then
+	testutils.AssertExpectedErrorsHttpReponse(t, http.StatusTooManyRequests, rec.Code,
+		echo.NewHTTPError(http.StatusTooManyRequests, httpErrors.ErrLoginLocked), err)
+}
+
+func TestRefresh(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		body          string
+		expectedError error
+	}{
+		{
+			"Refresh with unknown token",
+			`{"refreshToken": "unknown-token"}`,
+			echo.NewHTTPError(http.StatusUnauthorized, httpErrors.ErrInvalidRefreshToken),
+		},
+		{
+			"Refresh without token",
+			`{}`,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			handler, _, repo := newHandler(t, ctrl)
+			repo.EXPECT().GetByTokenHash(gomock.Any(), sessions.HashToken("unknown-token")).Return(nil, mongo.ErrNoDocuments).MaxTimes(1)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			rec := httptest.NewRecorder()
+			echoCtx := echo.New().NewContext(req, rec)
+
+			// When
+			err := handler.Refresh(echoCtx)
+
+			// Then
+			testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedError.(*echo.HTTPError).Code, rec.Code, tc.expectedError, err)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	handler, _, repo := newHandler(t, ctrl)
+	session := models.Session{ID: uuid.New().String(), UserID: uuid.New().String()}
+	repo.EXPECT().GetByTokenHash(gomock.Any(), sessions.HashToken("logout-token")).Return(&session, nil)
+	repo.EXPECT().Revoke(gomock.Any(), session.UserID, session.ID).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/logout", strings.NewReader(`{"refreshToken": "logout-token"}`))
+	req.Header.Set(echo.HeaderContentType, "application/json")
+	rec := httptest.NewRecorder()
+	echoCtx := echo.New().NewContext(req, rec)
+
+	// When
+	err := handler.Logout(echoCtx)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equalf(t, http.StatusNoContent, rec.Code, "Expected status code to be %d, but was %d", http.StatusNoContent, rec.Code)
+}
+
+func TestListSessions(t *testing.T) {
+	userID := uuid.New()
+	mockedSessions := []models.Session{{ID: uuid.New().String(), UserID: userID.String(), UserAgent: "Mozilla/5.0"}}
+
+	for _, tc := range []struct {
+		name          string
+		principal     *auth.Principal
+		listTimes     int
+		expectedError error
+	}{
+		{
+			"List sessions without authentication",
+			nil,
+			1,
+			nil,
+		},
+		{
+			"User lists its sessions",
+			&auth.Principal{Subject: userID.String(), Role: auth.RoleUser},
+			1,
+			nil,
+		},
+		{
+			"User lists other user sessions",
+			&auth.Principal{Subject: uuid.New().String(), Role: auth.RoleUser},
+			0,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			handler, _, repo := newHandler(t, ctrl)
+			repo.EXPECT().ListActive(gomock.Any(), userID.String(), gomock.Any()).Return(mockedSessions, nil).Times(tc.listTimes)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+userID.String()+"/sessions", nil)
+			if tc.principal != nil {
+				req = req.WithContext(auth.NewPrincipalContext(req.Context(), *tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			echoCtx := echo.New().NewContext(req, rec)
+			echoCtx.SetParamNames("userId")
+			echoCtx.SetParamValues(userID.String())
+
+			// When
+			err := handler.ListSessions(echoCtx)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedError.(*echo.HTTPError).Code, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+			var body []models.Session
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			require.Len(t, body, 1)
+			assert.Equal(t, mockedSessions[0].ID, body[0].ID)
+			assert.NotContains(t, rec.Body.String(), "tokenHash", "Expected the token hashes not to be returned")
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	for _, tc := range []struct {
+		name          string
+		sessionID     string
+		mockedError   error
+		revokeTimes   int
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Revoke session successfully",
+			sessionID.String(),
+			nil,
+			1,
+			http.StatusNoContent,
+			nil,
+		},
+		{
+			"Revoke not found session",
+			sessionID.String(),
+			mongo.ErrNoDocuments,
+			1,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, "Session not found for ID "+sessionID.String()),
+		},
+		{
+			"Revoke session with invalid ID",
+			"invalid-id",
+			nil,
+			0,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid ID invalid-id"),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			handler, _, repo := newHandler(t, ctrl)
+			repo.EXPECT().Revoke(gomock.Any(), userID.String(), sessionID.String()).Return(tc.mockedError).Times(tc.revokeTimes)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+userID.String()+"/sessions/"+tc.sessionID, nil)
+			rec := httptest.NewRecorder()
+			echoCtx := echo.New().NewContext(req, rec)
+			echoCtx.SetParamNames("userId", "sessionId")
+			echoCtx.SetParamValues(userID.String(), tc.sessionID)
+
+			// When
+			err := handler.RevokeSession(echoCtx)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+		})
+	}
+}
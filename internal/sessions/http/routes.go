@@ -0,0 +1,20 @@
+package http
+
+import (
+	"user-microservice/internal/sessions"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendAuthRoutes - Sets the login routes for the given echo group, they must not require authentication
+func AppendAuthRoutes(e *echo.Group, h sessions.Handler) {
+	e.POST("/login", h.Login)
+	e.POST("/refresh", h.Refresh)
+	e.POST("/logout", h.Logout)
+}
+
+// AppendSessionsRoutes - Sets the user sessions routes for the given users echo group
+func AppendSessionsRoutes(e *echo.Group, h sessions.Handler) {
+	e.GET("/:userId/sessions", h.ListSessions)
+	e.DELETE("/:userId/sessions/:sessionId", h.RevokeSession)
+}
@@ -0,0 +1,26 @@
+//go:generate mockgen -source repository.go -destination mock/repository_mock.go -package mock
+package sessions
+
+import (
+	"context"
+	"time"
+	"user-microservice/internal/models"
+)
+
+// Repository - sessions repository
+type Repository interface {
+	// Create - inserts the session, setting its ID
+	Create(ctx context.Context, session models.Session) (*models.Session, error)
+	// GetByTokenHash - returns the session whose current or previous refresh tokens have the hash,
+	// or mongo.ErrNoDocuments if there is none
+	GetByTokenHash(ctx context.Context, hash string) (*models.Session, error)
+	// Rotate - replaces the current refresh token hash of the active session, keeping the old one as previous.
+	// Returns mongo.ErrNoDocuments if the current hash is not the given one anymore or the session is revoked
+	Rotate(ctx context.Context, id, currentHash, newHash string, expiresAt time.Time) error
+	// Revoke - revokes the session of the user, returning mongo.ErrNoDocuments if it does not exist or it is already revoked
+	Revoke(ctx context.Context, userID, id string) error
+	// RevokeAll - revokes all the active sessions of the user
+	RevokeAll(ctx context.Context, userID string) error
+	// ListActive - returns the not revoked nor expired sessions of the user, the newest first
+	ListActive(ctx context.Context, userID string, now time.Time) ([]models.Session, error)
+}
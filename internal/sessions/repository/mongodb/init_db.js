@@ -0,0 +1,67 @@
+db.sessions.createIndex({ token_hash: 1 }, { unique: true });
+db.sessions.createIndex({ previous_hashes: 1 });
+db.sessions.createIndex({ user_id: 1, created_at: -1 });
+
+db.sessions.insertMany([
+  {
+    // refresh token "active-refresh-token", rotated from "rotated-refresh-token"
+    _id: "7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a",
+    user_id: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+    token_hash: "0c83c7e151cc93313ff64682bb2b4f86915504ad16096e6410220cb7da50c445",
+    previous_hashes: ["81268b89d9bd2a8ef0531218064192f3597fc2f03d8dac6ff6dfc12fedb9daba"],
+    user_agent: "Mozilla/5.0",
+    ip: "192.0.2.1",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    last_used_at: new Date("2022-05-19T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+  },
+  {
+    // refresh token "revoked-refresh-token"
+    _id: "8e2f3a4b-5c6d-4e7f-9a8b-0c1d2e3f4a5b",
+    user_id: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+    token_hash: "f93a23e4cc4a65f5b7ca3b6ceeb8f4b39c19d881b6064cea4fdbd864f472d827",
+    previous_hashes: [],
+    user_agent: "Mozilla/5.0",
+    ip: "192.0.2.1",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    last_used_at: new Date("2022-05-18T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+    revoked_at: new Date("2022-05-20T16:00:00Z"),
+  },
+  {
+    // refresh token "expired-refresh-token"
+    _id: "9f3a4b5c-6d7e-4f8a-8b9c-1d2e3f4a5b6c",
+    user_id: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+    token_hash: "b810b42b1e2a00760df44961d3ce371a3a1534b14f96d104288e7d8205179d19",
+    previous_hashes: [],
+    user_agent: "curl/7.79.1",
+    ip: "192.0.2.2",
+    created_at: new Date("2020-05-18T16:00:00Z"),
+    last_used_at: new Date("2020-05-18T16:00:00Z"),
+    expires_at: new Date("2020-06-18T16:00:00Z"),
+  },
+  {
+    // refresh token "rotate-refresh-token"
+    _id: "0a4b5c6d-7e8f-4a9b-9c0d-2e3f4a5b6c7d",
+    user_id: "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739",
+    token_hash: "1aee5ea4cabcd0bb5c50adcaeaf8e460748f946e27917487582cdfd65faa2c26",
+    previous_hashes: [],
+    user_agent: "Mozilla/5.0",
+    ip: "192.0.2.3",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    last_used_at: new Date("2022-05-18T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+  },
+  {
+    // refresh token "revoke-refresh-token"
+    _id: "1b5c6d7e-8f9a-4b0c-8d1e-3f4a5b6c7d8e",
+    user_id: "5cace01f-45c3-49f0-a725-c22866874095",
+    token_hash: "f0066b65b3a2caad50d892befc0903d3ff128f17184145b1cc5386f48e0918c7",
+    previous_hashes: [],
+    user_agent: "Mozilla/5.0",
+    ip: "192.0.2.4",
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    last_used_at: new Date("2022-05-18T16:00:00Z"),
+    expires_at: new Date("2100-01-01T00:00:00Z"),
+  },
+]);
@@ -0,0 +1,134 @@
+package mongodb
+
+import (
+	"context"
+	"strings"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/sessions"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongodbCollection - collection of the sessions, with a unique index on the current token hash
+// and indexes on the previous hashes and the user ID
+const mongodbCollection = "sessions"
+
+type mongodbRepository struct {
+	db *mongo.Collection
+}
+
+var _ sessions.Repository = mongodbRepository{}
+var _ sessions.Repository = (*mongodbRepository)(nil)
+
+// NewMongoDBRepository - returns a new instance for the sessions mongodb repository
+func NewMongoDBRepository(db *mongo.Database) sessions.Repository {
+	return &mongodbRepository{db.Collection(mongodbCollection)}
+}
+
+// Create - inserts the session into the database and returns the inserted version
+func (r mongodbRepository) Create(ctx context.Context, session models.Session) (*models.Session, error) {
+	session.ID = strings.ToLower(uuid.New().String())
+	session.UserID = strings.ToLower(session.UserID)
+	session.RevokedAt = nil
+	if session.PreviousHashes == nil {
+		session.PreviousHashes = []string{}
+	}
+
+	if _, err := r.db.InsertOne(ctx, &session); err != nil {
+		logrus.Errorf("Error in sessions/repository/mongodb.Create -> error: %s", err)
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetByTokenHash - returns the session whose current or previous refresh tokens have the hash
+func (r mongodbRepository) GetByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"token_hash": hash}, bson.M{"previous_hashes": hash}}}
+
+	var session models.Session
+	if err := r.db.FindOne(ctx, filter).Decode(&session); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in sessions/repository/mongodb.GetByTokenHash -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Rotate - atomically replaces the current refresh token hash, so only one of the concurrent rotations succeeds
+func (r mongodbRepository) Rotate(ctx context.Context, id, currentHash, newHash string, expiresAt time.Time) error {
+	filter := bson.M{"_id": strings.ToLower(id), "token_hash": currentHash, "revoked_at": nil}
+	update := bson.M{
+		"$set": bson.M{
+			"token_hash":   newHash,
+			"last_used_at": time.Now().UTC().Truncate(time.Millisecond),
+			"expires_at":   expiresAt,
+		},
+		"$push": bson.M{"previous_hashes": currentHash},
+	}
+	res, err := r.db.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.Errorf("Error in sessions/repository/mongodb.Rotate -> error: %s", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// Revoke - sets the revocation date of the session
+func (r mongodbRepository) Revoke(ctx context.Context, userID, id string) error {
+	filter := bson.M{"_id": strings.ToLower(id), "user_id": strings.ToLower(userID), "revoked_at": nil}
+	res, err := r.db.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		logrus.Errorf("Error in sessions/repository/mongodb.Revoke -> error: %s", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// RevokeAll - sets the revocation date of all the not revoked sessions of the user
+func (r mongodbRepository) RevokeAll(ctx context.Context, userID string) error {
+	filter := bson.M{"user_id": strings.ToLower(userID), "revoked_at": nil}
+	if _, err := r.db.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}}); err != nil {
+		logrus.Errorf("Error in sessions/repository/mongodb.RevokeAll -> error: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListActive - returns the not revoked nor expired sessions of the user, the newest first
+func (r mongodbRepository) ListActive(ctx context.Context, userID string, now time.Time) ([]models.Session, error) {
+	filter := bson.M{
+		"user_id":    strings.ToLower(userID),
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	cursor, err := r.db.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		logrus.Errorf("Error in sessions/repository/mongodb.ListActive -> error executing find command: %s", err)
+		return nil, err
+	}
+
+	res := []models.Session{}
+	if err := cursor.All(ctx, &res); err != nil {
+		logrus.Errorf("Error in sessions/repository/mongodb.ListActive -> error decoding cursor: %s", err)
+		return nil, err
+	}
+
+	return res, nil
+}
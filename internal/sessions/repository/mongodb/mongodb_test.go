@@ -0,0 +1,193 @@
+package mongodb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/sessions"
+	"user-microservice/internal/sessions/repository/mongodb"
+	"user-microservice/internal/testutils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	activeSessionID = "7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a"
+	rotateSessionID = "0a4b5c6d-7e8f-4a9b-9c0d-2e3f4a5b6c7d"
+	revokeSessionID = "1b5c6d7e-8f9a-4b0c-8d1e-3f4a5b6c7d8e"
+	activeUserID    = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	revokeUserID    = "5cace01f-45c3-49f0-a725-c22866874095"
+)
+
+var dbClientTest *mongo.Client
+
+func TestMain(m *testing.M) {
+	dbClientTest = new(mongo.Client)
+	testutils.ExecuteTestMain(m, dbClientTest)
+}
+
+func TestMongoDBRepository_Create(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	session := models.Session{
+		UserID:     uuid.New().String(),
+		TokenHash:  sessions.HashToken("created-refresh-token"),
+		UserAgent:  "Mozilla/5.0",
+		IP:         "192.0.2.10",
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(time.Hour),
+	}
+
+	// When
+	res, err := repo.Create(ctx, session)
+
+	// Then
+	require.NoError(t, err)
+	require.NotNil(t, res, "Expected res not to be nil")
+	assert.NotEmpty(t, res.ID, "Expected ID not to be empty")
+	found, err := repo.GetByTokenHash(ctx, session.TokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, res.ID, found.ID)
+	assert.Equal(t, session.UserID, found.UserID)
+	assert.Empty(t, found.PreviousHashes)
+
+	// When the same token hash is inserted again
+	_, err = repo.Create(ctx, session)
+
+	// Then
+	assert.Truef(t, mongo.IsDuplicateKeyError(err), "Expected duplicate key error, but was %v", err)
+}
+
+func TestMongoDBRepository_GetByTokenHash(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		token         string
+		expectedID    string
+		expectedError error
+	}{
+		{"Get session by current token", "active-refresh-token", activeSessionID, nil},
+		{"Get session by rotated token", "rotated-refresh-token", activeSessionID, nil},
+		{"Get session by unknown token", "unknown-refresh-token", "", mongo.ErrNoDocuments},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+
+			// When
+			res, err := repo.GetByTokenHash(context.TODO(), sessions.HashToken(tc.token))
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+				assert.Equalf(t, tc.expectedError, err, "Expected error to be %s, but was %s", tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, res, "Expected res not to be nil")
+				assert.Equal(t, tc.expectedID, res.ID)
+				assert.Equal(t, sessions.HashToken("active-refresh-token"), res.TokenHash)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_Rotate(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	currentHash := sessions.HashToken("rotate-refresh-token")
+	newHash := sessions.HashToken("new-rotate-refresh-token")
+	expiresAt := time.Now().UTC().Truncate(time.Millisecond).Add(time.Hour)
+
+	// When
+	err := repo.Rotate(ctx, rotateSessionID, currentHash, newHash, expiresAt)
+
+	// Then
+	require.NoError(t, err)
+	res, err := repo.GetByTokenHash(ctx, currentHash)
+	require.NoError(t, err)
+	assert.Equal(t, newHash, res.TokenHash)
+	assert.Equal(t, []string{currentHash}, res.PreviousHashes)
+	assert.True(t, expiresAt.Equal(res.ExpiresAt), "Expected ExpiresAt to be %s, but was %s", expiresAt, res.ExpiresAt)
+
+	// When the rotated hash is rotated again
+	err = repo.Rotate(ctx, rotateSessionID, currentHash, sessions.HashToken("other-rotate-refresh-token"), expiresAt)
+
+	// Then
+	assert.Equalf(t, mongo.ErrNoDocuments, err, "Expected error to be %s, but was %v", mongo.ErrNoDocuments, err)
+}
+
+func TestMongoDBRepository_Revoke(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		userID        string
+		id            string
+		expectedError error
+	}{
+		{"Revoke session of other user", activeUserID, revokeSessionID, mongo.ErrNoDocuments},
+		{"Revoke already revoked session", activeUserID, "8e2f3a4b-5c6d-4e7f-9a8b-0c1d2e3f4a5b", mongo.ErrNoDocuments},
+		{"Revoke not found session", revokeUserID, uuid.New().String(), mongo.ErrNoDocuments},
+		{"Revoke session successfully", revokeUserID, revokeSessionID, nil},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+
+			// When
+			err := repo.Revoke(ctx, tc.userID, tc.id)
+
+			// Then
+			assert.Equalf(t, tc.expectedError, err, "Expected error to be %v, but was %v", tc.expectedError, err)
+			if tc.expectedError == nil {
+				active, err := repo.ListActive(ctx, tc.userID, time.Now())
+				require.NoError(t, err)
+				assert.Empty(t, active, "Expected the revoked session not to be active")
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_RevokeAll(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	userID := uuid.New().String()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, token := range []string{"first-revoke-all-token", "second-revoke-all-token"} {
+		_, err := repo.Create(ctx, models.Session{UserID: userID, TokenHash: sessions.HashToken(token), CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)})
+		require.NoError(t, err)
+	}
+
+	// When
+	err := repo.RevokeAll(ctx, userID)
+
+	// Then
+	require.NoError(t, err)
+	active, err := repo.ListActive(ctx, userID, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, active, "Expected all the sessions to be revoked")
+}
+
+func TestMongoDBRepository_ListActive(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+
+	// When
+	res, err := repo.ListActive(context.TODO(), activeUserID, time.Now())
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, res, 1, "Expected the revoked and expired sessions not to be listed")
+	assert.Equal(t, activeSessionID, res[0].ID)
+}
@@ -0,0 +1,283 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	"user-microservice/internal/users/sec"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultRefreshTokenTTL - default time a session is kept alive without refreshing it
+const DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidCredentials - the email does not exist or the password does not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidRefreshToken - the refresh token does not exist, is expired, was revoked or was already used
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrUserNotActive - the user is suspended or banned
+	ErrUserNotActive = errors.New("user not active")
+)
+
+// SecondFactor - checks the second factor of the users on login
+type SecondFactor interface {
+	// Check - returns nil if the user did not enroll a second factor or the code is valid for it
+	Check(ctx context.Context, userID, code string) error
+}
+
+// Lockout - counts the failed logins and locks out the accounts and IPs with too many failures
+type Lockout interface {
+	// Check - returns an error if the logins of the email or the IP are locked out
+	Check(ctx context.Context, email, ip string) error
+	// Fail - counts a failed login of the email from the IP
+	Fail(ctx context.Context, email, ip string)
+	// Succeed - resets the failed logins of the email
+	Succeed(ctx context.Context, email string)
+}
+
+// Manager - logs in the users and manages their sessions.
+// The refresh tokens are rotated on every use, and the reuse of a rotated token revokes its whole session (token family)
+type Manager struct {
+	users      users.Repository
+	repository Repository
+	signer     *auth.Signer
+	refreshTTL time.Duration
+	// secondFactor - nil if the second factor is disabled
+	secondFactor SecondFactor
+	// lockout - nil if the lockout is disabled
+	lockout Lockout
+	// dummyHash - compared when the email does not exist, so the response time does not reveal it
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+// NewManager - returns a new Manager with sessions valid for the given refreshTTL (DefaultRefreshTokenTTL if it's not positive).
+// The secondFactor can be nil, then only the passwords are checked, and the lockout can be nil, then the failed logins are not limited
+func NewManager(usersRepository users.Repository, repository Repository, signer *auth.Signer, refreshTTL time.Duration, secondFactor SecondFactor, lockout Lockout) *Manager {
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTokenTTL
+	}
+	return &Manager{
+		users:        usersRepository,
+		repository:   repository,
+		signer:       signer,
+		refreshTTL:   refreshTTL,
+		secondFactor: secondFactor,
+		lockout:      lockout,
+	}
+}
+
+// Login - verifies the credentials and starts a new session, returning its tokens.
+// Returns ErrInvalidCredentials if the email does not exist or the password does not match, ErrUserNotActive if the
+// user is suspended or banned, the second factor errors if the user enrolled one and the code is not valid, and the
+// lockout errors if the email or the IP are locked out. The wrong passwords and codes are counted as failed logins
+func (m *Manager) Login(ctx context.Context, credentials models.Credentials, userAgent, ip string) (*models.Tokens, error) {
+	email, password := credentials.Email, credentials.Password
+	if m.lockout != nil {
+		if err := m.lockout.Check(ctx, email, ip); err != nil {
+			return nil, err
+		}
+	}
+	user, err := m.users.GetByEmail(ctx, email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			sec.CheckPassword(m.getDummyHash(), password)
+			m.fail(ctx, email, ip)
+			return nil, ErrInvalidCredentials
+		}
+		logrus.Errorf("Error in sessions.Login -> error getting user: %s", err)
+		return nil, err
+	}
+	if !sec.CheckPassword(user.Password, password) {
+		m.fail(ctx, email, ip)
+		return nil, ErrInvalidCredentials
+	}
+	if status := user.CurrentStatus(time.Now()); status != models.StatusActive {
+		return nil, fmt.Errorf("%w: user %s is %s", ErrUserNotActive, user.ID, status)
+	}
+	if m.secondFactor != nil {
+		if err := m.secondFactor.Check(ctx, user.ID, credentials.Code); err != nil {
+			// asking for the code is not a failure, guessing it is
+			if credentials.Code != "" {
+				m.fail(ctx, email, ip)
+			}
+			return nil, err
+		}
+	}
+	if m.lockout != nil {
+		m.lockout.Succeed(ctx, email)
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		logrus.Errorf("Error in sessions.Login -> error generating refresh token: %s", err)
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	session, err := m.repository.Create(ctx, models.Session{
+		UserID:         user.ID,
+		TokenHash:      HashToken(refreshToken),
+		PreviousHashes: []string{},
+		UserAgent:      userAgent,
+		IP:             ip,
+		CreatedAt:      now,
+		LastUsedAt:     now,
+		ExpiresAt:      now.Add(m.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"user": user.ID, "session": session.ID}).Info("User logged in")
+
+	return m.tokens(session.UserID, refreshToken)
+}
+
+// Refresh - rotates the refresh token, returning the new tokens of the session.
+// Returns ErrInvalidRefreshToken if the token is not the current one of an active session or the user is not active.
+// If a rotated token is reused, the session is revoked because the token may have been stolen
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (*models.Tokens, error) {
+	hash := HashToken(refreshToken)
+	session, err := m.repository.GetByTokenHash(ctx, hash)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: unknown token", ErrInvalidRefreshToken)
+		}
+		logrus.Errorf("Error in sessions.Refresh -> error getting session: %s", err)
+		return nil, err
+	}
+
+	if session.TokenHash != hash {
+		return nil, m.revokeReused(ctx, *session)
+	}
+	if !session.Active(time.Now()) {
+		return nil, fmt.Errorf("%w: session %s is not active", ErrInvalidRefreshToken, session.ID)
+	}
+	user, err := m.users.GetById(ctx, session.UserID, "id", "status", "suspendedUntil")
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			m.revoke(ctx, *session)
+			return nil, fmt.Errorf("%w: user %s not found", ErrInvalidRefreshToken, session.UserID)
+		}
+		logrus.Errorf("Error in sessions.Refresh -> error getting user: %s", err)
+		return nil, err
+	}
+	if status := user.CurrentStatus(time.Now()); status != models.StatusActive {
+		// the sessions of the suspended and banned users are ended, so they have to log in again once reactivated
+		m.revoke(ctx, *session)
+		return nil, fmt.Errorf("%w: user %s is %s", ErrInvalidRefreshToken, session.UserID, status)
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		logrus.Errorf("Error in sessions.Refresh -> error generating refresh token: %s", err)
+		return nil, err
+	}
+	expiresAt := time.Now().UTC().Truncate(time.Millisecond).Add(m.refreshTTL)
+	if err := m.repository.Rotate(ctx, session.ID, hash, HashToken(newToken), expiresAt); err != nil {
+		if err == mongo.ErrNoDocuments {
+			// the token was rotated by a concurrent request, so it's reused
+			return nil, m.revokeReused(ctx, *session)
+		}
+		return nil, err
+	}
+
+	return m.tokens(session.UserID, newToken)
+}
+
+// Logout - revokes the session of the refresh token. Unknown or already revoked sessions are ignored
+func (m *Manager) Logout(ctx context.Context, refreshToken string) error {
+	session, err := m.repository.GetByTokenHash(ctx, HashToken(refreshToken))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		logrus.Errorf("Error in sessions.Logout -> error getting session: %s", err)
+		return err
+	}
+	if err := m.repository.Revoke(ctx, session.UserID, session.ID); err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	return nil
+}
+
+// List - returns the active sessions of the user
+func (m *Manager) List(ctx context.Context, userID string) ([]models.Session, error) {
+	return m.repository.ListActive(ctx, userID, time.Now())
+}
+
+// Revoke - revokes the session of the user, returning mongo.ErrNoDocuments if it's not an active session of the user
+func (m *Manager) Revoke(ctx context.Context, userID, id string) error {
+	return m.repository.Revoke(ctx, userID, id)
+}
+
+// tokens - returns the tokens with a new access token for the user
+func (m *Manager) tokens(userID, refreshToken string) (*models.Tokens, error) {
+	accessToken, _, err := m.signer.Sign(auth.Principal{Subject: userID, Role: auth.RoleUser})
+	if err != nil {
+		logrus.Errorf("Error in sessions.tokens -> error signing access token: %s", err)
+		return nil, err
+	}
+
+	return &models.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.signer.TTL().Seconds()),
+	}, nil
+}
+
+// revokeReused - revokes the session (token family) of a reused refresh token and returns the error to respond with
+func (m *Manager) revokeReused(ctx context.Context, session models.Session) error {
+	logrus.WithFields(logrus.Fields{"user": session.UserID, "session": session.ID}).
+		Warn("Refresh token reused, revoking the session")
+	m.revoke(ctx, session)
+	return fmt.Errorf("%w: reused token of session %s", ErrInvalidRefreshToken, session.ID)
+}
+
+func (m *Manager) revoke(ctx context.Context, session models.Session) {
+	if err := m.repository.Revoke(ctx, session.UserID, session.ID); err != nil && err != mongo.ErrNoDocuments {
+		logrus.Errorf("Error in sessions.revoke -> error revoking session %s: %s", session.ID, err)
+	}
+}
+
+// fail - counts the failed login if the lockout is enabled
+func (m *Manager) fail(ctx context.Context, email, ip string) {
+	if m.lockout != nil {
+		m.lockout.Fail(ctx, email, ip)
+	}
+}
+
+func (m *Manager) getDummyHash() string {
+	m.dummyHashOnce.Do(func() {
+		m.dummyHash, _ = sec.HashPassword("dummy password")
+	})
+	return m.dummyHash
+}
+
+// newRefreshToken - returns a new random refresh token
+func newRefreshToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// HashToken - returns the hex encoded SHA-256 hash of the refresh token, the only thing stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,422 @@
+package sessions_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/lockout"
+	"user-microservice/internal/mfa"
+	"user-microservice/internal/models"
+	"user-microservice/internal/sessions"
+	"user-microservice/internal/sessions/mock"
+	usersMock "user-microservice/internal/users/mock"
+	"user-microservice/internal/users/sec"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+
+var testSecret = []byte("test-secret")
+
+func TestMain(m *testing.M) {
+	sec.Cost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// fakeSecondFactor - sessions.SecondFactor returning the same error on every check
+type fakeSecondFactor struct {
+	err error
+}
+
+func (f fakeSecondFactor) Check(context.Context, string, string) error {
+	return f.err
+}
+
+// fakeLockout - sessions.Lockout recording the failed and succeeded logins
+type fakeLockout struct {
+	err       error
+	failures  int
+	successes int
+}
+
+func (f *fakeLockout) Check(context.Context, string, string) error {
+	return f.err
+}
+
+func (f *fakeLockout) Fail(context.Context, string, string) {
+	f.failures++
+}
+
+func (f *fakeLockout) Succeed(context.Context, string) {
+	f.successes++
+}
+
+func newManager(t *testing.T, usersRepo *usersMock.MockRepository, repo *mock.MockRepository, secondFactor sessions.SecondFactor, lockout sessions.Lockout) (*sessions.Manager, *auth.Validator) {
+	t.Helper()
+	signer, err := auth.NewSigner(testSecret, "test-issuer", "", time.Minute)
+	require.NoError(t, err)
+	validator, err := auth.NewValidator(auth.Options{Secret: testSecret, Issuer: "test-issuer"})
+	require.NoError(t, err)
+	return sessions.NewManager(usersRepo, repo, signer, time.Hour, secondFactor, lockout), validator
+}
+
+func TestManager_Login(t *testing.T) {
+	hashed, err := sec.HashPassword("Login Password")
+	require.NoError(t, err)
+	user := models.User{ID: userID, Email: "alicetingo@example.com", Password: hashed}
+	suspendedUntil := time.Now().Add(time.Hour)
+	suspended := user
+	suspended.Status, suspended.SuspendedUntil = models.StatusSuspended, &suspendedUntil
+	endedSuspension := user
+	endedUntil := time.Now().Add(-time.Minute)
+	endedSuspension.Status, endedSuspension.SuspendedUntil = models.StatusSuspended, &endedUntil
+	banned := user
+	banned.Status = models.StatusBanned
+
+	for _, tc := range []struct {
+		name          string
+		email         string
+		password      string
+		mockedUser    *models.User
+		mockedError   error
+		factorError   error
+		expectedError error
+	}{
+		{
+			"Login successfully",
+			"alicetingo@example.com",
+			"Login Password",
+			&user,
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"Login with wrong password",
+			"alicetingo@example.com",
+			"Wrong Password",
+			&user,
+			nil,
+			nil,
+			sessions.ErrInvalidCredentials,
+		},
+		{
+			"Login with unknown email",
+			"unknown@example.com",
+			"Login Password",
+			nil,
+			mongo.ErrNoDocuments,
+			nil,
+			sessions.ErrInvalidCredentials,
+		},
+		{
+			"Login with repository error",
+			"alicetingo@example.com",
+			"Login Password",
+			nil,
+			errors.New("homemade error"),
+			nil,
+			errors.New("homemade error"),
+		},
+		{
+			"Login without the second factor code",
+			"alicetingo@example.com",
+			"Login Password",
+			&user,
+			nil,
+			mfa.ErrCodeRequired,
+			mfa.ErrCodeRequired,
+		},
+		{
+			"Login suspended user",
+			"alicetingo@example.com",
+			"Login Password",
+			&suspended,
+			nil,
+			nil,
+			sessions.ErrUserNotActive,
+		},
+		{
+			"Login banned user",
+			"alicetingo@example.com",
+			"Login Password",
+			&banned,
+			nil,
+			nil,
+			sessions.ErrUserNotActive,
+		},
+		{
+			"Login user whose suspension ended",
+			"alicetingo@example.com",
+			"Login Password",
+			&endedSuspension,
+			nil,
+			nil,
+			nil,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			repo := mock.NewMockRepository(ctrl)
+			manager, validator := newManager(t, usersRepo, repo, fakeSecondFactor{tc.factorError}, nil)
+			ctx := context.Background()
+
+			usersRepo.EXPECT().GetByEmail(ctx, tc.email).Return(tc.mockedUser, tc.mockedError)
+			var created models.Session
+			createTimes := 0
+			if tc.expectedError == nil {
+				createTimes = 1
+			}
+			repo.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, session models.Session) (*models.Session, error) {
+				session.ID = "7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a"
+				created = session
+				return &session, nil
+			}).Times(createTimes)
+
+			// When
+			res, err := manager.Login(ctx, models.Credentials{Email: tc.email, Password: tc.password}, "test-agent", "192.0.2.1")
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+				if errors.Is(tc.expectedError, sessions.ErrInvalidCredentials) || errors.Is(tc.expectedError, mfa.ErrCodeRequired) ||
+					errors.Is(tc.expectedError, sessions.ErrUserNotActive) {
+					assert.ErrorIs(t, err, tc.expectedError)
+				} else {
+					assert.EqualError(t, err, tc.expectedError.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			assert.Equal(t, "Bearer", res.TokenType)
+			assert.Equal(t, int64(60), res.ExpiresIn)
+			assert.Equal(t, sessions.HashToken(res.RefreshToken), created.TokenHash, "Expected only the refresh token hash to be stored")
+			assert.Equal(t, userID, created.UserID)
+			assert.Equal(t, "test-agent", created.UserAgent)
+			assert.Equal(t, "192.0.2.1", created.IP)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), created.ExpiresAt, 5*time.Second)
+
+			claims, err := validator.Validate(ctx, res.AccessToken)
+			require.NoErrorf(t, err, "Expected the access token to be valid, but was %s", err)
+			assert.Equal(t, userID, claims.Subject)
+			assert.Equal(t, auth.RoleUser, claims.Role)
+		})
+	}
+}
+
+func TestManager_LoginLockout(t *testing.T) {
+	hashed, err := sec.HashPassword("Login Password")
+	require.NoError(t, err)
+	user := models.User{ID: userID, Email: "alicetingo@example.com", Password: hashed}
+	lockedErr := &lockout.LockedError{Kind: lockout.KindIP, RetryAfter: time.Minute}
+
+	for _, tc := range []struct {
+		name              string
+		password          string
+		code              string
+		lockoutError      error
+		factorError       error
+		expectedError     error
+		expectedFailures  int
+		expectedSuccesses int
+	}{
+		{"Login successfully resets the failures", "Login Password", "", nil, nil, nil, 0, 1},
+		{"Login with wrong password counts a failure", "Wrong Password", "", nil, nil, sessions.ErrInvalidCredentials, 1, 0},
+		{"Login without the second factor code is not a failure", "Login Password", "", nil, mfa.ErrCodeRequired, mfa.ErrCodeRequired, 0, 0},
+		{"Login with wrong second factor code counts a failure", "Login Password", "000000", nil, mfa.ErrInvalidCode, mfa.ErrInvalidCode, 1, 0},
+		{"Login locked out", "Login Password", "", lockedErr, nil, lockout.ErrLocked, 0, 0},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			repo := mock.NewMockRepository(ctrl)
+			limiter := &fakeLockout{err: tc.lockoutError}
+			manager, _ := newManager(t, usersRepo, repo, fakeSecondFactor{tc.factorError}, limiter)
+			ctx := context.Background()
+
+			getTimes := 1
+			if tc.lockoutError != nil {
+				getTimes = 0
+			}
+			usersRepo.EXPECT().GetByEmail(ctx, user.Email).Return(&user, nil).Times(getTimes)
+			repo.EXPECT().Create(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, session models.Session) (*models.Session, error) {
+				return &session, nil
+			}).MaxTimes(1)
+
+			// When
+			_, err := manager.Login(ctx, models.Credentials{Email: user.Email, Password: tc.password, Code: tc.code}, "test-agent", "192.0.2.1")
+
+			// Then
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedFailures, limiter.failures)
+			assert.Equal(t, tc.expectedSuccesses, limiter.successes)
+		})
+	}
+}
+
+func TestManager_Refresh(t *testing.T) {
+	const (
+		currentToken = "current-refresh-token"
+		rotatedToken = "rotated-refresh-token"
+	)
+	revokedAt := time.Now().Add(-time.Minute)
+	active := models.Session{
+		ID:             "7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a",
+		UserID:         userID,
+		TokenHash:      sessions.HashToken(currentToken),
+		PreviousHashes: []string{sessions.HashToken(rotatedToken)},
+		ExpiresAt:      time.Now().Add(time.Hour),
+	}
+	expired := active
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	revoked := active
+	revoked.RevokedAt = &revokedAt
+
+	for _, tc := range []struct {
+		name          string
+		token         string
+		mockedSession *models.Session
+		mockedError   error
+		userError     error
+		userStatus    models.UserStatus
+		rotateError   error
+		checkUser     bool
+		shouldRotate  bool
+		shouldRevoke  bool
+		expectedError error
+	}{
+		{"Refresh successfully", currentToken, &active, nil, nil, "", nil, true, true, false, nil},
+		{"Refresh with unknown token", "unknown-token", nil, mongo.ErrNoDocuments, nil, "", nil, false, false, false, sessions.ErrInvalidRefreshToken},
+		{"Refresh with rotated token revokes the session", rotatedToken, &active, nil, nil, "", nil, false, false, true, sessions.ErrInvalidRefreshToken},
+		{"Refresh with expired session", currentToken, &expired, nil, nil, "", nil, false, false, false, sessions.ErrInvalidRefreshToken},
+		{"Refresh with revoked session", currentToken, &revoked, nil, nil, "", nil, false, false, false, sessions.ErrInvalidRefreshToken},
+		{"Refresh with deleted user revokes the session", currentToken, &active, nil, mongo.ErrNoDocuments, "", nil, true, false, true, sessions.ErrInvalidRefreshToken},
+		{"Refresh concurrently rotated token revokes the session", currentToken, &active, nil, nil, "", mongo.ErrNoDocuments, true, true, true, sessions.ErrInvalidRefreshToken},
+		{"Refresh with banned user revokes the session", currentToken, &active, nil, nil, models.StatusBanned, nil, true, false, true, sessions.ErrInvalidRefreshToken},
+		{"Refresh with repository error", currentToken, nil, errors.New("homemade error"), nil, "", nil, false, false, false, errors.New("homemade error")},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			repo := mock.NewMockRepository(ctrl)
+			manager, _ := newManager(t, usersRepo, repo, nil, nil)
+			ctx := context.Background()
+
+			repo.EXPECT().GetByTokenHash(ctx, sessions.HashToken(tc.token)).Return(tc.mockedSession, tc.mockedError)
+			userTimes := 0
+			if tc.checkUser {
+				userTimes = 1
+			}
+			usersRepo.EXPECT().GetById(ctx, userID, "id", "status", "suspendedUntil").Return(&models.User{ID: userID, Status: tc.userStatus}, tc.userError).Times(userTimes)
+			var newHash string
+			rotateTimes := 0
+			if tc.shouldRotate {
+				rotateTimes = 1
+			}
+			repo.EXPECT().Rotate(ctx, active.ID, active.TokenHash, gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _, hash string, expiresAt time.Time) error {
+					newHash = hash
+					assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 5*time.Second)
+					return tc.rotateError
+				}).Times(rotateTimes)
+			revokeTimes := 0
+			if tc.shouldRevoke {
+				revokeTimes = 1
+			}
+			repo.EXPECT().Revoke(ctx, userID, active.ID).Return(nil).Times(revokeTimes)
+
+			// When
+			res, err := manager.Refresh(ctx, tc.token)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+				if errors.Is(tc.expectedError, sessions.ErrInvalidRefreshToken) {
+					assert.ErrorIs(t, err, sessions.ErrInvalidRefreshToken)
+				} else {
+					assert.EqualError(t, err, tc.expectedError.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			assert.NotEqual(t, tc.token, res.RefreshToken, "Expected the refresh token to be rotated")
+			assert.Equal(t, sessions.HashToken(res.RefreshToken), newHash, "Expected the new refresh token hash to be stored")
+			assert.NotEmpty(t, res.AccessToken)
+		})
+	}
+}
+
+func TestManager_Logout(t *testing.T) {
+	session := models.Session{ID: "7d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a", UserID: userID}
+	for _, tc := range []struct {
+		name          string
+		mockedSession *models.Session
+		mockedError   error
+		revokeError   error
+		shouldRevoke  bool
+		expectedError error
+	}{
+		{"Logout successfully", &session, nil, nil, true, nil},
+		{"Logout with already revoked session", &session, nil, mongo.ErrNoDocuments, true, nil},
+		{"Logout with unknown token", nil, mongo.ErrNoDocuments, nil, false, nil},
+		{"Logout with repository error", &session, nil, errors.New("homemade error"), true, errors.New("homemade error")},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock.NewMockRepository(ctrl)
+			manager, _ := newManager(t, usersMock.NewMockRepository(ctrl), repo, nil, nil)
+			ctx := context.Background()
+
+			repo.EXPECT().GetByTokenHash(ctx, sessions.HashToken("logout-token")).Return(tc.mockedSession, tc.mockedError)
+			revokeTimes := 0
+			if tc.shouldRevoke {
+				revokeTimes = 1
+			}
+			repo.EXPECT().Revoke(ctx, userID, session.ID).Return(tc.revokeError).Times(revokeTimes)
+
+			// When
+			err := manager.Logout(ctx, "logout-token")
+
+			// Then
+			if tc.expectedError != nil {
+				assert.EqualError(t, err, tc.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package testutils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -9,12 +10,18 @@ import (
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const testingDatabaseName = "test"
 
+const replicaSetName = "rs0"
+
+// alreadyInitializedCode - mongo error code returned when the replica set is already initiated
+const alreadyInitializedCode = 23
+
 // ExecuteTestMain - executes a custom TestMain function
 func ExecuteTestMain(m *testing.M, client *mongo.Client) {
 
@@ -54,6 +61,8 @@ func SetupDB(client *mongo.Client) (*dockertest.Pool, *dockertest.Resource, erro
 		Repository: "mongo",
 		Tag:        "6",
 		Env:        []string{},
+		// the users changes are audited inside transactions, which require a replica set
+		Cmd: []string{"--replSet", replicaSetName},
 		Mounts: []string{
 			dir + "/init_db.js:/docker-entrypoint-initdb.d/init_db.js",
 		},
@@ -75,7 +84,7 @@ func SetupDB(client *mongo.Client) (*dockertest.Pool, *dockertest.Resource, erro
 		_client, err := mongo.Connect(
 			context.TODO(),
 			options.Client().ApplyURI(
-				fmt.Sprintf("mongodb://localhost:%s", resource.GetPort("27017/tcp")),
+				fmt.Sprintf("mongodb://localhost:%s/?directConnection=true", resource.GetPort("27017/tcp")),
 			),
 		)
 		if err != nil {
@@ -89,9 +98,40 @@ func SetupDB(client *mongo.Client) (*dockertest.Pool, *dockertest.Resource, erro
 		return nil, nil, err
 	}
 
+	if err := initiateReplicaSet(pool, client); err != nil {
+		logrus.Fatalf("Could not initiate the replica set: %s", err)
+		return nil, nil, err
+	}
+
 	return pool, resource, nil
 }
 
+// initiateReplicaSet - initiates the single node replica set and waits until the node is the primary
+func initiateReplicaSet(pool *dockertest.Pool, client *mongo.Client) error {
+	admin := client.Database("admin")
+	err := admin.RunCommand(context.TODO(), bson.D{{Key: "replSetInitiate", Value: bson.M{
+		"_id":     replicaSetName,
+		"members": bson.A{bson.M{"_id": 0, "host": "localhost:27017"}},
+	}}}).Err()
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == alreadyInitializedCode) {
+		return err
+	}
+
+	return pool.Retry(func() error {
+		var res struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		if err := admin.RunCommand(context.TODO(), bson.D{{Key: "hello", Value: 1}}).Decode(&res); err != nil {
+			return err
+		}
+		if !res.IsWritablePrimary {
+			return errors.New("replica set node is not the primary yet")
+		}
+		return nil
+	})
+}
+
 func Cleanup(pool *dockertest.Pool, resource *dockertest.Resource, client *mongo.Client) error {
 	// When you're done, kill and remove the container
 	if err := pool.Purge(resource); err != nil {
@@ -0,0 +1,54 @@
+package bulk
+
+import (
+	"mime"
+	"strings"
+)
+
+// Format - bulk data format
+type Format string
+
+const (
+	// FormatNDJSON - newline delimited JSON, one user per line
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV - comma separated values with a header row
+	FormatCSV Format = "csv"
+)
+
+const (
+	// ContentTypeNDJSON - content type used for the FormatNDJSON
+	ContentTypeNDJSON = "application/x-ndjson"
+	// ContentTypeCSV - content type used for the FormatCSV
+	ContentTypeCSV = "text/csv"
+)
+
+// Valid - returns true if the Format is valid
+func (f Format) Valid() bool {
+	return f == FormatNDJSON || f == FormatCSV
+}
+
+// ContentType - returns the content type for the current Format
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return ContentTypeCSV
+	}
+	return ContentTypeNDJSON
+}
+
+// FormatFromContentType - returns the Format for the given content type.
+// Returns an empty Format if the content type is not supported
+func FormatFromContentType(contentType string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(mediaType) {
+	case ContentTypeNDJSON, "application/ndjson", "application/jsonl":
+		return FormatNDJSON
+	case ContentTypeCSV:
+		return FormatCSV
+	}
+
+	return ""
+}
@@ -0,0 +1,165 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"user-microservice/internal/audit"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/verification"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultChunkSize - default number of users inserted at once
+	DefaultChunkSize = 500
+	// MaxReportedErrors - maximum number of row errors included in the ImportReport
+	MaxReportedErrors = 1000
+)
+
+// ErrInvalidData - the bulk data could not be read (e.g. wrong csv header)
+var ErrInvalidData = errors.New("invalid bulk data")
+
+// ImportReport - result of an import
+type ImportReport struct {
+	DryRun   bool       `json:"dryRun"`
+	Total    int        `json:"total"`    // Total is the number of processed rows
+	Imported int        `json:"imported"` // Imported is the number of inserted users (or valid ones in dry run mode)
+	Failed   int        `json:"failed"`
+	Errors   []RowError `json:"errors"` // Errors contains up to MaxReportedErrors row errors
+}
+
+func (r *ImportReport) addError(rowErr RowError) {
+	r.Failed++
+	if len(r.Errors) < MaxReportedErrors {
+		r.Errors = append(r.Errors, rowErr)
+	}
+}
+
+// Importer - imports users in bulk
+type Importer struct {
+	repository       users.Repository
+	pubsubRepository userPS.PubSub
+	// verifier - sends the email verification tokens, nil if the email verification is disabled
+	verifier  verification.Sender
+	chunkSize int
+}
+
+// NewImporter - returns a new Importer. If chunkSize is not positive, DefaultChunkSize is used.
+// The verifier can be nil, then no email verification token is sent
+func NewImporter(usersRepository users.Repository, pubsubRepository userPS.PubSub, verifier verification.Sender, chunkSize int) *Importer {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &Importer{usersRepository, pubsubRepository, verifier, chunkSize}
+}
+
+// Import - reads the users from r with the given format, validates them the same way CreateUser does
+// and inserts the valid ones in chunks, publishing the creation events for each chunk.
+// In dry run mode the users are only validated. The returned error is only set when the
+// import could not continue (ErrInvalidData or database errors), row errors are in the report
+func (i Importer) Import(ctx context.Context, r io.Reader, format Format, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun, Errors: []RowError{}}
+
+	reader, err := newRowReader(r, format)
+	if err != nil {
+		logrus.Errorf("Error in users/bulk.Import -> error creating reader: %s", err)
+		return report, fmt.Errorf("%w: %s", ErrInvalidData, err)
+	}
+
+	chunk := make([]models.User, 0, i.chunkSize)
+	rows := make([]int, 0, i.chunkSize)
+	for {
+		row, user, rowErr, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logrus.Errorf("Error in users/bulk.Import -> error reading row %d: %s", row, err)
+			return report, fmt.Errorf("%w: %s", ErrInvalidData, err)
+		}
+
+		report.Total++
+		if rowErr != nil {
+			report.addError(*rowErr)
+			continue
+		}
+		if !user.Valid() {
+			report.addError(RowError{Row: row, Error: httpErrors.ErrInvalidBody})
+			continue
+		}
+		user.PrepareNew()
+
+		chunk = append(chunk, user)
+		rows = append(rows, row)
+		if len(chunk) == i.chunkSize {
+			if err := i.flush(ctx, chunk, rows, &report); err != nil {
+				return report, err
+			}
+			chunk = chunk[:0]
+			rows = rows[:0]
+		}
+	}
+
+	if err := i.flush(ctx, chunk, rows, &report); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// flush - inserts the chunk of users, publishes their creation and sends them the email verification
+func (i Importer) flush(ctx context.Context, chunk []models.User, rows []int, report *ImportReport) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+	if report.DryRun {
+		report.Imported += len(chunk)
+		return nil
+	}
+
+	created, err := i.repository.CreateMany(ctx, chunk)
+	if err != nil {
+		var bulkErr *users.BulkError
+		if !errors.As(err, &bulkErr) {
+			return err
+		}
+		indexes := make([]int, 0, len(bulkErr.Errors))
+		for index := range bulkErr.Errors {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+		for _, index := range indexes {
+			report.addError(RowError{Row: rows[index], Error: bulkErr.Errors[index].Error()})
+		}
+	}
+	report.Imported += len(created)
+
+	if err := i.pubsubRepository.NotifyUsersCreation(ctx, created); err != nil {
+		logrus.Errorf("Error in users/bulk.Import -> could not notify users creation: %s", err)
+	}
+	i.sendVerifications(audit.Detach(ctx), created)
+
+	return nil
+}
+
+// sendVerifications - sends the email verification tokens to the created users in background,
+// if the verification is enabled
+func (i Importer) sendVerifications(ctx context.Context, created []models.User) {
+	if i.verifier == nil || len(created) == 0 {
+		return
+	}
+	go func() {
+		for _, user := range created {
+			if err := i.verifier.Send(ctx, user); err != nil {
+				logrus.Errorf("Error in users/bulk.sendVerifications -> could not send verification to user %s: %s", user.ID, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,288 @@
+package bulk_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	"user-microservice/internal/users/bulk"
+	"user-microservice/internal/users/mock"
+	verificationMock "user-microservice/internal/verification/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validUser(nickname string) models.User {
+	return models.User{
+		FirstName: "Import FirstName",
+		LastName:  "Import LastName",
+		Nickname:  nickname,
+		Password:  "Import Password",
+		Email:     "Import Email",
+		Country:   "DE",
+		Status:    models.StatusActive,
+	}
+}
+
+func TestImporter_Import(t *testing.T) {
+	validNDJSON := `{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"first","password":"Import Password","email":"Import Email","country":"DE"}
+{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"second","password":"Import Password","email":"Import Email","country":"DE"}
+
+{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"third","password":"Import Password","email":"Import Email","country":"DE"}
+`
+	validCSV := `firstName,lastName,nickname,password,email,country
+Import FirstName,Import LastName,first,Import Password,Import Email,DE
+Import FirstName,Import LastName,second,Import Password,Import Email,DE
+Import FirstName,Import LastName,third,Import Password,Import Email,DE
+`
+	for _, tc := range []struct {
+		name           string
+		data           string
+		format         bulk.Format
+		dryRun         bool
+		chunkSize      int
+		mockedChunks   [][]models.User
+		mockedErrors   []error
+		expectedReport bulk.ImportReport
+		expectedError  error
+	}{
+		{
+			"Import ndjson users in chunks",
+			validNDJSON,
+			bulk.FormatNDJSON,
+			false,
+			2,
+			[][]models.User{
+				{validUser("first"), validUser("second")},
+				{validUser("third")},
+			},
+			[]error{nil, nil},
+			bulk.ImportReport{Total: 3, Imported: 3, Errors: []bulk.RowError{}},
+			nil,
+		},
+		{
+			"Import csv users",
+			validCSV,
+			bulk.FormatCSV,
+			false,
+			10,
+			[][]models.User{
+				{validUser("first"), validUser("second"), validUser("third")},
+			},
+			[]error{nil},
+			bulk.ImportReport{Total: 3, Imported: 3, Errors: []bulk.RowError{}},
+			nil,
+		},
+		{
+			"Import ndjson users resetting the fields managed by the service",
+			`{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"first","password":"Import Password","email":"Import Email","country":"DE","emailVerified":true,"pendingEmail":"other@example.com","status":"banned","statusReason":"Import Reason"}
+`,
+			bulk.FormatNDJSON,
+			false,
+			10,
+			[][]models.User{
+				{validUser("first")},
+			},
+			[]error{nil},
+			bulk.ImportReport{Total: 1, Imported: 1, Errors: []bulk.RowError{}},
+			nil,
+		},
+		{
+			"Import users in dry run mode",
+			validNDJSON,
+			bulk.FormatNDJSON,
+			true,
+			2,
+			nil,
+			nil,
+			bulk.ImportReport{DryRun: true, Total: 3, Imported: 3, Errors: []bulk.RowError{}},
+			nil,
+		},
+		{
+			"Import ndjson users with invalid rows",
+			`{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"first","password":"Import Password","email":"Import Email","country":"DE"}
+invalid row
+{"firstName":"Import FirstName"}
+`,
+			bulk.FormatNDJSON,
+			false,
+			10,
+			[][]models.User{
+				{validUser("first")},
+			},
+			[]error{nil},
+			bulk.ImportReport{
+				Total:    3,
+				Imported: 1,
+				Failed:   2,
+				Errors: []bulk.RowError{
+					{Row: 2, Error: "invalid character 'i' looking for beginning of value"},
+					{Row: 3, Error: httpErrors.ErrInvalidBody},
+				},
+			},
+			nil,
+		},
+		{
+			"Import csv users with invalid rows",
+			`nickname,firstName,lastName,password,email,country
+first,Import FirstName,Import LastName,Import Password,Import Email,DE
+second,Import FirstName
+third,Import FirstName,Import LastName,Import Password,Import Email,
+`,
+			bulk.FormatCSV,
+			false,
+			10,
+			[][]models.User{
+				{validUser("first")},
+			},
+			[]error{nil},
+			bulk.ImportReport{
+				Total:    3,
+				Imported: 1,
+				Failed:   2,
+				Errors: []bulk.RowError{
+					{Row: 3, Error: "expected 6 columns, but was 2"},
+					{Row: 4, Error: httpErrors.ErrInvalidBody},
+				},
+			},
+			nil,
+		},
+		{
+			"Import users with insertion errors",
+			validNDJSON,
+			bulk.FormatNDJSON,
+			false,
+			10,
+			[][]models.User{
+				{validUser("first"), validUser("second"), validUser("third")},
+			},
+			[]error{&users.BulkError{Errors: map[int]error{2: errors.New("duplicated"), 0: errors.New("duplicated")}}},
+			bulk.ImportReport{
+				Total:    3,
+				Imported: 1,
+				Failed:   2,
+				Errors: []bulk.RowError{
+					{Row: 1, Error: "duplicated"},
+					{Row: 4, Error: "duplicated"},
+				},
+			},
+			nil,
+		},
+		{
+			"Import csv users with unknown column",
+			`nickname,homemade
+first,value
+`,
+			bulk.FormatCSV,
+			false,
+			10,
+			nil,
+			nil,
+			bulk.ImportReport{Errors: []bulk.RowError{}},
+			bulk.ErrInvalidData,
+		},
+		{
+			"Import users with database error",
+			validNDJSON,
+			bulk.FormatNDJSON,
+			false,
+			10,
+			[][]models.User{
+				{validUser("first"), validUser("second"), validUser("third")},
+			},
+			[]error{errors.New("homemade error")},
+			bulk.ImportReport{Total: 3, Errors: []bulk.RowError{}},
+			errors.New("homemade error"),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			importer := bulk.NewImporter(userRepo, pubsubRepo, nil, tc.chunkSize)
+			ctx := context.TODO()
+
+			var calls []*gomock.Call
+			for i, chunk := range tc.mockedChunks {
+				var created []models.User
+				if bulkErr, isOK := tc.mockedErrors[i].(*users.BulkError); isOK {
+					for j, user := range chunk {
+						if _, failed := bulkErr.Errors[j]; !failed {
+							created = append(created, user)
+						}
+					}
+				} else if tc.mockedErrors[i] == nil {
+					created = chunk
+				}
+				calls = append(calls, userRepo.EXPECT().CreateMany(ctx, chunk).Return(created, tc.mockedErrors[i]))
+				if created != nil {
+					pubsubRepo.EXPECT().NotifyUsersCreation(ctx, created).Return(nil)
+				}
+			}
+			gomock.InOrder(calls...)
+
+			// When
+			report, err := importer.Import(ctx, strings.NewReader(tc.data), tc.format, tc.dryRun)
+
+			// Then
+			if tc.expectedError != nil {
+				require.Error(t, err)
+				if errors.Is(tc.expectedError, bulk.ErrInvalidData) {
+					assert.ErrorIsf(t, err, bulk.ErrInvalidData, "Expected err to be %s, but was %s", bulk.ErrInvalidData, err)
+				} else {
+					assert.Equalf(t, tc.expectedError, err, "Expected err to be %s, but was %s", tc.expectedError, err)
+				}
+			} else {
+				require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			}
+			assert.Equalf(t, tc.expectedReport, report, "Expected report to be %v, but was %v", tc.expectedReport, report)
+		})
+	}
+}
+
+func TestImporter_ImportSendsVerification(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	userRepo := mock.NewMockRepository(ctrl)
+	pubsubRepo := mock.NewMockPubSub(ctrl)
+	verifier := verificationMock.NewMockSender(ctrl)
+	importer := bulk.NewImporter(userRepo, pubsubRepo, verifier, 10)
+	ctx := context.TODO()
+
+	first := validUser("first")
+	first.ID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	duplicated := validUser("second")
+	userRepo.EXPECT().
+		CreateMany(ctx, []models.User{validUser("first"), duplicated}).
+		Return([]models.User{first}, &users.BulkError{Errors: map[int]error{1: users.ErrDuplicateEmail}})
+	pubsubRepo.EXPECT().NotifyUsersCreation(ctx, []models.User{first}).Return(nil)
+	sent := make(chan struct{})
+	verifier.EXPECT().Send(gomock.Any(), first).DoAndReturn(func(context.Context, models.User) error {
+		close(sent)
+		return nil
+	})
+
+	// When
+	report, err := importer.Import(ctx, strings.NewReader(`{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"first","password":"Import Password","email":"Import Email","country":"DE"}
+{"firstName":"Import FirstName","lastName":"Import LastName","nickname":"second","password":"Import Password","email":"Import Email","country":"DE"}
+`), bulk.FormatNDJSON, false)
+
+	// Then
+	require.NoErrorf(t, err, "Expected no error, but was %s", err)
+	assert.Equalf(t, 1, report.Imported, "Expected 1 imported user, but were %d", report.Imported)
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the verification to be sent to the imported user")
+	}
+}
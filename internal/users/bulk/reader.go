@@ -0,0 +1,135 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"user-microservice/internal/models"
+)
+
+// maxLineSize - maximum size of a single NDJSON line
+const maxLineSize = 1024 * 1024
+
+// csvColumns - setters for the supported CSV columns (json names)
+var csvColumns = map[string]func(*models.User, string){
+	"firstName": func(u *models.User, v string) { u.FirstName = v },
+	"lastName":  func(u *models.User, v string) { u.LastName = v },
+	"nickname":  func(u *models.User, v string) { u.Nickname = v },
+	"password":  func(u *models.User, v string) { u.Password = v },
+	"email":     func(u *models.User, v string) { u.Email = v },
+	"country":   func(u *models.User, v string) { u.Country = v },
+}
+
+// RowError - error for a single row of the bulk data
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// rowReader - reads users one by one from the bulk data
+type rowReader interface {
+	// Next - returns the next row number and user. A non nil *RowError means the row
+	// could not be parsed but the reading can continue. io.EOF is returned when there are no more rows
+	Next() (int, models.User, *RowError, error)
+}
+
+func newRowReader(r io.Reader, format Format) (rowReader, error) {
+	switch format {
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	}
+
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+func (r *ndjsonReader) Next() (int, models.User, *RowError, error) {
+	for r.scanner.Scan() {
+		r.row++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req models.UserRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			return r.row, models.User{}, &RowError{Row: r.row, Error: err.Error()}, nil
+		}
+		return r.row, req.ToUser(), nil, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return r.row, models.User{}, nil, err
+	}
+	return r.row, models.User{}, nil, io.EOF
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	setters []func(*models.User, string)
+	row     int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("missing csv header")
+		}
+		return nil, err
+	}
+
+	setters := make([]func(*models.User, string), 0, len(header))
+	for _, column := range header {
+		setter, ok := csvColumns[strings.TrimSpace(column)]
+		if !ok {
+			return nil, fmt.Errorf("unknown csv column %q", column)
+		}
+		setters = append(setters, setter)
+	}
+
+	// the header is the row 1
+	return &csvReader{reader: reader, setters: setters, row: 1}, nil
+}
+
+func (r *csvReader) Next() (int, models.User, *RowError, error) {
+	record, err := r.reader.Read()
+	r.row++
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return r.row, models.User{}, nil, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return r.row, models.User{}, &RowError{Row: r.row, Error: err.Error()}, nil
+		}
+		return r.row, models.User{}, nil, err
+	}
+
+	var user models.User
+	if len(record) != len(r.setters) {
+		return r.row, user, &RowError{Row: r.row, Error: fmt.Sprintf("expected %d columns, but was %d", len(r.setters), len(record))}, nil
+	}
+	for i, value := range record {
+		r.setters[i](&user, value)
+	}
+
+	return r.row, user, nil, nil
+}
@@ -0,0 +1,110 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"user-microservice/internal/models"
+)
+
+// DefaultExportFields - fields exported when no fields are requested (the password is never exported)
+var DefaultExportFields = models.UserFields{"id", "firstName", "lastName", "nickname", "email", "country", "createdAt", "updatedAt"}
+
+// exportColumns - getters for the exportable fields (json names)
+var exportColumns = map[string]func(models.User) string{
+	"id":        func(u models.User) string { return u.ID },
+	"firstName": func(u models.User) string { return u.FirstName },
+	"lastName":  func(u models.User) string { return u.LastName },
+	"nickname":  func(u models.User) string { return u.Nickname },
+	"email":     func(u models.User) string { return u.Email },
+	"country":   func(u models.User) string { return u.Country },
+	"createdAt": func(u models.User) string { return u.CreatedAt.Format(time.RFC3339) },
+	"updatedAt": func(u models.User) string { return u.UpdatedAt.Format(time.RFC3339) },
+	"version":   func(u models.User) string { return strconv.FormatInt(u.Version, 10) },
+}
+
+// Writer - writes users one by one with the given format
+type Writer interface {
+	Write(user models.User) error
+	// Flush - writes any buffered data to the underlying io.Writer
+	Flush() error
+}
+
+// NewWriter - returns a new Writer for the format, writing only the given fields (the id is always written).
+// If there are no fields, DefaultExportFields are used
+func NewWriter(w io.Writer, format Format, fields models.UserFields) (Writer, error) {
+	if len(fields) == 0 {
+		fields = DefaultExportFields
+	}
+	hasID := false
+	for _, field := range fields {
+		if _, ok := exportColumns[field]; !ok {
+			return nil, fmt.Errorf("field %q cannot be exported", field)
+		}
+		hasID = hasID || field == "id"
+	}
+	if !hasID {
+		fields = append(models.UserFields{"id"}, fields...)
+	}
+
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w), fields: fields}, nil
+	case FormatCSV:
+		return &csvWriter{writer: csv.NewWriter(w), fields: fields}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+	fields  models.UserFields
+}
+
+func (w *ndjsonWriter) Write(user models.User) error {
+	selected, err := user.Select(w.fields)
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(selected)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	fields        models.UserFields
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(user models.User) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(w.fields); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	record := make([]string, 0, len(w.fields))
+	for _, field := range w.fields {
+		record = append(record, exportColumns[field](user))
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Flush() error {
+	if !w.headerWritten {
+		if err := w.writer.Write(w.fields); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
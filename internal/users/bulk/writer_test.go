@@ -0,0 +1,126 @@
+package bulk_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users/bulk"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_Write(t *testing.T) {
+	createdAt := time.Date(2016, 5, 18, 16, 0, 0, 0, time.UTC)
+	exportUsers := []models.User{
+		{
+			ID:        "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+			FirstName: "Alice",
+			LastName:  "Tingo",
+			Nickname:  "atingo",
+			Password:  "Secret password",
+			Email:     "alicetingo@example.com",
+			Country:   "DE",
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		},
+		{
+			ID:       "5cace01f-45c3-49f0-a725-c22866874095",
+			Nickname: "Comma, nickname",
+			Country:  "ES",
+		},
+	}
+	for _, tc := range []struct {
+		name          string
+		format        bulk.Format
+		fields        models.UserFields
+		users         []models.User
+		expected      string
+		expectedError bool
+	}{
+		{
+			"Write ndjson users with default fields",
+			bulk.FormatNDJSON,
+			nil,
+			exportUsers[:1],
+			`{"country":"DE","createdAt":"2016-05-18T16:00:00Z","email":"alicetingo@example.com","firstName":"Alice","id":"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4","lastName":"Tingo","nickname":"atingo","updatedAt":"2016-05-18T16:00:00Z"}` + "\n",
+			false,
+		},
+		{
+			"Write ndjson users with selected fields",
+			bulk.FormatNDJSON,
+			models.UserFields{"nickname"},
+			exportUsers,
+			`{"id":"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4","nickname":"atingo"}` + "\n" +
+				`{"id":"5cace01f-45c3-49f0-a725-c22866874095","nickname":"Comma, nickname"}` + "\n",
+			false,
+		},
+		{
+			"Write csv users with default fields",
+			bulk.FormatCSV,
+			nil,
+			exportUsers[:1],
+			"id,firstName,lastName,nickname,email,country,createdAt,updatedAt\n" +
+				"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4,Alice,Tingo,atingo,alicetingo@example.com,DE,2016-05-18T16:00:00Z,2016-05-18T16:00:00Z\n",
+			false,
+		},
+		{
+			"Write csv users with selected fields",
+			bulk.FormatCSV,
+			models.UserFields{"nickname", "country"},
+			exportUsers,
+			"id,nickname,country\n" +
+				"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4,atingo,DE\n" +
+				"5cace01f-45c3-49f0-a725-c22866874095,\"Comma, nickname\",ES\n",
+			false,
+		},
+		{
+			"Write csv without users",
+			bulk.FormatCSV,
+			models.UserFields{"id", "nickname"},
+			nil,
+			"id,nickname\n",
+			false,
+		},
+		{
+			"Write users with password field",
+			bulk.FormatCSV,
+			models.UserFields{"password"},
+			nil,
+			"",
+			true,
+		},
+		{
+			"Write users with invalid format",
+			bulk.Format("xml"),
+			nil,
+			nil,
+			"",
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			var buf bytes.Buffer
+
+			// When
+			writer, err := bulk.NewWriter(&buf, tc.format, tc.fields)
+
+			// Then
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			for _, user := range tc.users {
+				require.NoError(t, writer.Write(user))
+			}
+			require.NoError(t, writer.Flush())
+			assert.Equalf(t, tc.expected, buf.String(), "Expected output to be %s, but was %s", tc.expected, buf.String())
+		})
+	}
+}
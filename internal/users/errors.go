@@ -0,0 +1,39 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrVersionConflict - returned when a user was modified by someone else since it was read
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrDuplicateEmail - returned when another not deleted user has the same email, ignoring the case
+var ErrDuplicateEmail = errors.New("email already in use")
+
+// BulkError - error returned by the bulk operations when some of the items could not be processed.
+// Errors maps the index of each failed item with its error
+type BulkError struct {
+	Errors map[int]error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d items could not be processed", len(e.Errors))
+}
+
+// VersionConflictError - error returned by the repository when the stored user version is not the expected one.
+// It matches ErrVersionConflict with errors.Is
+type VersionConflictError struct {
+	ID       string
+	Expected int64
+	Current  int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s for user %s: expected version %d, but was %d", ErrVersionConflict, e.ID, e.Expected, e.Current)
+}
+
+// Is - returns true if the target is ErrVersionConflict
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
@@ -0,0 +1,134 @@
+//go:generate mockgen -source handler.go -destination mock/handler_mock.go -package mock
+package graphql
+
+import (
+	"net/http"
+	"strings"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/verification"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Handler - GraphQL handler of the users
+type Handler interface {
+	Query(c echo.Context) error
+}
+
+type httpHandler struct {
+	repository users.Repository
+	schema     graphql.Schema
+	limits     Limits
+}
+
+// request - body of the GraphQL requests
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// NewHttpHandler - returns a new GraphQL handler of the users schema, resolved with the same semantics
+// as the user http handler. The verifier can be nil, then no email verification token is sent
+func NewHttpHandler(usersRepository users.Repository, pubsubRepository userPS.PubSub, verifier verification.Sender, limits Limits) (Handler, error) {
+	schema, err := newSchema(resolver{
+		repository:       usersRepository,
+		pubsubRepository: pubsubRepository,
+		verifier:         verifier,
+	})
+	if err != nil {
+		logrus.Errorf("Error in users/graphql.NewHttpHandler -> error creating schema: %s", err)
+		return nil, err
+	}
+
+	return httpHandler{
+		repository: usersRepository,
+		schema:     schema,
+		limits:     limits,
+	}, nil
+}
+
+// Query godoc
+//
+// @Summary     GraphQL users queries and mutations
+// @Description Executes a GraphQL operation over the users: the user(id) and users(filter, page, sort) queries,
+// @Description and the createUser, updateUser and deleteUser mutations. The queries exceeding the depth or the
+// @Description complexity limits are rejected before their execution
+// @Tags        GraphQL
+// @Accept      json
+// @Produce     json
+// @Param       request body     object true "GraphQL request with the query, the operationName and the variables"
+// @Success     200     {object} object "GraphQL result with the data and the errors of the execution"
+// @Failure     400     {object} object "GraphQL result with the errors of the parsing, the validation or the limits"
+// @Failure     401     {object} echo.HTTPError
+// @Failure     429     {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /graphql [post]
+func (h httpHandler) Query(c echo.Context) error {
+	var req request
+	if err := c.Bind(&req); err != nil {
+		logrus.Errorf("Error in users/graphql.Query -> error binding request: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+	if validation := graphql.ValidateDocument(&h.schema, doc, nil); !validation.IsValid {
+		return c.JSON(http.StatusBadRequest, graphql.Result{Errors: validation.Errors})
+	}
+	if err := h.limits.check(doc, req.OperationName, req.Variables); err != nil {
+		return c.JSON(http.StatusBadRequest, graphql.Result{Errors: []gqlerrors.FormattedError{
+			{Message: err.Error(), Extensions: err.Extensions()},
+		}})
+	}
+
+	res := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoader(c.Request().Context(), h.repository),
+	})
+	setExtensions(res.Errors)
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// setExtensions - sets the extensions of the errors that were lost by the executor, which wraps the errors
+// of the thunks several times before formatting them
+func setExtensions(errs []gqlerrors.FormattedError) {
+	for i := range errs {
+		if errs[i].Extensions != nil {
+			continue
+		}
+		err := errs[i].OriginalError()
+		for err != nil {
+			switch e := err.(type) {
+			case gqlerrors.ExtendedError:
+				errs[i].Extensions = e.Extensions()
+				err = nil
+			case gqlerrors.FormattedError:
+				err = e.OriginalError()
+			case *gqlerrors.Error:
+				err = e.OriginalError
+			default:
+				err = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,637 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/models"
+	"user-microservice/internal/pagination"
+	"user-microservice/internal/users"
+	usersGraphql "user-microservice/internal/users/graphql"
+	"user-microservice/internal/users/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	admin  = &auth.Principal{Subject: "apikey:admin", Scopes: []string{auth.ScopeUsersAdmin}}
+	reader = &auth.Principal{Subject: "apikey:reader", Scopes: []string{auth.ScopeUsersRead}}
+)
+
+// response - GraphQL response with the codes of the errors
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// execute - executes the query with the handler as the principal, nil for no principal (auth disabled)
+func execute(t *testing.T, h usersGraphql.Handler, principal *auth.Principal, query string, variables map[string]interface{}) (int, response) {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoErrorf(t, err, "Expected no error when marshaling request, but was %s", err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if principal != nil {
+		req = req.WithContext(auth.NewPrincipalContext(req.Context(), *principal))
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	err = h.Query(c)
+	require.NoErrorf(t, err, "Expected no error, but was %s", err)
+
+	var res response
+	err = json.Unmarshal(rec.Body.Bytes(), &res)
+	require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+	return rec.Code, res
+}
+
+func errorCode(res response) string {
+	if len(res.Errors) == 0 {
+		return ""
+	}
+	code, _ := res.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+func newUser() models.User {
+	return models.User{
+		ID:        uuid.New().String(),
+		FirstName: "Alice",
+		LastName:  "Tingo",
+		Nickname:  "atingo",
+		Password:  "hashed password",
+		Email:     "atingo@example.com",
+		Country:   "DE",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Status:    models.StatusActive,
+		Version:   3,
+	}
+}
+
+func TestQueryUser(t *testing.T) {
+	alice, bob := newUser(), newUser()
+	missing := uuid.New().String()
+	for _, tc := range []struct {
+		name           string
+		query          string
+		mockedIDs      []string
+		mockedUsers    []models.User
+		mockedError    error
+		expectedData   map[string]interface{}
+		expectedCode   string
+		shouldCallRepo bool
+	}{
+		{
+			"Get users in a single batch",
+			`{ a: user(id: "` + alice.ID + `") { nickname } b: user(id: "` + bob.ID + `") { id } c: user(id: "` + alice.ID + `") { status } }`,
+			[]string{alice.ID, bob.ID},
+			[]models.User{alice, bob},
+			nil,
+			map[string]interface{}{
+				"a": map[string]interface{}{"nickname": alice.Nickname},
+				"b": map[string]interface{}{"id": bob.ID},
+				"c": map[string]interface{}{"status": "ACTIVE"},
+			},
+			"",
+			true,
+		},
+		{
+			"Get not found user",
+			`{ user(id: "` + missing + `") { id } }`,
+			[]string{missing},
+			nil,
+			nil,
+			map[string]interface{}{"user": nil},
+			"",
+			true,
+		},
+		{
+			"Get user with invalid id",
+			`{ user(id: "homemade") { id } }`,
+			nil,
+			nil,
+			nil,
+			map[string]interface{}{"user": nil},
+			usersGraphql.CodeBadUserInput,
+			false,
+		},
+		{
+			"Get user with repository error",
+			`{ user(id: "` + alice.ID + `") { id } }`,
+			[]string{alice.ID},
+			nil,
+			errors.New("homemade error"),
+			map[string]interface{}{"user": nil},
+			usersGraphql.CodeInternal,
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			h, err := usersGraphql.NewHttpHandler(repo, mock.NewMockPubSub(ctrl), nil, usersGraphql.Limits{})
+			require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+			if tc.shouldCallRepo {
+				repo.EXPECT().GetByIDs(gomock.Any(), gomock.InAnyOrder(tc.mockedIDs)).Return(tc.mockedUsers, tc.mockedError).Times(1)
+			}
+
+			// When
+			code, res := execute(t, h, reader, tc.query, nil)
+
+			// Then
+			assert.Equalf(t, http.StatusOK, code, "Expected status code to be %d, but was %d", http.StatusOK, code)
+			assert.Equalf(t, tc.expectedData, res.Data, "Expected data to be %v, but was %v", tc.expectedData, res.Data)
+			assert.Equalf(t, tc.expectedCode, errorCode(res), "Expected error code to be %s, but was %v", tc.expectedCode, res.Errors)
+		})
+	}
+}
+
+func TestQueryUsers(t *testing.T) {
+	user := newUser()
+	for _, tc := range []struct {
+		name            string
+		query           string
+		variables       map[string]interface{}
+		principal       *auth.Principal
+		expectedPag     pagination.PaginationOptions
+		expectedFilters models.UserFilters
+		expectedCode    string
+		shouldCallRepo  bool
+	}{
+		{
+			"Get paginated users with defaults",
+			`{ users { users { id } totalCount hasMore } }`,
+			nil,
+			reader,
+			pagination.PaginationOptions{Page: 1, Size: 10},
+			models.UserFilters{},
+			"",
+			true,
+		},
+		{
+			"Get paginated users filtered and sorted",
+			`query ($page: PageInput) { users(filter: {country: "DE", status: BANNED}, page: $page, sort: {field: CREATED_AT, order: DESC}) { users { id } } }`,
+			map[string]interface{}{"page": map[string]interface{}{"page": 2, "size": 5}},
+			reader,
+			pagination.PaginationOptions{Page: 2, Size: 5, OrderBy: "createdAt", SortOrder: pagination.SortOrderDesc},
+			models.UserFilters{Country: "DE", Status: models.StatusBanned},
+			"",
+			true,
+		},
+		{
+			"Get paginated users with too large page",
+			`{ users(page: {size: 500}) { totalCount } }`,
+			nil,
+			reader,
+			pagination.PaginationOptions{},
+			models.UserFilters{},
+			usersGraphql.CodeBadUserInput,
+			false,
+		},
+		{
+			"Get paginated users without auth",
+			`{ users { totalCount } }`,
+			nil,
+			nil,
+			pagination.PaginationOptions{Page: 1, Size: 10},
+			models.UserFilters{},
+			"",
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			h, err := usersGraphql.NewHttpHandler(repo, mock.NewMockPubSub(ctrl), nil, usersGraphql.Limits{})
+			require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+			if tc.shouldCallRepo {
+				repo.EXPECT().GetPaginatedUsers(gomock.Any(), tc.expectedPag, tc.expectedFilters).Return(models.PaginatedUsers{
+					Paginated: pagination.Paginated{TotalCount: 1, TotalPages: 1, CurrentPage: tc.expectedPag.Page, Size: tc.expectedPag.Size},
+					Users:     []models.User{user},
+				}, nil)
+			}
+
+			// When
+			code, res := execute(t, h, tc.principal, tc.query, tc.variables)
+
+			// Then
+			assert.Equalf(t, http.StatusOK, code, "Expected status code to be %d, but was %d", http.StatusOK, code)
+			assert.Equalf(t, tc.expectedCode, errorCode(res), "Expected error code to be %s, but was %v", tc.expectedCode, res.Errors)
+			if tc.expectedCode == "" {
+				page, _ := res.Data["users"].(map[string]interface{})
+				require.NotNilf(t, page, "Expected users page, but was %v", res.Data)
+				if users, ok := page["users"].([]interface{}); ok {
+					assert.Lenf(t, users, 1, "Expected 1 user, but was %v", users)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	user := newUser()
+	query := `mutation ($input: CreateUserInput!) { createUser(input: $input) { id status } }`
+	validInput := map[string]interface{}{
+		"firstName": user.FirstName,
+		"lastName":  user.LastName,
+		"nickname":  user.Nickname,
+		"password":  "password",
+		"email":     user.Email,
+		"country":   user.Country,
+	}
+	for _, tc := range []struct {
+		name           string
+		principal      *auth.Principal
+		input          map[string]interface{}
+		mockedError    error
+		shouldCallRepo bool
+		expectedCode   string
+	}{
+		{
+			"Create user successfully",
+			admin,
+			validInput,
+			nil,
+			true,
+			"",
+		},
+		{
+			"Create user with empty fields",
+			admin,
+			map[string]interface{}{"firstName": "Alice", "lastName": "", "nickname": "", "password": "", "email": "", "country": ""},
+			nil,
+			false,
+			usersGraphql.CodeBadUserInput,
+		},
+		{
+			"Create user without permission",
+			reader,
+			validInput,
+			nil,
+			false,
+			usersGraphql.CodeForbidden,
+		},
+		{
+			"Create user with repository error",
+			admin,
+			validInput,
+			errors.New("homemade error"),
+			true,
+			usersGraphql.CodeInternal,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			ps := mock.NewMockPubSub(ctrl)
+			h, err := usersGraphql.NewHttpHandler(repo, ps, nil, usersGraphql.Limits{})
+			require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+			published := make(chan struct{})
+			if tc.shouldCallRepo {
+				var res *models.User
+				if tc.mockedError == nil {
+					res = &user
+					ps.EXPECT().NotifyUserCreation(gomock.Any(), user).DoAndReturn(func(context.Context, models.User) error {
+						close(published)
+						return nil
+					})
+				}
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, created models.User) (*models.User, error) {
+					assert.Equalf(t, models.StatusActive, created.Status, "Expected status to be active, but was %s", created.Status)
+					return res, tc.mockedError
+				})
+			}
+
+			// When
+			code, res := execute(t, h, tc.principal, query, map[string]interface{}{"input": tc.input})
+
+			// Then
+			assert.Equalf(t, http.StatusOK, code, "Expected status code to be %d, but was %d", http.StatusOK, code)
+			assert.Equalf(t, tc.expectedCode, errorCode(res), "Expected error code to be %s, but was %v", tc.expectedCode, res.Errors)
+			if tc.expectedCode == "" {
+				expected := map[string]interface{}{"id": user.ID, "status": "ACTIVE"}
+				assert.Equalf(t, expected, res.Data["createUser"], "Expected user to be %v, but was %v", expected, res.Data["createUser"])
+				select {
+				case <-published:
+				case <-time.After(time.Second):
+					t.Fatal("Expected the user creation to be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	user := newUser()
+	query := `mutation ($id: ID!, $version: Int) { updateUser(id: $id, input: {nickname: "newnick"}, version: $version) { nickname version } }`
+	for _, tc := range []struct {
+		name             string
+		principal        *auth.Principal
+		version          interface{}
+		mockedGetError   error
+		mockedUpdateErr  error
+		shouldCallUpdate bool
+		expectedCode     string
+	}{
+		{
+			"Update user successfully",
+			admin,
+			user.Version,
+			nil,
+			nil,
+			true,
+			"",
+		},
+		{
+			"Update user without version",
+			admin,
+			nil,
+			nil,
+			nil,
+			true,
+			"",
+		},
+		{
+			"Update user with stale version",
+			admin,
+			user.Version - 1,
+			nil,
+			nil,
+			false,
+			usersGraphql.CodeConflict,
+		},
+		{
+			"Update user with version conflict",
+			admin,
+			user.Version,
+			nil,
+			users.ErrVersionConflict,
+			true,
+			usersGraphql.CodeConflict,
+		},
+		{
+			"Update not found user",
+			admin,
+			nil,
+			mongo.ErrNoDocuments,
+			nil,
+			false,
+			usersGraphql.CodeNotFound,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			ps := mock.NewMockPubSub(ctrl)
+			h, err := usersGraphql.NewHttpHandler(repo, ps, nil, usersGraphql.Limits{})
+			require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+			current := user
+			if tc.mockedGetError != nil {
+				repo.EXPECT().GetById(gomock.Any(), user.ID).Return(nil, tc.mockedGetError)
+			} else {
+				repo.EXPECT().GetById(gomock.Any(), user.ID).Return(&current, nil)
+			}
+			published := make(chan struct{})
+			if tc.shouldCallUpdate {
+				updated := user
+				updated.Nickname = "newnick"
+				updated.Version++
+				if tc.mockedUpdateErr == nil {
+					ps.EXPECT().NotifyUserUpdate(gomock.Any(), updated).DoAndReturn(func(context.Context, models.User) error {
+						close(published)
+						return nil
+					})
+					repo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(&updated, nil)
+				} else {
+					repo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, tc.mockedUpdateErr)
+				}
+			}
+
+			// When
+			code, res := execute(t, h, tc.principal, query, map[string]interface{}{"id": user.ID, "version": tc.version})
+
+			// Then
+			assert.Equalf(t, http.StatusOK, code, "Expected status code to be %d, but was %d", http.StatusOK, code)
+			assert.Equalf(t, tc.expectedCode, errorCode(res), "Expected error code to be %s, but was %v", tc.expectedCode, res.Errors)
+			if tc.expectedCode == "" {
+				expected := map[string]interface{}{"nickname": "newnick", "version": float64(user.Version + 1)}
+				assert.Equalf(t, expected, res.Data["updateUser"], "Expected user to be %v, but was %v", expected, res.Data["updateUser"])
+				select {
+				case <-published:
+				case <-time.After(time.Second):
+					t.Fatal("Expected the user update to be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	userID := uuid.New().String()
+	for _, tc := range []struct {
+		name           string
+		principal      *auth.Principal
+		mockedError    error
+		shouldCallRepo bool
+		expectedCode   string
+	}{
+		{
+			"Delete user successfully",
+			admin,
+			nil,
+			true,
+			"",
+		},
+		{
+			"Delete not found user",
+			admin,
+			mongo.ErrNoDocuments,
+			true,
+			usersGraphql.CodeNotFound,
+		},
+		{
+			"Delete user without permission",
+			reader,
+			nil,
+			false,
+			usersGraphql.CodeForbidden,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			ps := mock.NewMockPubSub(ctrl)
+			h, err := usersGraphql.NewHttpHandler(repo, ps, nil, usersGraphql.Limits{})
+			require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+			published := make(chan struct{})
+			if tc.shouldCallRepo {
+				repo.EXPECT().DeleteById(gomock.Any(), userID).Return(tc.mockedError)
+				if tc.mockedError == nil {
+					ps.EXPECT().NotifyUserDeletion(gomock.Any(), userID).DoAndReturn(func(context.Context, string) error {
+						close(published)
+						return nil
+					})
+				}
+			}
+
+			// When
+			code, res := execute(t, h, tc.principal, `mutation ($id: ID!) { deleteUser(id: $id) }`, map[string]interface{}{"id": userID})
+
+			// Then
+			assert.Equalf(t, http.StatusOK, code, "Expected status code to be %d, but was %d", http.StatusOK, code)
+			assert.Equalf(t, tc.expectedCode, errorCode(res), "Expected error code to be %s, but was %v", tc.expectedCode, res.Errors)
+			if tc.expectedCode == "" {
+				assert.Equalf(t, true, res.Data["deleteUser"], "Expected deleteUser to be true, but was %v", res.Data["deleteUser"])
+				select {
+				case <-published:
+				case <-time.After(time.Second):
+					t.Fatal("Expected the user deletion to be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestQueryLimitsAndErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		query        string
+		variables    map[string]interface{}
+		limits       usersGraphql.Limits
+		expectedCode string
+	}{
+		{
+			"Query too deep",
+			`{ users { users { id } } }`,
+			nil,
+			usersGraphql.Limits{MaxDepth: 2},
+			usersGraphql.CodeLimitExceeded,
+		},
+		{
+			"Query too deep through fragments",
+			`query { ...page } fragment page on Query { users { ... on UserPage { users { id } } } }`,
+			nil,
+			usersGraphql.Limits{MaxDepth: 2},
+			usersGraphql.CodeLimitExceeded,
+		},
+		{
+			"Query too complex",
+			`{ users(page: {size: 100}) { users { id nickname } } }`,
+			nil,
+			usersGraphql.Limits{MaxComplexity: 200},
+			usersGraphql.CodeLimitExceeded,
+		},
+		{
+			"Query too complex with variables",
+			`query ($size: Int) { users(page: {size: $size}) { users { id nickname } } }`,
+			map[string]interface{}{"size": 100},
+			usersGraphql.Limits{MaxComplexity: 200},
+			usersGraphql.CodeLimitExceeded,
+		},
+		{
+			"Introspection query too deep",
+			`{ __type(name: "User") { fields { type { ofType { ofType { name } } } } } }`,
+			nil,
+			usersGraphql.Limits{MaxIntrospectionDepth: 5},
+			usersGraphql.CodeLimitExceeded,
+		},
+		{
+			"Introspection query too complex",
+			`{ __schema { types { name fields { name } } } }`,
+			nil,
+			usersGraphql.Limits{MaxComplexity: 4},
+			usersGraphql.CodeLimitExceeded,
+		},
+		{
+			"Query with syntax error",
+			`{ users { `,
+			nil,
+			usersGraphql.Limits{},
+			"",
+		},
+		{
+			"Query with unknown field",
+			`{ users { users { password } } }`,
+			nil,
+			usersGraphql.Limits{},
+			"",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			h, err := usersGraphql.NewHttpHandler(mock.NewMockRepository(ctrl), mock.NewMockPubSub(ctrl), nil, tc.limits)
+			require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+			// When
+			code, res := execute(t, h, reader, tc.query, tc.variables)
+
+			// Then
+			assert.Equalf(t, http.StatusBadRequest, code, "Expected status code to be %d, but was %d", http.StatusBadRequest, code)
+			require.NotEmptyf(t, res.Errors, "Expected errors, but there were none")
+			assert.Equalf(t, tc.expectedCode, errorCode(res), "Expected error code to be %s, but was %v", tc.expectedCode, res.Errors)
+			assert.Nilf(t, res.Data, "Expected no data, but was %v", res.Data)
+		})
+	}
+}
+
+func TestIntrospectionQuery(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	h, err := usersGraphql.NewHttpHandler(mock.NewMockRepository(ctrl), mock.NewMockPubSub(ctrl), nil, usersGraphql.Limits{})
+	require.NoErrorf(t, err, "Expected no error when creating handler, but was %s", err)
+
+	// When
+	code, res := execute(t, h, reader, testutil.IntrospectionQuery, nil)
+
+	// Then
+	assert.Equalf(t, http.StatusOK, code, "Expected status code to be %d, but was %d", http.StatusOK, code)
+	assert.Emptyf(t, res.Errors, "Expected the introspection query to be allowed by the default limits, but was %v", res.Errors)
+	assert.NotNilf(t, res.Data["__schema"], "Expected the schema, but the data was %v", res.Data)
+}
@@ -0,0 +1,186 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/pagination"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	DefaultMaxDepth      = 8
+	DefaultMaxComplexity = 1000
+	// DefaultMaxIntrospectionDepth - deep enough for the introspection queries of the usual GraphQL clients
+	DefaultMaxIntrospectionDepth = 15
+)
+
+// Limits - limits of the queries, checked before executing them
+type Limits struct {
+	// MaxDepth - maximum nesting of the selections, e.g. { user { id } } has a depth of 2
+	MaxDepth int
+	// MaxComplexity - maximum cost of the query. Each field costs 1, and the cost of the selections
+	// of the paginated fields is multiplied by their page size
+	MaxComplexity int
+	// MaxIntrospectionDepth - maximum nesting of the introspection selections (__schema and __type), which are
+	// deeper than the usual queries. Their fields are also counted in the complexity
+	MaxIntrospectionDepth int
+}
+
+// check - returns a limit exceeded error if any operation of the document to execute is too deep or too complex.
+// The introspection selections are limited by their own depth
+func (l Limits) check(doc *ast.Document, operationName string, variables map[string]interface{}) *gqlError {
+	maxDepth := l.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	maxComplexity := l.MaxComplexity
+	if maxComplexity <= 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+	maxIntrospectionDepth := l.MaxIntrospectionDepth
+	if maxIntrospectionDepth <= 0 {
+		maxIntrospectionDepth = DefaultMaxIntrospectionDepth
+	}
+
+	c := costCalculator{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			c.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (operation.Name == nil || operation.Name.Value != operationName) {
+			continue
+		}
+		cost := c.selectionSet(operation.SelectionSet, 0, false)
+		if cost.depth > maxDepth {
+			return &gqlError{fmt.Sprintf("%s: %d, the maximum is %d", httpErrors.ErrQueryTooDeep, cost.depth, maxDepth), CodeLimitExceeded}
+		}
+		if cost.introspectionDepth > maxIntrospectionDepth {
+			return &gqlError{fmt.Sprintf("%s: %d, the maximum for introspection is %d", httpErrors.ErrQueryTooDeep, cost.introspectionDepth, maxIntrospectionDepth), CodeLimitExceeded}
+		}
+		if cost.complexity > maxComplexity {
+			return &gqlError{fmt.Sprintf("%s: %d, the maximum is %d", httpErrors.ErrQueryTooComplex, cost.complexity, maxComplexity), CodeLimitExceeded}
+		}
+	}
+
+	return nil
+}
+
+// paginatedFields - root fields returning a page of users
+var paginatedFields = map[string]bool{"users": true}
+
+// introspectionFields - root fields querying the schema, whose selections are limited by the introspection depth
+var introspectionFields = map[string]bool{"__schema": true, "__type": true}
+
+// queryCost - complexity and depths of a selection set
+type queryCost struct {
+	complexity int
+	// depth - depth of the deepest selection, except the introspection ones
+	depth int
+	// introspectionDepth - depth of the deepest introspection selection
+	introspectionDepth int
+}
+
+// add - adds the complexity of the other cost and keeps the deepest depths
+func (qc *queryCost) add(other queryCost) {
+	qc.complexity += other.complexity
+	if other.depth > qc.depth {
+		qc.depth = other.depth
+	}
+	if other.introspectionDepth > qc.introspectionDepth {
+		qc.introspectionDepth = other.introspectionDepth
+	}
+}
+
+// costCalculator - computes the complexity and the depth of the validated documents,
+// which have no fragment cycles
+type costCalculator struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// selectionSet - returns the complexity of the selections and the depth of the deepest one, which is an
+// introspection one if the set is selected by an introspection field. The fields of the set are at the given depth + 1
+func (c costCalculator) selectionSet(set *ast.SelectionSet, depth int, introspection bool) queryCost {
+	res := queryCost{depth: depth}
+	if introspection {
+		res = queryCost{introspectionDepth: depth}
+	}
+	if set == nil {
+		return res
+	}
+
+	for _, selection := range set.Selections {
+		var cost queryCost
+		switch s := selection.(type) {
+		case *ast.Field:
+			cost = c.selectionSet(s.SelectionSet, depth+1, introspection || introspectionFields[s.Name.Value])
+			cost.complexity = 1 + cost.complexity*c.multiplier(s, depth)
+		case *ast.InlineFragment:
+			cost = c.selectionSet(s.SelectionSet, depth, introspection)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[s.Name.Value]; ok {
+				cost = c.selectionSet(fragment.SelectionSet, depth, introspection)
+			}
+		}
+		res.add(cost)
+	}
+
+	return res
+}
+
+// multiplier - returns the page size of the paginated root fields, capped to the MaxPageSize, and 1 for the others
+func (c costCalculator) multiplier(field *ast.Field, depth int) int {
+	if depth > 0 || !paginatedFields[field.Name.Value] {
+		return 1
+	}
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "page" {
+			continue
+		}
+		page, _ := c.value(arg.Value).(map[string]interface{})
+		if size := toInt(page["size"]); size > 0 {
+			if size > MaxPageSize {
+				return MaxPageSize
+			}
+			return size
+		}
+	}
+	return pagination.DefaultSize
+}
+
+// value - returns the go value of the argument, replacing the variables by their values
+func (c costCalculator) value(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.Variable:
+		return c.variables[v.Name.Value]
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(v.Value)
+		return n
+	case *ast.ObjectValue:
+		res := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			res[field.Name.Value] = c.value(field.Value)
+		}
+		return res
+	}
+	return nil
+}
+
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
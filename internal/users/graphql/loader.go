@@ -0,0 +1,104 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+)
+
+type loaderKey struct{}
+
+// loader - batches the user lookups by ID of a request. The loads only register the IDs and return a thunk,
+// the first thunk resolved by the executor retrieves all the pending IDs with a single GetByIDs call.
+// The users are cached for the rest of the request
+type loader struct {
+	repository users.Repository
+	mu         sync.Mutex
+	pending    []string
+	users      map[string]*models.User
+	errs       map[string]error
+	ctx        context.Context
+}
+
+// withLoader - returns a context with a new loader for the request
+func withLoader(ctx context.Context, repository users.Repository) context.Context {
+	return context.WithValue(ctx, loaderKey{}, &loader{
+		repository: repository,
+		users:      map[string]*models.User{},
+		errs:       map[string]error{},
+		ctx:        ctx,
+	})
+}
+
+// loaderFromContext - returns the loader of the request, or a new one if there is none
+func loaderFromContext(ctx context.Context, repository users.Repository) *loader {
+	if l, ok := ctx.Value(loaderKey{}).(*loader); ok {
+		return l
+	}
+	return withLoader(ctx, repository).Value(loaderKey{}).(*loader)
+}
+
+// load - registers the user ID and returns the thunk resolving the user, nil if it does not exist
+func (l *loader) load(id string) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.isLoaded(id) && !contains(l.pending, id) {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !l.isLoaded(id) {
+			l.dispatch()
+		}
+		if err := l.errs[id]; err != nil {
+			return nil, err
+		}
+		if user := l.users[id]; user != nil {
+			return user, nil
+		}
+		return nil, nil
+	}
+}
+
+// dispatch - retrieves the pending users, must be called holding the lock
+func (l *loader) dispatch() {
+	ids := l.pending
+	l.pending = nil
+	if len(ids) == 0 {
+		return
+	}
+
+	found, err := l.repository.GetByIDs(l.ctx, ids)
+	if err != nil {
+		err = internalError("loader.dispatch", err)
+		for _, id := range ids {
+			l.errs[id] = err
+		}
+		return
+	}
+
+	for _, id := range ids {
+		l.users[id] = nil
+	}
+	for i := range found {
+		l.users[found[i].ID] = &found[i]
+	}
+}
+
+func (l *loader) isLoaded(id string) bool {
+	_, loaded := l.users[id]
+	_, failed := l.errs[id]
+	return loaded || failed
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
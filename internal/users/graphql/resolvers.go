@@ -0,0 +1,322 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/pagination"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/verification"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxPageSize - maximum page size of the users query
+const MaxPageSize = 100
+
+// Error codes, returned in the extensions of the errors
+const (
+	CodeBadUserInput  = "BAD_USER_INPUT"
+	CodeNotFound      = "NOT_FOUND"
+	CodeForbidden     = "FORBIDDEN"
+	CodeConflict      = "CONFLICT"
+	CodeLimitExceeded = "LIMIT_EXCEEDED"
+	CodeInternal      = "INTERNAL"
+)
+
+// gqlError - error returned to the client with its code in the extensions
+type gqlError struct {
+	message string
+	code    string
+}
+
+func (e gqlError) Error() string {
+	return e.message
+}
+
+// Extensions - implements gqlerrors.ExtendedError
+func (e gqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// updatableFields - the fields (json names) that can be changed with updateUser
+var updatableFields = []string{"firstName", "lastName", "nickname", "password", "email", "country"}
+
+// resolver - resolves the users schema with the same semantics as the user http handler
+type resolver struct {
+	repository       users.Repository
+	pubsubRepository userPS.PubSub
+	// verifier - sends the email verification tokens, nil if the email verification is disabled
+	verifier verification.Sender
+}
+
+// user - loads the user with the request loader, so all the users of the query are retrieved at once
+func (r resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	userID, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(p.Context, auth.ActionRead, userID); err != nil {
+		return nil, err
+	}
+
+	return loaderFromContext(p.Context, r.repository).load(userID), nil
+}
+
+func (r resolver) users(p graphql.ResolveParams) (interface{}, error) {
+	if err := authorize(p.Context, auth.ActionList, ""); err != nil {
+		return nil, err
+	}
+
+	filter, _ := p.Args["filter"].(map[string]interface{})
+	userStatus, _ := filter["status"].(models.UserStatus)
+	includeDeleted, _ := filter["includeDeleted"].(bool)
+	filters := models.UserFilters{
+		FirstName:      stringArg(filter, "firstName"),
+		LastName:       stringArg(filter, "lastName"),
+		Nickname:       stringArg(filter, "nickname"),
+		Email:          stringArg(filter, "email"),
+		Country:        stringArg(filter, "country"),
+		Status:         userStatus,
+		IncludeDeleted: includeDeleted,
+	}
+
+	pagOpts := pagination.PaginationOptions{Page: pagination.FirstPage, Size: pagination.DefaultSize}
+	if page, ok := p.Args["page"].(map[string]interface{}); ok {
+		pagOpts.Page, _ = page["page"].(int)
+		pagOpts.Size, _ = page["size"].(int)
+	}
+	if pagOpts.Page < pagination.FirstPage || pagOpts.Size <= 0 || pagOpts.Size > MaxPageSize {
+		return nil, gqlError{httpErrors.ErrInvalidParams, CodeBadUserInput}
+	}
+	if sort, ok := p.Args["sort"].(map[string]interface{}); ok {
+		pagOpts.OrderBy, _ = sort["field"].(string)
+		pagOpts.SortOrder, _ = sort["order"].(pagination.SortOrder)
+	}
+
+	res, err := r.repository.GetPaginatedUsers(p.Context, pagOpts, filters)
+	if err != nil {
+		return nil, internalError("users", err)
+	}
+
+	return map[string]interface{}{
+		"users":       res.Users,
+		"totalCount":  res.TotalCount,
+		"totalPages":  res.TotalPages,
+		"currentPage": res.CurrentPage,
+		"size":        res.Size,
+		"hasMore":     res.HasMore,
+	}, nil
+}
+
+func (r resolver) createUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := authorize(p.Context, auth.ActionCreate, ""); err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]interface{})
+	user := models.User{
+		FirstName: stringArg(input, "firstName"),
+		LastName:  stringArg(input, "lastName"),
+		Nickname:  stringArg(input, "nickname"),
+		Password:  stringArg(input, "password"),
+		Email:     stringArg(input, "email"),
+		Country:   stringArg(input, "country"),
+	}
+	if missing := user.MissingFields(); len(missing) > 0 {
+		return nil, gqlError{fmt.Sprintf("%s: %s", httpErrors.ErrMissingFields, strings.Join(missing, ",")), CodeBadUserInput}
+	}
+	user.PrepareNew()
+
+	// the context keeps the audit info of the request, but it is not cancelled with it so the user is notified
+	ctx := audit.Detach(p.Context)
+	res, err := r.repository.Create(ctx, user)
+	if err != nil {
+		if errors.Is(err, users.ErrDuplicateEmail) {
+			return nil, gqlError{httpErrors.ErrDuplicateEmail, CodeConflict}
+		}
+		return nil, internalError("createUser", err)
+	}
+
+	go func() {
+		if err := r.pubsubRepository.NotifyUserCreation(ctx, *res); err != nil {
+			logrus.Errorf("Error in users/graphql.createUser -> could not notify user creation: %s", err)
+		}
+	}()
+	r.sendVerification(ctx, *res)
+
+	return res, nil
+}
+
+// updateUser - updates the fields of the input and notifies the update. A changed email is kept pending
+// until it's verified with the token sent to it, and the status is only changed by the moderation
+func (r resolver) updateUser(p graphql.ResolveParams) (interface{}, error) {
+	userID, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(p.Context, auth.ActionUpdate, userID); err != nil {
+		return nil, err
+	}
+
+	// the principal is kept in the request context to authorize the changed fields
+	detached := audit.Detach(p.Context)
+	current, err := r.repository.GetById(detached, userID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, notFoundError(userID)
+		}
+		return nil, internalError("updateUser", err)
+	}
+	if version, ok := p.Args["version"].(int); ok && int64(version) != current.Version {
+		return nil, gqlError{httpErrors.ErrPreconditionFailed, CodeConflict}
+	}
+
+	user := *current
+	input, _ := p.Args["input"].(map[string]interface{})
+	for _, field := range updatableFields {
+		value, ok := input[field].(string)
+		if !ok {
+			continue
+		}
+		switch field {
+		case "firstName":
+			user.FirstName = value
+		case "lastName":
+			user.LastName = value
+		case "nickname":
+			user.Nickname = value
+		case "password":
+			// an empty password keeps the current one
+			if value != "" {
+				user.Password = value
+			}
+		case "email":
+			user.Email = value
+		case "country":
+			user.Country = value
+		}
+	}
+	if missing := user.MissingUpdateFields(); len(missing) > 0 {
+		return nil, gqlError{fmt.Sprintf("%s: %s", httpErrors.ErrMissingFields, strings.Join(missing, ",")), CodeBadUserInput}
+	}
+
+	changes := models.DiffUsers(current, user)
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	if err := authorize(p.Context, auth.ActionUpdate, userID, fields...); err != nil {
+		return nil, err
+	}
+	user.KeepEmailVerification(*current)
+	user.KeepStatus(*current)
+
+	res, err := r.repository.Update(detached, user)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, notFoundError(userID)
+		}
+		if errors.Is(err, users.ErrVersionConflict) {
+			logrus.Infof("Info in users/graphql.updateUser -> %s", err)
+			return nil, gqlError{httpErrors.ErrVersionConflict, CodeConflict}
+		}
+		return nil, internalError("updateUser", err)
+	}
+
+	go func() {
+		if err := r.pubsubRepository.NotifyUserUpdate(detached, *res); err != nil {
+			logrus.Errorf("Error in users/graphql.updateUser -> could not notify user update: %s", err)
+		}
+	}()
+	if res.PendingEmail != current.PendingEmail {
+		r.sendVerification(detached, *res)
+	}
+
+	return res, nil
+}
+
+func (r resolver) deleteUser(p graphql.ResolveParams) (interface{}, error) {
+	userID, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(p.Context, auth.ActionDelete, userID); err != nil {
+		return nil, err
+	}
+
+	ctx := audit.Detach(p.Context)
+	if err := r.repository.DeleteById(ctx, userID); err != nil {
+		if isNotFound(err) {
+			return nil, notFoundError(userID)
+		}
+		return nil, internalError("deleteUser", err)
+	}
+
+	go func() {
+		if err := r.pubsubRepository.NotifyUserDeletion(ctx, userID); err != nil {
+			logrus.Errorf("Error in users/graphql.deleteUser -> could not notify user deletion: %s", err)
+		}
+	}()
+
+	return true, nil
+}
+
+// sendVerification - sends the email verification token to the user in background, if the verification is enabled
+func (r resolver) sendVerification(ctx context.Context, user models.User) {
+	if r.verifier == nil {
+		return
+	}
+	go func() {
+		if err := r.verifier.Send(ctx, user); err != nil {
+			logrus.Errorf("Error in users/graphql.sendVerification -> could not send verification to user %s: %s", user.ID, err)
+		}
+	}()
+}
+
+// authorize - auth.Check for the GraphQL requests, returning a forbidden error if the action is not allowed
+func authorize(ctx context.Context, action auth.Action, target string, fields ...string) error {
+	if err := auth.Check(ctx, action, target, fields...); err != nil {
+		return gqlError{httpErrors.ErrForbidden, CodeForbidden}
+	}
+	return nil
+}
+
+// parseID - returns the normalized user ID, or a bad user input error if it's not a UUID
+func parseID(id interface{}) (string, error) {
+	idStr, _ := id.(string)
+	userID, err := uuid.Parse(idStr)
+	if err != nil {
+		return "", gqlError{fmt.Sprintf("Invalid user ID %s", idStr), CodeBadUserInput}
+	}
+	return userID.String(), nil
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+func notFoundError(userID string) error {
+	return gqlError{fmt.Sprintf("User not found for ID %s", userID), CodeNotFound}
+}
+
+// internalError - logs the error and returns an internal error, hiding its details to the client
+func internalError(resolver string, err error) error {
+	logrus.Errorf("Error in users/graphql.%s -> %s", resolver, err)
+	return gqlError{"internal error", CodeInternal}
+}
+
+// isNotFound - returns true if the repository error means the user does not exist
+func isNotFound(err error) bool {
+	return err == mongo.ErrNoDocuments || err == mongo.ErrNilDocument
+}
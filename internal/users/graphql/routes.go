@@ -0,0 +1,8 @@
+package graphql
+
+import "github.com/labstack/echo/v4"
+
+// AppendGraphQLRoutes - Sets the GraphQL route for the given echo group
+func AppendGraphQLRoutes(e *echo.Group, h Handler) {
+	e.POST("", h.Query)
+}
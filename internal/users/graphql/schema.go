@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"user-microservice/internal/models"
+	"user-microservice/internal/pagination"
+
+	"github.com/graphql-go/graphql"
+)
+
+var userStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "UserStatus",
+	Description: "Account status of the users, changed by the moderation",
+	Values: graphql.EnumValueConfigMap{
+		"ACTIVE":    &graphql.EnumValueConfig{Value: models.StatusActive},
+		"SUSPENDED": &graphql.EnumValueConfig{Value: models.StatusSuspended},
+		"BANNED":    &graphql.EnumValueConfig{Value: models.StatusBanned},
+	},
+})
+
+// userSortFieldEnum - the user fields that can be sorted, with their json name as value
+var userSortFieldEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserSortField",
+	Values: graphql.EnumValueConfigMap{
+		"ID":              &graphql.EnumValueConfig{Value: "id"},
+		"FIRST_NAME":      &graphql.EnumValueConfig{Value: "firstName"},
+		"LAST_NAME":       &graphql.EnumValueConfig{Value: "lastName"},
+		"NICKNAME":        &graphql.EnumValueConfig{Value: "nickname"},
+		"EMAIL":           &graphql.EnumValueConfig{Value: "email"},
+		"COUNTRY":         &graphql.EnumValueConfig{Value: "country"},
+		"CREATED_AT":      &graphql.EnumValueConfig{Value: "createdAt"},
+		"UPDATED_AT":      &graphql.EnumValueConfig{Value: "updatedAt"},
+		"STATUS":          &graphql.EnumValueConfig{Value: "status"},
+		"SUSPENDED_UNTIL": &graphql.EnumValueConfig{Value: "suspendedUntil"},
+	},
+})
+
+var sortOrderEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortOrder",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: pagination.SortOrderAsc},
+		"DESC": &graphql.EnumValueConfig{Value: pagination.SortOrderDesc},
+	},
+})
+
+// userType - the user without its password, the fields are resolved from the models.User ones
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"firstName":       &graphql.Field{Type: graphql.String},
+		"lastName":        &graphql.Field{Type: graphql.String},
+		"nickname":        &graphql.Field{Type: graphql.String},
+		"email":           &graphql.Field{Type: graphql.String},
+		"country":         &graphql.Field{Type: graphql.String},
+		"createdAt":       &graphql.Field{Type: graphql.DateTime},
+		"updatedAt":       &graphql.Field{Type: graphql.DateTime},
+		"emailVerified":   &graphql.Field{Type: graphql.Boolean},
+		"emailVerifiedAt": &graphql.Field{Type: graphql.DateTime},
+		"pendingEmail":    &graphql.Field{Type: graphql.String},
+		"status":          &graphql.Field{Type: userStatusEnum},
+		"statusReason":    &graphql.Field{Type: graphql.String},
+		"statusChangedAt": &graphql.Field{Type: graphql.DateTime},
+		"suspendedUntil":  &graphql.Field{Type: graphql.DateTime},
+		"version":         &graphql.Field{Type: graphql.Int},
+		"deletedAt":       &graphql.Field{Type: graphql.DateTime},
+	},
+})
+
+var userPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserPage",
+	Fields: graphql.Fields{
+		"users":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+		"totalCount":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"totalPages":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"currentPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"size":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"hasMore":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+var userFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"lastName":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"nickname":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"email":          &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"country":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"status":         &graphql.InputObjectFieldConfig{Type: userStatusEnum},
+		"includeDeleted": &graphql.InputObjectFieldConfig{Type: graphql.Boolean, DefaultValue: false},
+	},
+})
+
+var pageInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "PageInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"page": &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: pagination.FirstPage},
+		"size": &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: pagination.DefaultSize},
+	},
+})
+
+var userSortInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserSort",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"field": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(userSortFieldEnum)},
+		"order": &graphql.InputObjectFieldConfig{Type: sortOrderEnum, DefaultValue: pagination.SortOrderAsc},
+	},
+})
+
+var createUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CreateUserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"lastName":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"nickname":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"password":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"email":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"country":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+// updateUserInput - the fields that are set are updated, the others are kept
+var updateUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UpdateUserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"firstName": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"lastName":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"nickname":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"password":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"email":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"country":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+// newSchema - returns the users schema resolved by the given resolver
+func newSchema(r resolver) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "Gets a user by its id, null if it does not exist",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.user,
+			},
+			"users": &graphql.Field{
+				Type:        graphql.NewNonNull(userPageType),
+				Description: "Gets the paginated users matching the filter",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: userFilterInput},
+					"page":   &graphql.ArgumentConfig{Type: pageInput},
+					"sort":   &graphql.ArgumentConfig{Type: userSortInput},
+				},
+				Resolve: r.users,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type:        graphql.NewNonNull(userType),
+				Description: "Creates a new user and notifies its creation",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createUserInput)},
+				},
+				Resolve: r.createUser,
+			},
+			"updateUser": &graphql.Field{
+				Type:        graphql.NewNonNull(userType),
+				Description: "Updates the given fields of the user. The update is rejected if the version is set and it's not the current one",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateUserInput)},
+					"version": &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: r.updateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Soft deletes a user and notifies its deletion",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.deleteUser,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
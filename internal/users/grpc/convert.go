@@ -0,0 +1,182 @@
+package grpc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	usersv1 "user-microservice/api/users/v1"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users/pubsub"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// jsonFields - json names of the user fields, by their proto name
+var jsonFields = map[string]string{
+	"id":              "id",
+	"first_name":      "firstName",
+	"last_name":       "lastName",
+	"nickname":        "nickname",
+	"password":        "password",
+	"email":           "email",
+	"country":         "country",
+	"created_at":      "createdAt",
+	"updated_at":      "updatedAt",
+	"version":         "version",
+	"email_verified":  "emailVerified",
+	"status":          "status",
+	"suspended_until": "suspendedUntil",
+}
+
+// updatableFields - proto names of the fields that can be changed with UpdateUser
+var updatableFields = []string{"first_name", "last_name", "nickname", "password", "email", "country"}
+
+var protoStatuses = map[models.UserStatus]usersv1.UserStatus{
+	models.StatusActive:    usersv1.UserStatus_USER_STATUS_ACTIVE,
+	models.StatusSuspended: usersv1.UserStatus_USER_STATUS_SUSPENDED,
+	models.StatusBanned:    usersv1.UserStatus_USER_STATUS_BANNED,
+}
+
+var eventTypes = map[string]usersv1.UserEventType{
+	pubsub.TopicUserCreation:      usersv1.UserEventType_USER_EVENT_TYPE_CREATED,
+	pubsub.TopicUserUpdate:        usersv1.UserEventType_USER_EVENT_TYPE_UPDATED,
+	pubsub.TopicUserDeletion:      usersv1.UserEventType_USER_EVENT_TYPE_DELETED,
+	pubsub.TopicUserRestore:       usersv1.UserEventType_USER_EVENT_TYPE_RESTORED,
+	pubsub.TopicUserPurge:         usersv1.UserEventType_USER_EVENT_TYPE_PURGED,
+	pubsub.TopicUserEmailVerified: usersv1.UserEventType_USER_EVENT_TYPE_EMAIL_VERIFIED,
+	pubsub.TopicUserStatusChanged: usersv1.UserEventType_USER_EVENT_TYPE_STATUS_CHANGED,
+}
+
+// toProtoUser - converts the user to its proto message, without the password
+func toProtoUser(user models.User) *usersv1.User {
+	return &usersv1.User{
+		Id:              user.ID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Nickname:        user.Nickname,
+		Email:           user.Email,
+		Country:         user.Country,
+		CreatedAt:       toTimestamp(&user.CreatedAt),
+		UpdatedAt:       toTimestamp(&user.UpdatedAt),
+		EmailVerified:   user.EmailVerified,
+		EmailVerifiedAt: toTimestamp(user.EmailVerifiedAt),
+		PendingEmail:    user.PendingEmail,
+		Status:          protoStatuses[user.Status],
+		StatusReason:    user.StatusReason,
+		StatusChangedAt: toTimestamp(user.StatusChangedAt),
+		SuspendedUntil:  toTimestamp(user.SuspendedUntil),
+		Version:         user.Version,
+		DeletedAt:       toTimestamp(user.DeletedAt),
+	}
+}
+
+func toProtoUsers(users []models.User) []*usersv1.User {
+	res := make([]*usersv1.User, 0, len(users))
+	for _, user := range users {
+		res = append(res, toProtoUser(user))
+	}
+	return res
+}
+
+// toTimestamp - returns nil for the nil and zero times, which are not set
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// fromProtoStatus - returns the user status of the proto status, empty for the unspecified one
+func fromProtoStatus(status usersv1.UserStatus) (models.UserStatus, error) {
+	if status == usersv1.UserStatus_USER_STATUS_UNSPECIFIED {
+		return "", nil
+	}
+	for userStatus, protoStatus := range protoStatuses {
+		if protoStatus == status {
+			return userStatus, nil
+		}
+	}
+	return "", fmt.Errorf("invalid status %d", status)
+}
+
+// readFields - returns the user fields (json names) of the read mask, all of them if empty
+func readFields(mask *fieldmaskpb.FieldMask) (models.UserFields, error) {
+	paths := mask.GetPaths()
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name, ok := jsonFields[path]
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q", path)
+		}
+		names = append(names, name)
+	}
+	return models.ParseUserFields(strings.Join(names, ","))
+}
+
+// applyUpdate - sets the fields of the update mask to the user, all the updatable ones if the mask is empty
+func applyUpdate(user *models.User, req *usersv1.UpdateUserRequest) error {
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = updatableFields
+	}
+	for _, path := range paths {
+		switch path {
+		case "first_name":
+			user.FirstName = req.GetFirstName()
+		case "last_name":
+			user.LastName = req.GetLastName()
+		case "nickname":
+			user.Nickname = req.GetNickname()
+		case "password":
+			// an empty password keeps the current one
+			if password := req.GetPassword(); password != "" {
+				user.Password = password
+			}
+		case "email":
+			user.Email = req.GetEmail()
+		case "country":
+			user.Country = req.GetCountry()
+		default:
+			return fmt.Errorf("field %q cannot be updated", path)
+		}
+	}
+	return nil
+}
+
+// topics - returns the pubsub topics of the event types, all of them if empty
+func topics(types []usersv1.UserEventType) ([]string, error) {
+	if len(types) == 0 {
+		return pubsub.GetAllUsersTopics(), nil
+	}
+	res := make([]string, 0, len(types))
+	for _, eventType := range types {
+		topic, ok := topicOf(eventType)
+		if !ok {
+			return nil, fmt.Errorf("invalid event type %d", eventType)
+		}
+		res = append(res, topic)
+	}
+	return res, nil
+}
+
+func topicOf(eventType usersv1.UserEventType) (string, bool) {
+	for topic, t := range eventTypes {
+		if t == eventType {
+			return topic, true
+		}
+	}
+	return "", false
+}
+
+// toProtoEvent - converts the pubsub event to its proto message
+func toProtoEvent(event pubsub.Event) *usersv1.UserEvent {
+	res := &usersv1.UserEvent{
+		Type:   eventTypes[event.Topic],
+		UserId: event.UserID,
+	}
+	if event.User != nil {
+		res.User = toProtoUser(*event.User)
+	}
+	return res
+}
@@ -0,0 +1,366 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	usersv1 "user-microservice/api/users/v1"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/pagination"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/verification"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// MaxBatchSize - maximum number of IDs that can be requested at once in BatchGetUsers, the same as the HTTP API
+const MaxBatchSize = 100
+
+type grpcServer struct {
+	usersv1.UnimplementedUserServiceServer
+	repository       users.Repository
+	pubsubRepository userPS.PubSub
+	subscriber       userPS.Subscriber
+	// verifier - sends the email verification tokens, nil if the email verification is disabled
+	verifier verification.Sender
+}
+
+var _ usersv1.UserServiceServer = grpcServer{}
+
+// NewGrpcServer - returns a new UserService gRPC server initialized with the repository, with the same semantics
+// as the user http handler. The subscriber streams the user events of WatchUsers.
+// The verifier can be nil, then no email verification token is sent
+func NewGrpcServer(usersRepository users.Repository, pubsubRepository userPS.PubSub, subscriber userPS.Subscriber, verifier verification.Sender) usersv1.UserServiceServer {
+	return grpcServer{
+		repository:       usersRepository,
+		pubsubRepository: pubsubRepository,
+		subscriber:       subscriber,
+		verifier:         verifier,
+	}
+}
+
+// CreateUser - creates a new user and notifies its creation
+func (s grpcServer) CreateUser(ctx context.Context, req *usersv1.CreateUserRequest) (*usersv1.User, error) {
+	if err := auth.CheckCall(ctx, auth.ActionCreate, ""); err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Nickname:  req.GetNickname(),
+		Password:  req.GetPassword(),
+		Email:     req.GetEmail(),
+		Country:   req.GetCountry(),
+	}
+	if missing := user.MissingFields(); len(missing) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: %s", httpErrors.ErrMissingFields, strings.Join(missing, ","))
+	}
+	user.PrepareNew()
+
+	// the context keeps the audit info of the call, but it is not cancelled with it so the user is notified
+	ctx = audit.Detach(ctx)
+	res, err := s.repository.Create(ctx, user)
+	if err != nil {
+		if errors.Is(err, users.ErrDuplicateEmail) {
+			return nil, status.Error(codes.AlreadyExists, httpErrors.ErrDuplicateEmail)
+		}
+		return nil, internalError("CreateUser", err)
+	}
+
+	go func() {
+		if err := s.pubsubRepository.NotifyUserCreation(ctx, *res); err != nil {
+			logrus.Errorf("Error in users/grpc.CreateUser -> could not notify user creation: %s", err)
+		}
+	}()
+	s.sendVerification(ctx, *res)
+
+	return toProtoUser(*res), nil
+}
+
+// GetUser - gets a user by its id, with the fields of the read mask
+func (s grpcServer) GetUser(ctx context.Context, req *usersv1.GetUserRequest) (*usersv1.User, error) {
+	userID, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckCall(ctx, auth.ActionRead, userID); err != nil {
+		return nil, err
+	}
+
+	fields, err := readFields(req.GetReadMask())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, httpErrors.ErrInvalidFields)
+	}
+
+	user, err := s.repository.GetById(ctx, userID, fields...)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "User not found for ID %s", userID)
+		}
+		return nil, internalError("GetUser", err)
+	}
+
+	return toProtoUser(*user), nil
+}
+
+// BatchGetUsers - gets the users with the given ids keeping the request order
+func (s grpcServer) BatchGetUsers(ctx context.Context, req *usersv1.BatchGetUsersRequest) (*usersv1.BatchGetUsersResponse, error) {
+	if err := auth.CheckCall(ctx, auth.ActionList, ""); err != nil {
+		return nil, err
+	}
+
+	if len(req.GetIds()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, httpErrors.ErrInvalidBody)
+	}
+	if len(req.GetIds()) > MaxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, httpErrors.ErrBatchTooLarge)
+	}
+
+	fields, err := readFields(req.GetReadMask())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, httpErrors.ErrInvalidFields)
+	}
+
+	// parse and deduplicate the ids keeping the request order
+	ids := make([]string, 0, len(req.GetIds()))
+	seen := map[string]bool{}
+	for _, idStr := range req.GetIds() {
+		userID, err := parseID(idStr)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			ids = append(ids, userID)
+		}
+	}
+
+	found, err := s.repository.GetByIDs(ctx, ids, fields...)
+	if err != nil {
+		return nil, internalError("BatchGetUsers", err)
+	}
+
+	byID := make(map[string]models.User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+	res := &usersv1.BatchGetUsersResponse{
+		Users: make([]*usersv1.User, 0, len(found)),
+	}
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			res.Users = append(res.Users, toProtoUser(user))
+		} else {
+			res.NotFoundIds = append(res.NotFoundIds, id)
+		}
+	}
+
+	return res, nil
+}
+
+// ListUsers - gets the paginated users matching the filters
+func (s grpcServer) ListUsers(ctx context.Context, req *usersv1.ListUsersRequest) (*usersv1.ListUsersResponse, error) {
+	if err := auth.CheckCall(ctx, auth.ActionList, ""); err != nil {
+		return nil, err
+	}
+
+	userStatus, err := fromProtoStatus(req.GetFilters().GetStatus())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, httpErrors.ErrInvalidStatus)
+	}
+	fields, err := readFields(req.GetReadMask())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, httpErrors.ErrInvalidFields)
+	}
+
+	filters := models.UserFilters{
+		FirstName:      req.GetFilters().GetFirstName(),
+		LastName:       req.GetFilters().GetLastName(),
+		Nickname:       req.GetFilters().GetNickname(),
+		Email:          req.GetFilters().GetEmail(),
+		Country:        req.GetFilters().GetCountry(),
+		Status:         userStatus,
+		IncludeDeleted: req.GetFilters().GetIncludeDeleted(),
+	}
+	pagOpts := pagination.PaginationOptions{Page: int(req.GetPage()), Size: int(req.GetSize())}
+	res, err := s.repository.GetPaginatedUsers(ctx, pagOpts, filters, fields...)
+	if err != nil {
+		return nil, internalError("ListUsers", err)
+	}
+
+	return &usersv1.ListUsersResponse{
+		Users:       toProtoUsers(res.Users),
+		TotalCount:  res.TotalCount,
+		TotalPages:  res.TotalPages,
+		CurrentPage: int32(res.CurrentPage),
+		Size:        int32(res.Size),
+		HasMore:     res.HasMore,
+	}, nil
+}
+
+// UpdateUser - updates the fields of the update mask and notifies the update. A changed email is kept pending
+// until it's verified with the token sent to it, and the status is only changed by the moderation
+func (s grpcServer) UpdateUser(ctx context.Context, req *usersv1.UpdateUserRequest) (*usersv1.User, error) {
+	userID, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckCall(ctx, auth.ActionUpdate, userID); err != nil {
+		return nil, err
+	}
+
+	// the principal is kept in the call context to authorize the changed fields
+	detached := audit.Detach(ctx)
+	current, err := s.repository.GetById(detached, userID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "User not found for ID %s", userID)
+		}
+		return nil, internalError("UpdateUser", err)
+	}
+	if req.GetVersion() != 0 && req.GetVersion() != current.Version {
+		return nil, status.Error(codes.FailedPrecondition, httpErrors.ErrPreconditionFailed)
+	}
+
+	user := *current
+	if err := applyUpdate(&user, req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: %s", httpErrors.ErrInvalidFields, err)
+	}
+	if missing := user.MissingUpdateFields(); len(missing) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: %s", httpErrors.ErrMissingFields, strings.Join(missing, ","))
+	}
+
+	changes := models.DiffUsers(current, user)
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	if err := auth.CheckCall(ctx, auth.ActionUpdate, userID, fields...); err != nil {
+		return nil, err
+	}
+	user.KeepEmailVerification(*current)
+	user.KeepStatus(*current)
+
+	res, err := s.repository.Update(detached, user)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "User not found for ID %s", userID)
+		}
+		if errors.Is(err, users.ErrVersionConflict) {
+			logrus.Infof("Info in users/grpc.UpdateUser -> %s", err)
+			return nil, status.Error(codes.Aborted, httpErrors.ErrVersionConflict)
+		}
+		return nil, internalError("UpdateUser", err)
+	}
+
+	go func() {
+		if err := s.pubsubRepository.NotifyUserUpdate(detached, *res); err != nil {
+			logrus.Errorf("Error in users/grpc.UpdateUser -> could not notify user update: %s", err)
+		}
+	}()
+	if res.PendingEmail != current.PendingEmail {
+		s.sendVerification(detached, *res)
+	}
+
+	return toProtoUser(*res), nil
+}
+
+// DeleteUser - soft deletes a user and notifies its deletion
+func (s grpcServer) DeleteUser(ctx context.Context, req *usersv1.DeleteUserRequest) (*emptypb.Empty, error) {
+	userID, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckCall(ctx, auth.ActionDelete, userID); err != nil {
+		return nil, err
+	}
+
+	ctx = audit.Detach(ctx)
+	if err := s.repository.DeleteById(ctx, userID); err != nil {
+		if isNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "User not found for ID %s", userID)
+		}
+		return nil, internalError("DeleteUser", err)
+	}
+
+	go func() {
+		if err := s.pubsubRepository.NotifyUserDeletion(ctx, userID); err != nil {
+			logrus.Errorf("Error in users/grpc.DeleteUser -> could not notify user deletion: %s", err)
+		}
+	}()
+
+	return &emptypb.Empty{}, nil
+}
+
+// WatchUsers - streams the user events of the requested types until the client cancels the call
+func (s grpcServer) WatchUsers(req *usersv1.WatchUsersRequest, stream usersv1.UserService_WatchUsersServer) error {
+	ctx := stream.Context()
+	if err := auth.CheckCall(ctx, auth.ActionList, ""); err != nil {
+		return err
+	}
+
+	watchedTopics, err := topics(req.GetTypes())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, httpErrors.ErrInvalidParams)
+	}
+
+	events, err := s.subscriber.Subscribe(ctx, watchedTopics...)
+	if err != nil {
+		return internalError("WatchUsers", err)
+	}
+	for event := range events {
+		if err := stream.Send(toProtoEvent(event)); err != nil {
+			logrus.Infof("Info in users/grpc.WatchUsers -> error sending event: %s", err)
+			return err
+		}
+	}
+
+	// the events are closed when the call is cancelled, or when the subscription is lost
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Unavailable, "events subscription closed")
+}
+
+// sendVerification - sends the email verification token to the user in background, if the verification is enabled
+func (s grpcServer) sendVerification(ctx context.Context, user models.User) {
+	if s.verifier == nil {
+		return
+	}
+	go func() {
+		if err := s.verifier.Send(ctx, user); err != nil {
+			logrus.Errorf("Error in users/grpc.sendVerification -> could not send verification to user %s: %s", user.ID, err)
+		}
+	}()
+}
+
+// parseID - returns the normalized user ID, or an invalid argument error if it's not a UUID
+func parseID(id string) (string, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid user ID %s", id))
+	}
+	return userID.String(), nil
+}
+
+// internalError - logs the error and returns an internal error, hiding its details to the client
+func internalError(method string, err error) error {
+	logrus.Errorf("Error in users/grpc.%s -> %s", method, err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// isNotFound - returns true if the repository error means the user does not exist
+func isNotFound(err error) bool {
+	return err == mongo.ErrNoDocuments || err == mongo.ErrNilDocument
+}
@@ -0,0 +1,674 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	usersv1 "user-microservice/api/users/v1"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/models"
+	"user-microservice/internal/pagination"
+	"user-microservice/internal/users"
+	usersGrpc "user-microservice/internal/users/grpc"
+	"user-microservice/internal/users/mock"
+	usersPubSub "user-microservice/internal/users/pubsub"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// fakeKeys - KeyAuthenticator with the principal of each key
+type fakeKeys map[string]auth.Principal
+
+func (f fakeKeys) Authenticate(_ context.Context, key string) (auth.Principal, error) {
+	principal, ok := f[key]
+	if !ok {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+	return principal, nil
+}
+
+var testKeys = fakeKeys{
+	"umk_admin":   {Subject: "apikey:admin", Scopes: []string{auth.ScopeUsersAdmin}},
+	"umk_reader":  {Subject: "apikey:reader", Scopes: []string{auth.ScopeUsersRead}},
+	"umk_support": {Subject: "support-user", Role: auth.RoleSupport},
+}
+
+// newClient - serves the UserService on an in-memory connection and returns a client of it.
+// The calls are authenticated with the testKeys
+func newClient(t *testing.T, service usersv1.UserServiceServer) usersv1.UserServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(auth.UnaryServerInterceptor(nil, testKeys)),
+		grpc.StreamInterceptor(auth.StreamServerInterceptor(nil, testKeys)),
+	)
+	usersv1.RegisterUserServiceServer(srv, service)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoErrorf(t, err, "Expected no error when dialing, but was %s", err)
+	t.Cleanup(func() { conn.Close() })
+
+	return usersv1.NewUserServiceClient(conn)
+}
+
+// withKey - returns a context sending the API key in the call metadata
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), auth.MetadataAPIKey, key)
+}
+
+func assertCode(t *testing.T, expected codes.Code, err error) {
+	t.Helper()
+	assert.Equalf(t, expected, status.Code(err), "Expected code to be %s, but was %s (%v)", expected, status.Code(err), err)
+}
+
+func newUser() models.User {
+	return models.User{
+		ID:        uuid.New().String(),
+		FirstName: "Alice",
+		LastName:  "Tingo",
+		Nickname:  "atingo",
+		Password:  "hashed password",
+		Email:     "atingo@example.com",
+		Country:   "DE",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Status:    models.StatusActive,
+		Version:   3,
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	user := newUser()
+	validRequest := &usersv1.CreateUserRequest{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Nickname:  user.Nickname,
+		Password:  "password",
+		Email:     user.Email,
+		Country:   user.Country,
+	}
+	for _, tc := range []struct {
+		name           string
+		key            string
+		req            *usersv1.CreateUserRequest
+		mockedError    error
+		shouldCallRepo bool
+		expectedCode   codes.Code
+	}{
+		{
+			"Create user successfully",
+			"umk_admin",
+			validRequest,
+			nil,
+			true,
+			codes.OK,
+		},
+		{
+			"Create user with missing fields",
+			"umk_admin",
+			&usersv1.CreateUserRequest{FirstName: "Alice"},
+			nil,
+			false,
+			codes.InvalidArgument,
+		},
+		{
+			"Create user without permission",
+			"umk_reader",
+			validRequest,
+			nil,
+			false,
+			codes.PermissionDenied,
+		},
+		{
+			"Create user with invalid API key",
+			"umk_invalid",
+			validRequest,
+			nil,
+			false,
+			codes.Unauthenticated,
+		},
+		{
+			"Create user with repository error",
+			"umk_admin",
+			validRequest,
+			errors.New("homemade error"),
+			true,
+			codes.Internal,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			ps := mock.NewMockPubSub(ctrl)
+			client := newClient(t, usersGrpc.NewGrpcServer(repo, ps, mock.NewMockSubscriber(ctrl), nil))
+
+			published := make(chan struct{})
+			if tc.shouldCallRepo {
+				var res *models.User
+				if tc.mockedError == nil {
+					res = &user
+					ps.EXPECT().NotifyUserCreation(gomock.Any(), user).DoAndReturn(func(context.Context, models.User) error {
+						close(published)
+						return nil
+					})
+				}
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, created models.User) (*models.User, error) {
+					assert.Equalf(t, models.StatusActive, created.Status, "Expected status to be active, but was %s", created.Status)
+					assert.Falsef(t, created.EmailVerified, "Expected email not to be verified")
+					return res, tc.mockedError
+				})
+			}
+
+			// When
+			res, err := client.CreateUser(withKey(tc.key), tc.req)
+
+			// Then
+			assertCode(t, tc.expectedCode, err)
+			if tc.expectedCode == codes.OK {
+				assert.Equalf(t, user.ID, res.GetId(), "Expected id to be %s, but was %s", user.ID, res.GetId())
+				assert.Equalf(t, usersv1.UserStatus_USER_STATUS_ACTIVE, res.GetStatus(), "Expected status to be active, but was %s", res.GetStatus())
+				select {
+				case <-published:
+				case <-time.After(time.Second):
+					t.Fatal("Expected the user creation to be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	user := newUser()
+	for _, tc := range []struct {
+		name           string
+		req            *usersv1.GetUserRequest
+		expectedFields []string
+		mockedError    error
+		shouldCallRepo bool
+		expectedCode   codes.Code
+	}{
+		{
+			"Get user successfully",
+			&usersv1.GetUserRequest{Id: user.ID},
+			nil,
+			nil,
+			true,
+			codes.OK,
+		},
+		{
+			"Get user with read mask",
+			&usersv1.GetUserRequest{Id: user.ID, ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname", "first_name"}}},
+			[]string{"nickname", "firstName"},
+			nil,
+			true,
+			codes.OK,
+		},
+		{
+			"Get user with invalid read mask",
+			&usersv1.GetUserRequest{Id: user.ID, ReadMask: &fieldmaskpb.FieldMask{Paths: []string{"password"}}},
+			nil,
+			nil,
+			false,
+			codes.InvalidArgument,
+		},
+		{
+			"Get user with invalid id",
+			&usersv1.GetUserRequest{Id: "invalid"},
+			nil,
+			nil,
+			false,
+			codes.InvalidArgument,
+		},
+		{
+			"Get user not found",
+			&usersv1.GetUserRequest{Id: user.ID},
+			nil,
+			mongo.ErrNoDocuments,
+			true,
+			codes.NotFound,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			client := newClient(t, usersGrpc.NewGrpcServer(repo, mock.NewMockPubSub(ctrl), mock.NewMockSubscriber(ctrl), nil))
+
+			if tc.shouldCallRepo {
+				var res *models.User
+				if tc.mockedError == nil {
+					res = &user
+				}
+				fields := []interface{}{}
+				for _, field := range tc.expectedFields {
+					fields = append(fields, field)
+				}
+				repo.EXPECT().GetById(gomock.Any(), user.ID, fields...).Return(res, tc.mockedError)
+			}
+
+			// When
+			res, err := client.GetUser(withKey("umk_reader"), tc.req)
+
+			// Then
+			assertCode(t, tc.expectedCode, err)
+			if tc.expectedCode == codes.OK {
+				assert.Equalf(t, user.Nickname, res.GetNickname(), "Expected nickname to be %s, but was %s", user.Nickname, res.GetNickname())
+				assert.Equalf(t, user.Version, res.GetVersion(), "Expected version to be %d, but was %d", user.Version, res.GetVersion())
+				assert.Truef(t, res.GetCreatedAt().AsTime().Equal(user.CreatedAt), "Expected createdAt to be %s, but was %s", user.CreatedAt, res.GetCreatedAt().AsTime())
+				assert.Nilf(t, res.GetDeletedAt(), "Expected deletedAt to be nil, but was %s", res.GetDeletedAt())
+			}
+		})
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	first, second := newUser(), newUser()
+	missing := uuid.New().String()
+	for _, tc := range []struct {
+		name             string
+		ids              []string
+		shouldCallRepo   bool
+		expectedCode     codes.Code
+		expectedIDs      []string
+		expectedNotFound []string
+	}{
+		{
+			"Batch get users keeping the request order",
+			[]string{second.ID, missing, first.ID, second.ID},
+			true,
+			codes.OK,
+			[]string{second.ID, first.ID},
+			[]string{missing},
+		},
+		{
+			"Batch get users without ids",
+			nil,
+			false,
+			codes.InvalidArgument,
+			nil,
+			nil,
+		},
+		{
+			"Batch get too many users",
+			make([]string, usersGrpc.MaxBatchSize+1),
+			false,
+			codes.InvalidArgument,
+			nil,
+			nil,
+		},
+		{
+			"Batch get users with invalid id",
+			[]string{first.ID, "invalid"},
+			false,
+			codes.InvalidArgument,
+			nil,
+			nil,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			client := newClient(t, usersGrpc.NewGrpcServer(repo, mock.NewMockPubSub(ctrl), mock.NewMockSubscriber(ctrl), nil))
+
+			if tc.shouldCallRepo {
+				repo.EXPECT().GetByIDs(gomock.Any(), []string{second.ID, missing, first.ID}).Return([]models.User{first, second}, nil)
+			}
+
+			// When
+			res, err := client.BatchGetUsers(withKey("umk_reader"), &usersv1.BatchGetUsersRequest{Ids: tc.ids})
+
+			// Then
+			assertCode(t, tc.expectedCode, err)
+			if tc.expectedCode == codes.OK {
+				ids := []string{}
+				for _, user := range res.GetUsers() {
+					ids = append(ids, user.GetId())
+				}
+				assert.Equalf(t, tc.expectedIDs, ids, "Expected ids to be %v, but were %v", tc.expectedIDs, ids)
+				assert.Equalf(t, tc.expectedNotFound, res.GetNotFoundIds(), "Expected not found ids to be %v, but were %v", tc.expectedNotFound, res.GetNotFoundIds())
+			}
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	user := newUser()
+	for _, tc := range []struct {
+		name            string
+		req             *usersv1.ListUsersRequest
+		expectedPag     pagination.PaginationOptions
+		expectedFilters models.UserFilters
+		shouldCallRepo  bool
+		expectedCode    codes.Code
+	}{
+		{
+			"List users with filters",
+			&usersv1.ListUsersRequest{
+				Page: 2,
+				Size: 5,
+				Filters: &usersv1.UserFilters{
+					Country:        "DE",
+					Status:         usersv1.UserStatus_USER_STATUS_SUSPENDED,
+					IncludeDeleted: true,
+				},
+			},
+			pagination.PaginationOptions{Page: 2, Size: 5},
+			models.UserFilters{Country: "DE", Status: models.StatusSuspended, IncludeDeleted: true},
+			true,
+			codes.OK,
+		},
+		{
+			"List users without filters",
+			&usersv1.ListUsersRequest{},
+			pagination.PaginationOptions{},
+			models.UserFilters{},
+			true,
+			codes.OK,
+		},
+		{
+			"List users with invalid status",
+			&usersv1.ListUsersRequest{Filters: &usersv1.UserFilters{Status: usersv1.UserStatus(42)}},
+			pagination.PaginationOptions{},
+			models.UserFilters{},
+			false,
+			codes.InvalidArgument,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			client := newClient(t, usersGrpc.NewGrpcServer(repo, mock.NewMockPubSub(ctrl), mock.NewMockSubscriber(ctrl), nil))
+
+			mocked := models.PaginatedUsers{
+				Paginated: pagination.Paginated{TotalCount: 11, TotalPages: 3, CurrentPage: 2, Size: 5, HasMore: true},
+				Users:     []models.User{user},
+			}
+			if tc.shouldCallRepo {
+				repo.EXPECT().GetPaginatedUsers(gomock.Any(), tc.expectedPag, tc.expectedFilters).Return(mocked, nil)
+			}
+
+			// When
+			res, err := client.ListUsers(withKey("umk_reader"), tc.req)
+
+			// Then
+			assertCode(t, tc.expectedCode, err)
+			if tc.expectedCode == codes.OK {
+				assert.Equalf(t, int64(11), res.GetTotalCount(), "Expected total count to be 11, but was %d", res.GetTotalCount())
+				assert.Truef(t, res.GetHasMore(), "Expected hasMore to be true")
+				require.Lenf(t, res.GetUsers(), 1, "Expected 1 user, but were %d", len(res.GetUsers()))
+				assert.Equalf(t, user.ID, res.GetUsers()[0].GetId(), "Expected id to be %s, but was %s", user.ID, res.GetUsers()[0].GetId())
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	current := newUser()
+	for _, tc := range []struct {
+		name             string
+		key              string
+		req              *usersv1.UpdateUserRequest
+		mockedError      error
+		shouldUpdate     bool
+		expectedCode     codes.Code
+		expectedNickname string
+		expectedPending  string
+	}{
+		{
+			"Update masked fields",
+			"umk_admin",
+			&usersv1.UpdateUserRequest{Id: current.ID, Nickname: "alicet", Country: "ES", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname"}}},
+			nil,
+			true,
+			codes.OK,
+			"alicet",
+			"",
+		},
+		{
+			"Update email keeps it pending",
+			"umk_admin",
+			&usersv1.UpdateUserRequest{Id: current.ID, Email: "alice@example.com", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}}},
+			nil,
+			true,
+			codes.OK,
+			current.Nickname,
+			"alice@example.com",
+		},
+		{
+			"Update without mask replaces all the fields",
+			"umk_admin",
+			&usersv1.UpdateUserRequest{Id: current.ID, FirstName: "Alice"},
+			nil,
+			false,
+			codes.InvalidArgument,
+			"",
+			"",
+		},
+		{
+			"Update not updatable field",
+			"umk_admin",
+			&usersv1.UpdateUserRequest{Id: current.ID, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"version"}}},
+			nil,
+			false,
+			codes.InvalidArgument,
+			"",
+			"",
+		},
+		{
+			"Update with outdated version",
+			"umk_admin",
+			&usersv1.UpdateUserRequest{Id: current.ID, Version: current.Version - 1, Nickname: "alicet", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname"}}},
+			nil,
+			false,
+			codes.FailedPrecondition,
+			"",
+			"",
+		},
+		{
+			"Update with version conflict",
+			"umk_admin",
+			&usersv1.UpdateUserRequest{Id: current.ID, Version: current.Version, Nickname: "alicet", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname"}}},
+			&users.VersionConflictError{ID: current.ID, Expected: current.Version, Current: current.Version + 1},
+			true,
+			codes.Aborted,
+			"",
+			"",
+		},
+		{
+			"Update field not allowed to the support role",
+			"umk_support",
+			&usersv1.UpdateUserRequest{Id: current.ID, Email: "alice@example.com", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"email"}}},
+			nil,
+			false,
+			codes.PermissionDenied,
+			"",
+			"",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			ps := mock.NewMockPubSub(ctrl)
+			client := newClient(t, usersGrpc.NewGrpcServer(repo, ps, mock.NewMockSubscriber(ctrl), nil))
+
+			repo.EXPECT().GetById(gomock.Any(), current.ID).Return(&current, nil)
+			published := make(chan struct{})
+			if tc.shouldUpdate {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (*models.User, error) {
+					if tc.mockedError != nil {
+						return nil, tc.mockedError
+					}
+					user.Version++
+					return &user, nil
+				})
+				if tc.mockedError == nil {
+					ps.EXPECT().NotifyUserUpdate(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, models.User) error {
+						close(published)
+						return nil
+					})
+				}
+			}
+
+			// When
+			res, err := client.UpdateUser(withKey(tc.key), tc.req)
+
+			// Then
+			assertCode(t, tc.expectedCode, err)
+			if tc.expectedCode == codes.OK {
+				assert.Equalf(t, tc.expectedNickname, res.GetNickname(), "Expected nickname to be %s, but was %s", tc.expectedNickname, res.GetNickname())
+				assert.Equalf(t, current.Email, res.GetEmail(), "Expected email to be %s, but was %s", current.Email, res.GetEmail())
+				assert.Equalf(t, tc.expectedPending, res.GetPendingEmail(), "Expected pending email to be %s, but was %s", tc.expectedPending, res.GetPendingEmail())
+				assert.Equalf(t, current.Country, res.GetCountry(), "Expected country to be %s, but was %s", current.Country, res.GetCountry())
+				select {
+				case <-published:
+				case <-time.After(time.Second):
+					t.Fatal("Expected the user update to be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	userID := uuid.New().String()
+	for _, tc := range []struct {
+		name         string
+		mockedError  error
+		expectedCode codes.Code
+	}{
+		{
+			"Delete user successfully",
+			nil,
+			codes.OK,
+		},
+		{
+			"Delete user not found",
+			mongo.ErrNoDocuments,
+			codes.NotFound,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			ps := mock.NewMockPubSub(ctrl)
+			client := newClient(t, usersGrpc.NewGrpcServer(repo, ps, mock.NewMockSubscriber(ctrl), nil))
+
+			repo.EXPECT().DeleteById(gomock.Any(), userID).Return(tc.mockedError)
+			published := make(chan struct{})
+			if tc.mockedError == nil {
+				ps.EXPECT().NotifyUserDeletion(gomock.Any(), userID).DoAndReturn(func(context.Context, string) error {
+					close(published)
+					return nil
+				})
+			}
+
+			// When
+			_, err := client.DeleteUser(withKey("umk_admin"), &usersv1.DeleteUserRequest{Id: userID})
+
+			// Then
+			assertCode(t, tc.expectedCode, err)
+			if tc.expectedCode == codes.OK {
+				select {
+				case <-published:
+				case <-time.After(time.Second):
+					t.Fatal("Expected the user deletion to be notified")
+				}
+			}
+		})
+	}
+}
+
+func TestWatchUsers(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	subscriber := mock.NewMockSubscriber(ctrl)
+	client := newClient(t, usersGrpc.NewGrpcServer(mock.NewMockRepository(ctrl), mock.NewMockPubSub(ctrl), subscriber, nil))
+
+	user := newUser()
+	events := make(chan usersPubSub.Event, 2)
+	events <- usersPubSub.Event{Topic: usersPubSub.TopicUserUpdate, UserID: user.ID, User: &user}
+	events <- usersPubSub.Event{Topic: usersPubSub.TopicUserDeletion, UserID: user.ID}
+	close(events)
+	subscriber.EXPECT().
+		Subscribe(gomock.Any(), usersPubSub.TopicUserUpdate, usersPubSub.TopicUserDeletion).
+		Return((<-chan usersPubSub.Event)(events), nil)
+
+	// When
+	stream, err := client.WatchUsers(withKey("umk_reader"), &usersv1.WatchUsersRequest{
+		Types: []usersv1.UserEventType{usersv1.UserEventType_USER_EVENT_TYPE_UPDATED, usersv1.UserEventType_USER_EVENT_TYPE_DELETED},
+	})
+	require.NoErrorf(t, err, "Expected no error when watching users, but was %s", err)
+
+	// Then
+	updated, err := stream.Recv()
+	require.NoErrorf(t, err, "Expected no error when receiving event, but was %s", err)
+	assert.Equalf(t, usersv1.UserEventType_USER_EVENT_TYPE_UPDATED, updated.GetType(), "Expected type to be updated, but was %s", updated.GetType())
+	assert.Equalf(t, user.Nickname, updated.GetUser().GetNickname(), "Expected nickname to be %s, but was %s", user.Nickname, updated.GetUser().GetNickname())
+
+	deleted, err := stream.Recv()
+	require.NoErrorf(t, err, "Expected no error when receiving event, but was %s", err)
+	assert.Equalf(t, usersv1.UserEventType_USER_EVENT_TYPE_DELETED, deleted.GetType(), "Expected type to be deleted, but was %s", deleted.GetType())
+	assert.Equalf(t, user.ID, deleted.GetUserId(), "Expected user id to be %s, but was %s", user.ID, deleted.GetUserId())
+	assert.Nilf(t, deleted.GetUser(), "Expected user to be nil, but was %v", deleted.GetUser())
+
+	// the subscription was lost
+	_, err = stream.Recv()
+	assertCode(t, codes.Unavailable, err)
+}
+
+func TestWatchUsersWithoutPermission(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	client := newClient(t, usersGrpc.NewGrpcServer(mock.NewMockRepository(ctrl), mock.NewMockPubSub(ctrl), mock.NewMockSubscriber(ctrl), nil))
+
+	// When
+	stream, err := client.WatchUsers(withKey("umk_invalid"), &usersv1.WatchUsersRequest{})
+	require.NoErrorf(t, err, "Expected no error when opening the stream, but was %s", err)
+	_, err = stream.Recv()
+
+	// Then
+	assertCode(t, codes.Unauthenticated, err)
+}
@@ -6,8 +6,15 @@ import "github.com/labstack/echo/v4"
 // Handler - user handlers
 type Handler interface {
 	CreateUser(c echo.Context) error
+	ImportUsers(c echo.Context) error
 	GetAllUsers(c echo.Context) error
+	ExportUsers(c echo.Context) error
 	GetUserByID(c echo.Context) error
+	BatchGetUsers(c echo.Context) error
 	UpdateUserByID(c echo.Context) error
+	ReplaceUserByID(c echo.Context) error
+	PatchUserByID(c echo.Context) error
 	DeleteUserByID(c echo.Context) error
+	RestoreUserByID(c echo.Context) error
+	GetUserHistory(c echo.Context) error
 }
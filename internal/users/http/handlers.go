@@ -4,14 +4,24 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
 	httpErrors "user-microservice/internal/errors/http"
 	"user-microservice/internal/models"
 	"user-microservice/internal/pagination"
 	"user-microservice/internal/users"
+	"user-microservice/internal/users/bulk"
 	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/verification"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
@@ -20,17 +30,36 @@ import (
 
 var _ = echo.HTTPError{}
 
+const (
+	// ContentTypeMergePatch - content type for JSON Merge Patch (RFC 7396) documents
+	ContentTypeMergePatch = "application/merge-patch+json"
+	// ContentTypeJSONPatch - content type for JSON Patch (RFC 6902) documents
+	ContentTypeJSONPatch = "application/json-patch+json"
+
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+
+	// MaxBatchSize - maximum number of IDs that can be requested at once in BatchGetUsers
+	MaxBatchSize = 100
+	// exportFlushSize - number of exported users written between each response flush
+	exportFlushSize = 500
+)
+
 type httpHandler struct {
 	repository       users.Repository
 	pubsubRepository userPS.PubSub
+	// verifier - sends the email verification tokens, nil if the email verification is disabled
+	verifier verification.Sender
 }
 
 var _ users.Handler = httpHandler{}
 var _ users.Handler = (*httpHandler)(nil)
 
-// NewHttpHandler - returns a new user http handler initialized with the repository
-func NewHttpHandler(usersRepository users.Repository, pubsubRepository userPS.PubSub) users.Handler {
-	return &httpHandler{usersRepository, pubsubRepository}
+// NewHttpHandler - returns a new user http handler initialized with the repository.
+// The verifier can be nil, then no email verification token is sent
+func NewHttpHandler(usersRepository users.Repository, pubsubRepository userPS.PubSub, verifier verification.Sender) users.Handler {
+	return &httpHandler{usersRepository, pubsubRepository, verifier}
 }
 
 // CreateUser godoc
@@ -40,33 +69,44 @@ func NewHttpHandler(usersRepository users.Repository, pubsubRepository userPS.Pu
 // @Tags        Users
 // @Accept      json
 // @Produce     json
-// @Param       body body     models.User true "User to create"
-// @Success     201  {object} models.User
-// @Failure     400  {object} echo.HTTPError
-// @Failure     404  {object} echo.HTTPError
-// @Failure     500  {object} echo.HTTPError
+// @Param       body            body     models.UserRequest true  "User to create"
+// @Param       Idempotency-Key header   string             false "Key to safely retry the request"
+// @Success     201             {object} models.User
+// @Failure     400             {object} echo.HTTPError
+// @Failure     401             {object} echo.HTTPError
+// @Failure     403             {object} echo.HTTPError
+// @Failure     404             {object} echo.HTTPError
+// @Failure     409             {object} echo.HTTPError
+// @Failure     500             {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
 // @Router      /users [post]
 func (h httpHandler) CreateUser(c echo.Context) error {
-	var body models.User
+	if err := auth.CheckRequest(c, auth.ActionCreate, ""); err != nil {
+		return err
+	}
 
-	if err := c.Bind(&body); err != nil {
+	var req models.UserRequest
+
+	if err := c.Bind(&req); err != nil {
 		logrus.Errorf("Error in users/http.CreateUser -> error binding body: %s", err)
 
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	body := req.ToUser()
 	if !body.Valid() {
 		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
 	}
-	// pwd, err := sec.HashPassword(body.Password)
-	// if err != nil {
-	// 	return err
-	// }
-	// body.User.Password = pwd
+	body.PrepareNew()
 
-	ctx := context.TODO()
+	// the context keeps the audit info of the request, but it is not cancelled with it so the user is notified
+	ctx := audit.Detach(c.Request().Context())
 	res, err := h.repository.Create(ctx, body)
 	if err != nil {
+		if errors.Is(err, users.ErrDuplicateEmail) {
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrDuplicateEmail)
+		}
 		return err
 	}
 
@@ -76,32 +116,95 @@ func (h httpHandler) CreateUser(c echo.Context) error {
 			logrus.Errorf("Error in users/http.CreateUser -> could not notify user creation: %s", err)
 		}
 	}()
+	h.sendVerification(ctx, *res)
 
 	return c.JSON(http.StatusCreated, res)
 }
 
+// ImportUsers godoc
+//
+// @Summary     Imports users in bulk
+// @Description Streams the users in the body (NDJSON or CSV with header), validates each row like the user creation and inserts the valid ones in chunks
+// @Tags        Users
+// @Accept      application/x-ndjson,text/csv
+// @Produce     json
+// @Param       body   body     string false "Users to import, one per line"
+// @Param       format query    string false "Data format, if empty it's taken from the Content-Type" Enums(ndjson, csv)
+// @Param       dryRun query    bool   false "Only validate the users"                                default(false)
+// @Success     200    {object} bulk.ImportReport
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/import [post]
+func (h httpHandler) ImportUsers(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionCreate, ""); err != nil {
+		return err
+	}
+
+	format := bulk.Format(c.QueryParam("format"))
+	if format == "" {
+		format = bulk.FormatFromContentType(c.Request().Header.Get(echo.HeaderContentType))
+	}
+	if !format.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFormat)
+	}
+
+	var dryRun bool
+	if err := echo.QueryParamsBinder(c).Bool("dryRun", &dryRun).BindError(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+	}
+
+	importer := bulk.NewImporter(h.repository, h.pubsubRepository, h.verifier, bulk.DefaultChunkSize)
+	report, err := importer.Import(c.Request().Context(), c.Request().Body, format, dryRun)
+	if err != nil {
+		logrus.Errorf("Error in users/http.ImportUsers -> error importing users: %s", err)
+		if errors.Is(err, bulk.ErrInvalidData) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
 // GetAllUsers godoc
 //
 // @Summary     Gets paginated users
 // @Description Gets a paginated users list from the db and returns it
 // @Tags        Users
 // @Produce     json
-// @Param       page      query    int    false "Page to retrieve" default(1)  minimum(1) example(2)
-// @Param       size      query    int    false "Page size"        default(10) minimum(1) example(3)
-// @Param       firstName query    string false "FirstName filter" example(Alice)
-// @Param       lastName  query    string false "LastName filter"  example(Tingo)
-// @Param       email     query    string false "Email filter"     example(alicetingo@example.com) format(email)
-// @Param       nickname  query    string false "Nickname filter"  example(atingo)
-// @Param       country   query    string false "Country filter"   example(DE)
-// @Success     200       {object} models.PaginatedUsers
-// @Failure     400       {object} echo.HTTPError
-// @Failure     500       {object} echo.HTTPError
+// @Param       page           query    int    false "Page to retrieve"                           default(1)  minimum(1) example(2)
+// @Param       size           query    int    false "Page size"                                  default(10) minimum(1) example(3)
+// @Param       firstName      query    string false "FirstName filter"                           example(Alice)
+// @Param       lastName       query    string false "LastName filter"                            example(Tingo)
+// @Param       email          query    string false "Email filter"                               example(alicetingo@example.com) format(email)
+// @Param       nickname       query    string false "Nickname filter"                            example(atingo)
+// @Param       country        query    string false "Country filter"                             example(DE)
+// @Param       status         query    string false "Status filter"                              Enums(active, suspended, banned)
+// @Param       includeDeleted query    bool   false "Include the soft deleted users"             default(false)
+// @Param       orderBy        query    string false "Field to order by"                          example(createdAt)
+// @Param       sort           query    string false "Sort order of the orderBy field"            Enums(asc, desc) default(desc)
+// @Param       fields         query    string false "Comma separated list of fields to retrieve" example(id,nickname,country)
+// @Success     200            {object} models.PaginatedUsers
+// @Failure     400            {object} echo.HTTPError
+// @Failure     401            {object} echo.HTTPError
+// @Failure     403            {object} echo.HTTPError
+// @Failure     500            {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
 // @Router      /users [get]
 func (h httpHandler) GetAllUsers(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionList, ""); err != nil {
+		return err
+	}
 
 	type params struct {
 		pagination.PaginationOptions
 		models.UserFilters
+		Fields string `query:"fields"`
 	}
 
 	var pagOpts params
@@ -109,26 +212,155 @@ func (h httpHandler) GetAllUsers(c echo.Context) error {
 		logrus.Errorf("Error in users/http.GetAllUsers -> error binding params: %s", err)
 		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
 	}
+	if pagOpts.Status != "" && !pagOpts.Status.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidStatus)
+	}
+	if _, ok := models.UserSelectableFields[pagOpts.OrderBy]; pagOpts.OrderBy != "" && !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+	}
+	if pagOpts.SortOrder != "" && !pagOpts.SortOrder.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+	}
 
-	res, err := h.repository.GetPaginatedUsers(context.TODO(), pagOpts.PaginationOptions, pagOpts.UserFilters)
+	fields, err := models.ParseUserFields(pagOpts.Fields)
+	if err != nil {
+		logrus.Errorf("Error in users/http.GetAllUsers -> error parsing fields: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields)
+	}
+
+	res, err := h.repository.GetPaginatedUsers(c.Request().Context(), pagOpts.PaginationOptions, pagOpts.UserFilters, fields...)
 	if err != nil {
 		return err
 	}
 
+	if len(fields) > 0 {
+		sparse, err := res.Select(fields)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, sparse)
+	}
+
 	return c.JSON(http.StatusOK, res)
 }
 
+// ExportUsers godoc
+//
+// @Summary     Exports the users
+// @Description Streams all the users matching the filters as NDJSON or CSV. The password is never exported
+// @Tags        Users
+// @Produce     application/x-ndjson,text/csv
+// @Param       format         query    string false "Data format"                              Enums(ndjson, csv) default(ndjson)
+// @Param       fields         query    string false "Comma separated list of fields to export" example(id,nickname,country)
+// @Param       firstName      query    string false "FirstName filter"                         example(Alice)
+// @Param       lastName       query    string false "LastName filter"                          example(Tingo)
+// @Param       email          query    string false "Email filter"                             example(alicetingo@example.com) format(email)
+// @Param       nickname       query    string false "Nickname filter"                          example(atingo)
+// @Param       country        query    string false "Country filter"                           example(DE)
+// @Param       status         query    string false "Status filter"                            Enums(active, suspended, banned)
+// @Param       includeDeleted query    bool   false "Include the soft deleted users"           default(false)
+// @Success     200            {string} string
+// @Failure     400            {object} echo.HTTPError
+// @Failure     401            {object} echo.HTTPError
+// @Failure     403            {object} echo.HTTPError
+// @Failure     500            {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/export [get]
+func (h httpHandler) ExportUsers(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionList, ""); err != nil {
+		return err
+	}
+
+	type params struct {
+		models.UserFilters
+		Format string `query:"format"`
+		Fields string `query:"fields"`
+	}
+
+	var exportOpts params
+	if err := c.Bind(&exportOpts); err != nil {
+		logrus.Errorf("Error in users/http.ExportUsers -> error binding params: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+	}
+	if exportOpts.Status != "" && !exportOpts.Status.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidStatus)
+	}
+
+	format := bulk.Format(exportOpts.Format)
+	if format == "" {
+		format = bulk.FormatNDJSON
+	}
+	if !format.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFormat)
+	}
+
+	fields, err := models.ParseUserFields(exportOpts.Fields)
+	if err != nil {
+		logrus.Errorf("Error in users/http.ExportUsers -> error parsing fields: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields)
+	}
+
+	res := c.Response()
+	writer, err := bulk.NewWriter(res, format, fields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields)
+	}
+
+	res.Header().Set(echo.HeaderContentType, format.ContentType())
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=users.%s", format))
+
+	written := 0
+	err = h.repository.StreamUsers(c.Request().Context(), exportOpts.UserFilters, func(user models.User) error {
+		if !res.Committed {
+			res.WriteHeader(http.StatusOK)
+		}
+		if err := writer.Write(user); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushSize == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			res.Flush()
+		}
+		return nil
+	}, fields...)
+	if err != nil {
+		logrus.Errorf("Error in users/http.ExportUsers -> error exporting users: %s", err)
+		if !res.Committed {
+			return err
+		}
+		// the response has already been sent, so the error cannot be reported to the client
+		return nil
+	}
+
+	if !res.Committed {
+		res.WriteHeader(http.StatusOK)
+	}
+	return writer.Flush()
+}
+
 // GetUserByID godoc
 //
 // @Summary     Gets a user
 // @Description Gets a user by its id from the DB and returns it
 // @Tags        Users
 // @Produce     json
-// @Param       userId path     string true "User id" example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4) format(uuid)
-// @Success     200    {object} models.User
-// @Failure     400    {object} echo.HTTPError
-// @Failure     404    {object} echo.HTTPError
-// @Failure     500    {object} echo.HTTPError
+// @Param       userId        path     string true  "User id"                                    example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4) format(uuid)
+// @Param       fields        query    string false "Comma separated list of fields to retrieve" example(id,nickname,country)
+// @Param       If-None-Match header   string false "ETag of the cached user version"
+// @Success     200           {object} models.User
+// @Header      200           {string} ETag "User version"
+// @Success     304
+// @Failure     400 {object} echo.HTTPError
+// @Failure     401 {object} echo.HTTPError
+// @Failure     403 {object} echo.HTTPError
+// @Failure     404 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
 // @Router      /users/{userId} [get]
 func (h httpHandler) GetUserByID(c echo.Context) error {
 	userIDstr := c.Param("userId")
@@ -138,84 +370,436 @@ func (h httpHandler) GetUserByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDstr))
 	}
 
-	user, err := h.repository.GetById(context.TODO(), userID.String())
+	if err := auth.CheckRequest(c, auth.ActionRead, userID.String()); err != nil {
+		return err
+	}
+
+	fields, err := models.ParseUserFields(c.QueryParam("fields"))
 	if err != nil {
-		if err == mongo.ErrNilDocument {
+		logrus.Errorf("Error in users/http.GetUserByID -> error parsing fields: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields)
+	}
+
+	user, err := h.repository.GetById(c.Request().Context(), userID.String(), fields...)
+	if err != nil {
+		if isNotFound(err) {
 			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
 		}
 		logrus.Errorf("Testing: %s", err)
 		return err
 	}
 
+	c.Response().Header().Set(headerETag, user.ETag())
+	if etagMatches(c.Request().Header.Get(headerIfNoneMatch), user.ETag(), true) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	if len(fields) > 0 {
+		selected, err := user.Select(fields)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, selected)
+	}
+
 	return c.JSON(http.StatusOK, user)
 }
 
+// BatchGetUsers godoc
+//
+// @Summary     Gets several users
+// @Description Gets the users with the given ids from the DB, keeping the request order. The ids that do not exist are returned in notFound
+// @Tags        Users
+// @Accept      json
+// @Produce     json
+// @Param       body   body     object{ids=[]string} true  "User ids to retrieve"
+// @Param       fields query    string               false "Comma separated list of fields to retrieve" example(id,nickname,country)
+// @Success     200    {object} models.BatchUsers
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/batch-get [post]
+func (h httpHandler) BatchGetUsers(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionList, ""); err != nil {
+		return err
+	}
+
+	var body struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in users/http.BatchGetUsers -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if len(body.IDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+	if len(body.IDs) > MaxBatchSize {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrBatchTooLarge)
+	}
+
+	fields, err := models.ParseUserFields(c.QueryParam("fields"))
+	if err != nil {
+		logrus.Errorf("Error in users/http.BatchGetUsers -> error parsing fields: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields)
+	}
+
+	// parse and deduplicate the ids keeping the request order
+	ids := make([]string, 0, len(body.IDs))
+	seen := map[string]bool{}
+	for _, idStr := range body.IDs {
+		userID, err := uuid.Parse(idStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", idStr))
+		}
+		if !seen[userID.String()] {
+			seen[userID.String()] = true
+			ids = append(ids, userID.String())
+		}
+	}
+
+	found, err := h.repository.GetByIDs(c.Request().Context(), ids, fields...)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]models.User, len(found))
+	for _, user := range found {
+		byID[user.ID] = user
+	}
+	res := models.BatchUsers{
+		Users:    make([]models.User, 0, len(found)),
+		NotFound: []string{},
+	}
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			res.Users = append(res.Users, user)
+		} else {
+			res.NotFound = append(res.NotFound, id)
+		}
+	}
+
+	if len(fields) > 0 {
+		sparse, err := res.Select(fields)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, sparse)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
 // UpdateUserByID godoc
 //
 // @Summary     Updates a user
-// @Description Updates a user by its id with the given body data
+// @Description Updates a user by its id with the given body data. Deprecated: use PUT or PATCH instead
 // @Tags        Users
 // @Produce     json
 // @Accept      json
-// @Param       userId path     string      true "User id" example(7f598128-fb35-4ced-b80f-c5b5f66bd583) format(uuid)
-// @Param       body   body     models.User true "Request body"
-// @Success     200    {object} models.User
-// @Failure     400    {object} echo.HTTPError
-// @Failure     404    {object} echo.HTTPError
-// @Failure     500    {object} echo.HTTPError
+// @Param       userId          path     string             true  "User id" example(7f598128-fb35-4ced-b80f-c5b5f66bd583) format(uuid)
+// @Param       If-Match        header   string             false "ETag of the user version to update"
+// @Param       body            body     models.UserRequest true  "Request body"
+// @Param       Idempotency-Key header   string             false "Key to safely retry the request"
+// @Success     200             {object} models.User
+// @Header      200             {string} ETag "Updated user version"
+// @Failure     400             {object} echo.HTTPError
+// @Failure     401             {object} echo.HTTPError
+// @Failure     403             {object} echo.HTTPError
+// @Failure     404             {object} echo.HTTPError
+// @Failure     409             {object} echo.HTTPError
+// @Failure     412             {object} echo.HTTPError
+// @Failure     500             {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
 // @Router      /users/{userId} [post]
+// @Deprecated
 func (h httpHandler) UpdateUserByID(c echo.Context) error {
+	c.Response().Header().Set("Deprecation", "true")
+	c.Response().Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", c.Request().URL.Path))
+
 	userIDStr := c.Param("userId")
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
 	}
 
-	var body models.User
+	if err := auth.CheckRequest(c, auth.ActionUpdate, userID.String()); err != nil {
+		return err
+	}
+
+	var body models.UserRequest
 	if err := c.Bind(&body); err != nil {
 		logrus.Errorf("Error in users/http.UpdateUserByID -> error binding body: %s", err)
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	ctx := context.TODO()
+	ctx := audit.Detach(c.Request().Context())
 	userToModify, err := h.repository.GetById(ctx, userID.String())
 	if err != nil {
-		if err == mongo.ErrNilDocument {
+		if isNotFound(err) {
 			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
 		}
 		return err
 	}
+	if err := checkIfMatch(c, *userToModify); err != nil {
+		return err
+	}
 
-	userToModify.Modify(body)
-	if !userToModify.Valid() {
+	current := *userToModify
+	userToModify.Modify(body.ToUser())
+	if len(userToModify.MissingUpdateFields()) > 0 {
 		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
 	}
 
-	res, err := h.repository.Update(ctx, *userToModify)
+	return h.update(c, ctx, current, *userToModify)
+}
+
+// ReplaceUserByID godoc
+//
+// @Summary     Replaces a user
+// @Description Replaces all the user fields with the given body data. All the required fields must be present and id, createdAt and updatedAt cannot be modified
+// @Tags        Users
+// @Produce     json
+// @Accept      json
+// @Param       userId          path     string             true  "User id" example(7f598128-fb35-4ced-b80f-c5b5f66bd583) format(uuid)
+// @Param       If-Match        header   string             false "ETag of the user version to update"
+// @Param       body            body     models.UserRequest true  "Request body"
+// @Param       Idempotency-Key header   string             false "Key to safely retry the request"
+// @Success     200             {object} models.User
+// @Header      200             {string} ETag "Updated user version"
+// @Failure     400             {object} echo.HTTPError
+// @Failure     401             {object} echo.HTTPError
+// @Failure     403             {object} echo.HTTPError
+// @Failure     404             {object} echo.HTTPError
+// @Failure     409             {object} echo.HTTPError
+// @Failure     412             {object} echo.HTTPError
+// @Failure     500             {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId} [put]
+func (h httpHandler) ReplaceUserByID(c echo.Context) error {
+	userIDStr := c.Param("userId")
+	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionUpdate, userID.String()); err != nil {
+		return err
+	}
+
+	var req models.UserRequest
+	if err := c.Bind(&req); err != nil {
+		logrus.Errorf("Error in users/http.ReplaceUserByID -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	ctx := audit.Detach(c.Request().Context())
+	current, err := h.repository.GetById(ctx, userID.String())
+	if err != nil {
+		if isNotFound(err) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		}
+		return err
+	}
+	if err := checkIfMatch(c, *current); err != nil {
+		return err
+	}
+
+	body := req.ToUser()
+	// the immutable fields can be omitted, but if present they must not change
+	if body.ID == "" {
+		body.ID = current.ID
+	}
+	if body.CreatedAt.IsZero() {
+		body.CreatedAt = current.CreatedAt
+	}
+	if body.UpdatedAt.IsZero() {
+		body.UpdatedAt = current.UpdatedAt
+	}
+	// the version is the expected one when updating, if omitted the current one is expected
+	if body.Version == 0 {
+		body.Version = current.Version
+	}
+
+	return h.replace(c, ctx, *current, body)
+}
+
+// PatchUserByID godoc
+//
+// @Summary     Patches a user
+// @Description Partially updates a user using a JSON Merge Patch (RFC 7396, application/merge-patch+json) or a JSON Patch (RFC 6902, application/json-patch+json). id, createdAt and updatedAt cannot be modified
+// @Tags        Users
+// @Produce     json
+// @Accept      application/merge-patch+json,application/json-patch+json
+// @Param       userId          path     string true  "User id" example(7f598128-fb35-4ced-b80f-c5b5f66bd583) format(uuid)
+// @Param       If-Match        header   string false "ETag of the user version to update"
+// @Param       body            body     object true  "Patch document"
+// @Param       Idempotency-Key header   string false "Key to safely retry the request"
+// @Success     200             {object} models.User
+// @Header      200             {string} ETag "Updated user version"
+// @Failure     400             {object} echo.HTTPError
+// @Failure     401             {object} echo.HTTPError
+// @Failure     403             {object} echo.HTTPError
+// @Failure     404             {object} echo.HTTPError
+// @Failure     409             {object} echo.HTTPError
+// @Failure     412             {object} echo.HTTPError
+// @Failure     415             {object} echo.HTTPError
+// @Failure     422             {object} echo.HTTPError
+// @Failure     500             {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId} [patch]
+func (h httpHandler) PatchUserByID(c echo.Context) error {
+	userIDStr := c.Param("userId")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionUpdate, userID.String()); err != nil {
+		return err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || (mediaType != ContentTypeMergePatch && mediaType != ContentTypeJSONPatch) {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, httpErrors.ErrUnsupportedMediaType)
+	}
+
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		logrus.Errorf("Error in users/http.PatchUserByID -> error reading body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	ctx := audit.Detach(c.Request().Context())
+	current, err := h.repository.GetById(ctx, userID.String())
+	if err != nil {
+		if isNotFound(err) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		}
+		return err
+	}
+	if err := checkIfMatch(c, *current); err != nil {
+		return err
+	}
+
+	// the password is never returned, it's patched from empty to set a new one
+	original, err := json.Marshal(models.UserRequest{User: *current})
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	if mediaType == ContentTypeMergePatch {
+		patched, err = jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			logrus.Errorf("Error in users/http.PatchUserByID -> error applying merge patch: %s", err)
+			return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidPatch)
+		}
+	} else {
+		jsonPatch, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			logrus.Errorf("Error in users/http.PatchUserByID -> error decoding json patch: %s", err)
+			return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidPatch)
+		}
+		patched, err = jsonPatch.Apply(original)
+		if err != nil {
+			logrus.Errorf("Error in users/http.PatchUserByID -> error applying json patch: %s", err)
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, httpErrors.ErrInvalidPatch)
+		}
+	}
+
+	var modified models.UserRequest
+	if err := json.Unmarshal(patched, &modified); err != nil {
+		logrus.Errorf("Error in users/http.PatchUserByID -> error decoding patched user: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	return h.replace(c, ctx, *current, modified.ToUser())
+}
+
+// replace - checks that the modified user is valid and does not change the immutable fields before updating it.
+// The current password is kept if the modified user has none
+func (h httpHandler) replace(c echo.Context, ctx context.Context, current, modified models.User) error {
+	if changed := current.ChangedImmutableFields(modified); len(changed) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s: %s", httpErrors.ErrImmutableFields, strings.Join(changed, ",")))
+	}
+	if modified.Password == "" {
+		modified.Password = current.Password
+	}
+	if missing := modified.MissingUpdateFields(); len(missing) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s: %s", httpErrors.ErrMissingFields, strings.Join(missing, ",")))
+	}
+
+	return h.update(c, ctx, current, modified)
+}
+
+// update - checks the caller can change the modified fields, updates the user in the repository,
+// notifies the update and writes the response. A changed email is kept pending until it's verified
+// with the token sent to it, and the status is only changed by the moderation
+func (h httpHandler) update(c echo.Context, ctx context.Context, current, user models.User) error {
+	changes := models.DiffUsers(&current, user)
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	if err := auth.CheckRequest(c, auth.ActionUpdate, user.ID, fields...); err != nil {
+		return err
+	}
+	user.KeepEmailVerification(current)
+	user.KeepStatus(current)
+
+	res, err := h.repository.Update(ctx, user)
+	if err != nil {
+		if isNotFound(err) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", user.ID))
+		}
+		if errors.Is(err, users.ErrVersionConflict) {
+			logrus.Infof("Info in users/http.update -> %s", err)
+			if c.Request().Header.Get(headerIfMatch) != "" {
+				return echo.NewHTTPError(http.StatusPreconditionFailed, httpErrors.ErrPreconditionFailed)
+			}
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrVersionConflict)
+		}
 		return err
 	}
 
 	// Notify user update
 	go func() {
 		if err := h.pubsubRepository.NotifyUserUpdate(ctx, *res); err != nil {
-			logrus.Errorf("Error in users/http.UpdateUserByID -> could not notify user update -> %s", err)
+			logrus.Errorf("Error in users/http.update -> could not notify user update -> %s", err)
 		}
 	}()
+	if res.PendingEmail != current.PendingEmail {
+		h.sendVerification(ctx, *res)
+	}
 
+	c.Response().Header().Set(headerETag, res.ETag())
 	return c.JSON(http.StatusOK, res)
 }
 
 // DeleteUserByID godoc
 //
 // @Summary     Deletes a user
-// @Description Deletes a user by its id from the DB
+// @Description Soft deletes a user by its id. The user can be restored until it's purged after the retention period
 // @Tags        Users
 // @Accept      json
-// @Param       userId path string true "User id" format(uuid) example(5cace01f-45c3-49f0-a725-c22866874095)
+// @Param       userId          path   string true  "User id" format(uuid) example(5cace01f-45c3-49f0-a725-c22866874095)
+// @Param       Idempotency-Key header string false "Key to safely retry the request"
 // @Success     204
 // @Failure     400 {object} echo.HTTPError
+// @Failure     401 {object} echo.HTTPError
+// @Failure     403 {object} echo.HTTPError
+// @Failure     404 {object} echo.HTTPError
 // @Failure     500 {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
 // @Router      /users/{userId} [delete]
 func (h httpHandler) DeleteUserByID(c echo.Context) error {
 	idStr := c.Param("userId")
@@ -224,8 +808,15 @@ func (h httpHandler) DeleteUserByID(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", idStr))
 	}
 
-	ctx := context.TODO()
+	if err := auth.CheckRequest(c, auth.ActionDelete, userID.String()); err != nil {
+		return err
+	}
+
+	ctx := audit.Detach(c.Request().Context())
 	if err := h.repository.DeleteById(ctx, userID.String()); err != nil {
+		if isNotFound(err) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		}
 		return err
 	}
 
@@ -238,3 +829,147 @@ func (h httpHandler) DeleteUserByID(c echo.Context) error {
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// RestoreUserByID godoc
+//
+// @Summary     Restores a user
+// @Description Restores a soft deleted user by its id, if it has not been purged yet
+// @Tags        Users
+// @Produce     json
+// @Param       userId          path     string true  "User id" format(uuid) example(5cace01f-45c3-49f0-a725-c22866874095)
+// @Param       Idempotency-Key header   string false "Key to safely retry the request"
+// @Success     200             {object} models.User
+// @Failure     400             {object} echo.HTTPError
+// @Failure     401             {object} echo.HTTPError
+// @Failure     403             {object} echo.HTTPError
+// @Failure     404             {object} echo.HTTPError
+// @Failure     409             {object} echo.HTTPError
+// @Failure     500             {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/restore [post]
+func (h httpHandler) RestoreUserByID(c echo.Context) error {
+	idStr := c.Param("userId")
+	userID, err := uuid.Parse(idStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", idStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionRestore, userID.String()); err != nil {
+		return err
+	}
+
+	ctx := audit.Detach(c.Request().Context())
+	res, err := h.repository.Restore(ctx, userID.String())
+	if err != nil {
+		if isNotFound(err) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Deleted user not found for ID %s", userID))
+		}
+		if errors.Is(err, users.ErrDuplicateEmail) {
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrDuplicateEmail)
+		}
+		return err
+	}
+
+	// Notify user restoration
+	go func() {
+		if err := h.pubsubRepository.NotifyUserRestore(ctx, *res); err != nil {
+			logrus.Errorf("Error in users/http.RestoreUserByID -> could not notify user restoration: %s", err)
+		}
+	}()
+
+	c.Response().Header().Set(headerETag, res.ETag())
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetUserHistory godoc
+//
+// @Summary     Gets the user history
+// @Description Gets the paginated audit entries of the changes made to a user, the newest first. The password values are masked
+// @Tags        Users
+// @Produce     json
+// @Param       userId path     string true  "User id"          format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       page   query    int    false "Page to retrieve" default(1)   minimum(1) example(2)
+// @Param       size   query    int    false "Page size"        default(10)  minimum(1) example(3)
+// @Success     200    {object} models.PaginatedAuditEntries
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/history [get]
+func (h httpHandler) GetUserHistory(c echo.Context) error {
+	idStr := c.Param("userId")
+	userID, err := uuid.Parse(idStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", idStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionReadHistory, userID.String()); err != nil {
+		return err
+	}
+
+	var pagOpts pagination.PaginationOptions
+	if err := c.Bind(&pagOpts); err != nil {
+		logrus.Errorf("Error in users/http.GetUserHistory -> error binding params: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+	}
+
+	res, err := h.repository.GetUserHistory(c.Request().Context(), userID.String(), pagOpts)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// sendVerification - sends the email verification token to the user in background, if the verification is enabled
+func (h httpHandler) sendVerification(ctx context.Context, user models.User) {
+	if h.verifier == nil {
+		return
+	}
+	go func() {
+		if err := h.verifier.Send(ctx, user); err != nil {
+			logrus.Errorf("Error in users/http.sendVerification -> could not send verification to user %s: %s", user.ID, err)
+		}
+	}()
+}
+
+// isNotFound - returns true if the repository error means the user does not exist
+func isNotFound(err error) bool {
+	return err == mongo.ErrNoDocuments || err == mongo.ErrNilDocument
+}
+
+// checkIfMatch - returns a precondition failed error if the request has an If-Match header
+// that does not match the current user version
+func checkIfMatch(c echo.Context, current models.User) error {
+	ifMatch := c.Request().Header.Get(headerIfMatch)
+	if ifMatch != "" && !etagMatches(ifMatch, current.ETag(), false) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, httpErrors.ErrPreconditionFailed)
+	}
+
+	return nil
+}
+
+// etagMatches - returns true if the If-Match/If-None-Match header value contains the etag or is "*".
+// Weak etags (W/"...") only match when using the weak comparison (If-None-Match)
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -8,17 +8,23 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
 	httpErrors "user-microservice/internal/errors/http"
 	"user-microservice/internal/models"
 	"user-microservice/internal/pagination"
 	"user-microservice/internal/testutils"
+	"user-microservice/internal/users"
+	"user-microservice/internal/users/bulk"
 	userHttp "user-microservice/internal/users/http"
 	"user-microservice/internal/users/mock"
 	usersPubSub "user-microservice/internal/users/pubsub"
+	verificationMock "user-microservice/internal/verification/mock"
 
 	"github.com/go-redis/redismock/v8"
 	"github.com/golang/mock/gomock"
@@ -105,6 +111,16 @@ func TestCreateUser(t *testing.T) {
 			false,
 			false,
 		},
+		{
+			"Create user with duplicate email",
+			validBody,
+			nil,
+			http.StatusConflict,
+			fmt.Errorf("%w: email_unique dup key", users.ErrDuplicateEmail),
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrDuplicateEmail),
+			true,
+			false,
+		},
 		{
 			"Create user with internal server error",
 			validBody,
@@ -127,24 +143,27 @@ func TestCreateUser(t *testing.T) {
 			redisDB, redisMock := redismock.NewClientMock()
 			mockUserRepo := mock.NewMockRepository(ctrl)
 			pubsubRepo := usersPubSub.NewPubSub(redisDB)
-			userHandler := userHttp.NewHttpHandler(mockUserRepo, pubsubRepo)
+			userHandler := userHttp.NewHttpHandler(mockUserRepo, pubsubRepo, nil)
 
+			auditInfo := audit.Info{Actor: "admin@example.com", RequestID: "create-request-id"}
 			req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(tc.body))
 			req.Header.Set(echo.HeaderContentType, "application/json")
+			req = req.WithContext(audit.NewContext(req.Context(), auditInfo))
 			rec := httptest.NewRecorder()
 			e := echo.New()
 			echoCtx := e.NewContext(req, rec)
-			ctx := context.TODO()
+			// the repository receives the audit info of the request
+			ctx := audit.NewContext(context.Background(), auditInfo)
 
 			callTimes := 0
 			if tc.shouldExecCall {
 				callTimes = 1
 			}
-			mockUserRepo.EXPECT().Create(ctx, gomock.Any()).Return(tc.mockedUser, tc.expectedError).Times(callTimes)
+			mockUserRepo.EXPECT().Create(ctx, gomock.Any()).Return(tc.mockedUser, tc.mockedError).Times(callTimes)
 			if tc.shouldExecPublish {
 				encodedUser, err := json.Marshal(*tc.mockedUser)
 				require.NoErrorf(t, err, "Expected no error when marshaling mocked user for publish, but was %s", err)
-				redisMock.ExpectPublish(usersPubSub.TopicUserCreation, encodedUser)
+				redisMock.CustomMatch(matchPublish).ExpectPublish(usersPubSub.TopicUserCreation, encodedUser)
 			}
 
 			// When
@@ -204,6 +223,16 @@ func TestDeleteUser(t *testing.T) {
 			true,
 			false,
 		},
+		{
+			"Delete user not found",
+			userUUID.String(),
+			userUUID.String(),
+			mongo.ErrNoDocuments,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userUUID)),
+			http.StatusNotFound,
+			true,
+			false,
+		},
 		{
 			"Delete user with wrong id",
 			"invalid-user-id",
@@ -226,7 +255,7 @@ func TestDeleteUser(t *testing.T) {
 			redisDB, redisMock := redismock.NewClientMock()
 			pubsubRepo := usersPubSub.NewPubSub(redisDB)
 			mockUserRepo := mock.NewMockRepository(ctrl)
-			userHandler := userHttp.NewHttpHandler(mockUserRepo, pubsubRepo)
+			userHandler := userHttp.NewHttpHandler(mockUserRepo, pubsubRepo, nil)
 
 			req := httptest.NewRequest(http.MethodDelete, "/", nil)
 			req.Header.Set(echo.HeaderContentType, "application/json")
@@ -236,14 +265,14 @@ func TestDeleteUser(t *testing.T) {
 			c.SetPath("/api/v1/users/:userId")
 			c.SetParamNames("userId")
 			c.SetParamValues(tc.id)
-			ctx := context.TODO()
+			ctx := context.Background()
 			callTimes := 0
 			if tc.shouldCallRepo {
 				callTimes = 1
 			}
 			mockUserRepo.EXPECT().DeleteById(ctx, tc.mockedId).Return(tc.mockedError).Times(callTimes)
 			if tc.shouldExecPublish {
-				redisMock.ExpectPublish(usersPubSub.TopicUserDeletion, tc.mockedId)
+				redisMock.CustomMatch(matchPublish).ExpectPublish(usersPubSub.TopicUserDeletion, tc.mockedId)
 			}
 
 			//When
@@ -354,7 +383,7 @@ func TestGetUserByID(t *testing.T) {
 			userRepo := mock.NewMockRepository(ctrl)
 			redisDB, _ := redismock.NewClientMock()
 			pubsubRepo := usersPubSub.NewPubSub(redisDB)
-			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
 
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			rec := httptest.NewRecorder()
@@ -368,7 +397,7 @@ func TestGetUserByID(t *testing.T) {
 			if tc.shouldCallMock {
 				callTimes = 1
 			}
-			userRepo.EXPECT().GetById(context.TODO(), tc.mockedID).Return(tc.mockedUser, tc.mockedError).Times(callTimes)
+			userRepo.EXPECT().GetById(context.Background(), tc.mockedID).Return(tc.mockedUser, tc.mockedError).Times(callTimes)
 
 			//When
 			err := userHandler.GetUserByID(c)
@@ -390,6 +419,7 @@ func TestGetUserByID(t *testing.T) {
 			} else {
 				require.Nil(t, err)
 				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+				assert.NotContainsf(t, rec.Body.String(), "password", "Expected the password not to be returned, but was %s", rec.Body.String())
 
 				var body models.User
 				err := json.Unmarshal(rec.Body.Bytes(), &body)
@@ -404,7 +434,7 @@ func TestGetUserByID(t *testing.T) {
 						Option: testutils.DateCheckOptionEquals,
 						Value:  tc.mockedUser.UpdatedAt,
 					},
-					EqualPasswords: true,
+					EmptyPassword: true,
 				})
 			}
 		})
@@ -575,7 +605,7 @@ func TestUpdateUserByID(t *testing.T) {
 			userRepo := mock.NewMockRepository(ctrl)
 			redisDB, redisMock := redismock.NewClientMock()
 			pubsubRepo := usersPubSub.NewPubSub(redisDB)
-			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
 
 			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
 			req.Header.Set(echo.HeaderContentType, "application/json")
@@ -590,12 +620,12 @@ func TestUpdateUserByID(t *testing.T) {
 			if tc.shouldCallCreate {
 				callTimes = 1
 			}
-			userRepo.EXPECT().Update(context.TODO(), gomock.Any()).Return(&tc.mockedUser, tc.mockedError).Times(callTimes)
-			userRepo.EXPECT().GetById(context.TODO(), tc.mockedID).Return(&tc.mockedUser, tc.mockedGetError).AnyTimes()
+			userRepo.EXPECT().Update(context.Background(), gomock.Any()).Return(&tc.mockedUser, tc.mockedError).Times(callTimes)
+			userRepo.EXPECT().GetById(context.Background(), tc.mockedID).Return(&tc.mockedUser, tc.mockedGetError).AnyTimes()
 			if tc.shouldExecPublish {
 				encodedUser, err := json.Marshal(tc.mockedUser)
 				require.NoErrorf(t, err, "Expected no error when marshaling user to publish, but was %s", err)
-				redisMock.ExpectPublish(usersPubSub.TopicUserUpdate, encodedUser)
+				redisMock.CustomMatch(matchPublish).ExpectPublish(usersPubSub.TopicUserUpdate, encodedUser)
 			}
 
 			//when
@@ -613,7 +643,7 @@ func TestUpdateUserByID(t *testing.T) {
 				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
 
 				testutils.AssertUserBody(t, tc.mockedUser, body, testutils.AssertUserConfig{
-					EqualPasswords: true,
+					EmptyPassword: true,
 					UpdatedAt: &testutils.DateCheck{
 						Option: testutils.DateCheckOptionEquals,
 						Value:  tc.mockedUser.UpdatedAt,
@@ -703,6 +733,112 @@ func TestGetAllUsers(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"Get paginated users with status filter",
+			pagination.PaginationOptions{
+				Page: 1,
+				Size: 2,
+			},
+			models.PaginatedUsers{
+				Paginated: pagination.Paginated{
+					TotalCount:  1,
+					TotalPages:  1,
+					CurrentPage: 1,
+					Size:        2,
+				},
+				Users: []models.User{
+					{ID: uuid.New().String(), Status: models.StatusBanned},
+				},
+			},
+			map[string]string{
+				"status": "banned",
+			},
+			models.UserFilters{
+				Status: models.StatusBanned,
+			},
+			http.StatusOK,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Get paginated users with invalid status filter",
+			pagination.PaginationOptions{
+				Page: 1,
+				Size: 2,
+			},
+			models.PaginatedUsers{},
+			map[string]string{
+				"status": "homemade",
+			},
+			models.UserFilters{},
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidStatus),
+			nil,
+			false,
+		},
+		{
+			"Get paginated users sorted",
+			pagination.PaginationOptions{
+				Page:      1,
+				Size:      2,
+				OrderBy:   "createdAt",
+				SortOrder: pagination.SortOrderAsc,
+			},
+			models.PaginatedUsers{
+				Paginated: pagination.Paginated{
+					TotalCount:  1,
+					TotalPages:  1,
+					CurrentPage: 1,
+					Size:        2,
+				},
+				Users: []models.User{
+					{ID: uuid.New().String()},
+				},
+			},
+			map[string]string{
+				"orderBy": "createdAt",
+				"sort":    "asc",
+			},
+			models.UserFilters{},
+			http.StatusOK,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Get paginated users sorted by invalid field",
+			pagination.PaginationOptions{
+				Page: 1,
+				Size: 2,
+			},
+			models.PaginatedUsers{},
+			map[string]string{
+				"orderBy": "password",
+			},
+			models.UserFilters{},
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams),
+			nil,
+			false,
+		},
+		{
+			"Get paginated users with invalid sort order",
+			pagination.PaginationOptions{
+				Page: 1,
+				Size: 2,
+			},
+			models.PaginatedUsers{},
+			map[string]string{
+				"orderBy": "createdAt",
+				"sort":    "homemade",
+			},
+			models.UserFilters{},
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams),
+			nil,
+			false,
+		},
 		{
 			"Get paginated users with get error",
 			pagination.PaginationOptions{},
@@ -760,13 +896,13 @@ func TestGetAllUsers(t *testing.T) {
 			userRepo := mock.NewMockRepository(ctrl)
 			redisDB, _ := redismock.NewClientMock()
 			pubsubRepo := usersPubSub.NewPubSub(redisDB)
-			h := userHttp.NewHttpHandler(userRepo, pubsubRepo)
+			h := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
 
 			callTimes := 0
 			if tc.shouldCallRepo {
 				callTimes = 1
 			}
-			userRepo.EXPECT().GetPaginatedUsers(context.TODO(), tc.pagination, tc.mockedFilters).Return(tc.mockedRes, tc.mockedError).Times(callTimes)
+			userRepo.EXPECT().GetPaginatedUsers(context.Background(), tc.pagination, tc.mockedFilters).Return(tc.mockedRes, tc.mockedError).Times(callTimes)
 
 			//When
 			err := h.GetAllUsers(c)
@@ -786,3 +922,1809 @@ func TestGetAllUsers(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUserByIDWithFields(t *testing.T) {
+	userUUID := uuid.New()
+	now := time.Now().UTC().Add(-1 * time.Minute)
+	for _, tc := range []struct {
+		name           string
+		fields         string
+		mockedFields   []interface{}
+		mockedUser     *models.User
+		expectedKeys   []string
+		expectedError  error
+		shouldCallMock bool
+	}{
+		{
+			"Get user with selected fields",
+			"nickname,country",
+			[]interface{}{"nickname", "country"},
+			&models.User{
+				ID:       userUUID.String(),
+				Nickname: "Retrieved user Nickname",
+				Country:  "Retrieved user Country",
+			},
+			[]string{"id", "nickname", "country"},
+			nil,
+			true,
+		},
+		{
+			"Get user with repeated fields",
+			"id,nickname,id",
+			[]interface{}{"id", "nickname"},
+			&models.User{
+				ID:       userUUID.String(),
+				Nickname: "Retrieved user Nickname",
+			},
+			[]string{"id", "nickname"},
+			nil,
+			true,
+		},
+		{
+			"Get user with all fields when empty",
+			"",
+			[]interface{}{},
+			&models.User{
+				ID:        userUUID.String(),
+				FirstName: "Retrieved user FirstName",
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			[]string{"id", "firstName", "lastName", "nickname", "email", "country", "createdAt", "updatedAt", "version", "emailVerified", "status"},
+			nil,
+			true,
+		},
+		{
+			"Get user with not allowed field",
+			"nickname,password",
+			nil,
+			nil,
+			nil,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields),
+			false,
+		},
+		{
+			"Get user with unknown field",
+			"homemade",
+			nil,
+			nil,
+			nil,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			redisDB, _ := redismock.NewClientMock()
+			pubsubRepo := usersPubSub.NewPubSub(redisDB)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			q := make(url.Values)
+			q.Set("fields", tc.fields)
+			req := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(userUUID.String())
+
+			callTimes := 0
+			if tc.shouldCallMock {
+				callTimes = 1
+			}
+			userRepo.EXPECT().GetById(context.Background(), userUUID.String(), tc.mockedFields...).Return(tc.mockedUser, nil).Times(callTimes)
+
+			//When
+			err := userHandler.GetUserByID(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, http.StatusBadRequest, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var body map[string]interface{}
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+
+				assert.Lenf(t, body, len(tc.expectedKeys), "Expected body to have %d keys, but was %v", len(tc.expectedKeys), body)
+				for _, key := range tc.expectedKeys {
+					assert.Containsf(t, body, key, "Expected body to contain %s", key)
+				}
+				assert.Equalf(t, userUUID.String(), body["id"], "Expected id to be %s, but was %s", userUUID.String(), body["id"])
+			}
+		})
+	}
+}
+
+func TestGetAllUsersWithFields(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		fields         string
+		mockedFields   []interface{}
+		mockedRes      models.PaginatedUsers
+		expectedKeys   []string
+		expectedError  error
+		shouldCallRepo bool
+	}{
+		{
+			"Get paginated users with selected fields",
+			"nickname,country",
+			[]interface{}{"nickname", "country"},
+			models.PaginatedUsers{
+				Paginated: pagination.Paginated{
+					TotalCount:  2,
+					TotalPages:  1,
+					CurrentPage: 1,
+					Size:        2,
+				},
+				Users: []models.User{
+					{ID: uuid.New().String(), Nickname: "atingo", Country: "DE"},
+					{ID: uuid.New().String(), Nickname: "btingo", Country: "ES"},
+				},
+			},
+			[]string{"id", "nickname", "country"},
+			nil,
+			true,
+		},
+		{
+			"Get paginated users with invalid fields",
+			"nickname,homemade",
+			nil,
+			models.PaginatedUsers{},
+			nil,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			pagOpts := pagination.PaginationOptions{Page: 1, Size: 2}
+			q := make(url.Values)
+			q.Set("page", strconv.Itoa(pagOpts.Page))
+			q.Set("size", strconv.Itoa(pagOpts.Size))
+			q.Set("fields", tc.fields)
+			req := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			redisDB, _ := redismock.NewClientMock()
+			pubsubRepo := usersPubSub.NewPubSub(redisDB)
+			h := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			callTimes := 0
+			if tc.shouldCallRepo {
+				callTimes = 1
+			}
+			userRepo.EXPECT().GetPaginatedUsers(context.Background(), pagOpts, models.UserFilters{}, tc.mockedFields...).Return(tc.mockedRes, nil).Times(callTimes)
+
+			//When
+			err := h.GetAllUsers(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, http.StatusBadRequest, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoErrorf(t, err, "Expected no error but was %s", err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var body models.SparsePaginatedUsers
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				assert.Equalf(t, tc.mockedRes.Paginated, body.Paginated, "Expected pagination to be %v, but was %v", tc.mockedRes.Paginated, body.Paginated)
+				require.Lenf(t, body.Users, len(tc.mockedRes.Users), "Expected %d users, but was %d", len(tc.mockedRes.Users), len(body.Users))
+				for i, user := range body.Users {
+					assert.Lenf(t, user, len(tc.expectedKeys), "Expected user to have %d keys, but was %v", len(tc.expectedKeys), user)
+					assert.Equalf(t, tc.mockedRes.Users[i].ID, user["id"], "Expected id to be %s, but was %s", tc.mockedRes.Users[i].ID, user["id"])
+				}
+			}
+		})
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	firstID := uuid.New()
+	secondID := uuid.New()
+	notFoundID := uuid.New()
+	tooManyIDs := make([]string, 0, userHttp.MaxBatchSize+1)
+	for i := 0; i <= userHttp.MaxBatchSize; i++ {
+		tooManyIDs = append(tooManyIDs, fmt.Sprintf("%q", uuid.New().String()))
+	}
+	for _, tc := range []struct {
+		name           string
+		body           string
+		mockedIDs      []string
+		mockedUsers    []models.User
+		mockedError    error
+		expectedCode   int
+		expectedError  error
+		expectedRes    models.BatchUsers
+		shouldCallRepo bool
+	}{
+		{
+			"Batch get users keeping the request order",
+			fmt.Sprintf(`{"ids": [%q, %q, %q]}`, secondID, notFoundID, firstID),
+			[]string{secondID.String(), notFoundID.String(), firstID.String()},
+			[]models.User{
+				{ID: firstID.String(), Nickname: "First nickname"},
+				{ID: secondID.String(), Nickname: "Second nickname"},
+			},
+			nil,
+			http.StatusOK,
+			nil,
+			models.BatchUsers{
+				Users: []models.User{
+					{ID: secondID.String(), Nickname: "Second nickname"},
+					{ID: firstID.String(), Nickname: "First nickname"},
+				},
+				NotFound: []string{notFoundID.String()},
+			},
+			true,
+		},
+		{
+			"Batch get users with duplicated and uppercase ids",
+			fmt.Sprintf(`{"ids": [%q, %q]}`, strings.ToUpper(firstID.String()), firstID),
+			[]string{firstID.String()},
+			[]models.User{
+				{ID: firstID.String(), Nickname: "First nickname"},
+			},
+			nil,
+			http.StatusOK,
+			nil,
+			models.BatchUsers{
+				Users: []models.User{
+					{ID: firstID.String(), Nickname: "First nickname"},
+				},
+				NotFound: []string{},
+			},
+			true,
+		},
+		{
+			"Batch get users with invalid id",
+			fmt.Sprintf(`{"ids": [%q, "invalid-id"]}`, firstID),
+			nil,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID invalid-id"),
+			models.BatchUsers{},
+			false,
+		},
+		{
+			"Batch get users with empty ids",
+			`{"ids": []}`,
+			nil,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+			models.BatchUsers{},
+			false,
+		},
+		{
+			"Batch get users exceeding the batch size",
+			fmt.Sprintf(`{"ids": [%s]}`, strings.Join(tooManyIDs, ",")),
+			nil,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrBatchTooLarge),
+			models.BatchUsers{},
+			false,
+		},
+		{
+			"Batch get users with invalid body",
+			`invalid body`,
+			nil,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, nil),
+			models.BatchUsers{},
+			false,
+		},
+		{
+			"Batch get users with internal server error",
+			fmt.Sprintf(`{"ids": [%q]}`, firstID),
+			[]string{firstID.String()},
+			nil,
+			errors.New("homemade error"),
+			http.StatusInternalServerError,
+			errors.New("homemade error"),
+			models.BatchUsers{},
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			redisDB, _ := redismock.NewClientMock()
+			pubsubRepo := usersPubSub.NewPubSub(redisDB)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+
+			callTimes := 0
+			if tc.shouldCallRepo {
+				callTimes = 1
+			}
+			userRepo.EXPECT().GetByIDs(context.Background(), tc.mockedIDs).Return(tc.mockedUsers, tc.mockedError).Times(callTimes)
+
+			//When
+			err := userHandler.BatchGetUsers(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var body models.BatchUsers
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				assert.Equalf(t, tc.expectedRes, body, "Expected body to be %v, but was %v", tc.expectedRes, body)
+			}
+		})
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	validCSV := `firstName,lastName,nickname,password,email,country
+Import FirstName,Import LastName,Import Nickname,Import Password,Import Email,DE
+`
+	for _, tc := range []struct {
+		name           string
+		body           string
+		contentType    string
+		query          string
+		expectedCode   int
+		expectedError  error
+		expectedReport bulk.ImportReport
+		shouldCallRepo bool
+	}{
+		{
+			"Import users with format from content type",
+			validCSV,
+			"text/csv; charset=utf-8",
+			"",
+			http.StatusOK,
+			nil,
+			bulk.ImportReport{Total: 1, Imported: 1, Errors: []bulk.RowError{}},
+			true,
+		},
+		{
+			"Import users with format from query param",
+			validCSV,
+			"application/octet-stream",
+			"format=csv",
+			http.StatusOK,
+			nil,
+			bulk.ImportReport{Total: 1, Imported: 1, Errors: []bulk.RowError{}},
+			true,
+		},
+		{
+			"Import users in dry run mode",
+			validCSV,
+			"text/csv",
+			"dryRun=true",
+			http.StatusOK,
+			nil,
+			bulk.ImportReport{DryRun: true, Total: 1, Imported: 1, Errors: []bulk.RowError{}},
+			false,
+		},
+		{
+			"Import users with unsupported format",
+			validCSV,
+			"application/json",
+			"",
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFormat),
+			bulk.ImportReport{},
+			false,
+		},
+		{
+			"Import users with invalid dry run",
+			validCSV,
+			"text/csv",
+			"dryRun=homemade",
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams),
+			bulk.ImportReport{},
+			false,
+		},
+		{
+			"Import users with invalid csv header",
+			"homemade,header\n",
+			"text/csv",
+			"",
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, nil),
+			bulk.ImportReport{},
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/?"+tc.query, strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+
+			callTimes := 0
+			if tc.shouldCallRepo {
+				callTimes = 1
+			}
+			userRepo.EXPECT().CreateMany(gomock.Any(), gomock.Len(1)).DoAndReturn(func(_ context.Context, users []models.User) ([]models.User, error) {
+				return users, nil
+			}).Times(callTimes)
+			pubsubRepo.EXPECT().NotifyUsersCreation(gomock.Any(), gomock.Len(1)).Return(nil).Times(callTimes)
+
+			//When
+			err := userHandler.ImportUsers(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var report bulk.ImportReport
+				err := json.Unmarshal(rec.Body.Bytes(), &report)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				assert.Equalf(t, tc.expectedReport, report, "Expected report to be %v, but was %v", tc.expectedReport, report)
+			}
+		})
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	exportUsers := []models.User{
+		{ID: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Nickname: "atingo", Country: "DE"},
+		{ID: "5cace01f-45c3-49f0-a725-c22866874095", Nickname: "btingo", Country: "DE"},
+	}
+	for _, tc := range []struct {
+		name                string
+		query               string
+		mockedFilters       models.UserFilters
+		mockedFields        []interface{}
+		mockedError         error
+		expectedContentType string
+		expectedBody        string
+		expectedError       error
+		shouldCallRepo      bool
+	}{
+		{
+			"Export users as ndjson by default",
+			"country=DE&fields=nickname",
+			models.UserFilters{Country: "DE"},
+			[]interface{}{"nickname"},
+			nil,
+			bulk.ContentTypeNDJSON,
+			`{"id":"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4","nickname":"atingo"}` + "\n" +
+				`{"id":"5cace01f-45c3-49f0-a725-c22866874095","nickname":"btingo"}` + "\n",
+			nil,
+			true,
+		},
+		{
+			"Export users as csv",
+			"format=csv&fields=id,country",
+			models.UserFilters{},
+			[]interface{}{"id", "country"},
+			nil,
+			bulk.ContentTypeCSV,
+			"id,country\nddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4,DE\n5cace01f-45c3-49f0-a725-c22866874095,DE\n",
+			nil,
+			true,
+		},
+		{
+			"Export users with invalid format",
+			"format=xml",
+			models.UserFilters{},
+			nil,
+			nil,
+			"",
+			"",
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFormat),
+			false,
+		},
+		{
+			"Export users with password field",
+			"fields=password",
+			models.UserFilters{},
+			nil,
+			nil,
+			"",
+			"",
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidFields),
+			false,
+		},
+		{
+			"Export users with database error",
+			"",
+			models.UserFilters{},
+			[]interface{}{},
+			errors.New("homemade error"),
+			"",
+			"",
+			errors.New("homemade error"),
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+
+			callTimes := 0
+			if tc.shouldCallRepo {
+				callTimes = 1
+			}
+			userRepo.EXPECT().StreamUsers(gomock.Any(), tc.mockedFilters, gomock.Any(), tc.mockedFields...).
+				DoAndReturn(func(_ context.Context, _ models.UserFilters, fn func(models.User) error, _ ...string) error {
+					if tc.mockedError != nil {
+						return tc.mockedError
+					}
+					for _, user := range exportUsers {
+						if err := fn(user); err != nil {
+							return err
+						}
+					}
+					return nil
+				}).Times(callTimes)
+
+			//When
+			err := userHandler.ExportUsers(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, http.StatusBadRequest, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+				assert.Equalf(t, tc.expectedContentType, rec.Header().Get(echo.HeaderContentType), "Expected content type to be %s, but was %s", tc.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+				assert.Equalf(t, tc.expectedBody, rec.Body.String(), "Expected body to be %s, but was %s", tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestReplaceUserByID(t *testing.T) {
+	userID := uuid.New()
+	createdAt := time.Date(2016, 5, 18, 16, 0, 0, 0, time.UTC)
+	current := models.User{
+		ID:        userID.String(),
+		FirstName: "Current FirstName",
+		LastName:  "Current LastName",
+		Nickname:  "Current Nickname",
+		Password:  "Current Password",
+		Email:     "Current Email",
+		Country:   "DE",
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	// the changed email is pending until it's verified
+	replaced := models.User{
+		ID:           userID.String(),
+		FirstName:    "Replaced FirstName",
+		LastName:     "Replaced LastName",
+		Nickname:     "Replaced Nickname",
+		Password:     "Replaced Password",
+		Email:        "Current Email",
+		PendingEmail: "Replaced Email",
+		Country:      "ES",
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+	}
+	validBody := `{
+		"firstName": "Replaced FirstName",
+		"lastName": "Replaced LastName",
+		"nickname": "Replaced Nickname",
+		"password": "Replaced Password",
+		"email": "Replaced Email",
+		"country": "ES"
+	}`
+	for _, tc := range []struct {
+		name             string
+		id               string
+		body             string
+		mockedGetError   error
+		expectedUpdate   *models.User
+		expectedCode     int
+		expectedError    error
+		shouldCallGet    bool
+		shouldCallUpdate bool
+	}{
+		{
+			"Replace user successfully",
+			userID.String(),
+			validBody,
+			nil,
+			&replaced,
+			http.StatusOK,
+			nil,
+			true,
+			true,
+		},
+		{
+			"Replace user with the same immutable fields",
+			userID.String(),
+			fmt.Sprintf(`{
+				"id": %q,
+				"firstName": "Replaced FirstName",
+				"lastName": "Replaced LastName",
+				"nickname": "Replaced Nickname",
+				"password": "Replaced Password",
+				"email": "Replaced Email",
+				"country": "ES",
+				"createdAt": "2016-05-18T16:00:00Z"
+			}`, userID),
+			nil,
+			&replaced,
+			http.StatusOK,
+			nil,
+			true,
+			true,
+		},
+		{
+			"Replace user with missing fields",
+			userID.String(),
+			`{"firstName": "Replaced FirstName", "nickname": "Replaced Nickname"}`,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrMissingFields+": country,email,lastName"),
+			true,
+			false,
+		},
+		{
+			"Replace user modifying immutable fields",
+			userID.String(),
+			fmt.Sprintf(`{
+				"id": %q,
+				"firstName": "Replaced FirstName",
+				"lastName": "Replaced LastName",
+				"nickname": "Replaced Nickname",
+				"password": "Replaced Password",
+				"email": "Replaced Email",
+				"country": "ES",
+				"createdAt": "2020-05-18T16:00:00Z"
+			}`, uuid.New()),
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrImmutableFields+": id,createdAt"),
+			true,
+			false,
+		},
+		{
+			"Replace user with wrong id",
+			"wrong-id",
+			validBody,
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID wrong-id"),
+			false,
+			false,
+		},
+		{
+			"Replace user not found",
+			userID.String(),
+			validBody,
+			mongo.ErrNoDocuments,
+			nil,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID)),
+			true,
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(tc.id)
+
+			getTimes, updateTimes := 0, 0
+			if tc.shouldCallGet {
+				getTimes = 1
+			}
+			if tc.shouldCallUpdate {
+				updateTimes = 1
+			}
+			currentUser := current
+			var mockedGetUser *models.User
+			if tc.mockedGetError == nil {
+				mockedGetUser = &currentUser
+			}
+			userRepo.EXPECT().GetById(context.Background(), userID.String()).Return(mockedGetUser, tc.mockedGetError).Times(getTimes)
+			if tc.expectedUpdate != nil {
+				userRepo.EXPECT().Update(context.Background(), *tc.expectedUpdate).Return(tc.expectedUpdate, nil).Times(updateTimes)
+				pubsubRepo.EXPECT().NotifyUserUpdate(gomock.Any(), *tc.expectedUpdate).Return(nil).MaxTimes(1)
+			}
+
+			//When
+			err := userHandler.ReplaceUserByID(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var body models.User
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				testutils.AssertUserBody(t, *tc.expectedUpdate, body, testutils.AssertUserConfig{EmptyPassword: true})
+			}
+		})
+	}
+}
+
+func TestPatchUserByID(t *testing.T) {
+	userID := uuid.New()
+	createdAt := time.Date(2016, 5, 18, 16, 0, 0, 0, time.UTC)
+	current := models.User{
+		ID:        userID.String(),
+		FirstName: "Current FirstName",
+		LastName:  "Current LastName",
+		Nickname:  "Current Nickname",
+		Password:  "Current Password",
+		Email:     "Current Email",
+		Country:   "DE",
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	withNickname := current
+	withNickname.Nickname = "Patched Nickname"
+	for _, tc := range []struct {
+		name             string
+		body             string
+		contentType      string
+		expectedUpdate   *models.User
+		expectedCode     int
+		expectedError    error
+		shouldCallGet    bool
+		shouldCallUpdate bool
+	}{
+		{
+			"Patch user with merge patch",
+			`{"nickname": "Patched Nickname"}`,
+			userHttp.ContentTypeMergePatch,
+			&withNickname,
+			http.StatusOK,
+			nil,
+			true,
+			true,
+		},
+		{
+			"Patch user with json patch",
+			`[{"op": "test", "path": "/nickname", "value": "Current Nickname"}, {"op": "replace", "path": "/nickname", "value": "Patched Nickname"}]`,
+			userHttp.ContentTypeJSONPatch,
+			&withNickname,
+			http.StatusOK,
+			nil,
+			true,
+			true,
+		},
+		{
+			"Patch user with failing json patch test",
+			`[{"op": "test", "path": "/nickname", "value": "Another Nickname"}, {"op": "replace", "path": "/nickname", "value": "Patched Nickname"}]`,
+			userHttp.ContentTypeJSONPatch,
+			nil,
+			http.StatusUnprocessableEntity,
+			echo.NewHTTPError(http.StatusUnprocessableEntity, httpErrors.ErrInvalidPatch),
+			true,
+			false,
+		},
+		{
+			"Patch user with invalid json patch",
+			`{"op": "replace"}`,
+			userHttp.ContentTypeJSONPatch,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidPatch),
+			true,
+			false,
+		},
+		{
+			"Patch user removing required field",
+			`{"email": null}`,
+			userHttp.ContentTypeMergePatch,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrMissingFields+": email"),
+			true,
+			false,
+		},
+		{
+			"Patch user modifying immutable fields",
+			`{"id": "homemade-id", "updatedAt": null}`,
+			userHttp.ContentTypeMergePatch,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrImmutableFields+": id,updatedAt"),
+			true,
+			false,
+		},
+		{
+			"Patch user setting deletedAt",
+			`{"deletedAt": "2020-05-18T16:00:00Z"}`,
+			userHttp.ContentTypeMergePatch,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrImmutableFields+": deletedAt"),
+			true,
+			false,
+		},
+		{
+			"Patch user with invalid field types",
+			`{"nickname": 42}`,
+			userHttp.ContentTypeMergePatch,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+			true,
+			false,
+		},
+		{
+			"Patch user with unsupported content type",
+			`{"nickname": "Patched Nickname"}`,
+			"application/json",
+			nil,
+			http.StatusUnsupportedMediaType,
+			echo.NewHTTPError(http.StatusUnsupportedMediaType, httpErrors.ErrUnsupportedMediaType),
+			false,
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(userID.String())
+
+			getTimes, updateTimes := 0, 0
+			if tc.shouldCallGet {
+				getTimes = 1
+			}
+			if tc.shouldCallUpdate {
+				updateTimes = 1
+			}
+			currentUser := current
+			userRepo.EXPECT().GetById(context.Background(), userID.String()).Return(&currentUser, nil).Times(getTimes)
+			if tc.expectedUpdate != nil {
+				userRepo.EXPECT().Update(context.Background(), *tc.expectedUpdate).Return(tc.expectedUpdate, nil).Times(updateTimes)
+				pubsubRepo.EXPECT().NotifyUserUpdate(gomock.Any(), *tc.expectedUpdate).Return(nil).MaxTimes(1)
+			}
+
+			//When
+			err := userHandler.PatchUserByID(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var body models.User
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				testutils.AssertUserBody(t, *tc.expectedUpdate, body, testutils.AssertUserConfig{EmptyPassword: true})
+			}
+		})
+	}
+}
+
+func TestGetUserByIDWithETag(t *testing.T) {
+	userID := uuid.New()
+	for _, tc := range []struct {
+		name         string
+		ifNoneMatch  string
+		expectedCode int
+	}{
+		{
+			"Get user without If-None-Match",
+			"",
+			http.StatusOK,
+		},
+		{
+			"Get user with matching If-None-Match",
+			`"3"`,
+			http.StatusNotModified,
+		},
+		{
+			"Get user with matching weak If-None-Match",
+			`"1", W/"3"`,
+			http.StatusNotModified,
+		},
+		{
+			"Get user with any If-None-Match",
+			"*",
+			http.StatusNotModified,
+		},
+		{
+			"Get user with outdated If-None-Match",
+			`"2"`,
+			http.StatusOK,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(userID.String())
+
+			userRepo.EXPECT().GetById(context.Background(), userID.String()).Return(&models.User{
+				ID:        userID.String(),
+				FirstName: "Retrieved FirstName",
+				Version:   3,
+			}, nil)
+
+			//When
+			err := userHandler.GetUserByID(c)
+
+			//Then
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+			assert.Equalf(t, `"3"`, rec.Header().Get("ETag"), "Expected ETag to be %q, but was %q", `"3"`, rec.Header().Get("ETag"))
+			if tc.expectedCode == http.StatusNotModified {
+				assert.Emptyf(t, rec.Body.String(), "Expected empty body, but was %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestReplaceUserByIDWithVersion(t *testing.T) {
+	userID := uuid.New()
+	createdAt := time.Date(2016, 5, 18, 16, 0, 0, 0, time.UTC)
+	current := models.User{
+		ID:        userID.String(),
+		FirstName: "Current FirstName",
+		LastName:  "Current LastName",
+		Nickname:  "Current Nickname",
+		Password:  "Current Password",
+		Email:     "Current Email",
+		Country:   "DE",
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Version:   3,
+	}
+	replaced := current
+	replaced.Nickname = "Replaced Nickname"
+	updated := replaced
+	updated.Version = 4
+	body := `{
+		"firstName": "Current FirstName",
+		"lastName": "Current LastName",
+		"nickname": "Replaced Nickname",
+		"password": "Current Password",
+		"email": "Current Email",
+		"country": "DE"
+	}`
+	for _, tc := range []struct {
+		name             string
+		body             string
+		ifMatch          string
+		expectedUpdate   models.User
+		mockedError      error
+		expectedCode     int
+		expectedError    error
+		shouldCallUpdate bool
+	}{
+		{
+			"Replace user with matching If-Match",
+			body,
+			`"3"`,
+			replaced,
+			nil,
+			http.StatusOK,
+			nil,
+			true,
+		},
+		{
+			"Replace user with outdated If-Match",
+			body,
+			`"2"`,
+			replaced,
+			nil,
+			http.StatusPreconditionFailed,
+			echo.NewHTTPError(http.StatusPreconditionFailed, httpErrors.ErrPreconditionFailed),
+			false,
+		},
+		{
+			"Replace user with weak If-Match",
+			body,
+			`W/"3"`,
+			replaced,
+			nil,
+			http.StatusPreconditionFailed,
+			echo.NewHTTPError(http.StatusPreconditionFailed, httpErrors.ErrPreconditionFailed),
+			false,
+		},
+		{
+			"Replace user modified concurrently with If-Match",
+			body,
+			`"3"`,
+			replaced,
+			&users.VersionConflictError{ID: userID.String(), Expected: 3, Current: 4},
+			http.StatusPreconditionFailed,
+			echo.NewHTTPError(http.StatusPreconditionFailed, httpErrors.ErrPreconditionFailed),
+			true,
+		},
+		{
+			"Replace user modified concurrently without If-Match",
+			body,
+			"",
+			replaced,
+			&users.VersionConflictError{ID: userID.String(), Expected: 3, Current: 4},
+			http.StatusConflict,
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrVersionConflict),
+			true,
+		},
+		{
+			"Replace user with outdated version in body",
+			strings.Replace(body, `"country": "DE"`, `"country": "DE", "version": 2`, 1),
+			"",
+			func() models.User { u := replaced; u.Version = 2; return u }(),
+			&users.VersionConflictError{ID: userID.String(), Expected: 2, Current: 3},
+			http.StatusConflict,
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrVersionConflict),
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(userID.String())
+
+			updateTimes := 0
+			if tc.shouldCallUpdate {
+				updateTimes = 1
+			}
+			currentUser := current
+			userRepo.EXPECT().GetById(context.Background(), userID.String()).Return(&currentUser, nil)
+			var mockedUpdate *models.User
+			if tc.mockedError == nil {
+				mockedUpdate = &updated
+			}
+			userRepo.EXPECT().Update(context.Background(), tc.expectedUpdate).Return(mockedUpdate, tc.mockedError).Times(updateTimes)
+			pubsubRepo.EXPECT().NotifyUserUpdate(gomock.Any(), updated).Return(nil).MaxTimes(1)
+
+			//When
+			err := userHandler.ReplaceUserByID(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+				assert.Equalf(t, `"4"`, rec.Header().Get("ETag"), "Expected ETag to be %q, but was %q", `"4"`, rec.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestRestoreUserByID(t *testing.T) {
+	userUUID := uuid.New()
+	restored := models.User{
+		ID:        userUUID.String(),
+		FirstName: "Restored FirstName",
+		LastName:  "Restored LastName",
+		Nickname:  "Restored Nickname",
+		Password:  "Restored Password",
+		Email:     "Restored Email",
+		Country:   "DE",
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Version:   3,
+	}
+	for _, tc := range []struct {
+		name           string
+		id             string
+		mockedUser     *models.User
+		mockedError    error
+		expectedCode   int
+		expectedError  error
+		shouldCallRepo bool
+	}{
+		{
+			"Restore user successfully",
+			userUUID.String(),
+			&restored,
+			nil,
+			http.StatusOK,
+			nil,
+			true,
+		},
+		{
+			"Restore user not deleted",
+			userUUID.String(),
+			nil,
+			mongo.ErrNoDocuments,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Deleted user not found for ID %s", userUUID)),
+			true,
+		},
+		{
+			"Restore user with error",
+			userUUID.String(),
+			nil,
+			errors.New("homemade error"),
+			http.StatusInternalServerError,
+			errors.New("homemade error"),
+			true,
+		},
+		{
+			"Restore user with wrong id",
+			"invalid-user-id",
+			nil,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid ID invalid-user-id"),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId/restore")
+			c.SetParamNames("userId")
+			c.SetParamValues(tc.id)
+
+			callTimes := 0
+			if tc.shouldCallRepo {
+				callTimes = 1
+			}
+			userRepo.EXPECT().Restore(context.Background(), userUUID.String()).Return(tc.mockedUser, tc.mockedError).Times(callTimes)
+			if tc.mockedUser != nil {
+				pubsubRepo.EXPECT().NotifyUserRestore(gomock.Any(), *tc.mockedUser).Return(nil).MaxTimes(1)
+			}
+
+			//When
+			err := userHandler.RestoreUserByID(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+				assert.Equalf(t, tc.mockedUser.ETag(), rec.Header().Get("ETag"), "Expected ETag to be %s, but was %s", tc.mockedUser.ETag(), rec.Header().Get("ETag"))
+
+				var body models.User
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				testutils.AssertUserBody(t, *tc.mockedUser, body, testutils.AssertUserConfig{EmptyPassword: true})
+			}
+		})
+	}
+}
+
+func TestGetUserHistory(t *testing.T) {
+	userUUID := uuid.New()
+	for _, tc := range []struct {
+		name           string
+		id             string
+		queryParams    map[string]string
+		expectedPag    pagination.PaginationOptions
+		mockedEntries  models.PaginatedAuditEntries
+		mockedError    error
+		expectedCode   int
+		expectedError  error
+		shouldCallRepo bool
+	}{
+		{
+			"Get user history successfully",
+			userUUID.String(),
+			map[string]string{"page": "1", "size": "2"},
+			pagination.PaginationOptions{
+				Page: 1,
+				Size: 2,
+			},
+			models.PaginatedAuditEntries{
+				Paginated: pagination.Paginated{
+					TotalCount:  3,
+					TotalPages:  2,
+					CurrentPage: 1,
+					Size:        2,
+					HasMore:     true,
+				},
+				Entries: []models.AuditEntry{
+					{
+						ID:      uuid.New().String(),
+						UserID:  userUUID.String(),
+						Action:  models.AuditActionUpdate,
+						Actor:   "admin@example.com",
+						Changes: []models.FieldChange{{Field: "password", Old: models.MaskedValue, New: models.MaskedValue}},
+					},
+					{
+						ID:      uuid.New().String(),
+						UserID:  userUUID.String(),
+						Action:  models.AuditActionCreate,
+						Actor:   "anonymous",
+						Changes: []models.FieldChange{{Field: "firstName", Old: nil, New: "FirstName"}},
+					},
+				},
+			},
+			nil,
+			http.StatusOK,
+			nil,
+			true,
+		},
+		{
+			"Get user history with repository error",
+			userUUID.String(),
+			map[string]string{},
+			pagination.PaginationOptions{},
+			models.PaginatedAuditEntries{},
+			errors.New("homemade error"),
+			http.StatusInternalServerError,
+			errors.New("homemade error"),
+			true,
+		},
+		{
+			"Get user history with invalid pagination",
+			userUUID.String(),
+			map[string]string{"page": "first"},
+			pagination.PaginationOptions{},
+			models.PaginatedAuditEntries{},
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams),
+			false,
+		},
+		{
+			"Get user history with wrong id",
+			"invalid-user-id",
+			map[string]string{},
+			pagination.PaginationOptions{},
+			models.PaginatedAuditEntries{},
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid ID invalid-user-id"),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			q := make(url.Values)
+			for k, v := range tc.queryParams {
+				q.Set(k, v)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId/history")
+			c.SetParamNames("userId")
+			c.SetParamValues(tc.id)
+
+			callTimes := 0
+			if tc.shouldCallRepo {
+				callTimes = 1
+			}
+			userRepo.EXPECT().GetUserHistory(context.Background(), userUUID.String(), tc.expectedPag).Return(tc.mockedEntries, tc.mockedError).Times(callTimes)
+
+			//When
+			err := userHandler.GetUserHistory(c)
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, http.StatusOK, rec.Code, "Expected status code to be %d, but was %d", http.StatusOK, rec.Code)
+
+				var body models.PaginatedAuditEntries
+				err := json.Unmarshal(rec.Body.Bytes(), &body)
+				require.NoErrorf(t, err, "Expected no error when unmarshaling body, but was %s", err)
+				assert.Equalf(t, tc.mockedEntries, body, "Expected body to be %v, but was %v", tc.mockedEntries, body)
+			}
+		})
+	}
+}
+
+func TestUsersAuthorization(t *testing.T) {
+	userID := uuid.New()
+	current := models.User{
+		ID:        userID.String(),
+		FirstName: "Current FirstName",
+		LastName:  "Current LastName",
+		Nickname:  "Current Nickname",
+		Password:  "Current Password",
+		Email:     "Current Email",
+		Country:   "DE",
+		Version:   1,
+	}
+	withNickname := current
+	withNickname.Nickname = "Patched Nickname"
+	self := auth.Principal{Subject: userID.String(), Role: auth.RoleUser}
+	other := auth.Principal{Subject: uuid.New().String(), Role: auth.RoleUser}
+	support := auth.Principal{Subject: "support-id", Role: auth.RoleSupport}
+	admin := auth.Principal{Subject: "admin-id", Role: auth.RoleAdmin}
+	readKey := auth.Principal{Subject: "read-key", Scopes: []string{auth.ScopeUsersRead}}
+
+	for _, tc := range []struct {
+		name          string
+		principal     auth.Principal
+		method        string
+		body          string
+		getTimes      int
+		updateTimes   int
+		deleteTimes   int
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"User gets itself",
+			self,
+			http.MethodGet,
+			"",
+			1,
+			0,
+			0,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"User gets other user",
+			other,
+			http.MethodGet,
+			"",
+			0,
+			0,
+			0,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"User patches itself",
+			self,
+			http.MethodPatch,
+			`{"nickname": "Patched Nickname"}`,
+			1,
+			1,
+			0,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"User patches other user",
+			other,
+			http.MethodPatch,
+			`{"nickname": "Patched Nickname"}`,
+			0,
+			0,
+			0,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"User patches its password",
+			self,
+			http.MethodPatch,
+			`{"password": "Patched Password"}`,
+			1,
+			0,
+			0,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Support patches nickname",
+			support,
+			http.MethodPatch,
+			`{"nickname": "Patched Nickname"}`,
+			1,
+			1,
+			0,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"Support patches password",
+			support,
+			http.MethodPatch,
+			`{"password": "Patched Password"}`,
+			1,
+			0,
+			0,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"User deletes itself",
+			self,
+			http.MethodDelete,
+			"",
+			0,
+			0,
+			0,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Read key deletes user",
+			readKey,
+			http.MethodDelete,
+			"",
+			0,
+			0,
+			0,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Admin deletes user",
+			admin,
+			http.MethodDelete,
+			"",
+			0,
+			0,
+			1,
+			http.StatusNoContent,
+			nil,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, nil)
+
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, userHttp.ContentTypeMergePatch)
+			req = req.WithContext(auth.NewPrincipalContext(req.Context(), tc.principal))
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(userID.String())
+
+			currentUser := current
+			userRepo.EXPECT().GetById(gomock.Any(), userID.String()).Return(&currentUser, nil).Times(tc.getTimes)
+			userRepo.EXPECT().Update(gomock.Any(), withNickname).Return(&withNickname, nil).Times(tc.updateTimes)
+			userRepo.EXPECT().DeleteById(gomock.Any(), userID.String()).Return(nil).Times(tc.deleteTimes)
+			pubsubRepo.EXPECT().NotifyUserUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			pubsubRepo.EXPECT().NotifyUserDeletion(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			//When
+			var err error
+			switch tc.method {
+			case http.MethodGet:
+				err = userHandler.GetUserByID(c)
+			case http.MethodPatch:
+				err = userHandler.PatchUserByID(c)
+			case http.MethodDelete:
+				err = userHandler.DeleteUserByID(c)
+			}
+
+			//Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equalf(t, tc.expectedCode, rec.Code, "Expected status code to be %d, but was %d", tc.expectedCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestEmailVerificationOnUserChanges(t *testing.T) {
+	userID := uuid.New()
+	createdAt := time.Date(2016, 5, 18, 16, 0, 0, 0, time.UTC)
+	verifiedAt := time.Date(2016, 5, 19, 16, 0, 0, 0, time.UTC)
+	current := models.User{
+		ID:              userID.String(),
+		FirstName:       "Current FirstName",
+		LastName:        "Current LastName",
+		Nickname:        "Current Nickname",
+		Password:        "Current Password",
+		Email:           "current@example.com",
+		Country:         "DE",
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+		EmailVerified:   true,
+		EmailVerifiedAt: &verifiedAt,
+		Status:          models.StatusActive,
+	}
+	withEmail := func(email, pendingEmail string) models.User {
+		user := current
+		user.Email = email
+		user.PendingEmail = pendingEmail
+		return user
+	}
+	for _, tc := range []struct {
+		name          string
+		method        string
+		body          string
+		expectedSaved models.User
+		shouldSend    bool
+	}{
+		{
+			"Create user resets the email verification",
+			http.MethodPost,
+			`{
+				"firstName": "Current FirstName",
+				"lastName": "Current LastName",
+				"nickname": "Current Nickname",
+				"password": "Current Password",
+				"email": "current@example.com",
+				"country": "DE",
+				"emailVerified": true,
+				"pendingEmail": "other@example.com",
+				"status": "banned"
+			}`,
+			models.User{
+				FirstName: "Current FirstName",
+				LastName:  "Current LastName",
+				Nickname:  "Current Nickname",
+				Password:  "Current Password",
+				Email:     "current@example.com",
+				Country:   "DE",
+				Status:    models.StatusActive,
+			},
+			true,
+		},
+		{
+			"Replace user keeps the changed email pending",
+			http.MethodPut,
+			`{
+				"firstName": "Current FirstName",
+				"lastName": "Current LastName",
+				"nickname": "Current Nickname",
+				"password": "Current Password",
+				"email": "new@example.com",
+				"country": "DE"
+			}`,
+			withEmail("current@example.com", "new@example.com"),
+			true,
+		},
+		{
+			"Replace user without changing the email keeps the verification",
+			http.MethodPut,
+			`{
+				"firstName": "Current FirstName",
+				"lastName": "Current LastName",
+				"nickname": "Current Nickname",
+				"password": "Current Password",
+				"email": "current@example.com",
+				"country": "DE",
+				"emailVerified": false
+			}`,
+			withEmail("current@example.com", ""),
+			false,
+		},
+		{
+			"Replace user without changing the status",
+			http.MethodPut,
+			`{
+				"firstName": "Current FirstName",
+				"lastName": "Current LastName",
+				"nickname": "Current Nickname",
+				"password": "Current Password",
+				"email": "current@example.com",
+				"country": "DE",
+				"status": "banned",
+				"suspendedUntil": "2016-05-25T16:00:00Z"
+			}`,
+			withEmail("current@example.com", ""),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			verifier := verificationMock.NewMockSender(ctrl)
+			userHandler := userHttp.NewHttpHandler(userRepo, pubsubRepo, verifier)
+
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId")
+			c.SetParamNames("userId")
+			c.SetParamValues(userID.String())
+
+			currentUser := current
+			userRepo.EXPECT().GetById(gomock.Any(), userID.String()).Return(&currentUser, nil).AnyTimes()
+			userRepo.EXPECT().Create(gomock.Any(), tc.expectedSaved).Return(&tc.expectedSaved, nil).AnyTimes()
+			userRepo.EXPECT().Update(gomock.Any(), tc.expectedSaved).Return(&tc.expectedSaved, nil).AnyTimes()
+			pubsubRepo.EXPECT().NotifyUserCreation(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			pubsubRepo.EXPECT().NotifyUserUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			sent := make(chan models.User, 1)
+			sendTimes := 0
+			if tc.shouldSend {
+				sendTimes = 1
+			}
+			verifier.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) error {
+				sent <- user
+				return nil
+			}).Times(sendTimes)
+
+			// When
+			var err error
+			if tc.method == http.MethodPost {
+				err = userHandler.CreateUser(c)
+			} else {
+				err = userHandler.ReplaceUserByID(c)
+			}
+
+			// Then
+			require.NoError(t, err)
+			if tc.shouldSend {
+				select {
+				case user := <-sent:
+					assert.Equalf(t, tc.expectedSaved, user, "Expected the verification to be sent to %v, but was %v", tc.expectedSaved, user)
+				case <-time.After(time.Second):
+					t.Fatal("Expected the verification to be sent")
+				}
+			}
+		})
+	}
+}
+
+// matchPublish - matches the published events by their topic and content, ignoring their event ID
+func matchPublish(expected, actual []interface{}) error {
+	if len(expected) != 3 || len(actual) != 3 || expected[1] != actual[1] {
+		return fmt.Errorf("expected %v, but was %v", expected, actual)
+	}
+	topic := fmt.Sprint(expected[1])
+	expectedEvent, err := usersPubSub.DecodeEvent(topic, publishedPayload(expected[2]))
+	if err != nil {
+		return err
+	}
+	actualEvent, err := usersPubSub.DecodeEvent(topic, publishedPayload(actual[2]))
+	if err != nil {
+		return err
+	}
+	if actualEvent.ID == "" {
+		return fmt.Errorf("expected the %s event to have an ID", topic)
+	}
+	actualEvent.ID = expectedEvent.ID
+	if !reflect.DeepEqual(expectedEvent, actualEvent) {
+		return fmt.Errorf("expected %+v, but was %+v", expectedEvent, actualEvent)
+	}
+	return nil
+}
+
+func publishedPayload(message interface{}) string {
+	if encoded, ok := message.([]byte); ok {
+		return string(encoded)
+	}
+	return fmt.Sprint(message)
+}
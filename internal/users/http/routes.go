@@ -6,11 +6,20 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// AppendUsersRoutes - Sets the users routes for the given echo group
-func AppendUsersRoutes(e *echo.Group, h users.Handler) {
+// AppendUsersRoutes - Sets the users routes for the given echo group.
+// The mutation middlewares are only applied to the routes creating or modifying a single user
+func AppendUsersRoutes(e *echo.Group, h users.Handler, mutation ...echo.MiddlewareFunc) {
 	e.GET("", h.GetAllUsers)
-	e.POST("", h.CreateUser)
+	e.GET("/export", h.ExportUsers)
+	e.POST("", h.CreateUser, mutation...)
+	e.POST("/batch-get", h.BatchGetUsers)
+	e.POST("/import", h.ImportUsers)
 	e.GET("/:userId", h.GetUserByID)
-	e.POST("/:userId", h.UpdateUserByID)
-	e.DELETE("/:userId", h.DeleteUserByID)
+	e.GET("/:userId/history", h.GetUserHistory)
+	e.PUT("/:userId", h.ReplaceUserByID, mutation...)
+	e.PATCH("/:userId", h.PatchUserByID, mutation...)
+	// Deprecated: use PUT or PATCH instead
+	e.POST("/:userId", h.UpdateUserByID, mutation...)
+	e.DELETE("/:userId", h.DeleteUserByID, mutation...)
+	e.POST("/:userId/restore", h.RestoreUserByID, mutation...)
 }
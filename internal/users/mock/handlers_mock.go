@@ -34,6 +34,20 @@ func (m *MockHandler) EXPECT() *MockHandlerMockRecorder {
 	return m.recorder
 }
 
+// BatchGetUsers mocks base method.
+func (m *MockHandler) BatchGetUsers(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchGetUsers", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BatchGetUsers indicates an expected call of BatchGetUsers.
+func (mr *MockHandlerMockRecorder) BatchGetUsers(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchGetUsers", reflect.TypeOf((*MockHandler)(nil).BatchGetUsers), c)
+}
+
 // CreateUser mocks base method.
 func (m *MockHandler) CreateUser(c echo.Context) error {
 	m.ctrl.T.Helper()
@@ -62,6 +76,20 @@ func (mr *MockHandlerMockRecorder) DeleteUserByID(c interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByID", reflect.TypeOf((*MockHandler)(nil).DeleteUserByID), c)
 }
 
+// ExportUsers mocks base method.
+func (m *MockHandler) ExportUsers(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUsers", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportUsers indicates an expected call of ExportUsers.
+func (mr *MockHandlerMockRecorder) ExportUsers(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUsers", reflect.TypeOf((*MockHandler)(nil).ExportUsers), c)
+}
+
 // GetAllUsers mocks base method.
 func (m *MockHandler) GetAllUsers(c echo.Context) error {
 	m.ctrl.T.Helper()
@@ -90,6 +118,76 @@ func (mr *MockHandlerMockRecorder) GetUserByID(c interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockHandler)(nil).GetUserByID), c)
 }
 
+// GetUserHistory mocks base method.
+func (m *MockHandler) GetUserHistory(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserHistory", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetUserHistory indicates an expected call of GetUserHistory.
+func (mr *MockHandlerMockRecorder) GetUserHistory(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserHistory", reflect.TypeOf((*MockHandler)(nil).GetUserHistory), c)
+}
+
+// ImportUsers mocks base method.
+func (m *MockHandler) ImportUsers(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportUsers", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ImportUsers indicates an expected call of ImportUsers.
+func (mr *MockHandlerMockRecorder) ImportUsers(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportUsers", reflect.TypeOf((*MockHandler)(nil).ImportUsers), c)
+}
+
+// PatchUserByID mocks base method.
+func (m *MockHandler) PatchUserByID(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchUserByID", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchUserByID indicates an expected call of PatchUserByID.
+func (mr *MockHandlerMockRecorder) PatchUserByID(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchUserByID", reflect.TypeOf((*MockHandler)(nil).PatchUserByID), c)
+}
+
+// ReplaceUserByID mocks base method.
+func (m *MockHandler) ReplaceUserByID(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceUserByID", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceUserByID indicates an expected call of ReplaceUserByID.
+func (mr *MockHandlerMockRecorder) ReplaceUserByID(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceUserByID", reflect.TypeOf((*MockHandler)(nil).ReplaceUserByID), c)
+}
+
+// RestoreUserByID mocks base method.
+func (m *MockHandler) RestoreUserByID(c echo.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUserByID", c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUserByID indicates an expected call of RestoreUserByID.
+func (mr *MockHandlerMockRecorder) RestoreUserByID(c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUserByID", reflect.TypeOf((*MockHandler)(nil).RestoreUserByID), c)
+}
+
 // UpdateUserByID mocks base method.
 func (m *MockHandler) UpdateUserByID(c echo.Context) error {
 	m.ctrl.T.Helper()
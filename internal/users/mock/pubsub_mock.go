@@ -0,0 +1,192 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pubsub.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	models "user-microservice/internal/models"
+	pubsub "user-microservice/internal/users/pubsub"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockPubSub is a mock of PubSub interface.
+type MockPubSub struct {
+	ctrl     *gomock.Controller
+	recorder *MockPubSubMockRecorder
+}
+
+// MockPubSubMockRecorder is the mock recorder for MockPubSub.
+type MockPubSubMockRecorder struct {
+	mock *MockPubSub
+}
+
+// NewMockPubSub creates a new mock instance.
+func NewMockPubSub(ctrl *gomock.Controller) *MockPubSub {
+	mock := &MockPubSub{ctrl: ctrl}
+	mock.recorder = &MockPubSubMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPubSub) EXPECT() *MockPubSubMockRecorder {
+	return m.recorder
+}
+
+// NotifyEmailVerified mocks base method.
+func (m *MockPubSub) NotifyEmailVerified(ctx context.Context, verified models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyEmailVerified", ctx, verified)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyEmailVerified indicates an expected call of NotifyEmailVerified.
+func (mr *MockPubSubMockRecorder) NotifyEmailVerified(ctx, verified interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyEmailVerified", reflect.TypeOf((*MockPubSub)(nil).NotifyEmailVerified), ctx, verified)
+}
+
+// NotifyStatusChanged mocks base method.
+func (m *MockPubSub) NotifyStatusChanged(ctx context.Context, changed models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyStatusChanged", ctx, changed)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyStatusChanged indicates an expected call of NotifyStatusChanged.
+func (mr *MockPubSubMockRecorder) NotifyStatusChanged(ctx, changed interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyStatusChanged", reflect.TypeOf((*MockPubSub)(nil).NotifyStatusChanged), ctx, changed)
+}
+
+// NotifyUserCreation mocks base method.
+func (m *MockPubSub) NotifyUserCreation(ctx context.Context, created models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUserCreation", ctx, created)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUserCreation indicates an expected call of NotifyUserCreation.
+func (mr *MockPubSubMockRecorder) NotifyUserCreation(ctx, created interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUserCreation", reflect.TypeOf((*MockPubSub)(nil).NotifyUserCreation), ctx, created)
+}
+
+// NotifyUserDeletion mocks base method.
+func (m *MockPubSub) NotifyUserDeletion(ctx context.Context, deletedUserID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUserDeletion", ctx, deletedUserID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUserDeletion indicates an expected call of NotifyUserDeletion.
+func (mr *MockPubSubMockRecorder) NotifyUserDeletion(ctx, deletedUserID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUserDeletion", reflect.TypeOf((*MockPubSub)(nil).NotifyUserDeletion), ctx, deletedUserID)
+}
+
+// NotifyUserRestore mocks base method.
+func (m *MockPubSub) NotifyUserRestore(ctx context.Context, restored models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUserRestore", ctx, restored)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUserRestore indicates an expected call of NotifyUserRestore.
+func (mr *MockPubSubMockRecorder) NotifyUserRestore(ctx, restored interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUserRestore", reflect.TypeOf((*MockPubSub)(nil).NotifyUserRestore), ctx, restored)
+}
+
+// NotifyUserUpdate mocks base method.
+func (m *MockPubSub) NotifyUserUpdate(ctx context.Context, updatedUser models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUserUpdate", ctx, updatedUser)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUserUpdate indicates an expected call of NotifyUserUpdate.
+func (mr *MockPubSubMockRecorder) NotifyUserUpdate(ctx, updatedUser interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUserUpdate", reflect.TypeOf((*MockPubSub)(nil).NotifyUserUpdate), ctx, updatedUser)
+}
+
+// NotifyUsersCreation mocks base method.
+func (m *MockPubSub) NotifyUsersCreation(ctx context.Context, created []models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUsersCreation", ctx, created)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUsersCreation indicates an expected call of NotifyUsersCreation.
+func (mr *MockPubSubMockRecorder) NotifyUsersCreation(ctx, created interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUsersCreation", reflect.TypeOf((*MockPubSub)(nil).NotifyUsersCreation), ctx, created)
+}
+
+// NotifyUsersPurge mocks base method.
+func (m *MockPubSub) NotifyUsersPurge(ctx context.Context, purgedUserIDs []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUsersPurge", ctx, purgedUserIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUsersPurge indicates an expected call of NotifyUsersPurge.
+func (mr *MockPubSubMockRecorder) NotifyUsersPurge(ctx, purgedUserIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUsersPurge", reflect.TypeOf((*MockPubSub)(nil).NotifyUsersPurge), ctx, purgedUserIDs)
+}
+
+// MockSubscriber is a mock of Subscriber interface.
+type MockSubscriber struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubscriberMockRecorder
+}
+
+// MockSubscriberMockRecorder is the mock recorder for MockSubscriber.
+type MockSubscriberMockRecorder struct {
+	mock *MockSubscriber
+}
+
+// NewMockSubscriber creates a new mock instance.
+func NewMockSubscriber(ctrl *gomock.Controller) *MockSubscriber {
+	mock := &MockSubscriber{ctrl: ctrl}
+	mock.recorder = &MockSubscriberMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSubscriber) EXPECT() *MockSubscriberMockRecorder {
+	return m.recorder
+}
+
+// Subscribe mocks base method.
+func (m *MockSubscriber) Subscribe(ctx context.Context, topics ...string) (<-chan pubsub.Event, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range topics {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Subscribe", varargs...)
+	ret0, _ := ret[0].(<-chan pubsub.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockSubscriberMockRecorder) Subscribe(ctx interface{}, topics ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, topics...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockSubscriber)(nil).Subscribe), varargs...)
+}
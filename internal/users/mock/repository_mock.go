@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 	models "user-microservice/internal/models"
 	pagination "user-microservice/internal/pagination"
 
@@ -51,6 +52,21 @@ func (mr *MockRepositoryMockRecorder) Create(ctx, user interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), ctx, user)
 }
 
+// CreateMany mocks base method.
+func (m *MockRepository) CreateMany(ctx context.Context, users []models.User) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMany", ctx, users)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMany indicates an expected call of CreateMany.
+func (mr *MockRepositoryMockRecorder) CreateMany(ctx, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMany", reflect.TypeOf((*MockRepository)(nil).CreateMany), ctx, users)
+}
+
 // DeleteById mocks base method.
 func (m *MockRepository) DeleteById(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
@@ -65,34 +81,187 @@ func (mr *MockRepositoryMockRecorder) DeleteById(ctx, id interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteById", reflect.TypeOf((*MockRepository)(nil).DeleteById), ctx, id)
 }
 
+// GetByEmail mocks base method.
+func (m *MockRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByEmail", ctx, email)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByEmail indicates an expected call of GetByEmail.
+func (mr *MockRepositoryMockRecorder) GetByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockRepository)(nil).GetByEmail), ctx, email)
+}
+
+// GetByIDs mocks base method.
+func (m *MockRepository) GetByIDs(ctx context.Context, ids []string, fields ...string) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, ids}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetByIDs", varargs...)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIDs indicates an expected call of GetByIDs.
+func (mr *MockRepositoryMockRecorder) GetByIDs(ctx, ids interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, ids}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDs", reflect.TypeOf((*MockRepository)(nil).GetByIDs), varargs...)
+}
+
 // GetById mocks base method.
-func (m *MockRepository) GetById(ctx context.Context, id string) (*models.User, error) {
+func (m *MockRepository) GetById(ctx context.Context, id string, fields ...string) (*models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetById", ctx, id)
+	varargs := []interface{}{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetById", varargs...)
 	ret0, _ := ret[0].(*models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetById indicates an expected call of GetById.
-func (mr *MockRepositoryMockRecorder) GetById(ctx, id interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetById(ctx, id interface{}, fields ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockRepository)(nil).GetById), ctx, id)
+	varargs := append([]interface{}{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockRepository)(nil).GetById), varargs...)
+}
+
+// GetExpiredSuspensions mocks base method.
+func (m *MockRepository) GetExpiredSuspensions(ctx context.Context, before time.Time) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiredSuspensions", ctx, before)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiredSuspensions indicates an expected call of GetExpiredSuspensions.
+func (mr *MockRepositoryMockRecorder) GetExpiredSuspensions(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiredSuspensions", reflect.TypeOf((*MockRepository)(nil).GetExpiredSuspensions), ctx, before)
+}
+
+// GetMFA mocks base method.
+func (m *MockRepository) GetMFA(ctx context.Context, id string) (*models.MFA, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMFA", ctx, id)
+	ret0, _ := ret[0].(*models.MFA)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMFA indicates an expected call of GetMFA.
+func (mr *MockRepositoryMockRecorder) GetMFA(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMFA", reflect.TypeOf((*MockRepository)(nil).GetMFA), ctx, id)
 }
 
 // GetPaginatedUsers mocks base method.
-func (m *MockRepository) GetPaginatedUsers(ctx context.Context, pagination pagination.PaginationOptions, filters models.UserFilters) (models.PaginatedUsers, error) {
+func (m *MockRepository) GetPaginatedUsers(ctx context.Context, pagination pagination.PaginationOptions, filters models.UserFilters, fields ...string) (models.PaginatedUsers, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPaginatedUsers", ctx, pagination, filters)
+	varargs := []interface{}{ctx, pagination, filters}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetPaginatedUsers", varargs...)
 	ret0, _ := ret[0].(models.PaginatedUsers)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPaginatedUsers indicates an expected call of GetPaginatedUsers.
-func (mr *MockRepositoryMockRecorder) GetPaginatedUsers(ctx, pagination, filters interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetPaginatedUsers(ctx, pagination, filters interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, pagination, filters}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaginatedUsers", reflect.TypeOf((*MockRepository)(nil).GetPaginatedUsers), varargs...)
+}
+
+// GetUserHistory mocks base method.
+func (m *MockRepository) GetUserHistory(ctx context.Context, userID string, pagination pagination.PaginationOptions) (models.PaginatedAuditEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserHistory", ctx, userID, pagination)
+	ret0, _ := ret[0].(models.PaginatedAuditEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserHistory indicates an expected call of GetUserHistory.
+func (mr *MockRepositoryMockRecorder) GetUserHistory(ctx, userID, pagination interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaginatedUsers", reflect.TypeOf((*MockRepository)(nil).GetPaginatedUsers), ctx, pagination, filters)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserHistory", reflect.TypeOf((*MockRepository)(nil).GetUserHistory), ctx, userID, pagination)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockRepository) PurgeDeleted(ctx context.Context, before time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx, before)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockRepositoryMockRecorder) PurgeDeleted(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockRepository)(nil).PurgeDeleted), ctx, before)
+}
+
+// Restore mocks base method.
+func (m *MockRepository) Restore(ctx context.Context, id string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, id)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockRepositoryMockRecorder) Restore(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRepository)(nil).Restore), ctx, id)
+}
+
+// SetMFA mocks base method.
+func (m *MockRepository) SetMFA(ctx context.Context, id string, mfa *models.MFA) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMFA", ctx, id, mfa)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMFA indicates an expected call of SetMFA.
+func (mr *MockRepositoryMockRecorder) SetMFA(ctx, id, mfa interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMFA", reflect.TypeOf((*MockRepository)(nil).SetMFA), ctx, id, mfa)
+}
+
+// StreamUsers mocks base method.
+func (m *MockRepository) StreamUsers(ctx context.Context, filters models.UserFilters, fn func(models.User) error, fields ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, filters, fn}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "StreamUsers", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamUsers indicates an expected call of StreamUsers.
+func (mr *MockRepositoryMockRecorder) StreamUsers(ctx, filters, fn interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, filters, fn}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamUsers", reflect.TypeOf((*MockRepository)(nil).StreamUsers), varargs...)
 }
 
 // Update mocks base method.
@@ -109,3 +278,33 @@ func (mr *MockRepositoryMockRecorder) Update(ctx, user interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), ctx, user)
 }
+
+// UseMFARecoveryCode mocks base method.
+func (m *MockRepository) UseMFARecoveryCode(ctx context.Context, id, hash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMFARecoveryCode", ctx, id, hash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMFARecoveryCode indicates an expected call of UseMFARecoveryCode.
+func (mr *MockRepositoryMockRecorder) UseMFARecoveryCode(ctx, id, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMFARecoveryCode", reflect.TypeOf((*MockRepository)(nil).UseMFARecoveryCode), ctx, id, hash)
+}
+
+// UseMFAStep mocks base method.
+func (m *MockRepository) UseMFAStep(ctx context.Context, id string, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMFAStep", ctx, id, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMFAStep indicates an expected call of UseMFAStep.
+func (mr *MockRepositoryMockRecorder) UseMFAStep(ctx, id, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMFAStep", reflect.TypeOf((*MockRepository)(nil).UseMFAStep), ctx, id, step)
+}
@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// minResubscribeDelay - delay before subscribing again after the first failure, doubled on every failure
+	minResubscribeDelay = time.Second
+	// maxResubscribeDelay - maximum delay before subscribing again
+	maxResubscribeDelay = 30 * time.Second
+)
+
+// Listen - calls handle with the events of the topics (all of them if empty) until the context is done, subscribing
+// again with an exponential backoff if the subscription fails (e.g. redis is unavailable on start) or is lost.
+// The name of the listener is only used in the logs
+func Listen(ctx context.Context, subscriber Subscriber, name string, handle func(Event), topics ...string) {
+	delay := minResubscribeDelay
+	for {
+		events, err := subscriber.Subscribe(ctx, topics...)
+		if err != nil {
+			logrus.Errorf("Error in pubsub.Listen -> error subscribing the %s to the user topics: %s", name, err)
+		} else {
+			delay = minResubscribeDelay
+			for event := range events {
+				handle(event)
+			}
+			if ctx.Err() == nil {
+				logrus.Warnf("The user topics subscription of the %s was lost, subscribing again", name)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxResubscribeDelay {
+			delay = maxResubscribeDelay
+		}
+	}
+}
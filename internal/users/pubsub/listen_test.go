@@ -0,0 +1,82 @@
+package pubsub_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/users/pubsub"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakySubscriber - subscriber failing the first subscription, and sending the events to the next ones
+type flakySubscriber struct {
+	mu            sync.Mutex
+	subscriptions int
+	topics        []string
+	events        chan pubsub.Event
+}
+
+func (s *flakySubscriber) Subscribe(ctx context.Context, topics ...string) (<-chan pubsub.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions++
+	s.topics = topics
+	if s.subscriptions == 1 {
+		return nil, errors.New("homemade error")
+	}
+
+	events := make(chan pubsub.Event)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-s.events:
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func TestListen(t *testing.T) {
+	// Given
+	subscriber := &flakySubscriber{events: make(chan pubsub.Event)}
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan pubsub.Event, 1)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		pubsub.Listen(ctx, subscriber, "test", func(event pubsub.Event) { received <- event }, pubsub.TopicUserDeletion)
+	}()
+
+	// When
+	event := pubsub.Event{ID: "5b0d4a6e-8f9c-4c55-9d51-8d7f2a3b1c0e", Topic: pubsub.TopicUserDeletion, UserID: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"}
+	select {
+	case subscriber.events <- event:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected to subscribe again after the failed subscription")
+	}
+
+	// Then
+	assert.Equal(t, event, <-received)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Listen to return when the context is done")
+	}
+	subscriber.mu.Lock()
+	defer subscriber.mu.Unlock()
+	require.Equalf(t, 2, subscriber.subscriptions, "Expected 2 subscriptions, but were %d", subscriber.subscriptions)
+	assert.Equal(t, []string{pubsub.TopicUserDeletion}, subscriber.topics)
+}
@@ -1,3 +1,4 @@
+//go:generate mockgen -source pubsub.go -destination ../mock/pubsub_mock.go -package mock
 package pubsub
 
 import (
@@ -7,6 +8,30 @@ import (
 
 type PubSub interface {
 	NotifyUserCreation(ctx context.Context, created models.User) error
+	NotifyUsersCreation(ctx context.Context, created []models.User) error
 	NotifyUserUpdate(ctx context.Context, updatedUser models.User) error
 	NotifyUserDeletion(ctx context.Context, deletedUserID string) error
+	NotifyUserRestore(ctx context.Context, restored models.User) error
+	NotifyUsersPurge(ctx context.Context, purgedUserIDs []string) error
+	NotifyEmailVerified(ctx context.Context, verified models.User) error
+	NotifyStatusChanged(ctx context.Context, changed models.User) error
+}
+
+// Event - user event received from the pubsub
+type Event struct {
+	// ID - unique ID of the event, so the subscribers can tell apart two identical events. Empty for the events
+	// published by the older versions
+	ID     string
+	Topic  string
+	UserID string
+	// User - the user of the event, nil for the deletion and purge events, which only have the user ID
+	User *models.User
+}
+
+// Subscriber - receives the user events
+type Subscriber interface {
+	// Subscribe - returns a channel receiving the events of the given topics (all of them if empty),
+	// closed when the context is done. The subscription is confirmed before returning, so no event
+	// published afterwards is lost
+	Subscribe(ctx context.Context, topics ...string) (<-chan Event, error)
 }
@@ -3,9 +3,12 @@ package pubsub
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"user-microservice/internal/models"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
 type redisPubSub struct {
@@ -14,6 +17,19 @@ type redisPubSub struct {
 
 var _ PubSub = redisPubSub{}
 var _ PubSub = (*redisPubSub)(nil)
+var _ Subscriber = redisPubSub{}
+
+// userMessage - payload of the user events, the user with the ID of the event
+type userMessage struct {
+	*models.User
+	EventID string `json:"eventId"`
+}
+
+// userIDMessage - payload of the deletion and purge events, the user ID with the ID of the event
+type userIDMessage struct {
+	UserID  string `json:"userId"`
+	EventID string `json:"eventId"`
+}
 
 // NewPubSub - returns a new User PubSub
 func NewPubSub(rc *redis.Client) *redisPubSub {
@@ -22,16 +38,35 @@ func NewPubSub(rc *redis.Client) *redisPubSub {
 
 // NotifyUserCreation - publish to the TopicUserCreation topic
 func (rps redisPubSub) NotifyUserCreation(ctx context.Context, created models.User) error {
-	encoded, err := json.Marshal(created)
+	encoded, err := encodeUser(created)
 	if err != nil {
 		return err
 	}
 	return rps.rc.Publish(ctx, TopicUserCreation, encoded).Err()
 }
 
+// NotifyUsersCreation - publish every user to the TopicUserCreation topic using a single pipeline
+func (rps redisPubSub) NotifyUsersCreation(ctx context.Context, created []models.User) error {
+	if len(created) == 0 {
+		return nil
+	}
+
+	_, err := rps.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, user := range created {
+			encoded, err := encodeUser(user)
+			if err != nil {
+				return err
+			}
+			pipe.Publish(ctx, TopicUserCreation, encoded)
+		}
+		return nil
+	})
+	return err
+}
+
 // NotifyUserUpdate - publish to the TopicUserUpdate topic
 func (rps redisPubSub) NotifyUserUpdate(ctx context.Context, updatedUser models.User) error {
-	encoded, err := json.Marshal(updatedUser)
+	encoded, err := encodeUser(updatedUser)
 	if err != nil {
 		return err
 	}
@@ -40,5 +75,132 @@ func (rps redisPubSub) NotifyUserUpdate(ctx context.Context, updatedUser models.
 
 // NotifyUserDeletion - publish to the TopicUserDeletion topic
 func (rps redisPubSub) NotifyUserDeletion(ctx context.Context, deletedUserID string) error {
-	return rps.rc.Publish(ctx, TopicUserDeletion, deletedUserID).Err()
+	encoded, err := encodeUserID(deletedUserID)
+	if err != nil {
+		return err
+	}
+	return rps.rc.Publish(ctx, TopicUserDeletion, encoded).Err()
+}
+
+// NotifyUserRestore - publish to the TopicUserRestore topic
+func (rps redisPubSub) NotifyUserRestore(ctx context.Context, restored models.User) error {
+	encoded, err := encodeUser(restored)
+	if err != nil {
+		return err
+	}
+	return rps.rc.Publish(ctx, TopicUserRestore, encoded).Err()
+}
+
+// NotifyUsersPurge - publish every user ID to the TopicUserPurge topic using a single pipeline
+func (rps redisPubSub) NotifyUsersPurge(ctx context.Context, purgedUserIDs []string) error {
+	if len(purgedUserIDs) == 0 {
+		return nil
+	}
+
+	_, err := rps.rc.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range purgedUserIDs {
+			encoded, err := encodeUserID(id)
+			if err != nil {
+				return err
+			}
+			pipe.Publish(ctx, TopicUserPurge, encoded)
+		}
+		return nil
+	})
+	return err
+}
+
+// NotifyEmailVerified - publish to the TopicUserEmailVerified topic
+func (rps redisPubSub) NotifyEmailVerified(ctx context.Context, verified models.User) error {
+	encoded, err := encodeUser(verified)
+	if err != nil {
+		return err
+	}
+	return rps.rc.Publish(ctx, TopicUserEmailVerified, encoded).Err()
+}
+
+// NotifyStatusChanged - publish to the TopicUserStatusChanged topic
+func (rps redisPubSub) NotifyStatusChanged(ctx context.Context, changed models.User) error {
+	encoded, err := encodeUser(changed)
+	if err != nil {
+		return err
+	}
+	return rps.rc.Publish(ctx, TopicUserStatusChanged, encoded).Err()
+}
+
+// Subscribe - subscribes to the redis channels of the topics, decoding the messages into events.
+// The messages that cannot be decoded are logged and skipped
+func (rps redisPubSub) Subscribe(ctx context.Context, topics ...string) (<-chan Event, error) {
+	if len(topics) == 0 {
+		topics = GetAllUsersTopics()
+	}
+	subscription := rps.rc.Subscribe(ctx, topics...)
+	if _, err := subscription.Receive(ctx); err != nil {
+		subscription.Close()
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer subscription.Close()
+
+		ch := subscription.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, isOpen := <-ch:
+				if !isOpen {
+					return
+				}
+				event, err := DecodeEvent(msg.Channel, msg.Payload)
+				if err != nil {
+					logrus.Errorf("Error in pubsub.Subscribe -> error decoding %s event: %s", msg.Channel, err)
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// encodeUser - returns the payload of a user event, with a new event ID
+func encodeUser(user models.User) ([]byte, error) {
+	return json.Marshal(userMessage{User: &user, EventID: uuid.NewString()})
+}
+
+// encodeUserID - returns the payload of a deletion or purge event, with a new event ID
+func encodeUserID(userID string) ([]byte, error) {
+	return json.Marshal(userIDMessage{UserID: userID, EventID: uuid.NewString()})
+}
+
+// DecodeEvent - returns the event of the topic payload. The deletion and purge events payload has the user ID,
+// while the rest of them contain the whole user, both with the event ID in the eventId field. The payloads
+// published by the older versions, without event ID and with only the user ID as deletion and purge payloads,
+// are decoded too
+func DecodeEvent(topic, payload string) (Event, error) {
+	switch topic {
+	case TopicUserDeletion, TopicUserPurge:
+		if !strings.HasPrefix(payload, "{") {
+			return Event{Topic: topic, UserID: payload}, nil
+		}
+		var message userIDMessage
+		if err := json.Unmarshal([]byte(payload), &message); err != nil {
+			return Event{}, err
+		}
+		return Event{ID: message.EventID, Topic: topic, UserID: message.UserID}, nil
+	default:
+		message := userMessage{User: &models.User{}}
+		if err := json.Unmarshal([]byte(payload), &message); err != nil {
+			return Event{}, err
+		}
+		return Event{ID: message.EventID, Topic: topic, UserID: message.User.ID, User: message.User}, nil
+	}
 }
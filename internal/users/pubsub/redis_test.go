@@ -0,0 +1,189 @@
+package pubsub_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users/pubsub"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisPubSub_Subscribe(t *testing.T) {
+	user := models.User{ID: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", Nickname: "atingo", Version: 2}
+	for _, tc := range []struct {
+		name          string
+		topics        []string
+		notify        func(ctx context.Context, ps pubsub.PubSub) error
+		expectedEvent pubsub.Event
+	}{
+		{
+			"Receive update event with the user",
+			nil,
+			func(ctx context.Context, ps pubsub.PubSub) error {
+				return ps.NotifyUserUpdate(ctx, user)
+			},
+			pubsub.Event{Topic: pubsub.TopicUserUpdate, UserID: user.ID, User: &user},
+		},
+		{
+			"Receive deletion event with the user ID",
+			[]string{pubsub.TopicUserDeletion},
+			func(ctx context.Context, ps pubsub.PubSub) error {
+				return ps.NotifyUserDeletion(ctx, user.ID)
+			},
+			pubsub.Event{Topic: pubsub.TopicUserDeletion, UserID: user.ID},
+		},
+		{
+			"Receive purge event with the user ID",
+			[]string{pubsub.TopicUserPurge},
+			func(ctx context.Context, ps pubsub.PubSub) error {
+				return ps.NotifyUsersPurge(ctx, []string{user.ID})
+			},
+			pubsub.Event{Topic: pubsub.TopicUserPurge, UserID: user.ID},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mr := miniredis.RunT(t)
+			rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			ps := pubsub.NewPubSub(rc)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events, err := ps.Subscribe(ctx, tc.topics...)
+			require.NoError(t, err)
+
+			// When
+			require.NoError(t, tc.notify(ctx, ps))
+
+			// Then
+			select {
+			case event := <-events:
+				assert.NotEmptyf(t, event.ID, "Expected the event to have an ID")
+				assert.Equalf(t, tc.expectedEvent.Topic, event.Topic, "Expected topic to be %s, but was %s", tc.expectedEvent.Topic, event.Topic)
+				assert.Equalf(t, tc.expectedEvent.UserID, event.UserID, "Expected user ID to be %s, but was %s", tc.expectedEvent.UserID, event.UserID)
+				if tc.expectedEvent.User == nil {
+					assert.Nilf(t, event.User, "Expected user to be nil, but was %v", event.User)
+				} else {
+					require.NotNil(t, event.User, "Expected user not to be nil")
+					assert.Equalf(t, tc.expectedEvent.User.Nickname, event.User.Nickname, "Expected nickname to be %s, but was %s", tc.expectedEvent.User.Nickname, event.User.Nickname)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected an event, but none was received")
+			}
+		})
+	}
+}
+
+func TestRedisPubSub_SubscribeClosesOnCancel(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ps := pubsub.NewPubSub(rc)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := ps.Subscribe(ctx)
+	require.NoError(t, err)
+
+	// When
+	cancel()
+
+	// Then
+	select {
+	case _, isOpen := <-events:
+		assert.False(t, isOpen, "Expected the events channel to be closed")
+	case <-time.After(time.Second):
+		t.Fatal("Expected the events channel to be closed")
+	}
+}
+
+func TestDecodeEvent_InvalidPayload(t *testing.T) {
+	// When
+	_, err := pubsub.DecodeEvent(pubsub.TopicUserCreation, "not json")
+
+	// Then
+	assert.Error(t, err, "Expected error")
+}
+
+func TestRedisPubSub_SubscribeIdenticalEvents(t *testing.T) {
+	// Given
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ps := pubsub.NewPubSub(rc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := ps.Subscribe(ctx, pubsub.TopicUserDeletion)
+	require.NoError(t, err)
+	userID := "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+
+	// When
+	require.NoError(t, ps.NotifyUserDeletion(ctx, userID))
+	require.NoError(t, ps.NotifyUserDeletion(ctx, userID))
+
+	// Then
+	ids := make([]string, 0, 2)
+	for len(ids) < 2 {
+		select {
+		case event := <-events:
+			ids = append(ids, event.ID)
+		case <-time.After(time.Second):
+			t.Fatal("Expected 2 events")
+		}
+	}
+	assert.NotEqualf(t, ids[0], ids[1], "Expected the events to have different IDs, but both were %s", ids[0])
+}
+
+func TestDecodeEvent(t *testing.T) {
+	userID := "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	eventID := "5b0d4a6e-8f9c-4c55-9d51-8d7f2a3b1c0e"
+	for _, tc := range []struct {
+		name          string
+		topic         string
+		payload       string
+		expectedEvent pubsub.Event
+	}{
+		{
+			"Decode user event",
+			pubsub.TopicUserUpdate,
+			`{"id":"` + userID + `","nickname":"atingo","eventId":"` + eventID + `"}`,
+			pubsub.Event{ID: eventID, Topic: pubsub.TopicUserUpdate, UserID: userID},
+		},
+		{
+			"Decode user event without ID",
+			pubsub.TopicUserUpdate,
+			`{"id":"` + userID + `","nickname":"atingo"}`,
+			pubsub.Event{Topic: pubsub.TopicUserUpdate, UserID: userID},
+		},
+		{
+			"Decode deletion event",
+			pubsub.TopicUserDeletion,
+			`{"userId":"` + userID + `","eventId":"` + eventID + `"}`,
+			pubsub.Event{ID: eventID, Topic: pubsub.TopicUserDeletion, UserID: userID},
+		},
+		{
+			"Decode deletion event with only the user ID",
+			pubsub.TopicUserDeletion,
+			userID,
+			pubsub.Event{Topic: pubsub.TopicUserDeletion, UserID: userID},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			event, err := pubsub.DecodeEvent(tc.topic, tc.payload)
+
+			// Then
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedEvent.ID, event.ID, "Expected event ID to be %s, but was %s", tc.expectedEvent.ID, event.ID)
+			assert.Equalf(t, tc.expectedEvent.Topic, event.Topic, "Expected topic to be %s, but was %s", tc.expectedEvent.Topic, event.Topic)
+			assert.Equalf(t, tc.expectedEvent.UserID, event.UserID, "Expected user ID to be %s, but was %s", tc.expectedEvent.UserID, event.UserID)
+		})
+	}
+}
@@ -9,6 +9,18 @@ const (
 
 	//TopicUserDeletion - Topic used to notify user deletions
 	TopicUserDeletion string = "user-deleted"
+
+	//TopicUserRestore - Topic used to notify the restoration of deleted users
+	TopicUserRestore string = "user-restored"
+
+	//TopicUserPurge - Topic used to notify the permanent removal of deleted users
+	TopicUserPurge string = "user-purged"
+
+	//TopicUserEmailVerified - Topic used to notify the verification of user emails
+	TopicUserEmailVerified string = "user-email-verified"
+
+	//TopicUserStatusChanged - Topic used to notify the user status changes (suspensions, bans and reactivations)
+	TopicUserStatusChanged string = "user-status-changed"
 )
 
 // GetAllUsersTopics - returns a slice with all the users topics for easy
@@ -18,5 +30,9 @@ func GetAllUsersTopics() []string {
 		TopicUserUpdate,
 		TopicUserCreation,
 		TopicUserDeletion,
+		TopicUserRestore,
+		TopicUserPurge,
+		TopicUserEmailVerified,
+		TopicUserStatusChanged,
 	}
 }
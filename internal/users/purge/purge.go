@@ -0,0 +1,72 @@
+package purge
+
+import (
+	"context"
+	"time"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRetention - default time the soft deleted users are kept before purging them
+	DefaultRetention = 30 * 24 * time.Hour
+	// DefaultInterval - default time between each purge
+	DefaultInterval = time.Hour
+)
+
+// Purger - permanently removes the users soft deleted for longer than the retention period
+type Purger struct {
+	repository       users.Repository
+	pubsubRepository userPS.PubSub
+	retention        time.Duration
+	interval         time.Duration
+}
+
+// NewPurger - returns a new Purger. The zero retention and interval are replaced by the default ones
+func NewPurger(usersRepository users.Repository, pubsubRepository userPS.PubSub, retention, interval time.Duration) *Purger {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Purger{usersRepository, pubsubRepository, retention, interval}
+}
+
+// Run - purges the users every interval until the context is done. Should be run in its own goroutine
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Purge(ctx); err != nil {
+			logrus.Errorf("Error in users/purge.Run -> error purging users: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge - removes the users deleted before the retention period, notifies it and returns how many were removed.
+// The users removed before an error are notified too
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	purged, err := p.repository.PurgeDeleted(ctx, time.Now().UTC().Add(-p.retention))
+	if len(purged) > 0 {
+		logrus.Infof("Purged %d deleted users", len(purged))
+		if err := p.pubsubRepository.NotifyUsersPurge(ctx, purged); err != nil {
+			logrus.Errorf("Error in users/purge.Purge -> could not notify users purge: %s", err)
+		}
+	}
+	if err != nil {
+		return len(purged), err
+	}
+
+	return len(purged), nil
+}
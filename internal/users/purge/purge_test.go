@@ -0,0 +1,128 @@
+package purge_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user-microservice/internal/users/mock"
+	"user-microservice/internal/users/purge"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurger_Purge(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		retention     time.Duration
+		mockedIDs     []string
+		mockedError   error
+		publishError  error
+		expectedCount int
+		expectedError error
+		shouldPublish bool
+	}{
+		{
+			"Purge deleted users",
+			time.Hour,
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", "f4c9c17e-c260-4a0b-a1f1-a3f3ef6a3739"},
+			nil,
+			nil,
+			2,
+			nil,
+			true,
+		},
+		{
+			"Purge deleted users with default retention",
+			0,
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"},
+			nil,
+			nil,
+			1,
+			nil,
+			true,
+		},
+		{
+			"Purge without deleted users",
+			time.Hour,
+			[]string{},
+			nil,
+			nil,
+			0,
+			nil,
+			false,
+		},
+		{
+			"Purge deleted users with publish error",
+			time.Hour,
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"},
+			nil,
+			errors.New("homemade error"),
+			1,
+			nil,
+			true,
+		},
+		{
+			"Purge deleted users with database error",
+			time.Hour,
+			nil,
+			errors.New("homemade error"),
+			nil,
+			0,
+			errors.New("homemade error"),
+			false,
+		},
+		{
+			"Purge deleted users with database error after some of them",
+			time.Hour,
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"},
+			errors.New("homemade error"),
+			nil,
+			1,
+			errors.New("homemade error"),
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			pubsubRepo := mock.NewMockPubSub(ctrl)
+			purger := purge.NewPurger(userRepo, pubsubRepo, tc.retention, time.Minute)
+			ctx := context.TODO()
+
+			expectedRetention := tc.retention
+			if expectedRetention == 0 {
+				expectedRetention = purge.DefaultRetention
+			}
+			now := time.Now().UTC()
+			userRepo.EXPECT().PurgeDeleted(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, before time.Time) ([]string, error) {
+				expectedBefore := now.Add(-expectedRetention)
+				assert.WithinDurationf(t, expectedBefore, before, time.Second, "Expected before to be %s, but was %s", expectedBefore, before)
+				return tc.mockedIDs, tc.mockedError
+			})
+			publishTimes := 0
+			if tc.shouldPublish {
+				publishTimes = 1
+			}
+			pubsubRepo.EXPECT().NotifyUsersPurge(ctx, tc.mockedIDs).Return(tc.publishError).Times(publishTimes)
+
+			// When
+			count, err := purger.Purge(ctx)
+
+			// Then
+			if tc.expectedError != nil {
+				require.Error(t, err)
+				assert.Equalf(t, tc.expectedError, err, "Expected err to be %s, but was %s", tc.expectedError, err)
+			} else {
+				require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			}
+			assert.Equalf(t, tc.expectedCount, count, "Expected count to be %d, but was %d", tc.expectedCount, count)
+		})
+	}
+}
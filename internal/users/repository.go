@@ -3,15 +3,44 @@ package users
 
 import (
 	"context"
+	"time"
 	"user-microservice/internal/models"
 	"user-microservice/internal/pagination"
 )
 
-// Repository - users repository
+// Repository - users repository.
+// The optional fields (json names) restrict the retrieved user fields, an empty list means all the fields
 type Repository interface {
 	Create(ctx context.Context, user models.User) (*models.User, error)
-	GetById(ctx context.Context, id string) (*models.User, error)
+	CreateMany(ctx context.Context, users []models.User) ([]models.User, error)
+	GetById(ctx context.Context, id string, fields ...string) (*models.User, error)
+	GetByIDs(ctx context.Context, ids []string, fields ...string) ([]models.User, error)
+	// GetByEmail - returns the not deleted user with the email, or mongo.ErrNoDocuments if there is none
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// Update - replaces the user, keeping the stored password if the user has no password
 	Update(ctx context.Context, user models.User) (*models.User, error)
+	// DeleteById - soft deletes the user, returning mongo.ErrNoDocuments if it does not exist or it is already deleted
 	DeleteById(ctx context.Context, id string) error
-	GetPaginatedUsers(ctx context.Context, pagination pagination.PaginationOptions, filters models.UserFilters) (models.PaginatedUsers, error)
+	// Restore - undoes the soft deletion of the user, returning mongo.ErrNoDocuments if there is no deleted user with the id
+	Restore(ctx context.Context, id string) (*models.User, error)
+	// PurgeDeleted - permanently removes the users deleted before the given time and returns their IDs,
+	// also the ones removed before an error interrupted it
+	PurgeDeleted(ctx context.Context, before time.Time) ([]string, error)
+	// GetExpiredSuspensions - returns the not deleted suspended users whose suspension ended before the given time
+	GetExpiredSuspensions(ctx context.Context, before time.Time) ([]models.User, error)
+	GetPaginatedUsers(ctx context.Context, pagination pagination.PaginationOptions, filters models.UserFilters, fields ...string) (models.PaginatedUsers, error)
+	StreamUsers(ctx context.Context, filters models.UserFilters, fn func(models.User) error, fields ...string) error
+	// GetMFA - returns the second factor of the not deleted user, nil if it did not enroll one, or mongo.ErrNoDocuments if there is no user
+	GetMFA(ctx context.Context, id string) (*models.MFA, error)
+	// SetMFA - sets the second factor of the not deleted user, removing it if nil, or returns mongo.ErrNoDocuments if there is no user.
+	// The second factor is not modified by the other methods
+	SetMFA(ctx context.Context, id string, mfa *models.MFA) error
+	// UseMFAStep - sets the last used TOTP step of the enabled second factor of the not deleted user only if the
+	// stored one is older, and returns false if it's not (e.g. the step was used concurrently) or there is no user
+	UseMFAStep(ctx context.Context, id string, step int64) (bool, error)
+	// UseMFARecoveryCode - removes the recovery code hash from the enabled second factor of the not deleted user,
+	// and returns false if it was not there (e.g. it was used concurrently) or there is no user
+	UseMFARecoveryCode(ctx context.Context, id, hash string) (bool, error)
+	// GetUserHistory - returns the paginated audit entries of the user changes, the newest first
+	GetUserHistory(ctx context.Context, userID string, pagination pagination.PaginationOptions) (models.PaginatedAuditEntries, error)
 }
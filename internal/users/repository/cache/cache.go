@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultTTL - default time a user is kept in the cache
+const DefaultTTL = 5 * time.Minute
+
+// keyPrefix - prefix of the redis keys storing the cached users
+const keyPrefix = "users:cache:"
+
+// loadTimeout - time given to the load of a user shared by the concurrent calls, which is not cancelled
+// with the context of any of them
+const loadTimeout = 10 * time.Second
+
+// cachedRepository - users.Repository decorator caching the users retrieved by ID in redis.
+// The rest of the methods are delegated to the decorated repository, evicting the modified users
+type cachedRepository struct {
+	users.Repository
+	rc      *redis.Client
+	ttl     time.Duration
+	metrics *Metrics
+	// group - deduplicates the concurrent loads of the same user (cache stampede protection)
+	group *singleflight.Group
+}
+
+var _ users.Repository = (*cachedRepository)(nil)
+
+// NewCachedRepository - returns a new users.Repository caching the users of the given repository
+// for the given ttl (DefaultTTL if it's not positive)
+func NewCachedRepository(repository users.Repository, rc *redis.Client, ttl time.Duration, metrics *Metrics) *cachedRepository {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &cachedRepository{
+		Repository: repository,
+		rc:         rc,
+		ttl:        ttl,
+		metrics:    metrics,
+		group:      &singleflight.Group{},
+	}
+}
+
+// GetById - returns the cached user, loading it from the decorated repository if it's not cached.
+// The whole user is always cached except its password, the fields only restrict the returned ones
+func (r *cachedRepository) GetById(ctx context.Context, id string, fields ...string) (*models.User, error) {
+	id = strings.ToLower(id)
+	user, err := r.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectFields(*user, fields)
+}
+
+// Update - updates the user and evicts it. The user is also evicted when the update fails,
+// because a version conflict may mean the cached user is stale
+func (r *cachedRepository) Update(ctx context.Context, user models.User) (*models.User, error) {
+	updated, err := r.Repository.Update(ctx, user)
+	r.Evict(ctx, user.ID)
+	return updated, err
+}
+
+// DeleteById - deletes the user and evicts it
+func (r *cachedRepository) DeleteById(ctx context.Context, id string) error {
+	err := r.Repository.DeleteById(ctx, id)
+	r.Evict(ctx, id)
+	return err
+}
+
+// Restore - restores the user and evicts it
+func (r *cachedRepository) Restore(ctx context.Context, id string) (*models.User, error) {
+	restored, err := r.Repository.Restore(ctx, id)
+	r.Evict(ctx, id)
+	return restored, err
+}
+
+// PurgeDeleted - purges the deleted users and evicts them
+func (r *cachedRepository) PurgeDeleted(ctx context.Context, before time.Time) ([]string, error) {
+	purged, err := r.Repository.PurgeDeleted(ctx, before)
+	r.Evict(ctx, purged...)
+	return purged, err
+}
+
+// Evict - removes the users from the cache. The errors are only logged, the users will expire anyway
+func (r *cachedRepository) Evict(ctx context.Context, ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, key(id))
+	}
+	if err := r.rc.Del(ctx, keys...).Err(); err != nil {
+		logrus.Errorf("Error in repository/cache.Evict -> error deleting keys %v: %s", keys, err)
+		return
+	}
+	r.metrics.evictions.Add(float64(len(ids)))
+}
+
+// get - returns the whole user from the cache or loads it, only once for the concurrent calls. The load is not
+// cancelled when the caller that started it is, so the other callers waiting for it still get the user
+func (r *cachedRepository) get(ctx context.Context, id string) (*models.User, error) {
+	encoded, err := r.rc.Get(ctx, key(id)).Bytes()
+	if err == nil {
+		var user models.User
+		if err := json.Unmarshal(encoded, &user); err == nil {
+			r.metrics.hits.Inc()
+			return &user, nil
+		}
+		logrus.Errorf("Error in repository/cache.get -> error decoding user %s: %s", id, err)
+	} else if !errors.Is(err, redis.Nil) {
+		// the cache is not available, the user is loaded from the repository anyway
+		logrus.Errorf("Error in repository/cache.get -> error getting user %s: %s", id, err)
+	}
+	r.metrics.misses.Inc()
+
+	loaded := r.group.DoChan(id, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+		defer cancel()
+		return r.load(ctx, id)
+	})
+	var res singleflight.Result
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res = <-loaded:
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	user := *res.Val.(*models.User)
+	// the password is not cached, so it's not returned either when the user is loaded
+	user.Password = ""
+
+	return &user, nil
+}
+
+// load - retrieves the whole user from the decorated repository and caches it
+func (r *cachedRepository) load(ctx context.Context, id string) (*models.User, error) {
+	user, err := r.Repository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(user)
+	if err != nil {
+		logrus.Errorf("Error in repository/cache.load -> error encoding user %s: %s", id, err)
+		return user, nil
+	}
+	if err := r.rc.Set(ctx, key(id), encoded, r.ttl).Err(); err != nil {
+		logrus.Errorf("Error in repository/cache.load -> error setting user %s: %s", id, err)
+	}
+
+	return user, nil
+}
+
+// selectFields - returns a user containing only the given fields (json names), the id and the version.
+// If there are no fields, the whole user is returned
+func selectFields(user models.User, fields []string) (*models.User, error) {
+	if len(fields) == 0 {
+		return &user, nil
+	}
+
+	selected, err := user.Select(fields)
+	if err != nil {
+		return nil, err
+	}
+	selected["version"] = user.Version
+	encoded, err := json.Marshal(selected)
+	if err != nil {
+		return nil, err
+	}
+	var res models.User
+	if err := json.Unmarshal(encoded, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func key(id string) string {
+	return keyPrefix + strings.ToLower(id)
+}
@@ -0,0 +1,404 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	"user-microservice/internal/users/mock"
+	"user-microservice/internal/users/pubsub"
+	"user-microservice/internal/users/repository/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newUser() models.User {
+	return models.User{
+		ID:        uuid.New().String(),
+		FirstName: "Cached FirstName",
+		LastName:  "Cached LastName",
+		Nickname:  "Cached Nickname",
+		Password:  "Cached Password",
+		Email:     "Cached Email",
+		Country:   "ES",
+		CreatedAt: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2022, 10, 2, 12, 0, 0, 0, time.UTC),
+		Version:   2,
+	}
+}
+
+// withoutPassword - returns the user as it's returned by the cache, without the password
+func withoutPassword(user models.User) *models.User {
+	user.Password = ""
+	return &user
+}
+
+// counterValue - returns the value of the registered counter with the given name
+func counterValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	require.NoErrorf(t, err, "Expected no error when gathering metrics, but was %s", err)
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestCachedRepository_GetById(t *testing.T) {
+	user := newUser()
+	publicUser := withoutPassword(user)
+	for _, tc := range []struct {
+		name           string
+		fields         []string
+		mockedUser     *models.User
+		mockedError    error
+		expectedUser   *models.User
+		expectedError  error
+		expectedCalls  int
+		expectedHits   float64
+		expectedMisses float64
+	}{
+		{
+			"Get user from cache after the first retrieval",
+			nil,
+			&user,
+			nil,
+			publicUser,
+			nil,
+			1,
+			1,
+			1,
+		},
+		{
+			"Get cached user with fields",
+			[]string{"nickname", "country"},
+			&user,
+			nil,
+			&models.User{ID: user.ID, Nickname: user.Nickname, Country: user.Country, Version: user.Version},
+			nil,
+			1,
+			1,
+			1,
+		},
+		{
+			"Get not found user is not cached",
+			nil,
+			nil,
+			mongo.ErrNoDocuments,
+			nil,
+			mongo.ErrNoDocuments,
+			2,
+			0,
+			2,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mr := miniredis.RunT(t)
+			rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			reg := prometheus.NewRegistry()
+			cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(reg))
+			ctx := context.TODO()
+
+			userRepo.EXPECT().GetById(gomock.Any(), user.ID).Return(tc.mockedUser, tc.mockedError).Times(tc.expectedCalls)
+
+			// When
+			first, firstErr := cachedRepo.GetById(ctx, user.ID, tc.fields...)
+			res, err := cachedRepo.GetById(ctx, user.ID, tc.fields...)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Equalf(t, tc.expectedError, firstErr, "Expected error to be %s, but was %s", tc.expectedError, firstErr)
+				assert.Equalf(t, tc.expectedError, err, "Expected error to be %s, but was %s", tc.expectedError, err)
+				assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+			} else {
+				require.NoError(t, firstErr)
+				require.NoError(t, err)
+				assert.Equalf(t, tc.expectedUser, first, "Expected loaded user to be %v, but was %v", tc.expectedUser, first)
+				assert.Equalf(t, tc.expectedUser, res, "Expected user to be %v, but was %v", tc.expectedUser, res)
+			}
+			hits := counterValue(t, reg, "users_cache_hits_total")
+			misses := counterValue(t, reg, "users_cache_misses_total")
+			assert.Equalf(t, tc.expectedHits, hits, "Expected hits to be %v, but were %v", tc.expectedHits, hits)
+			assert.Equalf(t, tc.expectedMisses, misses, "Expected misses to be %v, but were %v", tc.expectedMisses, misses)
+		})
+	}
+}
+
+func TestCachedRepository_GetByIdWithoutRedis(t *testing.T) {
+	// Given
+	user := newUser()
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	mr.Close()
+	ctrl := gomock.NewController(t)
+	userRepo := mock.NewMockRepository(ctrl)
+	cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(prometheus.NewRegistry()))
+
+	userRepo.EXPECT().GetById(gomock.Any(), user.ID).Return(&user, nil).Times(1)
+
+	// When
+	res, err := cachedRepo.GetById(context.TODO(), user.ID)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equalf(t, withoutPassword(user), res, "Expected user to be %v, but was %v", withoutPassword(user), res)
+}
+
+func TestCachedRepository_GetByIdConcurrently(t *testing.T) {
+	// Given
+	user := newUser()
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctrl := gomock.NewController(t)
+	userRepo := mock.NewMockRepository(ctrl)
+	cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(prometheus.NewRegistry()))
+
+	userRepo.EXPECT().GetById(gomock.Any(), user.ID).DoAndReturn(func(context.Context, string, ...string) (*models.User, error) {
+		time.Sleep(100 * time.Millisecond)
+		return &user, nil
+	}).Times(1)
+
+	// When
+	var wg sync.WaitGroup
+	results := make([]*models.User, 10)
+	errs := make([]error, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = cachedRepo.GetById(context.TODO(), user.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	// Then
+	for i := range results {
+		require.NoError(t, errs[i])
+		assert.Equalf(t, withoutPassword(user), results[i], "Expected user to be %v, but was %v", withoutPassword(user), results[i])
+	}
+}
+
+func TestCachedRepository_GetByIdConcurrentlyWithCancelledCaller(t *testing.T) {
+	// Given
+	user := newUser()
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctrl := gomock.NewController(t)
+	userRepo := mock.NewMockRepository(ctrl)
+	cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(prometheus.NewRegistry()))
+
+	loading := make(chan struct{})
+	userRepo.EXPECT().GetById(gomock.Any(), user.ID).DoAndReturn(func(ctx context.Context, _ string, _ ...string) (*models.User, error) {
+		close(loading)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return &user, nil
+		}
+	}).Times(1)
+
+	// When
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, err := cachedRepo.GetById(cancelledCtx, user.ID)
+		cancelledErr <- err
+	}()
+	<-loading
+	waiting := make(chan *models.User, 1)
+	go func() {
+		found, err := cachedRepo.GetById(context.TODO(), user.ID)
+		assert.NoError(t, err)
+		waiting <- found
+	}()
+	cancel()
+
+	// Then
+	assert.ErrorIsf(t, <-cancelledErr, context.Canceled, "Expected the cancelled caller to return its context error")
+	assert.Equalf(t, withoutPassword(user), <-waiting, "Expected the other caller to get the user")
+}
+
+func TestCachedRepository_Evict(t *testing.T) {
+	user := newUser()
+	for _, tc := range []struct {
+		name   string
+		expect func(userRepo *mock.MockRepository)
+		modify func(ctx context.Context, repo users.Repository) error
+	}{
+		{
+			"Evict updated user",
+			func(userRepo *mock.MockRepository) {
+				userRepo.EXPECT().Update(gomock.Any(), user).Return(&user, nil)
+			},
+			func(ctx context.Context, repo users.Repository) error {
+				_, err := repo.Update(ctx, user)
+				return err
+			},
+		},
+		{
+			"Evict user with update error",
+			func(userRepo *mock.MockRepository) {
+				userRepo.EXPECT().Update(gomock.Any(), user).Return(nil, mongo.ErrNoDocuments)
+			},
+			func(ctx context.Context, repo users.Repository) error {
+				_, _ = repo.Update(ctx, user)
+				return nil
+			},
+		},
+		{
+			"Evict deleted user",
+			func(userRepo *mock.MockRepository) {
+				userRepo.EXPECT().DeleteById(gomock.Any(), user.ID).Return(nil)
+			},
+			func(ctx context.Context, repo users.Repository) error {
+				return repo.DeleteById(ctx, user.ID)
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mr := miniredis.RunT(t)
+			rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(prometheus.NewRegistry()))
+			ctx := context.TODO()
+
+			userRepo.EXPECT().GetById(gomock.Any(), user.ID).Return(&user, nil).Times(2)
+			tc.expect(userRepo)
+			_, err := cachedRepo.GetById(ctx, user.ID)
+			require.NoError(t, err)
+
+			// When
+			err = tc.modify(ctx, cachedRepo)
+
+			// Then
+			require.NoError(t, err)
+			// the user is retrieved again from the repository
+			_, err = cachedRepo.GetById(ctx, user.ID)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCachedRepository_ListenInvalidations(t *testing.T) {
+	user := newUser()
+	encodedUser, err := json.Marshal(user)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		name    string
+		topic   string
+		payload string
+	}{
+		{
+			"Invalidate user on update event",
+			pubsub.TopicUserUpdate,
+			string(encodedUser),
+		},
+		{
+			"Invalidate user on deletion event",
+			pubsub.TopicUserDeletion,
+			user.ID,
+		},
+		{
+			"Invalidate user on purge event",
+			pubsub.TopicUserPurge,
+			user.ID,
+		},
+		{
+			"Invalidate user on email verification event",
+			pubsub.TopicUserEmailVerified,
+			string(encodedUser),
+		},
+		{
+			"Invalidate user on status change event",
+			pubsub.TopicUserStatusChanged,
+			string(encodedUser),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mr := miniredis.RunT(t)
+			rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			ctrl := gomock.NewController(t)
+			userRepo := mock.NewMockRepository(ctrl)
+			cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(prometheus.NewRegistry()))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			userRepo.EXPECT().GetById(gomock.Any(), user.ID).Return(&user, nil).Times(1)
+			_, err := cachedRepo.GetById(ctx, user.ID)
+			require.NoError(t, err)
+			require.Lenf(t, mr.Keys(), 1, "Expected the user to be cached, but the keys were %v", mr.Keys())
+
+			go cachedRepo.ListenInvalidations(ctx)
+			require.Eventuallyf(t, func() bool {
+				return mr.PubSubNumSub(tc.topic)[tc.topic] == 1
+			}, time.Second, 10*time.Millisecond, "Expected the cache to be subscribed to %s", tc.topic)
+
+			// When
+			mr.Publish(tc.topic, tc.payload)
+
+			// Then
+			assert.Eventuallyf(t, func() bool {
+				return len(mr.Keys()) == 0
+			}, time.Second, 10*time.Millisecond, "Expected the user to be evicted, but the keys were %v", mr.Keys())
+		})
+	}
+}
+
+func TestCachedRepository_ListenInvalidationsWithUnavailableRedis(t *testing.T) {
+	// Given
+	user := newUser()
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctrl := gomock.NewController(t)
+	userRepo := mock.NewMockRepository(ctrl)
+	cachedRepo := cache.NewCachedRepository(userRepo, rc, time.Minute, cache.NewMetrics(prometheus.NewRegistry()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	userRepo.EXPECT().GetById(gomock.Any(), user.ID).Return(&user, nil).Times(1)
+	_, err := cachedRepo.GetById(ctx, user.ID)
+	require.NoError(t, err)
+	mr.Close()
+
+	// When
+	go cachedRepo.ListenInvalidations(ctx)
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, mr.Restart())
+
+	// Then
+	require.Eventuallyf(t, func() bool {
+		return mr.PubSubNumSub(pubsub.TopicUserDeletion)[pubsub.TopicUserDeletion] == 1
+	}, 5*time.Second, 10*time.Millisecond, "Expected the cache to subscribe again once redis is available")
+	mr.Publish(pubsub.TopicUserDeletion, user.ID)
+	assert.Eventuallyf(t, func() bool {
+		return len(mr.Keys()) == 0
+	}, time.Second, 10*time.Millisecond, "Expected the user to be evicted, but the keys were %v", mr.Keys())
+}
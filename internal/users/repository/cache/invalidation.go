@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"user-microservice/internal/users/pubsub"
+)
+
+// invalidationTopics - users topics whose events make the cached user stale
+var invalidationTopics = []string{
+	pubsub.TopicUserUpdate,
+	pubsub.TopicUserDeletion,
+	pubsub.TopicUserRestore,
+	pubsub.TopicUserPurge,
+	pubsub.TopicUserEmailVerified,
+	pubsub.TopicUserStatusChanged,
+}
+
+// ListenInvalidations - evicts the users of the received users events until the context is done,
+// so the users modified by other replicas or services are not served stale. It subscribes again with
+// an exponential backoff if the subscription fails (e.g. redis is unavailable on start) or is lost
+func (r *cachedRepository) ListenInvalidations(ctx context.Context) {
+	pubsub.Listen(ctx, pubsub.NewPubSub(r.rc), "users cache", func(event pubsub.Event) {
+		r.Evict(ctx, event.UserID)
+	}, invalidationTopics...)
+}
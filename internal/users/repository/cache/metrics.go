@@ -0,0 +1,31 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics - prometheus metrics of the users cache
+type Metrics struct {
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evictions prometheus.Counter
+}
+
+// NewMetrics - returns the cache metrics, registered in the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "users_cache_hits_total",
+			Help: "Number of users retrieved from the cache",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "users_cache_misses_total",
+			Help: "Number of users not found in the cache",
+		}),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "users_cache_evictions_total",
+			Help: "Number of users evicted from the cache",
+		}),
+	}
+	reg.MustRegister(m.hits, m.misses, m.evictions)
+
+	return m
+}
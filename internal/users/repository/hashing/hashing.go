@@ -0,0 +1,93 @@
+package hashing
+
+import (
+	"context"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	"user-microservice/internal/users/sec"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SessionsRevoker - revokes the sessions of the users whose password changed
+type SessionsRevoker interface {
+	RevokeAll(ctx context.Context, userID string) error
+}
+
+// hashingRepository - users.Repository decorator storing the passwords hashed with bcrypt.
+// The given passwords are always taken as plain ones, except in the updates keeping the stored hash
+type hashingRepository struct {
+	users.Repository
+	sessions SessionsRevoker
+}
+
+var _ users.Repository = (*hashingRepository)(nil)
+
+// NewHashingRepository - returns a new users.Repository hashing the passwords before storing them in the given repository.
+// The sessions of a user are revoked when its password changes, if there is a sessions revoker
+func NewHashingRepository(repository users.Repository, sessions SessionsRevoker) users.Repository {
+	return &hashingRepository{repository, sessions}
+}
+
+// Create - hashes the password and creates the user
+func (r *hashingRepository) Create(ctx context.Context, user models.User) (*models.User, error) {
+	if err := hashPassword(&user); err != nil {
+		return nil, err
+	}
+	return r.Repository.Create(ctx, user)
+}
+
+// CreateMany - hashes the passwords and creates the users
+func (r *hashingRepository) CreateMany(ctx context.Context, users []models.User) ([]models.User, error) {
+	hashed := make([]models.User, len(users))
+	copy(hashed, users)
+	for i := range hashed {
+		if err := hashPassword(&hashed[i]); err != nil {
+			return nil, err
+		}
+	}
+	return r.Repository.CreateMany(ctx, hashed)
+}
+
+// Update - hashes the password if it changed and updates the user, revoking its sessions. An empty password or the
+// stored hash (read and written back by the caller) keep the stored password, any other value is a new plain password
+func (r *hashingRepository) Update(ctx context.Context, user models.User) (*models.User, error) {
+	changed := false
+	if user.Password != "" {
+		stored, err := r.Repository.GetById(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Password != stored.Password {
+			if err := hashPassword(&user); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+	}
+
+	updated, err := r.Repository.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if changed && r.sessions != nil {
+		if err := r.sessions.RevokeAll(ctx, user.ID); err != nil {
+			logrus.Errorf("Error in repository/hashing.Update -> error revoking sessions of user %s: %s", user.ID, err)
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
+func hashPassword(user *models.User) error {
+	if user.Password == "" {
+		return nil
+	}
+	hashed, err := sec.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	return nil
+}
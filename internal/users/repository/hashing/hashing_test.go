@@ -0,0 +1,171 @@
+package hashing_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"user-microservice/internal/models"
+	sessionsMock "user-microservice/internal/sessions/mock"
+	"user-microservice/internal/users/mock"
+	"user-microservice/internal/users/repository/hashing"
+	"user-microservice/internal/users/sec"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	sec.Cost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+func TestHashingRepository_Create(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := mock.NewMockRepository(ctrl)
+	repo := hashing.NewHashingRepository(usersRepo, nil)
+	var stored models.User
+	usersRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (*models.User, error) {
+		stored = user
+		return &user, nil
+	})
+
+	// When
+	_, err := repo.Create(context.Background(), models.User{Nickname: "atingo", Password: "Created Password"})
+
+	// Then
+	require.NoError(t, err)
+	assert.Truef(t, sec.IsHashed(stored.Password), "Expected stored password to be hashed, but was %s", stored.Password)
+	assert.True(t, sec.CheckPassword(stored.Password, "Created Password"), "Expected stored password to match the created one")
+}
+
+func TestHashingRepository_CreateMany(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := mock.NewMockRepository(ctrl)
+	repo := hashing.NewHashingRepository(usersRepo, nil)
+	toCreate := []models.User{{Nickname: "first", Password: "First Password"}, {Nickname: "second", Password: "Second Password"}}
+	usersRepo.EXPECT().CreateMany(gomock.Any(), gomock.Len(2)).DoAndReturn(func(_ context.Context, created []models.User) ([]models.User, error) {
+		return created, nil
+	})
+
+	// When
+	res, err := repo.CreateMany(context.Background(), toCreate)
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	for i, user := range res {
+		assert.Truef(t, sec.CheckPassword(user.Password, toCreate[i].Password), "Expected password %d to match %s", i, toCreate[i].Password)
+	}
+	assert.Equal(t, "First Password", toCreate[0].Password, "Expected the given users not to be modified")
+}
+
+func TestHashingRepository_Update(t *testing.T) {
+	hashed, err := sec.HashPassword("Current Password")
+	require.NoError(t, err)
+	forged, err := sec.HashPassword("Forged Password")
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		name             string
+		password         string
+		expectedStored   string
+		expectedPassword string
+		expectedRevoked  bool
+	}{
+		{
+			"Update user with a new password",
+			"Updated Password",
+			"",
+			"Updated Password",
+			true,
+		},
+		{
+			"Update user keeping the stored hash",
+			hashed,
+			hashed,
+			"Current Password",
+			false,
+		},
+		{
+			"Update user with an empty password",
+			"",
+			"",
+			"",
+			false,
+		},
+		{
+			"Update user with a hash made by the client",
+			forged,
+			"",
+			forged,
+			true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			usersRepo := mock.NewMockRepository(ctrl)
+			sessionsRepo := sessionsMock.NewMockRepository(ctrl)
+			repo := hashing.NewHashingRepository(usersRepo, sessionsRepo)
+			userID := "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+			usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(&models.User{ID: userID, Password: hashed}, nil).MaxTimes(1)
+			var stored models.User
+			usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (*models.User, error) {
+				stored = user
+				return &user, nil
+			})
+			revokedTimes := 0
+			if tc.expectedRevoked {
+				revokedTimes = 1
+			}
+			sessionsRepo.EXPECT().RevokeAll(gomock.Any(), userID).Return(nil).Times(revokedTimes)
+
+			// When
+			_, err := repo.Update(context.Background(), models.User{ID: userID, Password: tc.password})
+
+			// Then
+			require.NoError(t, err)
+			switch {
+			case tc.expectedStored != "":
+				assert.Equal(t, tc.expectedStored, stored.Password, "Expected the stored hash not to be hashed again")
+			case tc.expectedPassword == "":
+				assert.Empty(t, stored.Password, "Expected the password to be kept empty, so the stored one is kept")
+			default:
+				assert.Truef(t, sec.CheckPassword(stored.Password, tc.expectedPassword), "Expected stored password to match %s", tc.expectedPassword)
+			}
+		})
+	}
+}
+
+func TestHashingRepository_UpdateRevokingSessionsFails(t *testing.T) {
+	// Given
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	usersRepo := mock.NewMockRepository(ctrl)
+	sessionsRepo := sessionsMock.NewMockRepository(ctrl)
+	repo := hashing.NewHashingRepository(usersRepo, sessionsRepo)
+	userID := "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	revokeErr := errors.New("revoke error")
+	usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(&models.User{ID: userID}, nil)
+	usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (*models.User, error) {
+		return &user, nil
+	})
+	sessionsRepo.EXPECT().RevokeAll(gomock.Any(), userID).Return(revokeErr)
+
+	// When
+	res, err := repo.Update(context.Background(), models.User{ID: userID, Password: "Updated Password"})
+
+	// Then
+	assert.ErrorIsf(t, err, revokeErr, "Expected error to be %s, but was %s", revokeErr, err)
+	assert.Nilf(t, res, "Expected res to be nil, but was %v", res)
+}
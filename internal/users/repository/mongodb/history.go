@@ -0,0 +1,102 @@
+package mongodb
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/models"
+	"user-microservice/internal/pagination"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// historyCollection - append-only collection with the audit entries of the users changes
+const historyCollection = "users_history"
+
+// GetUserHistory - returns the paginated audit entries of the user, the newest first
+func (r mongodbRepository) GetUserHistory(ctx context.Context, userID string, pag pagination.PaginationOptions) (models.PaginatedAuditEntries, error) {
+	pageSize := pag.Size
+	if pageSize <= 0 {
+		pageSize = pagination.DefaultSize
+	}
+	currentPage := pag.Page
+	if currentPage <= 0 {
+		currentPage = pagination.FirstPage
+	}
+
+	res := models.PaginatedAuditEntries{
+		Paginated: pagination.Paginated{
+			Size:        pageSize,
+			CurrentPage: currentPage,
+		},
+		Entries: []models.AuditEntry{},
+	}
+
+	filter := bson.M{"user_id": strings.ToLower(userID)}
+	totalCount, err := r.history.CountDocuments(ctx, filter)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetUserHistory -> error executing count command: %s", err)
+		return res, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(pageSize)).
+		SetSkip((int64(currentPage) - 1) * int64(pageSize))
+	cursor, err := r.history.Find(ctx, filter, findOptions)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetUserHistory -> error executing find command: %s", err)
+		return res, err
+	}
+	if err := cursor.All(ctx, &res.Entries); err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetUserHistory -> error decoding cursor: %s", err)
+		return res, err
+	}
+
+	res.TotalCount = totalCount
+	res.TotalPages = int64(math.Ceil(float64(totalCount) / float64(pageSize)))
+	res.HasMore = int64(currentPage) < res.TotalPages
+
+	return res, nil
+}
+
+// audit - inserts the audit entry, should be called inside the transaction making the change
+func (r mongodbRepository) audit(sc mongo.SessionContext, entry models.AuditEntry) error {
+	_, err := r.history.InsertOne(sc, entry)
+	return err
+}
+
+// withTransaction - executes fn inside a transaction, so all its writes are made atomically.
+// fn can be executed several times if the transaction is retried
+func (r mongodbRepository) withTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := r.db.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// newAuditEntry - returns a new audit entry with the actor and request ID from the context
+func newAuditEntry(ctx context.Context, userID string, action models.AuditAction, changes []models.FieldChange) models.AuditEntry {
+	info := audit.FromContext(ctx)
+	return models.AuditEntry{
+		ID:        strings.ToLower(uuid.New().String()),
+		UserID:    userID,
+		Action:    action,
+		Actor:     info.Actor,
+		RequestID: info.RequestID,
+		Changes:   changes,
+		Timestamp: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// emailIndexKeys - keys of the email index, the emails are unique among the not deleted users (without deleted_at)
+var emailIndexKeys = bson.D{{Key: "email", Value: 1}, {Key: "deleted_at", Value: 1}}
+
+// EnsureIndexes - creates the indexes the repository relies on, like the unique email one, if they do not exist.
+// It can be run at every start, but it fails if an index exists with other options or the stored users break it
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection(mongodbCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: emailIndexKeys,
+		Options: options.Index().
+			SetName(emailIndex).
+			SetUnique(true).
+			SetCollation(emailCollation).
+			SetPartialFilterExpression(bson.M{"email": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.EnsureIndexes -> error creating %s index: %s", emailIndex, err)
+		return err
+	}
+
+	return nil
+}
+
+// isEmailIndexKeyPattern - returns true if the key pattern of the server error is the one of the email index
+func isEmailIndexKeyPattern(raw bson.Raw) bool {
+	var res struct {
+		KeyPattern bson.D `bson:"keyPattern"`
+	}
+	if len(raw) == 0 || bson.Unmarshal(raw, &res) != nil || len(res.KeyPattern) != len(emailIndexKeys) {
+		return false
+	}
+	for i, key := range emailIndexKeys {
+		if res.KeyPattern[i].Key != key.Key {
+			return false
+		}
+	}
+	return true
+}
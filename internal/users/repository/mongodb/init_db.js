@@ -76,4 +76,43 @@ db.users.insertMany([
     created_at: new Date("2018-05-18T16:00:00Z"),
     updated_at: new Date("2018-05-18T16:00:00Z"),
   },
+  {
+    _id: "0e0b5a3c-6f1d-4d7e-9b8a-2c3d4e5f6a78",
+    first_name: "Deleted user first name 8",
+    last_name: "Deleted user last name 8",
+    nickname: "Deleted user nickname 8",
+    password: "Deleted user password 8",
+    email: "Deleted user email 8",
+    country: "Deleted user country 8",
+    created_at: new Date("2018-05-18T16:00:00Z"),
+    updated_at: new Date("2022-05-18T16:00:00Z"),
+    deleted_at: new Date("2022-05-18T16:00:00Z"),
+    version: 2,
+  },
+  {
+    _id: "1f2a3b4c-5d6e-4f70-8a9b-0c1d2e3f4a59",
+    first_name: "User to purge first name 9",
+    last_name: "User to purge last name 9",
+    nickname: "User to purge nickname 9",
+    password: "User to purge password 9",
+    email: "User to purge email 9",
+    country: "User to purge country 9",
+    created_at: new Date("2018-05-18T16:00:00Z"),
+    updated_at: new Date("2019-05-18T16:00:00Z"),
+    deleted_at: new Date("2019-05-18T16:00:00Z"),
+    version: 2,
+  },
+  {
+    _id: "2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d",
+    first_name: "Deleted user first name 10",
+    last_name: "Deleted user last name 10",
+    nickname: "Deleted user nickname 10",
+    password: "Deleted user password 10",
+    email: "Deleted user email 10",
+    country: "Deleted user country 10",
+    created_at: new Date("2018-05-18T16:00:00Z"),
+    updated_at: new Date("2021-05-18T16:00:00Z"),
+    deleted_at: new Date("2021-05-18T16:00:00Z"),
+    version: 2,
+  },
 ]);
@@ -2,6 +2,8 @@ package mongodb
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"strings"
 	"time"
@@ -16,10 +18,24 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const mongodbCollection = "users"
+const (
+	mongodbCollection = "users"
+	// streamBatchSize - number of documents retrieved on each cursor batch when streaming
+	streamBatchSize = 1000
+	// purgeBatchSize - maximum number of users purged in each transaction
+	purgeBatchSize = 500
+	// emailIndex - name of the unique index of the not deleted users emails
+	emailIndex = "email_unique"
+	// duplicateKeyCode - code of the write errors caused by a unique index
+	duplicateKeyCode = 11000
+)
+
+// emailCollation - collation of the email index, which compares the emails ignoring the case
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
 
 type mongodbRepository struct {
-	db *mongo.Collection
+	db      *mongo.Collection
+	history *mongo.Collection
 }
 
 var _ users.Repository = mongodbRepository{}
@@ -27,27 +43,152 @@ var _ users.Repository = (*mongodbRepository)(nil)
 
 // NewMongoDBRepository - returns a new instance for the mongodb repository
 func NewMongoDBRepository(db *mongo.Database) users.Repository {
-	return &mongodbRepository{db.Collection(mongodbCollection)}
+	return &mongodbRepository{db.Collection(mongodbCollection), db.Collection(historyCollection)}
 }
 
-// Create - inserts the user into the database and returns the updated version
+// Create - inserts the user into the database along with its audit entry and returns the updated version
 func (r mongodbRepository) Create(ctx context.Context, user models.User) (*models.User, error) {
 	user.ID = strings.ToLower(uuid.New().String())
 	user.CreatedAt = time.Now().UTC()
 	user.UpdatedAt = time.Now().UTC()
+	user.Version = 1
 
-	if _, err := r.db.InsertOne(ctx, &user); err != nil {
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := r.db.InsertOne(sc, &user); err != nil {
+			return err
+		}
+		return r.audit(sc, newAuditEntry(ctx, user.ID, models.AuditActionCreate, models.DiffUsers(nil, user)))
+	})
+	if err != nil {
 		logrus.Errorf("Error in repository/mongodb.Create -> error: %s", err)
-		return nil, err
+		return nil, duplicateEmail(err)
 	}
 
 	return &user, nil
 }
 
-// GetById - retrieves the user with the given ID
-func (r mongodbRepository) GetById(ctx context.Context, id string) (*models.User, error) {
+// CreateMany - inserts the users into the database along with their audit entries and returns the
+// inserted ones. The users whose email is already in use are discarded before inserting the rest of them
+// in a single transaction. If a user still cannot be inserted (e.g. its email was taken meanwhile), it's
+// discarded and the rest of them are retried. If some of them could not be inserted, a *users.BulkError
+// is returned along with the inserted users
+func (r mongodbRepository) CreateMany(ctx context.Context, usersToCreate []models.User) ([]models.User, error) {
+	if len(usersToCreate) == 0 {
+		return []models.User{}, nil
+	}
+
+	now := time.Now().UTC()
+	for i := range usersToCreate {
+		usersToCreate[i].ID = strings.ToLower(uuid.New().String())
+		usersToCreate[i].CreatedAt = now
+		usersToCreate[i].UpdatedAt = now
+		usersToCreate[i].Version = 1
+	}
+
+	duplicates, err := r.duplicateEmails(ctx, usersToCreate)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.CreateMany -> error checking emails: %s", err)
+		return nil, err
+	}
+	bulkErr := &users.BulkError{Errors: duplicates}
+	// indexes of the users still to insert
+	pending := make([]int, 0, len(usersToCreate))
+	for i := range usersToCreate {
+		if _, failed := bulkErr.Errors[i]; !failed {
+			pending = append(pending, i)
+		}
+	}
+	for len(pending) > 0 {
+		docs := make([]interface{}, 0, len(pending))
+		entries := make([]interface{}, 0, len(pending))
+		for _, i := range pending {
+			docs = append(docs, usersToCreate[i])
+			entries = append(entries, newAuditEntry(ctx, usersToCreate[i].ID, models.AuditActionCreate, models.DiffUsers(nil, usersToCreate[i])))
+		}
+
+		var insertErr error
+		err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+			if _, insertErr = r.db.InsertMany(sc, docs); insertErr != nil {
+				return insertErr
+			}
+			_, err := r.history.InsertMany(sc, entries)
+			return err
+		})
+		if err == nil {
+			break
+		}
+
+		// an ordered insert stops at the first failed user, which is discarded before retrying
+		bulkException, isOK := insertErr.(mongo.BulkWriteException)
+		if !isOK || len(bulkException.WriteErrors) == 0 {
+			logrus.Errorf("Error in repository/mongodb.CreateMany -> error: %s", err)
+			return nil, err
+		}
+		failed := bulkException.WriteErrors[0].Index
+		bulkErr.Errors[pending[failed]] = duplicateEmail(bulkException.WriteErrors[0])
+		pending = append(pending[:failed], pending[failed+1:]...)
+	}
+
+	if len(bulkErr.Errors) == 0 {
+		return usersToCreate, nil
+	}
+	created := make([]models.User, 0, len(usersToCreate)-len(bulkErr.Errors))
+	for i, user := range usersToCreate {
+		if _, failed := bulkErr.Errors[i]; !failed {
+			created = append(created, user)
+		}
+	}
+
+	return created, bulkErr
+}
+
+// duplicateEmails - returns the errors of the users whose email, ignoring the case like the email index, is used
+// by a not deleted user or by a previous user of the slice, by their index
+func (r mongodbRepository) duplicateEmails(ctx context.Context, usersToCreate []models.User) (map[int]error, error) {
+	errs := map[int]error{}
+	emails := make([]string, 0, len(usersToCreate))
+	repeated := map[string]bool{}
+	for i, user := range usersToCreate {
+		email := strings.ToLower(user.Email)
+		if repeated[email] {
+			errs[i] = fmt.Errorf("%w: %s is repeated", users.ErrDuplicateEmail, user.Email)
+			continue
+		}
+		repeated[email] = true
+		emails = append(emails, user.Email)
+	}
+
+	opts := options.Find().SetProjection(bson.M{"email": 1}).SetCollation(emailCollation)
+	cursor, err := r.db.Find(ctx, notDeleted(bson.M{"email": bson.M{"$in": emails}}), opts)
+	if err != nil {
+		return nil, err
+	}
+	var existing []models.User
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool, len(existing))
+	for _, user := range existing {
+		used[strings.ToLower(user.Email)] = true
+	}
+	for i, user := range usersToCreate {
+		if _, failed := errs[i]; !failed && used[strings.ToLower(user.Email)] {
+			errs[i] = fmt.Errorf("%w: %s", users.ErrDuplicateEmail, user.Email)
+		}
+	}
+
+	return errs, nil
+}
+
+// GetById - retrieves the user with the given ID, the soft deleted users are not retrieved
+func (r mongodbRepository) GetById(ctx context.Context, id string, fields ...string) (*models.User, error) {
+	findOptions := options.FindOne()
+	if projection := models.UserFields(fields).Projection(); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+
 	var res models.User
-	if err := r.db.FindOne(ctx, bson.M{"_id": strings.ToLower(id)}).Decode(&res); err != nil {
+	if err := r.db.FindOne(ctx, notDeleted(bson.M{"_id": strings.ToLower(id)}), findOptions).Decode(&res); err != nil {
 		if err != mongo.ErrNoDocuments {
 			logrus.Errorf("Error in repository/mongodb.GetById -> error: %s", err)
 		}
@@ -57,39 +198,297 @@ func (r mongodbRepository) GetById(ctx context.Context, id string) (*models.User
 	return &res, nil
 }
 
-// Update - updates the user in the DB and returns the updated version
-func (r mongodbRepository) Update(ctx context.Context, user models.User) (*models.User, error) {
-	user.UpdatedAt = time.Now().UTC()
-	if _, err := r.db.ReplaceOne(ctx, bson.M{"_id": user.ID}, user); err != nil {
+// GetByEmail - retrieves the not deleted user with the given email, ignoring the case
+func (r mongodbRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var res models.User
+	opts := options.FindOne().SetCollation(emailCollation)
+	if err := r.db.FindOne(ctx, notDeleted(bson.M{"email": email}), opts).Decode(&res); err != nil {
 		if err != mongo.ErrNoDocuments {
-			logrus.Errorf("Error in repository/mongodb.Update -> error updating document: %s", err)
+			logrus.Errorf("Error in repository/mongodb.GetByEmail -> error: %s", err)
 		}
 		return nil, err
 	}
 
-	var res models.User
-	if err := r.db.FindOne(ctx, bson.M{"_id": user.ID}).Decode(&res); err != nil {
+	return &res, nil
+}
+
+// GetMFA - retrieves the second factor of the not deleted user with the given ID, nil if it did not enroll one
+func (r mongodbRepository) GetMFA(ctx context.Context, id string) (*models.MFA, error) {
+	var res struct {
+		MFA *models.MFA `bson:"mfa"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"mfa": 1})
+	if err := r.db.FindOne(ctx, notDeleted(bson.M{"_id": strings.ToLower(id)}), opts).Decode(&res); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in repository/mongodb.GetMFA -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return res.MFA, nil
+}
+
+// SetMFA - sets the second factor of the not deleted user with the given ID, removing it if nil
+func (r mongodbRepository) SetMFA(ctx context.Context, id string, mfa *models.MFA) error {
+	update := bson.M{"$set": bson.M{"mfa": mfa}}
+	if mfa == nil {
+		update = bson.M{"$unset": bson.M{"mfa": ""}}
+	}
+	res, err := r.db.UpdateOne(ctx, notDeleted(bson.M{"_id": strings.ToLower(id)}), update)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.SetMFA -> error: %s", err)
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// UseMFAStep - sets the last used TOTP step of the enabled second factor of the not deleted user with the given ID
+// in a single conditional update, so each step is only used once even by concurrent logins
+func (r mongodbRepository) UseMFAStep(ctx context.Context, id string, step int64) (bool, error) {
+	filter := notDeleted(bson.M{"_id": strings.ToLower(id), "mfa.enabled": true, "mfa.last_used_step": bson.M{"$lt": step}})
+	res, err := r.db.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"mfa.last_used_step": step}})
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.UseMFAStep -> error: %s", err)
+		return false, err
+	}
+
+	return res.MatchedCount == 1, nil
+}
+
+// UseMFARecoveryCode - removes the recovery code hash from the enabled second factor of the not deleted user with
+// the given ID in a single conditional update, so each code is only used once even by concurrent logins
+func (r mongodbRepository) UseMFARecoveryCode(ctx context.Context, id, hash string) (bool, error) {
+	filter := notDeleted(bson.M{"_id": strings.ToLower(id), "mfa.enabled": true, "mfa.recovery_codes": hash})
+	res, err := r.db.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"mfa.recovery_codes": hash}})
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.UseMFARecoveryCode -> error: %s", err)
+		return false, err
+	}
+
+	return res.MatchedCount == 1, nil
+}
+
+// GetByIDs - retrieves the users with the given IDs using a single query.
+// The result order is not guaranteed and the not found (or soft deleted) IDs are ignored
+func (r mongodbRepository) GetByIDs(ctx context.Context, ids []string, fields ...string) ([]models.User, error) {
+	lowerIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		lowerIDs = append(lowerIDs, strings.ToLower(id))
+	}
+
+	findOptions := options.Find()
+	if projection := models.UserFields(fields).Projection(); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+
+	cursor, err := r.db.Find(ctx, notDeleted(bson.M{"_id": bson.M{"$in": lowerIDs}}), findOptions)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetByIDs -> error executing find command: %s", err)
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetByIDs -> error decoding cursor: %s", err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Update - updates the user in the DB along with its audit entry and returns the updated version, keeping
+// the stored password if the user has none. The user is only updated if the stored version is the given one, otherwise a *users.VersionConflictError is returned
+func (r mongodbRepository) Update(ctx context.Context, user models.User) (*models.User, error) {
+	filter := notDeleted(bson.M{"_id": user.ID, "version": user.Version})
+	if user.Version == 0 {
+		// documents created before the versioning do not have the field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	expectedVersion := user.Version
+	// mongodb stores the dates with millisecond precision
+	user.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	user.Version++
+
+	// the user is replaced keeping its second factor, that is only modified by SetMFA, and its password
+	// if it's empty. $literal prevents the values starting with $ (e.g. the password hashes) from being taken as field paths
+	kept := bson.M{"mfa": "$mfa"}
+	if user.Password == "" {
+		kept["password"] = "$password"
+	}
+	replacement := mongo.Pipeline{{{Key: "$replaceWith", Value: bson.M{
+		"$mergeObjects": bson.A{bson.M{"$literal": user}, kept},
+	}}}}
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var old models.User
+		if err := r.db.FindOneAndUpdate(sc, filter, replacement).Decode(&old); err != nil {
+			return err
+		}
+		if user.Password == "" {
+			user.Password = old.Password
+		}
+		return r.audit(sc, newAuditEntry(ctx, user.ID, models.AuditActionUpdate, models.DiffUsers(&old, user)))
+	})
+	if err == nil {
+		return &user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		logrus.Errorf("Error in repository/mongodb.Update -> error updating document: %s", err)
+		return nil, duplicateEmail(err)
+	}
+
+	// the user does not exist or its version is not the expected one
+	var current models.User
+	if err := r.db.FindOne(ctx, notDeleted(bson.M{"_id": user.ID}), options.FindOne().SetProjection(bson.M{"version": 1})).Decode(&current); err != nil {
 		if err != mongo.ErrNoDocuments {
 			logrus.Errorf("Error in repository/mongodb.Update -> error retrieving document: %s", err)
 		}
 		return nil, err
 	}
 
-	return &res, nil
+	return nil, &users.VersionConflictError{ID: user.ID, Expected: expectedVersion, Current: current.Version}
 }
 
-// DeleteById - removes the user with the given ID from the DB
+// DeleteById - soft deletes the user with the given ID setting its deleted_at, and records its audit entry.
+// Returns mongo.ErrNoDocuments if there is no user (not deleted) with the ID
 func (r mongodbRepository) DeleteById(ctx context.Context, id string) error {
-	if _, err := r.db.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+	id = strings.ToLower(id)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		res, err := r.db.UpdateOne(sc, notDeleted(bson.M{"_id": id}), bson.M{
+			"$set": bson.M{"deleted_at": now, "updated_at": now},
+			"$inc": bson.M{"version": 1},
+		})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		changes := []models.FieldChange{{Field: "deletedAt", Old: nil, New: now}}
+		return r.audit(sc, newAuditEntry(ctx, id, models.AuditActionDelete, changes))
+	})
+	if err != nil && err != mongo.ErrNoDocuments {
 		logrus.Errorf("Error in repository/mongodb.DeleteById -> error: %s", err)
+	}
+
+	return err
+}
+
+// Restore - removes the deleted_at of the soft deleted user with the given ID, records its audit entry and returns it.
+// Returns mongo.ErrNoDocuments if there is no deleted user with the ID
+func (r mongodbRepository) Restore(ctx context.Context, id string) (*models.User, error) {
+	filter := bson.M{"_id": strings.ToLower(id), "deleted_at": bson.M{"$ne": nil}}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": ""},
+		"$set":   bson.M{"updated_at": now},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	var res models.User
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var old models.User
+		if err := r.db.FindOneAndUpdate(sc, filter, update).Decode(&old); err != nil {
+			return err
+		}
+		res = old
+		res.DeletedAt = nil
+		res.UpdatedAt = now
+		res.Version++
+		return r.audit(sc, newAuditEntry(ctx, res.ID, models.AuditActionRestore, models.DiffUsers(&old, res)))
+	})
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in repository/mongodb.Restore -> error: %s", err)
+		}
+		return nil, duplicateEmail(err)
+	}
+
+	return &res, nil
+}
+
+// PurgeDeleted - permanently removes the users soft deleted before the given time, records their audit entries
+// and returns their IDs. The users are purged in batches of purgeBatchSize, each one in its own transaction, so
+// the transactions stay small. If a batch fails, the IDs of the previous ones are returned along with the error
+func (r mongodbRepository) PurgeDeleted(ctx context.Context, before time.Time) ([]string, error) {
+	ids := []string{}
+	for {
+		purged, err := r.purgeBatch(ctx, before)
+		ids = append(ids, purged...)
+		if err != nil {
+			logrus.Errorf("Error in repository/mongodb.PurgeDeleted -> error: %s", err)
+			return ids, err
+		}
+		if len(purged) < purgeBatchSize {
+			return ids, nil
+		}
+	}
+}
+
+// purgeBatch - permanently removes up to purgeBatchSize users soft deleted before the given time in a transaction,
+// records their audit entries and returns their IDs
+func (r mongodbRepository) purgeBatch(ctx context.Context, before time.Time) ([]string, error) {
+	var ids []string
+	err := r.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		filter := bson.M{"deleted_at": bson.M{"$lt": before}}
+		opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(purgeBatchSize)
+		cursor, err := r.db.Find(sc, filter, opts)
+		if err != nil {
+			return err
+		}
+		var deleted []models.User
+		if err := cursor.All(sc, &deleted); err != nil {
+			return err
+		}
+
+		ids = make([]string, 0, len(deleted))
+		entries := make([]interface{}, 0, len(deleted))
+		for _, user := range deleted {
+			ids = append(ids, user.ID)
+			entries = append(entries, newAuditEntry(ctx, user.ID, models.AuditActionPurge, []models.FieldChange{}))
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		filter["_id"] = bson.M{"$in": ids}
+		if _, err := r.db.DeleteMany(sc, filter); err != nil {
+			return err
+		}
+		_, err = r.history.InsertMany(sc, entries)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return ids, nil
 }
 
-// GetPaginatedUsers - returns a list of paginated user
-func (r mongodbRepository) GetPaginatedUsers(ctx context.Context, pag pagination.PaginationOptions, filters models.UserFilters) (models.PaginatedUsers, error) {
+// GetExpiredSuspensions - returns the not deleted suspended users whose suspension ended before the given time
+func (r mongodbRepository) GetExpiredSuspensions(ctx context.Context, before time.Time) ([]models.User, error) {
+	filter := notDeleted(bson.M{"status": models.StatusSuspended, "suspended_until": bson.M{"$lte": before}})
+	cursor, err := r.db.Find(ctx, filter)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetExpiredSuspensions -> error: %s", err)
+		return nil, err
+	}
+	res := []models.User{}
+	if err := cursor.All(ctx, &res); err != nil {
+		logrus.Errorf("Error in repository/mongodb.GetExpiredSuspensions -> error decoding users: %s", err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetPaginatedUsers - returns a list of paginated user.
+// The soft deleted users are excluded unless filters.IncludeDeleted is set
+func (r mongodbRepository) GetPaginatedUsers(ctx context.Context, pag pagination.PaginationOptions, filters models.UserFilters, fields ...string) (models.PaginatedUsers, error) {
 	pageSize := pag.Size
 	if pageSize <= 0 {
 		pageSize = pagination.DefaultSize
@@ -107,9 +506,10 @@ func (r mongodbRepository) GetPaginatedUsers(ctx context.Context, pag pagination
 	}
 
 	// filters := uFilters.ToBsonM()
+	filter := usersFilter(filters)
 
 	//count how many users are stored
-	totalCount, err := r.db.CountDocuments(ctx, filters)
+	totalCount, err := r.db.CountDocuments(ctx, filter)
 	if err != nil {
 		logrus.Errorf("Error in repository/mongodb.GetPaginatedUsers -> error executing count command: %s", err)
 		return res, nil
@@ -122,16 +522,20 @@ func (r mongodbRepository) GetPaginatedUsers(ctx context.Context, pag pagination
 	if skipValue < totalCount {
 		findOptions.SetSkip(skipValue)
 	}
-	// if pag.OrderBy != "" {
-	// 	sortOrder := 1
-	// 	if pag.SortOrder.IsDesc() {
-	// 		sortOrder = -1
-	// 	}
-	// 	findOptions.SetSort(bson.D{{Key: pag.OrderBy, Value: sortOrder}})
-	// }
+	if projection := models.UserFields(fields).Projection(); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+	// the id breaks the ties so the pages are stable
+	if orderBy, ok := models.UserSelectableFields[pag.OrderBy]; ok {
+		sortOrder := 1
+		if pag.SortOrder.IsDesc() {
+			sortOrder = -1
+		}
+		findOptions.SetSort(bson.D{{Key: orderBy, Value: sortOrder}, {Key: "_id", Value: sortOrder}})
+	}
 
 	// retrieve the users
-	cursor, err := r.db.Find(ctx, filters, findOptions)
+	cursor, err := r.db.Find(ctx, filter, findOptions)
 	if err != nil {
 		logrus.Errorf("Error in repository/mongodb.GetPaginatedUsers -> error executing find command: %s", err)
 		return res, nil
@@ -150,3 +554,117 @@ func (r mongodbRepository) GetPaginatedUsers(ctx context.Context, pag pagination
 
 	return res, nil
 }
+
+// StreamUsers - iterates over all the users matching the filters, calling fn for each one of them
+// without loading them all in memory. The password is never retrieved.
+// The iteration stops at the first error returned by fn
+func (r mongodbRepository) StreamUsers(ctx context.Context, filters models.UserFilters, fn func(models.User) error, fields ...string) error {
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetBatchSize(streamBatchSize)
+	if projection := models.UserFields(fields).Projection(); projection != nil {
+		findOptions.SetProjection(projection)
+	} else {
+		findOptions.SetProjection(bson.M{"password": 0})
+	}
+
+	cursor, err := r.db.Find(ctx, usersFilter(filters), findOptions)
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.StreamUsers -> error executing find command: %s", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			logrus.Errorf("Error in repository/mongodb.StreamUsers -> error decoding user: %s", err)
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		logrus.Errorf("Error in repository/mongodb.StreamUsers -> cursor error: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// duplicateEmail - returns a users.ErrDuplicateEmail error if the error was caused by the email index,
+// identified by the duplicate key code and the key pattern of the index, otherwise the error itself
+func duplicateEmail(err error) error {
+	if isEmailDuplicate(err) {
+		return fmt.Errorf("%w: %s", users.ErrDuplicateEmail, err)
+	}
+	return err
+}
+
+// isEmailDuplicate - returns true if any of the write errors, or the command error, is a duplicate key
+// of the email index
+func isEmailDuplicate(err error) bool {
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeErr := range writeException.WriteErrors {
+			if writeErr.Code == duplicateKeyCode && isEmailIndexKeyPattern(writeErr.Raw) {
+				return true
+			}
+		}
+		return false
+	}
+	var writeErr mongo.WriteError
+	if errors.As(err, &writeErr) {
+		return writeErr.Code == duplicateKeyCode && isEmailIndexKeyPattern(writeErr.Raw)
+	}
+	var bulkWriteErr mongo.BulkWriteError
+	if errors.As(err, &bulkWriteErr) {
+		return bulkWriteErr.Code == duplicateKeyCode && isEmailIndexKeyPattern(bulkWriteErr.Raw)
+	}
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code == duplicateKeyCode && isEmailIndexKeyPattern(commandErr.Raw)
+	}
+	return false
+}
+
+// notDeleted - adds the condition excluding the soft deleted users to the filter
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// usersFilter - returns the filter for the UserFilters, excluding the soft deleted users unless they are requested
+func usersFilter(filters models.UserFilters) interface{} {
+	conditions := bson.A{filters}
+	if filters.Status != "" {
+		conditions = append(conditions, statusFilter(filters.Status, time.Now().UTC()))
+	}
+	if !filters.IncludeDeleted {
+		conditions = append(conditions, bson.M{"deleted_at": nil})
+	}
+	if len(conditions) == 1 {
+		return filters
+	}
+	return bson.M{"$and": conditions}
+}
+
+// statusFilter - returns the filter of the users with the status at the given time (see models.User.CurrentStatus):
+// the users without status and the ones whose suspension ended are active
+func statusFilter(status models.UserStatus, now time.Time) bson.M {
+	switch status {
+	case models.StatusActive:
+		return bson.M{"$or": bson.A{
+			bson.M{"status": bson.M{"$in": bson.A{models.StatusActive, nil}}},
+			bson.M{"status": models.StatusSuspended, "suspended_until": bson.M{"$lte": now}},
+		}}
+	case models.StatusSuspended:
+		return bson.M{"status": models.StatusSuspended, "$or": bson.A{
+			bson.M{"suspended_until": nil},
+			bson.M{"suspended_until": bson.M{"$gt": now}},
+		}}
+	default:
+		return bson.M{"status": status}
+	}
+}
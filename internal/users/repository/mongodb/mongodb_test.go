@@ -2,17 +2,24 @@ package mongodb_test
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
+	"user-microservice/internal/audit"
 	"user-microservice/internal/models"
 	"user-microservice/internal/pagination"
 	"user-microservice/internal/testutils"
+	"user-microservice/internal/users"
 	"user-microservice/internal/users/repository/mongodb"
+	"user-microservice/internal/users/sec"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var dbClientTest *mongo.Client
@@ -114,6 +121,69 @@ func TestMongoDBRepository_Create(t *testing.T) {
 				assert.NotEqual(t, tc.user.CreatedAt, res.CreatedAt, "Expected CreatedAt not to be equal")
 				assert.NotEqual(t, tc.user.UpdatedAt, res.UpdatedAt, "Expected UpdatedAt not to be equal")
 				assert.NotEqual(t, tc.user.ID, res.ID, "Expected ID not to be equal")
+				assert.Equalf(t, int64(1), res.Version, "Expected Version to be 1, but was %d", res.Version)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_CreateMany(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		users []models.User
+	}{
+		{
+			"Create many users successfully",
+			[]models.User{
+				{
+					FirstName: "Create many First Name 1",
+					LastName:  "Create many Last name 1",
+					Nickname:  "Create many Nickname 1",
+					Password:  "Create many Password 1",
+					Email:     "Create many Email 1",
+					Country:   "Create many Country 1",
+				},
+				{
+					ID:        uuid.New().String(),
+					FirstName: "Create many First Name 2",
+					LastName:  "Create many Last name 2",
+					Nickname:  "Create many Nickname 2",
+					Password:  "Create many Password 2",
+					Email:     "Create many Email 2",
+					Country:   "Create many Country 2",
+					CreatedAt: time.Now().Add(-2 * time.Hour),
+				},
+			},
+		},
+		{
+			"Create many users without users",
+			[]models.User{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			now := time.Now().UTC().Add(-1 * time.Minute)
+			mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+			toCreate := make([]models.User, len(tc.users))
+			copy(toCreate, tc.users)
+
+			//When
+			res, err := mongoRepo.CreateMany(ctx, toCreate)
+
+			//Then
+			require.NoErrorf(t, err, "Expected err to be nil, but was %s", err)
+			require.Lenf(t, res, len(tc.users), "Expected %d users, but was %d", len(tc.users), len(res))
+			for i, user := range res {
+				assert.NotEqualf(t, tc.users[i].ID, user.ID, "Expected ID not to be %s", tc.users[i].ID)
+				assert.Truef(t, user.CreatedAt.After(now), "Expected CreatedAt to be after %s, but was %s", now, user.CreatedAt)
+
+				fromDB, err := mongoRepo.GetById(ctx, user.ID)
+				require.NoErrorf(t, err, "Expected no error retrieving created user, but was %s", err)
+				assert.Equalf(t, tc.users[i].Nickname, fromDB.Nickname, "Expected Nickname to be %s, but was %s", tc.users[i].Nickname, fromDB.Nickname)
 			}
 		})
 	}
@@ -152,6 +222,12 @@ func TestMongoDBRepository_GetById(t *testing.T) {
 			nil,
 			mongo.ErrNoDocuments,
 		},
+		{
+			"Get deleted user by ID",
+			"2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d",
+			nil,
+			mongo.ErrNoDocuments,
+		},
 	} {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
@@ -191,6 +267,216 @@ func TestMongoDBRepository_GetById(t *testing.T) {
 	}
 }
 
+func TestMongoDBRepository_GetByIdWithFields(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		id       string
+		fields   []string
+		expected models.User
+	}{
+		{
+			"Get user by ID with nickname and country",
+			"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+			[]string{"nickname", "country"},
+			models.User{
+				ID:       "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+				Nickname: "atingo",
+				Country:  "DE",
+			},
+		},
+		{
+			"Get user by ID with id only",
+			"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+			[]string{"id"},
+			models.User{
+				ID: "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+
+			// When
+			res, err := mongoRepo.GetById(ctx, tc.id, tc.fields...)
+
+			// Then
+			require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			require.NotNil(t, res, "Expected res not to be nil")
+			assert.Equalf(t, tc.expected, *res, "Expected res to be %v, but was %v", tc.expected, *res)
+		})
+	}
+}
+
+func TestMongoDBRepository_GetByEmail(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		email         string
+		expectedID    string
+		expectedError error
+	}{
+		{
+			"Get user by email successfully",
+			"alicetingo@example.com",
+			"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+			nil,
+		},
+		{
+			"Get user by email ignoring the case",
+			"AliceTingo@Example.com",
+			"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+			nil,
+		},
+		{
+			"Get not found user by email",
+			"unknown@example.com",
+			"",
+			mongo.ErrNoDocuments,
+		},
+		{
+			"Get deleted user by email",
+			"Deleted user email 10",
+			"",
+			mongo.ErrNoDocuments,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+
+			// When
+			res, err := mongoRepo.GetByEmail(ctx, tc.email)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.Nilf(t, res, "Expected res to be nil but was %v", res)
+				assert.Equalf(t, tc.expectedError, err, "Expected err to be %s, but was %s", tc.expectedError, err)
+			} else {
+				require.NoErrorf(t, err, "Expected no error, but was %s", err)
+				require.NotNil(t, res, "Expected res not to be nil")
+				assert.Equalf(t, tc.expectedID, res.ID, "Expected ID to be %s, but was %s", tc.expectedID, res.ID)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_DuplicateEmail(t *testing.T) {
+	// Given
+	db := dbClientTest.Database("users_duplicate_email")
+	ctx := context.TODO()
+	t.Cleanup(func() { _ = db.Drop(ctx) })
+	// the indexes are ensured at every start
+	require.NoError(t, mongodb.EnsureIndexes(ctx, db))
+	require.NoError(t, mongodb.EnsureIndexes(ctx, db))
+	mongoRepo := mongodb.NewMongoDBRepository(db)
+	first, err := mongoRepo.Create(ctx, models.User{Nickname: "First", Email: "duplicate@example.com"})
+	require.NoError(t, err)
+	other, err := mongoRepo.Create(ctx, models.User{Nickname: "Other", Email: "other@example.com"})
+	require.NoError(t, err)
+
+	// When
+	_, duplicateErr := mongoRepo.Create(ctx, models.User{Nickname: "Second", Email: "Duplicate@Example.com"})
+	other.Email = "DUPLICATE@example.com"
+	_, updateErr := mongoRepo.Update(ctx, *other)
+	require.NoError(t, mongoRepo.DeleteById(ctx, first.ID))
+	_, afterDeleteErr := mongoRepo.Create(ctx, models.User{Nickname: "Third", Email: "DUPLICATE@example.com"})
+
+	// Then
+	assert.ErrorIsf(t, duplicateErr, users.ErrDuplicateEmail, "Expected error to be %s, but was %s", users.ErrDuplicateEmail, duplicateErr)
+	assert.ErrorIsf(t, updateErr, users.ErrDuplicateEmail, "Expected error to be %s, but was %s", users.ErrDuplicateEmail, updateErr)
+	assert.NoErrorf(t, afterDeleteErr, "Expected the email of the deleted user to be available, but was %s", afterDeleteErr)
+}
+
+func TestMongoDBRepository_CreateManyWithDuplicateEmails(t *testing.T) {
+	// Given
+	db := dbClientTest.Database("users_create_many_duplicate_emails")
+	ctx := context.TODO()
+	t.Cleanup(func() { _ = db.Drop(ctx) })
+	mongoRepo := mongodb.NewMongoDBRepository(db)
+	_, err := mongoRepo.Create(ctx, models.User{Nickname: "Existing", Email: "existing@example.com"})
+	require.NoError(t, err)
+
+	// When
+	created, err := mongoRepo.CreateMany(ctx, []models.User{
+		{Nickname: "First", Email: "first@example.com"},
+		{Nickname: "Existing again", Email: "Existing@Example.com"},
+		{Nickname: "Second", Email: "second@example.com"},
+		{Nickname: "First again", Email: "FIRST@example.com"},
+	})
+
+	// Then
+	var bulkErr *users.BulkError
+	require.ErrorAs(t, err, &bulkErr)
+	require.Len(t, bulkErr.Errors, 2)
+	assert.ErrorIs(t, bulkErr.Errors[1], users.ErrDuplicateEmail)
+	assert.ErrorIs(t, bulkErr.Errors[3], users.ErrDuplicateEmail)
+	require.Len(t, created, 2)
+	assert.Equal(t, "First", created[0].Nickname)
+	assert.Equal(t, "Second", created[1].Nickname)
+	count, err := db.Collection("users").CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equalf(t, int64(3), count, "Expected the duplicated users not to be inserted, but there were %d users", count)
+}
+
+func TestMongoDBRepository_GetByIDs(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		ids         []string
+		fields      []string
+		expectedIDs []string
+	}{
+		{
+			"Get users by IDs successfully",
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", "5cace01f-45c3-49f0-a725-c22866874095"},
+			nil,
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4", "5cace01f-45c3-49f0-a725-c22866874095"},
+		},
+		{
+			"Get users by IDs ignoring not found IDs",
+			[]string{"DDD50D89-0CF4-4D35-B8E8-51A2B5A06CE4", uuid.New().String()},
+			[]string{"nickname"},
+			[]string{"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"},
+		},
+		{
+			"Get users by not found IDs",
+			[]string{uuid.New().String()},
+			nil,
+			[]string{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+
+			// When
+			res, err := mongoRepo.GetByIDs(ctx, tc.ids, tc.fields...)
+
+			// Then
+			require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			ids := make([]string, 0, len(res))
+			for _, user := range res {
+				ids = append(ids, user.ID)
+				if len(tc.fields) > 0 {
+					assert.Emptyf(t, user.Email, "Expected Email to be empty, but was %s", user.Email)
+				}
+			}
+			assert.ElementsMatchf(t, tc.expectedIDs, ids, "Expected IDs to be %v, but was %v", tc.expectedIDs, ids)
+		})
+	}
+}
+
 func TestMongoDBRepository_DeleteById(t *testing.T) {
 	for _, tc := range []struct {
 		name          string
@@ -205,7 +491,12 @@ func TestMongoDBRepository_DeleteById(t *testing.T) {
 		{
 			"Delete user not found with error",
 			uuid.New().String(),
-			nil,
+			mongo.ErrNoDocuments,
+		},
+		{
+			"Delete already deleted user with error",
+			"2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d",
+			mongo.ErrNoDocuments,
 		},
 	} {
 		tc := tc
@@ -230,11 +521,128 @@ func TestMongoDBRepository_DeleteById(t *testing.T) {
 				require.Error(t, err)
 				require.Nil(t, fromDB)
 				assert.Equal(t, mongo.ErrNoDocuments, err, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, err)
+
+				//the user is only soft deleted
+				deleted, err := mongoRepository.GetPaginatedUsers(ctx, pagination.PaginationOptions{Page: 1, Size: 100}, models.UserFilters{IncludeDeleted: true})
+				require.NoError(t, err)
+				found := false
+				for _, user := range deleted.Users {
+					if user.ID == tc.id {
+						found = true
+						require.NotNil(t, user.DeletedAt, "Expected DeletedAt not to be nil")
+					}
+				}
+				assert.Truef(t, found, "Expected deleted user %s to be retrieved with IncludeDeleted", tc.id)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_Restore(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		id              string
+		expectedVersion int64
+		expectedError   error
+	}{
+		{
+			"Restore deleted user successfully",
+			"0e0b5a3c-6f1d-4d7e-9b8a-2c3d4e5f6a78",
+			3,
+			nil,
+		},
+		{
+			"Restore not deleted user with error",
+			"5cace01f-45c3-49f0-a725-c22866874095",
+			0,
+			mongo.ErrNoDocuments,
+		},
+		{
+			"Restore not found user with error",
+			uuid.New().String(),
+			0,
+			mongo.ErrNoDocuments,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			mongoRepository := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+
+			// When
+			res, err := mongoRepository.Restore(ctx, tc.id)
+
+			// Then
+			if tc.expectedError != nil {
+				require.Error(t, err)
+				assert.Nil(t, res)
+				assert.Equalf(t, tc.expectedError, err, "Expected error to be %s, but was %s", tc.expectedError, err)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, res, "Expected res not to be nil")
+				assert.Nilf(t, res.DeletedAt, "Expected DeletedAt to be nil, but was %s", res.DeletedAt)
+				assert.Equalf(t, tc.expectedVersion, res.Version, "Expected Version to be %d, but was %d", tc.expectedVersion, res.Version)
+
+				fromDB, err := mongoRepository.GetById(ctx, tc.id)
+				require.NoError(t, err)
+				assert.Equalf(t, tc.id, fromDB.ID, "Expected ID to be %s, but was %s", tc.id, fromDB.ID)
 			}
 		})
 	}
 }
 
+func TestMongoDBRepository_PurgeDeleted(t *testing.T) {
+	// Given
+	mongoRepository := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	before := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// When
+	purged, err := mongoRepository.PurgeDeleted(ctx, before)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equalf(t, []string{"1f2a3b4c-5d6e-4f70-8a9b-0c1d2e3f4a59"}, purged, "Expected purged to be the user deleted before %s, but was %v", before, purged)
+
+	remaining, err := mongoRepository.GetPaginatedUsers(ctx, pagination.PaginationOptions{Page: 1, Size: 100}, models.UserFilters{IncludeDeleted: true})
+	require.NoError(t, err)
+	for _, user := range remaining.Users {
+		assert.NotEqualf(t, "1f2a3b4c-5d6e-4f70-8a9b-0c1d2e3f4a59", user.ID, "Expected user %s to be purged", user.ID)
+	}
+}
+
+func TestMongoDBRepository_PurgeDeletedInBatches(t *testing.T) {
+	// Given
+	db := dbClientTest.Database("users_purge_batches")
+	ctx := context.TODO()
+	t.Cleanup(func() { _ = db.Drop(ctx) })
+	deletedAt := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	const deleted = 1200
+	docs := make([]interface{}, 0, deleted+1)
+	for i := 0; i < deleted; i++ {
+		docs = append(docs, bson.M{"_id": uuid.New().String(), "deleted_at": deletedAt})
+	}
+	docs = append(docs, bson.M{"_id": "not-deleted", "deleted_at": nil})
+	_, err := db.Collection("users").InsertMany(ctx, docs)
+	require.NoError(t, err)
+
+	// When
+	purged, err := mongodb.NewMongoDBRepository(db).PurgeDeleted(ctx, deletedAt.Add(time.Hour))
+
+	// Then
+	require.NoError(t, err)
+	assert.Lenf(t, purged, deleted, "Expected all the deleted users to be purged, but were %d", len(purged))
+	remaining, err := db.Collection("users").CountDocuments(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equalf(t, int64(1), remaining, "Expected only the not deleted user to remain, but were %d", remaining)
+	entries, err := db.Collection("users_history").CountDocuments(ctx, bson.M{"action": models.AuditActionPurge})
+	require.NoError(t, err)
+	assert.Equalf(t, int64(deleted), entries, "Expected an audit entry by purged user, but were %d", entries)
+}
+
 type expected struct {
 	err          error
 	equalLength  bool
@@ -515,6 +923,20 @@ func TestMongoDBRepository_UpdateUser(t *testing.T) {
 			},
 			mongo.ErrNoDocuments,
 		},
+		{
+			"Update user with outdated version",
+			models.User{
+				ID:        "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4",
+				FirstName: "Modified FirstName",
+				LastName:  "Modified LastName",
+				Nickname:  "Modified Nickname",
+				Password:  "Modified Password",
+				Email:     "Modified Email",
+				Country:   "Modified Country",
+				Version:   5,
+			},
+			users.ErrVersionConflict,
+		},
 	} {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
@@ -532,7 +954,11 @@ func TestMongoDBRepository_UpdateUser(t *testing.T) {
 			if tc.expectedError != nil {
 				require.Error(t, err)
 				assert.Nil(t, res)
-				assert.Equalf(t, tc.expectedError, err, "Expected err to be %v, but was %v", tc.expectedError, err)
+				if errors.Is(tc.expectedError, users.ErrVersionConflict) {
+					assert.ErrorIsf(t, err, users.ErrVersionConflict, "Expected err to be %v, but was %v", tc.expectedError, err)
+				} else {
+					assert.Equalf(t, tc.expectedError, err, "Expected err to be %v, but was %v", tc.expectedError, err)
+				}
 			} else {
 				require.NoError(t, err)
 				require.NotNil(t, res, "Expected res not to be nil")
@@ -547,7 +973,311 @@ func TestMongoDBRepository_UpdateUser(t *testing.T) {
 
 				// assert.Truef(t, res.CreatedAt.Before(now), "Expected CreatedAt to be before %s but was %s", now, res.CreatedAt)
 				assert.Truef(t, res.UpdatedAt.After(now), "Expected UpdatedAt to be after %s but was %s", now, res.UpdatedAt)
+				assert.Equalf(t, tc.user.Version+1, res.Version, "Expected Version to be %d, but was %d", tc.user.Version+1, res.Version)
+			}
+		})
+	}
+}
+
+func TestMongoDBRepository_MFA(t *testing.T) {
+	// Given
+	mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	created, err := mongoRepo.Create(ctx, models.User{
+		FirstName: "MFA FirstName",
+		LastName:  "MFA LastName",
+		Nickname:  "MFA Nickname",
+		Password:  "MFA Password",
+		Email:     "MFA Email",
+		Country:   "MFA Country",
+	})
+	require.NoError(t, err)
+	enrolledAt := time.Now().UTC().Truncate(time.Millisecond)
+	mfa := models.MFA{Secret: "encrypted secret", Enabled: true, EnrolledAt: &enrolledAt, RecoveryCodes: []string{"hash"}, LastUsedStep: 42}
+
+	// When
+	notEnrolled, notEnrolledErr := mongoRepo.GetMFA(ctx, created.ID)
+	setErr := mongoRepo.SetMFA(ctx, created.ID, &mfa)
+	created.Nickname = "MFA Modified Nickname"
+	_, updateErr := mongoRepo.Update(ctx, *created)
+	enrolled, enrolledErr := mongoRepo.GetMFA(ctx, created.ID)
+	removeErr := mongoRepo.SetMFA(ctx, created.ID, nil)
+	removed, removedErr := mongoRepo.GetMFA(ctx, created.ID)
+	notFoundErr := mongoRepo.SetMFA(ctx, uuid.New().String(), &mfa)
+	_, getNotFoundErr := mongoRepo.GetMFA(ctx, uuid.New().String())
+
+	// Then
+	require.NoError(t, notEnrolledErr)
+	assert.Nilf(t, notEnrolled, "Expected no second factor before enrolling, but was %v", notEnrolled)
+	require.NoError(t, setErr)
+	require.NoError(t, updateErr)
+	require.NoError(t, enrolledErr)
+	assert.Equalf(t, &mfa, enrolled, "Expected the second factor to be kept after updating the user, but was %v", enrolled)
+	require.NoError(t, removeErr)
+	require.NoError(t, removedErr)
+	assert.Nilf(t, removed, "Expected the second factor to be removed, but was %v", removed)
+	assert.Equal(t, mongo.ErrNoDocuments, notFoundErr)
+	assert.Equal(t, mongo.ErrNoDocuments, getNotFoundErr)
+}
+
+func TestMongoDBRepository_UseMFAConcurrently(t *testing.T) {
+	// Given
+	mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	created, err := mongoRepo.Create(ctx, models.User{
+		FirstName: "Use MFA FirstName",
+		LastName:  "Use MFA LastName",
+		Nickname:  "Use MFA Nickname",
+		Password:  "Use MFA Password",
+		Email:     "Use MFA Email",
+		Country:   "Use MFA Country",
+	})
+	require.NoError(t, err)
+	require.NoError(t, mongoRepo.SetMFA(ctx, created.ID, &models.MFA{Secret: "encrypted secret", Enabled: true, RecoveryCodes: []string{"hash", "other hash"}, LastUsedStep: 42}))
+
+	// When
+	const uses = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	usedSteps, usedCodes := 0, 0
+	for i := 0; i < uses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			usedStep, stepErr := mongoRepo.UseMFAStep(ctx, created.ID, 43)
+			usedCode, codeErr := mongoRepo.UseMFARecoveryCode(ctx, created.ID, "hash")
+			assert.NoError(t, stepErr)
+			assert.NoError(t, codeErr)
+			mu.Lock()
+			defer mu.Unlock()
+			if usedStep {
+				usedSteps++
+			}
+			if usedCode {
+				usedCodes++
+			}
+		}()
+	}
+	wg.Wait()
+	olderStep, olderErr := mongoRepo.UseMFAStep(ctx, created.ID, 41)
+	used, usedErr := mongoRepo.GetMFA(ctx, created.ID)
+
+	// Then
+	assert.Equal(t, 1, usedSteps, "Expected the step to be used only once")
+	assert.Equal(t, 1, usedCodes, "Expected the recovery code to be used only once")
+	require.NoError(t, olderErr)
+	assert.False(t, olderStep, "Expected an older step not to be used")
+	require.NoError(t, usedErr)
+	assert.Equal(t, int64(43), used.LastUsedStep)
+	assert.Equal(t, []string{"other hash"}, used.RecoveryCodes)
+}
+
+func TestMongoDBRepository_Statuses(t *testing.T) {
+	// Given
+	mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	ended, ongoing := now.Add(-time.Minute), now.Add(time.Hour)
+	ids := map[string]string{}
+	for name, user := range map[string]models.User{
+		"withoutStatus": {},
+		"active":        {Status: models.StatusActive},
+		"ended":         {Status: models.StatusSuspended, SuspendedUntil: &ended},
+		"suspended":     {Status: models.StatusSuspended, SuspendedUntil: &ongoing},
+		"banned":        {Status: models.StatusBanned},
+	} {
+		user.FirstName, user.LastName, user.Nickname, user.Password = "Status FirstName", "Status LastName", name, "Status Password"
+		user.Email, user.Country = name+"@status.example.com", "Status Country"
+		created, err := mongoRepo.Create(ctx, user)
+		require.NoError(t, err)
+		ids[name] = created.ID
+	}
+	idsOf := func(users []models.User) []string {
+		res := []string{}
+		for _, user := range users {
+			res = append(res, user.ID)
+		}
+		return res
+	}
+
+	for _, tc := range []struct {
+		status      models.UserStatus
+		expectedIDs []string
+	}{
+		{models.StatusActive, []string{ids["withoutStatus"], ids["active"], ids["ended"]}},
+		{models.StatusSuspended, []string{ids["suspended"]}},
+		{models.StatusBanned, []string{ids["banned"]}},
+	} {
+		// When
+		res, err := mongoRepo.GetPaginatedUsers(ctx, pagination.PaginationOptions{Page: 1, Size: 10}, models.UserFilters{Country: "Status Country", Status: tc.status})
+
+		// Then
+		require.NoError(t, err)
+		assert.ElementsMatchf(t, tc.expectedIDs, idsOf(res.Users), "Expected the %s users", tc.status)
+	}
+
+	// When
+	expired, err := mongoRepo.GetExpiredSuspensions(ctx, now)
+
+	// Then
+	require.NoError(t, err)
+	assert.Contains(t, idsOf(expired), ids["ended"])
+	assert.NotContains(t, idsOf(expired), ids["suspended"])
+}
+
+func TestMongoDBRepository_StreamUsers(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		filters       models.UserFilters
+		fields        []string
+		stopError     error
+		expectedError error
+	}{
+		{
+			"Stream users with filters",
+			models.UserFilters{Country: "ES"},
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"Stream users with fields",
+			models.UserFilters{LastName: "Tingo"},
+			[]string{"nickname"},
+			nil,
+			nil,
+		},
+		{
+			"Stream users stopping with error",
+			models.UserFilters{Country: "ES"},
+			nil,
+			errors.New("homemade error"),
+			errors.New("homemade error"),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			//Given
+			mongoRepo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+			ctx := context.TODO()
+			var streamed []models.User
+
+			//When
+			err := mongoRepo.StreamUsers(ctx, tc.filters, func(user models.User) error {
+				streamed = append(streamed, user)
+				return tc.stopError
+			}, tc.fields...)
+
+			//Then
+			if tc.expectedError != nil {
+				require.Error(t, err)
+				assert.Equalf(t, tc.expectedError, err, "Expected err to be %s, but was %s", tc.expectedError, err)
+				assert.Lenf(t, streamed, 1, "Expected 1 streamed user, but was %d", len(streamed))
+				return
+			}
+			require.NoErrorf(t, err, "Expected no error, but was %s", err)
+			assert.NotEmptyf(t, streamed, "Expected streamed users not to be empty")
+			for _, user := range streamed {
+				assert.Emptyf(t, user.Password, "Expected Password to be empty, but was %s", user.Password)
+				assert.NotEmptyf(t, user.ID, "Expected ID not to be empty")
+				if tc.filters.Country != "" {
+					assert.Equalf(t, tc.filters.Country, user.Country, "Expected Country to be %s, but was %s", tc.filters.Country, user.Country)
+				}
+				if len(tc.fields) > 0 {
+					assert.Emptyf(t, user.Email, "Expected Email to be empty, but was %s", user.Email)
+				}
 			}
 		})
 	}
 }
+
+func TestMongoDBRepository_GetUserHistory(t *testing.T) {
+	// Given
+	mongoRepository := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	info := audit.Info{Actor: "admin@example.com", RequestID: uuid.New().String()}
+	ctx := audit.NewContext(context.TODO(), info)
+
+	created, err := mongoRepository.Create(ctx, models.User{
+		FirstName: "History First Name",
+		LastName:  "History Last Name",
+		Nickname:  "History Nickname",
+		Password:  "History Password",
+		Email:     "History Email",
+		Country:   "ES",
+	})
+	require.NoError(t, err)
+	toUpdate := *created
+	toUpdate.Nickname = "History New Nickname"
+	toUpdate.Password = "History New Password"
+	_, err = mongoRepository.Update(ctx, toUpdate)
+	require.NoError(t, err)
+	require.NoError(t, mongoRepository.DeleteById(ctx, created.ID))
+
+	// When
+	history, err := mongoRepository.GetUserHistory(ctx, created.ID, pagination.PaginationOptions{Page: 1, Size: 2})
+
+	// Then
+	require.NoError(t, err)
+	assert.Equalf(t, int64(3), history.TotalCount, "Expected TotalCount to be 3, but was %d", history.TotalCount)
+	assert.Truef(t, history.HasMore, "Expected HasMore to be true")
+	require.Lenf(t, history.Entries, 2, "Expected 2 entries, but were %d", len(history.Entries))
+
+	deleted, updated := history.Entries[0], history.Entries[1]
+	assert.Equalf(t, models.AuditActionDelete, deleted.Action, "Expected first entry action to be %s, but was %s", models.AuditActionDelete, deleted.Action)
+	assert.Equalf(t, models.AuditActionUpdate, updated.Action, "Expected second entry action to be %s, but was %s", models.AuditActionUpdate, updated.Action)
+	for _, entry := range history.Entries {
+		assert.Equalf(t, created.ID, entry.UserID, "Expected UserID to be %s, but was %s", created.ID, entry.UserID)
+		assert.Equalf(t, info.Actor, entry.Actor, "Expected Actor to be %s, but was %s", info.Actor, entry.Actor)
+		assert.Equalf(t, info.RequestID, entry.RequestID, "Expected RequestID to be %s, but was %s", info.RequestID, entry.RequestID)
+	}
+	assert.ElementsMatchf(t, []models.FieldChange{
+		{Field: "nickname", Old: "History Nickname", New: "History New Nickname"},
+		{Field: "password", Old: models.MaskedValue, New: models.MaskedValue},
+	}, updated.Changes, "Expected update changes to be masked, but were %v", updated.Changes)
+
+	lastPage, err := mongoRepository.GetUserHistory(ctx, created.ID, pagination.PaginationOptions{Page: 2, Size: 2})
+	require.NoError(t, err)
+	require.Lenf(t, lastPage.Entries, 1, "Expected 1 entry, but were %d", len(lastPage.Entries))
+	assert.Equalf(t, models.AuditActionCreate, lastPage.Entries[0].Action, "Expected last entry action to be %s, but was %s", models.AuditActionCreate, lastPage.Entries[0].Action)
+}
+
+func TestHashPlainPasswords(t *testing.T) {
+	// Given
+	db := dbClientTest.Database("users_plain_passwords")
+	ctx := context.TODO()
+	t.Cleanup(func() { _ = db.Drop(ctx) })
+	cost := sec.Cost
+	sec.Cost = bcrypt.MinCost
+	t.Cleanup(func() { sec.Cost = cost })
+	hashed, err := sec.HashPassword("Hashed Password")
+	require.NoError(t, err)
+	_, err = db.Collection("users").InsertMany(ctx, []interface{}{
+		bson.M{"_id": "plain", "password": "Plain Password"},
+		bson.M{"_id": "hashed", "password": hashed},
+		bson.M{"_id": "empty", "password": ""},
+	})
+	require.NoError(t, err)
+
+	// When
+	first, err := mongodb.HashPlainPasswords(ctx, db)
+	require.NoError(t, err)
+	second, err := mongodb.HashPlainPasswords(ctx, db)
+
+	// Then
+	require.NoError(t, err)
+	assert.Equalf(t, 1, first, "Expected 1 hashed password, but were %d", first)
+	assert.Equalf(t, 0, second, "Expected no hashed passwords on the second run, but were %d", second)
+	passwords := map[string]string{}
+	for _, id := range []string{"plain", "hashed", "empty"} {
+		var user struct {
+			Password string `bson:"password"`
+		}
+		require.NoError(t, db.Collection("users").FindOne(ctx, bson.M{"_id": id}).Decode(&user))
+		passwords[id] = user.Password
+	}
+	assert.Truef(t, sec.CheckPassword(passwords["plain"], "Plain Password"), "Expected the plain password to be hashed, but was %s", passwords["plain"])
+	assert.Equalf(t, hashed, passwords["hashed"], "Expected the hashed password to be kept, but was %s", passwords["hashed"])
+	assert.Emptyf(t, passwords["empty"], "Expected the empty password to be kept, but was %s", passwords["empty"])
+}
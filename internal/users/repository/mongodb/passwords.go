@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"user-microservice/internal/users/sec"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// bcryptPattern - prefix of the bcrypt hashes, the stored passwords not matching it are in plain text
+const bcryptPattern = `^\$2[abxy]?\$[0-9]{2}\$`
+
+// HashPlainPasswords - hashes the passwords stored in plain text before they were hashed on write and
+// returns the number of hashed ones. Each password is only replaced if it was not modified meanwhile,
+// without a new version or audit entry, so it can be run at every start
+func HashPlainPasswords(ctx context.Context, db *mongo.Database) (int, error) {
+	collection := db.Collection(mongodbCollection)
+	filter := bson.M{
+		"password": bson.M{"$nin": bson.A{"", nil}, "$not": primitive.Regex{Pattern: bcryptPattern}},
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"password": 1}).SetBatchSize(streamBatchSize))
+	if err != nil {
+		logrus.Errorf("Error in repository/mongodb.HashPlainPasswords -> error finding plain passwords: %s", err)
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	hashed := 0
+	for cursor.Next(ctx) {
+		var user struct {
+			ID       string `bson:"_id"`
+			Password string `bson:"password"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			logrus.Errorf("Error in repository/mongodb.HashPlainPasswords -> error decoding user: %s", err)
+			return hashed, err
+		}
+		if sec.IsHashed(user.Password) {
+			continue
+		}
+		password, err := sec.HashPassword(user.Password)
+		if err != nil {
+			return hashed, err
+		}
+		res, err := collection.UpdateOne(ctx,
+			bson.M{"_id": user.ID, "password": user.Password},
+			bson.M{"$set": bson.M{"password": password}},
+		)
+		if err != nil {
+			logrus.Errorf("Error in repository/mongodb.HashPlainPasswords -> error hashing password of user %s: %s", user.ID, err)
+			return hashed, err
+		}
+		hashed += int(res.ModifiedCount)
+	}
+	if err := cursor.Err(); err != nil {
+		logrus.Errorf("Error in repository/mongodb.HashPlainPasswords -> error iterating users: %s", err)
+		return hashed, err
+	}
+
+	return hashed, nil
+}
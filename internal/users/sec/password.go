@@ -1,13 +1,18 @@
 package sec
 
 import (
+	"errors"
+
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Cost - bcrypt cost of the hashed passwords
+var Cost = 14
+
 // HashPassword - hashes the given password using bcrypt
 func HashPassword(pwd string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(pwd), 14)
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pwd), Cost)
 	if err != nil {
 		logrus.Errorf("Error in users/sec.HashPassword -> error: %s", err)
 		return "", err
@@ -15,3 +20,18 @@ func HashPassword(pwd string) (string, error) {
 
 	return string(hashed), err
 }
+
+// CheckPassword - returns true if the password matches the bcrypt hashed one
+func CheckPassword(hashed, pwd string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(pwd))
+	if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		logrus.Errorf("Error in users/sec.CheckPassword -> error: %s", err)
+	}
+	return err == nil
+}
+
+// IsHashed - returns true if the password is already a bcrypt hash
+func IsHashed(pwd string) bool {
+	_, err := bcrypt.Cost([]byte(pwd))
+	return err == nil
+}
@@ -0,0 +1,9 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package verification
+
+import "github.com/labstack/echo/v4"
+
+// Handler - email verification handlers
+type Handler interface {
+	VerifyEmail(c echo.Context) error
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	"user-microservice/internal/verification"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpHandler struct {
+	manager *verification.Manager
+}
+
+var _ verification.Handler = httpHandler{}
+var _ verification.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new email verification http handler initialized with the manager
+func NewHttpHandler(manager *verification.Manager) verification.Handler {
+	return &httpHandler{manager}
+}
+
+// VerifyEmail godoc
+//
+// @Summary     Verifies the user email
+// @Description Verifies the email of the user with the mailed verification token. If the token is for the pending email, it replaces the user email
+// @Tags        Users
+// @Accept      json
+// @Produce     json
+// @Param       userId path     string                   true "User id" format(uuid) example(ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4)
+// @Param       body   body     models.EmailVerification true "Verification token"
+// @Success     200    {object} models.User
+// @Failure     400    {object} echo.HTTPError
+// @Failure     401    {object} echo.HTTPError
+// @Failure     403    {object} echo.HTTPError
+// @Failure     404    {object} echo.HTTPError
+// @Failure     409    {object} echo.HTTPError
+// @Failure     500    {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /users/{userId}/verify-email [post]
+func (h httpHandler) VerifyEmail(c echo.Context) error {
+	userIDStr := c.Param("userId")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid user ID %s", userIDStr))
+	}
+
+	if err := auth.CheckRequest(c, auth.ActionVerifyEmail, userID.String()); err != nil {
+		return err
+	}
+
+	var body models.EmailVerification
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in verification/http.VerifyEmail -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if body.Token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	}
+
+	user, err := h.manager.Verify(c.Request().Context(), userID.String(), body.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, verification.ErrInvalidToken):
+			logrus.Infof("Info in verification/http.VerifyEmail -> %s", err)
+			return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidVerificationToken)
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID))
+		case errors.Is(err, users.ErrVersionConflict):
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrVersionConflict)
+		case errors.Is(err, users.ErrDuplicateEmail):
+			return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrDuplicateEmail)
+		}
+		return err
+	}
+
+	c.Response().Header().Set("ETag", user.ETag())
+	return c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,158 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/models"
+	"user-microservice/internal/testutils"
+	usersMock "user-microservice/internal/users/mock"
+	"user-microservice/internal/verification"
+	verificationHttp "user-microservice/internal/verification/http"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	secret = "verification-secret"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	signer, err := verification.NewSigner([]byte(secret), time.Hour)
+	require.NoError(t, err)
+	token, err := signer.Sign(userID, "alicetingo@example.com")
+	require.NoError(t, err)
+	user := models.User{ID: userID, Email: "alicetingo@example.com", Version: 1}
+	self := auth.Principal{Subject: userID, Role: auth.RoleUser}
+	other := auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleUser}
+
+	for _, tc := range []struct {
+		name          string
+		id            string
+		body          string
+		principal     *auth.Principal
+		mockedError   error
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Verify email successfully",
+			userID,
+			fmt.Sprintf(`{"token": %q}`, token),
+			&self,
+			nil,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"Verify email with invalid token",
+			userID,
+			`{"token": "invalid"}`,
+			&self,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidVerificationToken),
+		},
+		{
+			"Verify email without token",
+			userID,
+			`{}`,
+			&self,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Verify email with wrong id",
+			"wrong-id",
+			fmt.Sprintf(`{"token": %q}`, token),
+			&self,
+			nil,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID wrong-id"),
+		},
+		{
+			"Verify email of other user",
+			userID,
+			fmt.Sprintf(`{"token": %q}`, token),
+			&other,
+			nil,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Verify email of not found user",
+			userID,
+			fmt.Sprintf(`{"token": %q}`, token),
+			nil,
+			mongo.ErrNoDocuments,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("User not found for ID %s", userID)),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			usersRepo := usersMock.NewMockRepository(ctrl)
+			pubsubRepo := usersMock.NewMockPubSub(ctrl)
+			manager, err := verification.NewManager(usersRepo, pubsubRepo, mail.NewLogMailer(), []byte(secret), verification.Options{})
+			require.NoError(t, err)
+			handler := verificationHttp.NewHttpHandler(manager)
+
+			var mockedUser *models.User
+			if tc.mockedError == nil {
+				current := user
+				mockedUser = &current
+			}
+			usersRepo.EXPECT().GetById(gomock.Any(), userID).Return(mockedUser, tc.mockedError).MaxTimes(1)
+			usersRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, u models.User) (*models.User, error) {
+				u.Version++
+				return &u, nil
+			}).MaxTimes(1)
+			pubsubRepo.EXPECT().NotifyEmailVerified(gomock.Any(), gomock.Any()).Return(nil).MaxTimes(1)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			if tc.principal != nil {
+				req = req.WithContext(auth.NewPrincipalContext(req.Context(), *tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetPath("/api/v1/users/:userId/verify-email")
+			c.SetParamNames("userId")
+			c.SetParamValues(tc.id)
+
+			// When
+			err = handler.VerifyEmail(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+			var body models.User
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.True(t, body.EmailVerified)
+			assert.NotNil(t, body.EmailVerifiedAt)
+		})
+	}
+}
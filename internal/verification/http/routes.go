@@ -0,0 +1,12 @@
+package http
+
+import (
+	"user-microservice/internal/verification"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendVerificationRoutes - Sets the email verification routes for the given users echo group
+func AppendVerificationRoutes(e *echo.Group, h verification.Handler) {
+	e.POST("/:userId/verify-email", h.VerifyEmail)
+}
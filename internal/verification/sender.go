@@ -0,0 +1,13 @@
+//go:generate mockgen -source sender.go -destination mock/sender_mock.go -package mock
+package verification
+
+import (
+	"context"
+	"user-microservice/internal/models"
+)
+
+// Sender - sends the email verification tokens
+type Sender interface {
+	// Send - mails a verification token to the email of the user waiting for verification, if any
+	Send(ctx context.Context, user models.User) error
+}
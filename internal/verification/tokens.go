@@ -0,0 +1,83 @@
+package verification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultTokenTTL - default time a verification token is valid
+const DefaultTokenTTL = 24 * time.Hour
+
+// tokenAudience - "aud" claim of the verification tokens
+const tokenAudience = "email-verification"
+
+// Claims - claims of the verification tokens, the subject is the user ID
+type Claims struct {
+	jwt.RegisteredClaims
+	// Email - verified email
+	Email string `json:"email"`
+}
+
+// Signer - signs and parses the email verification tokens (HS256). The key is derived from the secret,
+// so the tokens are never accepted as access tokens even if the secret is shared with the auth
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewSigner - returns a new Signer of tokens valid for the given ttl (DefaultTokenTTL if it's not positive),
+// or an error if the secret is empty
+func NewSigner(secret []byte, ttl time.Duration) (*Signer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("email verification needs a secret to sign the tokens")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(tokenAudience))
+	return &Signer{key: mac.Sum(nil), ttl: ttl}, nil
+}
+
+// TTL - returns the lifetime of the signed tokens
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign - returns a new signed token verifying the email of the user
+func (s *Signer) Sign(userID, email string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{tokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+		},
+		Email: email,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.key)
+}
+
+// Parse - returns the claims of the token, or an ErrInvalidToken error if it's not valid or it's expired
+func (s *Signer) Parse(token string) (Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return s.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if !claims.VerifyAudience(tokenAudience, true) || claims.Subject == "" || claims.Email == "" {
+		return Claims{}, fmt.Errorf("%w: missing claims", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,145 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidToken - the verification token is not valid, is expired or does not match the email waiting for verification
+var ErrInvalidToken = errors.New("invalid verification token")
+
+// Options - Manager options
+type Options struct {
+	// TokenTTL - time a verification token is valid, DefaultTokenTTL if it's not positive
+	TokenTTL time.Duration
+	// URL - page where the users verify their email, the user ID and the token are added as the "userId" and "token" query params
+	URL string
+}
+
+// Manager - sends the verification tokens and verifies the emails
+type Manager struct {
+	users  users.Repository
+	pubsub userPS.PubSub
+	mailer mail.Mailer
+	signer *Signer
+	opts   Options
+}
+
+var _ Sender = (*Manager)(nil)
+
+// NewManager - returns a new Manager signing the tokens with the secret, or an error if the secret is empty
+func NewManager(usersRepository users.Repository, pubsubRepository userPS.PubSub, mailer mail.Mailer, secret []byte, opts Options) (*Manager, error) {
+	signer, err := NewSigner(secret, opts.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	opts.TokenTTL = signer.TTL()
+
+	return &Manager{
+		users:  usersRepository,
+		pubsub: pubsubRepository,
+		mailer: mailer,
+		signer: signer,
+		opts:   opts,
+	}, nil
+}
+
+// Send - mails a verification token to the pending email of the user, or to its email if it's not verified yet.
+// Nothing is sent if there is no email waiting for verification
+func (m *Manager) Send(ctx context.Context, user models.User) error {
+	email := user.VerificationEmail()
+	if email == "" {
+		return nil
+	}
+
+	token, err := m.signer.Sign(user.ID, email)
+	if err != nil {
+		logrus.Errorf("Error in verification.Send -> error signing token: %s", err)
+		return err
+	}
+	if err := m.mailer.Send(ctx, m.message(user, email, token)); err != nil {
+		logrus.Errorf("Error in verification.Send -> error sending mail to user %s: %s", user.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// Verify - marks the email of the token as verified, replacing the user email if it was the pending one,
+// and notifies it. Returns ErrInvalidToken if the token is not valid for the user or its email is not waiting
+// for verification anymore. Verifying an already verified email does nothing
+func (m *Manager) Verify(ctx context.Context, userID, token string) (*models.User, error) {
+	claims, err := m.signer.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject != userID {
+		return nil, fmt.Errorf("%w: issued for user %s", ErrInvalidToken, claims.Subject)
+	}
+
+	user, err := m.users.GetById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case user.PendingEmail != "" && claims.Email == user.PendingEmail:
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+	case claims.Email == user.Email && !user.EmailVerified:
+	case claims.Email == user.Email:
+		return user, nil
+	default:
+		return nil, fmt.Errorf("%w: email of user %s is not waiting for verification", ErrInvalidToken, userID)
+	}
+
+	// the verification is audited as made by the user itself
+	info := audit.FromContext(ctx)
+	info.Actor = user.ID
+	ctx = audit.NewContext(ctx, info)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	res, err := m.users.Update(ctx, *user)
+	if err != nil {
+		logrus.Errorf("Error in verification.Verify -> error updating user %s: %s", user.ID, err)
+		return nil, err
+	}
+
+	if err := m.pubsub.NotifyEmailVerified(ctx, *res); err != nil {
+		logrus.Errorf("Error in verification.Verify -> could not notify email verification: %s", err)
+	}
+	logrus.WithField("user", res.ID).Info("Email verified")
+
+	return res, nil
+}
+
+// message - returns the mail with the verification link
+func (m *Manager) message(user models.User, email, token string) mail.Message {
+	link := m.opts.URL
+	if parsed, err := url.Parse(m.opts.URL); err == nil {
+		query := parsed.Query()
+		query.Set("userId", user.ID)
+		query.Set("token", token)
+		parsed.RawQuery = query.Encode()
+		link = parsed.String()
+	}
+
+	return mail.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the following link to verify your email, it expires in %s:\n\n%s\n\nIf you did not create an account or change your email, you can ignore this email.",
+			user.FirstName, m.opts.TokenTTL, link),
+	}
+}
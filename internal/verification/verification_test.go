@@ -0,0 +1,231 @@
+package verification_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/audit"
+	"user-microservice/internal/auth"
+	"user-microservice/internal/mail"
+	"user-microservice/internal/models"
+	usersMock "user-microservice/internal/users/mock"
+	"user-microservice/internal/verification"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	userID = "ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"
+	secret = "verification-secret"
+)
+
+// fakeMailer - mail.Mailer recording the sent messages
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return m.err
+}
+
+type mocks struct {
+	users  *usersMock.MockRepository
+	pubsub *usersMock.MockPubSub
+	mailer *fakeMailer
+}
+
+func newManager(t *testing.T) (*verification.Manager, mocks) {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	m := mocks{
+		users:  usersMock.NewMockRepository(ctrl),
+		pubsub: usersMock.NewMockPubSub(ctrl),
+		mailer: &fakeMailer{},
+	}
+	opts := verification.Options{TokenTTL: time.Hour, URL: "https://example.com/verify-email?lang=en"}
+	manager, err := verification.NewManager(m.users, m.pubsub, m.mailer, []byte(secret), opts)
+	require.NoError(t, err)
+	return manager, m
+}
+
+func newToken(t *testing.T, userID, email string) string {
+	t.Helper()
+	signer, err := verification.NewSigner([]byte(secret), time.Hour)
+	require.NoError(t, err)
+	token, err := signer.Sign(userID, email)
+	require.NoError(t, err)
+	return token
+}
+
+func TestSigner_Parse(t *testing.T) {
+	signer, err := verification.NewSigner([]byte(secret), time.Hour)
+	require.NoError(t, err)
+	otherSigner, err := verification.NewSigner([]byte("other-secret"), time.Hour)
+	require.NoError(t, err)
+	authSigner, err := auth.NewSigner([]byte(secret), "", "", time.Hour)
+	require.NoError(t, err)
+
+	valid, err := signer.Sign(userID, "alicetingo@example.com")
+	require.NoError(t, err)
+	otherSecret, err := otherSigner.Sign(userID, "alicetingo@example.com")
+	require.NoError(t, err)
+	accessToken, _, err := authSigner.Sign(auth.Principal{Subject: userID, Role: auth.RoleUser})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name          string
+		token         string
+		expectedEmail string
+		expectedError error
+	}{
+		{"Parse valid token", valid, "alicetingo@example.com", nil},
+		{"Parse token of other secret", otherSecret, "", verification.ErrInvalidToken},
+		{"Parse access token signed with the same secret", accessToken, "", verification.ErrInvalidToken},
+		{"Parse malformed token", "malformed", "", verification.ErrInvalidToken},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			claims, err := signer.Parse(tc.token)
+
+			// Then
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, userID, claims.Subject)
+				assert.Equal(t, tc.expectedEmail, claims.Email)
+			}
+		})
+	}
+}
+
+func TestManager_Send(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		user       models.User
+		expectedTo string
+	}{
+		{"Send verification of new email", models.User{ID: userID, Email: "alicetingo@example.com"}, "alicetingo@example.com"},
+		{"Send verification of pending email", models.User{ID: userID, Email: "alicetingo@example.com", EmailVerified: true, PendingEmail: "alice@example.com"}, "alice@example.com"},
+		{"Send nothing for verified email", models.User{ID: userID, Email: "alicetingo@example.com", EmailVerified: true}, ""},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			manager, m := newManager(t)
+
+			// When
+			err := manager.Send(context.Background(), tc.user)
+
+			// Then
+			require.NoError(t, err)
+			if tc.expectedTo == "" {
+				assert.Empty(t, m.mailer.sent)
+				return
+			}
+			require.Len(t, m.mailer.sent, 1)
+			msg := m.mailer.sent[0]
+			assert.Equal(t, tc.expectedTo, msg.To)
+
+			start := strings.Index(msg.Body, "https://")
+			require.NotEqual(t, -1, start, "Expected the mail to have the verification link")
+			link, err := url.Parse(strings.Fields(msg.Body[start:])[0])
+			require.NoError(t, err)
+			assert.Equal(t, "en", link.Query().Get("lang"), "Expected the URL query params to be kept")
+			assert.Equal(t, userID, link.Query().Get("userId"))
+			signer, err := verification.NewSigner([]byte(secret), time.Hour)
+			require.NoError(t, err)
+			claims, err := signer.Parse(link.Query().Get("token"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedTo, claims.Email, "Expected the token to verify the mailed email")
+		})
+	}
+}
+
+func TestManager_Verify(t *testing.T) {
+	verifiedAt := time.Date(2016, 5, 18, 16, 0, 0, 0, time.UTC)
+	unverified := models.User{ID: userID, Email: "alicetingo@example.com", Version: 3}
+	pending := models.User{ID: userID, Email: "alicetingo@example.com", EmailVerified: true, EmailVerifiedAt: &verifiedAt, PendingEmail: "alice@example.com", Version: 3}
+	verified := models.User{ID: userID, Email: "alicetingo@example.com", EmailVerified: true, EmailVerifiedAt: &verifiedAt, Version: 3}
+
+	for _, tc := range []struct {
+		name          string
+		mockedUser    models.User
+		token         string
+		expectedEmail string
+		shouldUpdate  bool
+		mockedError   error
+		expectedError error
+	}{
+		{"Verify new email", unverified, newToken(t, userID, "alicetingo@example.com"), "alicetingo@example.com", true, nil, nil},
+		{"Verify pending email", pending, newToken(t, userID, "alice@example.com"), "alice@example.com", true, nil, nil},
+		{"Verify already verified email", verified, newToken(t, userID, "alicetingo@example.com"), "alicetingo@example.com", false, nil, nil},
+		{"Verify replaced pending email", pending, newToken(t, userID, "old@example.com"), "", false, nil, verification.ErrInvalidToken},
+		{"Verify token of other user", unverified, newToken(t, "5cace01f-45c3-49f0-a725-c22866874095", "alicetingo@example.com"), "", false, nil, verification.ErrInvalidToken},
+		{"Verify with update error", unverified, newToken(t, userID, "alicetingo@example.com"), "", true, errors.New("homemade error"), errors.New("homemade error")},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			manager, m := newManager(t)
+			ctx := audit.NewContext(context.Background(), audit.Info{Actor: "admin@example.com"})
+			mockedUser := tc.mockedUser
+			m.users.EXPECT().GetById(ctx, userID).Return(&mockedUser, nil).MaxTimes(1)
+			var updated models.User
+			updateTimes := 0
+			if tc.shouldUpdate {
+				updateTimes = 1
+			}
+			m.users.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user models.User) (*models.User, error) {
+				assert.Equal(t, userID, audit.FromContext(ctx).Actor, "Expected the verification to be audited as made by the user")
+				updated = user
+				if tc.mockedError != nil {
+					return nil, tc.mockedError
+				}
+				return &user, nil
+			}).Times(updateTimes)
+			notifyTimes := 0
+			if tc.shouldUpdate && tc.mockedError == nil {
+				notifyTimes = 1
+			}
+			m.pubsub.EXPECT().NotifyEmailVerified(gomock.Any(), gomock.Any()).Return(nil).Times(notifyTimes)
+
+			// When
+			res, err := manager.Verify(ctx, userID, tc.token)
+
+			// Then
+			if tc.expectedError != nil {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedEmail, res.Email)
+			assert.True(t, res.EmailVerified)
+			assert.Empty(t, res.PendingEmail)
+			if tc.shouldUpdate {
+				assert.Equal(t, tc.mockedUser.Version, updated.Version, "Expected the update to check the read version")
+				require.NotNil(t, updated.EmailVerifiedAt)
+				assert.WithinDuration(t, time.Now(), *updated.EmailVerifiedAt, 5*time.Second)
+			}
+		})
+	}
+}
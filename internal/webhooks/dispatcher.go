@@ -0,0 +1,419 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Headers of the webhook requests
+const (
+	// HeaderEvent - topic of the delivered event
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery - ID of the delivery, different in each redelivery
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderTimestamp - unix time of the attempt, signed with the payload to prevent replays
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature - HMAC-SHA256 signature of the timestamp and the payload, see Sign
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// DefaultMaxAttempts - default number of attempts of each delivery
+	DefaultMaxAttempts = 6
+	// DefaultInitialBackoff - default time before the first retry, doubled on each retry
+	DefaultInitialBackoff = 30 * time.Second
+	// DefaultMaxBackoff - default maximum time between retries
+	DefaultMaxBackoff = time.Hour
+	// DefaultTimeout - default time given to the webhook URLs to reply
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxFailures - default number of consecutive failed attempts disabling a webhook
+	DefaultMaxFailures = 20
+	// DefaultPollInterval - default time between each check of the due retries
+	DefaultPollInterval = 5 * time.Second
+	// DefaultWorkers - default number of concurrent deliveries
+	DefaultWorkers = 8
+
+	// userAgent - User-Agent header of the webhook requests
+	userAgent = "user-microservice-webhooks"
+	// claimTTL - time an event is claimed by the replica dispatching it, the other replicas receive it too
+	claimTTL = time.Minute
+	// claimPrefix - prefix of the redis keys claiming the events
+	claimPrefix = "webhooks:event:"
+	// leaseMargin - time added to the timeout to claim a delivery, it's attempted again if the replica stops meanwhile
+	leaseMargin = 30 * time.Second
+	// maxResponseBody - bytes of the webhook responses read before closing them
+	maxResponseBody = 64 << 10
+)
+
+// Options - dispatcher options, the zero values are replaced by the defaults
+type Options struct {
+	// MaxAttempts - number of attempts of each delivery before failing it
+	MaxAttempts int
+	// InitialBackoff - time before the first retry, doubled on each retry
+	InitialBackoff time.Duration
+	// MaxBackoff - maximum time between retries
+	MaxBackoff time.Duration
+	// Timeout - time given to the webhook URLs to reply
+	Timeout time.Duration
+	// MaxFailures - consecutive failed attempts of a webhook, in any delivery, disabling it
+	MaxFailures int
+	// PollInterval - time between each check of the due retries
+	PollInterval time.Duration
+	// Workers - number of concurrent deliveries
+	Workers int
+}
+
+// Payload - JSON body sent to the webhooks, the user password is never serialised
+type Payload struct {
+	// ID - ID of the event, the same in all its deliveries, so the receivers can discard the duplicates
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UserID    string       `json:"userId"`
+	User      *models.User `json:"user,omitempty"`
+}
+
+// Dispatcher - delivers the user events to the webhooks subscribed to them. The deliveries are stored
+// before being sent, and the failed ones are retried with an exponential backoff by any replica
+type Dispatcher struct {
+	repo       Repository
+	subscriber userPS.Subscriber
+	rc         *redis.Client
+	client     *http.Client
+	opts       Options
+	metrics    *Metrics
+
+	// workers - semaphore limiting the concurrent deliveries
+	workers  chan struct{}
+	wakeup   chan struct{}
+	inFlight sync.WaitGroup
+}
+
+// NewDispatcher - returns a new Dispatcher of the subscriber events, which must be started with Run.
+// The events are claimed in redis, so each one is dispatched once even if every replica receives it
+func NewDispatcher(repo Repository, subscriber userPS.Subscriber, rc *redis.Client, opts Options, metrics *Metrics) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = DefaultMaxFailures
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+
+	return &Dispatcher{
+		repo:       repo,
+		subscriber: subscriber,
+		rc:         rc,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			// the redirects are failed attempts, the webhook URL must be updated instead
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		opts:    opts,
+		metrics: metrics,
+		workers: make(chan struct{}, opts.Workers),
+		wakeup:  make(chan struct{}, 1),
+	}
+}
+
+// Sign - returns the signature of the payload sent at the timestamp (unix time) with the secret, as sent in the
+// HeaderSignature header: "sha256=" followed by the hex encoded HMAC-SHA256 of "<timestamp>.<payload>"
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run - dispatches the events of all the user topics and sends the due deliveries until the context is done,
+// waiting for the deliveries in progress before returning. Should be run in its own goroutine
+func (d *Dispatcher) Run(ctx context.Context) {
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		d.subscribe(ctx)
+	}()
+	defer func() {
+		<-subscribed
+		d.inFlight.Wait()
+	}()
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wakeup:
+		}
+	}
+}
+
+// subscribe - dispatches the events of the user topics until the context is done
+func (d *Dispatcher) subscribe(ctx context.Context) {
+	userPS.Listen(ctx, d.subscriber, "webhooks", func(event userPS.Event) {
+		d.dispatch(ctx, event)
+	})
+}
+
+// dispatch - stores a pending delivery of the event for each enabled webhook of its topic
+func (d *Dispatcher) dispatch(ctx context.Context, event userPS.Event) {
+	webhooks, err := d.repo.ListEnabled(ctx, event.Topic)
+	if err != nil {
+		logrus.Errorf("Error in webhooks.dispatch -> error listing the webhooks of %s: %s", event.Topic, err)
+		return
+	}
+	if len(webhooks) == 0 || !d.claim(ctx, event) {
+		return
+	}
+
+	eventID := event.ID
+	if eventID == "" {
+		eventID = uuid.NewString()
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	payload, err := json.Marshal(newPayload(event, eventID, now))
+	if err != nil {
+		logrus.Errorf("Error in webhooks.dispatch -> error encoding %s event of user %s: %s", event.Topic, event.UserID, err)
+		return
+	}
+	for _, webhook := range webhooks {
+		_, err := d.repo.CreateDelivery(ctx, models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			Event:         event.Topic,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		})
+		if err != nil {
+			logrus.Errorf("Error in webhooks.dispatch -> error creating delivery of event %s to webhook %s: %s", eventID, webhook.ID, err)
+		}
+	}
+	d.wake()
+}
+
+// claim - returns true if this replica must dispatch the event, claimed by its ID. The events without ID,
+// published by the older versions, are claimed by their content instead. The event is dispatched anyway
+// if redis fails, duplicated deliveries are better than lost ones
+func (d *Dispatcher) claim(ctx context.Context, event userPS.Event) bool {
+	key := event.ID
+	if key == "" {
+		content := event.UserID
+		if event.User != nil {
+			encoded, err := json.Marshal(event.User)
+			if err == nil {
+				content = string(encoded)
+			}
+		}
+		sum := sha256.Sum256([]byte(event.Topic + "\n" + content))
+		key = hex.EncodeToString(sum[:])
+	}
+
+	claimed, err := d.rc.SetNX(ctx, claimPrefix+key, 1, claimTTL).Result()
+	if err != nil {
+		logrus.Errorf("Error in webhooks.claim -> error claiming %s event of user %s: %s", event.Topic, event.UserID, err)
+		return true
+	}
+	return claimed
+}
+
+// wake - makes Run check the due deliveries now
+func (d *Dispatcher) wake() {
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue - claims the due deliveries and sends them, while there are free workers
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d.workers <- struct{}{}:
+		}
+
+		delivery, err := d.repo.ClaimDelivery(ctx, time.Now().UTC().Truncate(time.Millisecond), d.opts.Timeout+leaseMargin)
+		if err != nil {
+			<-d.workers
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				logrus.Errorf("Error in webhooks.deliverDue -> error claiming a delivery: %s", err)
+			}
+			return
+		}
+
+		d.inFlight.Add(1)
+		go func() {
+			defer d.inFlight.Done()
+			defer func() { <-d.workers }()
+			d.attempt(*delivery)
+		}()
+	}
+}
+
+// attempt - sends the delivery and saves its result, scheduling its retry if it failed and counting the
+// failure in the webhook, which is disabled after too many consecutive failures
+func (d *Dispatcher) attempt(delivery models.WebhookDelivery) {
+	// the attempt is not cancelled when Run stops, its lease covers it
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout+leaseMargin)
+	defer cancel()
+
+	webhook, err := d.repo.Get(ctx, delivery.WebhookID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		// it's attempted again when the lease ends
+		return
+	}
+	if webhook == nil || !webhook.Enabled {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook deleted"
+		if webhook != nil {
+			delivery.Error = "webhook disabled"
+		}
+		delivery.NextAttemptAt = nil
+		d.save(ctx, delivery)
+		return
+	}
+
+	status, err := d.post(ctx, *webhook, delivery)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.Error = ""
+	delivery.NextAttemptAt = nil
+
+	if err == nil {
+		d.metrics.attempts.WithLabelValues("succeeded").Inc()
+		delivery.Status = models.DeliverySucceeded
+		d.save(ctx, delivery)
+		if webhook.Failures > 0 {
+			if err := d.repo.ResetFailures(ctx, webhook.ID); err != nil {
+				logrus.Errorf("Error in webhooks.attempt -> error resetting failures of webhook %s: %s", webhook.ID, err)
+			}
+		}
+		return
+	}
+
+	d.metrics.attempts.WithLabelValues("failed").Inc()
+	logrus.Infof("Info in webhooks.attempt -> attempt %d of delivery %s to webhook %s failed: %s", delivery.Attempts, delivery.ID, webhook.ID, err)
+	delivery.Error = err.Error()
+	disabled := d.fail(ctx, *webhook, now)
+	if disabled || delivery.Attempts >= d.opts.MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+	} else {
+		next := now.Add(d.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	d.save(ctx, delivery)
+}
+
+// post - sends the signed payload of the delivery to the webhook, returning the response status code
+// and an error if there is no response or it's not a 2xx one
+func (d *Dispatcher) post(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// the body is read so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// fail - counts the failed attempt in the webhook, disabling it if it has too many consecutive failures.
+// Returns true if it was disabled
+func (d *Dispatcher) fail(ctx context.Context, webhook models.Webhook, now time.Time) bool {
+	failures, err := d.repo.IncrementFailures(ctx, webhook.ID)
+	if err != nil {
+		logrus.Errorf("Error in webhooks.fail -> error counting failure of webhook %s: %s", webhook.ID, err)
+		return false
+	}
+	if failures < d.opts.MaxFailures {
+		return false
+	}
+
+	if err := d.repo.Disable(ctx, webhook.ID, now); err != nil {
+		logrus.Errorf("Error in webhooks.fail -> error disabling webhook %s: %s", webhook.ID, err)
+		return false
+	}
+	d.metrics.disabled.Inc()
+	logrus.WithFields(logrus.Fields{"webhook": webhook.ID, "failures": failures}).Warn("Webhook disabled after too many consecutive failures")
+
+	return true
+}
+
+func (d *Dispatcher) save(ctx context.Context, delivery models.WebhookDelivery) {
+	if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+		logrus.Errorf("Error in webhooks.save -> error saving delivery %s: %s", delivery.ID, err)
+	}
+}
+
+// backoff - returns the time before the retry of a delivery with the given attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.InitialBackoff
+	for i := 1; i < attempts && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
+
+func newPayload(event userPS.Event, id string, now time.Time) Payload {
+	return Payload{ID: id, Type: event.Topic, CreatedAt: now, UserID: event.UserID, User: event.User}
+}
@@ -0,0 +1,504 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/users/mock"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/webhooks"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const secret = "test-webhook-secret"
+
+// fakeRepository - in-memory webhooks repository
+type fakeRepository struct {
+	mu         sync.Mutex
+	webhooks   map[string]models.Webhook
+	deliveries map[string]models.WebhookDelivery
+}
+
+func newFakeRepository(webhooks ...models.Webhook) *fakeRepository {
+	r := &fakeRepository{webhooks: map[string]models.Webhook{}, deliveries: map[string]models.WebhookDelivery{}}
+	for _, webhook := range webhooks {
+		r.webhooks[webhook.ID] = webhook
+	}
+	return r
+}
+
+func (r *fakeRepository) Create(_ context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook.ID = uuid.NewString()
+	r.webhooks[webhook.ID] = webhook
+	return &webhook, nil
+}
+
+func (r *fakeRepository) Get(_ context.Context, id string) (*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, isOK := r.webhooks[id]
+	if !isOK {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &webhook, nil
+}
+
+func (r *fakeRepository) List(_ context.Context) ([]models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := []models.Webhook{}
+	for _, webhook := range r.webhooks {
+		res = append(res, webhook)
+	}
+	return res, nil
+}
+
+func (r *fakeRepository) ListEnabled(ctx context.Context, topic string) ([]models.Webhook, error) {
+	all, _ := r.List(ctx)
+	res := []models.Webhook{}
+	for _, webhook := range all {
+		if webhook.Enabled && webhook.Subscribed(topic) {
+			res = append(res, webhook)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeRepository) Update(_ context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, isOK := r.webhooks[webhook.ID]; !isOK {
+		return nil, mongo.ErrNoDocuments
+	}
+	r.webhooks[webhook.ID] = webhook
+	return &webhook, nil
+}
+
+func (r *fakeRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, isOK := r.webhooks[id]; !isOK {
+		return mongo.ErrNoDocuments
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *fakeRepository) IncrementFailures(_ context.Context, id string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, isOK := r.webhooks[id]
+	if !isOK {
+		return 0, mongo.ErrNoDocuments
+	}
+	webhook.Failures++
+	r.webhooks[id] = webhook
+	return webhook.Failures, nil
+}
+
+func (r *fakeRepository) ResetFailures(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if webhook, isOK := r.webhooks[id]; isOK {
+		webhook.Failures = 0
+		r.webhooks[id] = webhook
+	}
+	return nil
+}
+
+func (r *fakeRepository) Disable(_ context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if webhook, isOK := r.webhooks[id]; isOK && webhook.Enabled {
+		webhook.Enabled = false
+		webhook.DisabledAt = &at
+		r.webhooks[id] = webhook
+	}
+	return nil
+}
+
+func (r *fakeRepository) CreateDelivery(_ context.Context, delivery models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery.ID = uuid.NewString()
+	delivery.CreatedAt = time.Now()
+	r.deliveries[delivery.ID] = delivery
+	return &delivery, nil
+}
+
+func (r *fakeRepository) GetDelivery(_ context.Context, webhookID, id string) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, isOK := r.deliveries[id]
+	if !isOK || delivery.WebhookID != webhookID {
+		return nil, mongo.ErrNoDocuments
+	}
+	return &delivery, nil
+}
+
+func (r *fakeRepository) ListDeliveries(_ context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := []models.WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID {
+			res = append(res, delivery)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *fakeRepository) ClaimDelivery(_ context.Context, now time.Time, lease time.Duration) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, delivery := range r.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			next := now.Add(lease)
+			claimed := delivery
+			delivery.NextAttemptAt = &next
+			r.deliveries[id] = delivery
+			return &claimed, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *fakeRepository) UpdateDelivery(_ context.Context, delivery models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+// allDeliveries - returns all the deliveries of the webhook
+func (r *fakeRepository) allDeliveries(webhookID string) []models.WebhookDelivery {
+	res, _ := r.ListDeliveries(context.Background(), webhookID, 1000)
+	return res
+}
+
+// receiver - webhook URL replying with the statuses in order, the last one repeated
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		status := r.statuses[len(r.statuses)-1]
+		if len(r.requests) < len(r.statuses) {
+			status = r.statuses[len(r.requests)]
+		}
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// startDispatcher - runs a dispatcher of the repository relaying the events sent to the returned channel until the test ends
+func startDispatcher(t *testing.T, repo webhooks.Repository, rc *redis.Client, opts webhooks.Options) (*webhooks.Dispatcher, chan<- userPS.Event) {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	subscriber := mock.NewMockSubscriber(ctrl)
+	source := make(chan userPS.Event)
+	subscriber.EXPECT().Subscribe(gomock.Any()).Return((<-chan userPS.Event)(source), nil)
+
+	dispatcher := webhooks.NewDispatcher(repo, subscriber, rc, opts, webhooks.NewMetrics(prometheus.NewRegistry()))
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		close(source)
+		<-stopped
+	})
+
+	return dispatcher, source
+}
+
+func newRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	return redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+}
+
+func TestSign(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		secret    string
+		timestamp int64
+		payload   string
+		expected  string
+	}{
+		{
+			"Sign payload",
+			"It's a secret",
+			1684425600,
+			`{"id":"1"}`,
+			"sha256=ac6e3a781d8abc81cc09786ebc83dd93188d731c1d9a301a0c8db7d7fcb7dd4f",
+		},
+		{
+			"Sign payload with another secret at another time",
+			"Another secret",
+			1684425601,
+			`{"id":"1"}`,
+			"sha256=954d9b1a3c8adce2619209fdc675a755d92e2f856a1752d35ff05ba89b33db50",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// When
+			signature := webhooks.Sign(tc.secret, tc.timestamp, []byte(tc.payload))
+
+			// Then
+			assert.Equalf(t, tc.expected, signature, "Expected signature to be %s, but was %s", tc.expected, signature)
+		})
+	}
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	user := models.User{ID: uuid.NewString(), Nickname: "atingo", Password: "hashed password"}
+	for _, tc := range []struct {
+		name             string
+		statuses         []int
+		opts             webhooks.Options
+		expectedStatus   models.DeliveryStatus
+		expectedAttempts int
+		expectedEnabled  bool
+		expectedFailures int
+	}{
+		{
+			"Deliver event successfully",
+			[]int{http.StatusOK},
+			webhooks.Options{},
+			models.DeliverySucceeded,
+			1,
+			true,
+			0,
+		},
+		{
+			"Deliver event after retrying the failed attempts",
+			[]int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusNoContent},
+			webhooks.Options{},
+			models.DeliverySucceeded,
+			3,
+			true,
+			0,
+		},
+		{
+			"Fail delivery after the maximum attempts",
+			[]int{http.StatusInternalServerError},
+			webhooks.Options{MaxAttempts: 3},
+			models.DeliveryFailed,
+			3,
+			true,
+			3,
+		},
+		{
+			"Fail delivery with redirect",
+			[]int{http.StatusMovedPermanently},
+			webhooks.Options{MaxAttempts: 1},
+			models.DeliveryFailed,
+			1,
+			true,
+			1,
+		},
+		{
+			"Disable webhook after too many consecutive failures",
+			[]int{http.StatusInternalServerError},
+			webhooks.Options{MaxAttempts: 5, MaxFailures: 2},
+			models.DeliveryFailed,
+			2,
+			false,
+			2,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			server := newReceiver(t, tc.statuses...)
+			webhook := models.Webhook{
+				ID:      uuid.NewString(),
+				URL:     server.URL,
+				Events:  []string{userPS.TopicUserCreation},
+				Secret:  secret,
+				Enabled: true,
+			}
+			repo := newFakeRepository(webhook)
+			tc.opts.InitialBackoff = 10 * time.Millisecond
+			tc.opts.MaxBackoff = 20 * time.Millisecond
+			tc.opts.PollInterval = 10 * time.Millisecond
+			_, source := startDispatcher(t, repo, newRedis(t), tc.opts)
+
+			// When
+			source <- userPS.Event{Topic: userPS.TopicUserCreation, UserID: user.ID, User: &user}
+
+			// Then
+			var delivery models.WebhookDelivery
+			require.Eventuallyf(t, func() bool {
+				deliveries := repo.allDeliveries(webhook.ID)
+				if len(deliveries) != 1 || deliveries[0].Status == models.DeliveryPending {
+					return false
+				}
+				delivery = deliveries[0]
+				return true
+			}, 5*time.Second, 10*time.Millisecond, "Expected the delivery to end")
+
+			assert.Equalf(t, tc.expectedStatus, delivery.Status, "Expected status to be %s, but was %s", tc.expectedStatus, delivery.Status)
+			assert.Equalf(t, tc.expectedAttempts, delivery.Attempts, "Expected %d attempts, but were %d", tc.expectedAttempts, delivery.Attempts)
+			assert.Equalf(t, tc.expectedAttempts, server.received(), "Expected %d requests, but were %d", tc.expectedAttempts, server.received())
+			assert.Nilf(t, delivery.NextAttemptAt, "Expected no next attempt, but was %v", delivery.NextAttemptAt)
+			found, err := repo.Get(context.Background(), webhook.ID)
+			require.NoError(t, err)
+			assert.Equalf(t, tc.expectedEnabled, found.Enabled, "Expected enabled to be %t, but was %t", tc.expectedEnabled, found.Enabled)
+			assert.Equalf(t, tc.expectedFailures, found.Failures, "Expected %d failures, but were %d", tc.expectedFailures, found.Failures)
+
+			req, body := server.requests[0], server.bodies[0]
+			timestamp, err := strconv.ParseInt(req.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+			require.NoErrorf(t, err, "Expected a valid timestamp, but was %s", err)
+			expectedSignature := webhooks.Sign(secret, timestamp, body)
+			assert.Equalf(t, expectedSignature, req.Header.Get(webhooks.HeaderSignature), "Expected the signature to be %s", expectedSignature)
+			assert.Equalf(t, userPS.TopicUserCreation, req.Header.Get(webhooks.HeaderEvent), "Expected the event header to be %s", userPS.TopicUserCreation)
+			assert.Equalf(t, delivery.ID, req.Header.Get(webhooks.HeaderDelivery), "Expected the delivery header to be %s", delivery.ID)
+			var payload webhooks.Payload
+			require.NoError(t, json.Unmarshal(body, &payload))
+			assert.Equalf(t, delivery.EventID, payload.ID, "Expected the payload ID to be %s, but was %s", delivery.EventID, payload.ID)
+			assert.Equalf(t, user.ID, payload.UserID, "Expected the user ID to be %s, but was %s", user.ID, payload.UserID)
+			assert.NotContainsf(t, string(body), "password", "Expected the user not to contain the password")
+			require.NotNil(t, payload.User)
+			assert.Equalf(t, user.Nickname, payload.User.Nickname, "Expected nickname to be %s, but was %s", user.Nickname, payload.User.Nickname)
+		})
+	}
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server := newReceiver(t, http.StatusOK)
+	subscribed := models.Webhook{ID: uuid.NewString(), URL: server.URL, Events: []string{userPS.TopicUserDeletion}, Secret: secret, Enabled: true}
+	other := models.Webhook{ID: uuid.NewString(), URL: server.URL, Events: []string{userPS.TopicUserCreation}, Secret: secret, Enabled: true}
+	disabled := models.Webhook{ID: uuid.NewString(), URL: server.URL, Events: []string{userPS.TopicUserDeletion}, Secret: secret}
+	repo := newFakeRepository(subscribed, other, disabled)
+	rc := newRedis(t)
+	opts := webhooks.Options{PollInterval: 10 * time.Millisecond}
+	// every replica receives the events
+	_, first := startDispatcher(t, repo, rc, opts)
+	_, second := startDispatcher(t, repo, rc, opts)
+
+	// When
+	event := userPS.Event{ID: uuid.NewString(), Topic: userPS.TopicUserDeletion, UserID: uuid.NewString()}
+	first <- event
+	second <- event
+
+	// Then
+	require.Eventuallyf(t, func() bool { return server.received() == 1 }, 5*time.Second, 10*time.Millisecond, "Expected 1 request")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equalf(t, 1, server.received(), "Expected the event to be delivered once, but it was delivered %d times", server.received())
+	assert.Lenf(t, repo.allDeliveries(subscribed.ID), 1, "Expected 1 delivery of the subscribed webhook")
+	assert.Emptyf(t, repo.allDeliveries(other.ID), "Expected no delivery of the webhook of other events")
+	assert.Emptyf(t, repo.allDeliveries(disabled.ID), "Expected no delivery of the disabled webhook")
+}
+
+func TestDispatcher_DispatchIdenticalEvents(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server := newReceiver(t, http.StatusOK)
+	webhook := models.Webhook{ID: uuid.NewString(), URL: server.URL, Events: []string{userPS.TopicUserDeletion}, Secret: secret, Enabled: true}
+	repo := newFakeRepository(webhook)
+	rc := newRedis(t)
+	opts := webhooks.Options{PollInterval: 10 * time.Millisecond}
+	_, first := startDispatcher(t, repo, rc, opts)
+	_, second := startDispatcher(t, repo, rc, opts)
+
+	// When
+	// the same user is deleted, restored and deleted again
+	userID := uuid.NewString()
+	deleted := userPS.Event{ID: uuid.NewString(), Topic: userPS.TopicUserDeletion, UserID: userID}
+	deletedAgain := userPS.Event{ID: uuid.NewString(), Topic: userPS.TopicUserDeletion, UserID: userID}
+	for _, event := range []userPS.Event{deleted, deletedAgain} {
+		first <- event
+		second <- event
+	}
+
+	// Then
+	require.Eventuallyf(t, func() bool { return server.received() == 2 }, 5*time.Second, 10*time.Millisecond, "Expected 2 requests")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equalf(t, 2, server.received(), "Expected each event to be delivered once, but there were %d requests", server.received())
+	deliveries := repo.allDeliveries(webhook.ID)
+	require.Lenf(t, deliveries, 2, "Expected 2 deliveries")
+	assert.ElementsMatchf(t, []string{deleted.ID, deletedAgain.ID}, []string{deliveries[0].EventID, deliveries[1].EventID}, "Expected the deliveries to have the IDs of the events")
+}
+
+func TestDispatcher_DisabledWebhook(t *testing.T) {
+	t.Parallel()
+
+	// Given
+	server := newReceiver(t, http.StatusOK)
+	webhook := models.Webhook{ID: uuid.NewString(), URL: server.URL, Events: []string{userPS.TopicUserDeletion}, Secret: secret}
+	repo := newFakeRepository(webhook)
+	now := time.Now()
+	pending, err := repo.CreateDelivery(context.Background(), models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       uuid.NewString(),
+		Event:         userPS.TopicUserDeletion,
+		Payload:       `{}`,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+	})
+	require.NoError(t, err)
+
+	// When
+	startDispatcher(t, repo, newRedis(t), webhooks.Options{PollInterval: 10 * time.Millisecond})
+
+	// Then
+	require.Eventuallyf(t, func() bool {
+		delivery, _ := repo.GetDelivery(context.Background(), webhook.ID, pending.ID)
+		return delivery.Status == models.DeliveryFailed
+	}, 5*time.Second, 10*time.Millisecond, "Expected the delivery of the disabled webhook to fail")
+	assert.Zerof(t, server.received(), "Expected no request, but were %d", server.received())
+}
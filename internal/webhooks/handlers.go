@@ -0,0 +1,15 @@
+//go:generate mockgen -source handlers.go -destination mock/handlers_mock.go -package mock
+package webhooks
+
+import "github.com/labstack/echo/v4"
+
+// Handler - webhooks handlers
+type Handler interface {
+	CreateWebhook(c echo.Context) error
+	ListWebhooks(c echo.Context) error
+	GetWebhook(c echo.Context) error
+	UpdateWebhook(c echo.Context) error
+	DeleteWebhook(c echo.Context) error
+	ListDeliveries(c echo.Context) error
+	Redeliver(c echo.Context) error
+}
@@ -0,0 +1,301 @@
+// @tag.name        Webhooks
+// @tag.description Webhook subscriptions of the user events
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/webhooks"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type httpHandler struct {
+	manager *webhooks.Manager
+}
+
+var _ webhooks.Handler = httpHandler{}
+var _ webhooks.Handler = (*httpHandler)(nil)
+
+// NewHttpHandler - returns a new webhooks http handler initialized with the manager
+func NewHttpHandler(manager *webhooks.Manager) webhooks.Handler {
+	return &httpHandler{manager}
+}
+
+// CreateWebhook godoc
+//
+// @Summary     Registers a webhook
+// @Description Registers a URL receiving the user events of the given types. Each event is sent in a POST request signed with the secret: the X-Webhook-Signature header is "sha256=" followed by the hex encoded HMAC-SHA256 of the X-Webhook-Timestamp header, a dot and the body. The requests without a 2xx response are retried with an exponential backoff, and the webhook is disabled after too many consecutive failures
+// @Tags        Webhooks
+// @Accept      json
+// @Produce     json
+// @Param       body body     models.WebhookRequest true "Webhook URL, events and secret"
+// @Success     201  {object} models.Webhook
+// @Failure     400  {object} echo.HTTPError
+// @Failure     401  {object} echo.HTTPError
+// @Failure     403  {object} echo.HTTPError
+// @Failure     500  {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks [post]
+func (h httpHandler) CreateWebhook(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	var body models.WebhookRequest
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in webhooks/http.CreateWebhook -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	res, err := h.manager.Create(c.Request().Context(), body)
+	if err != nil {
+		return toHTTPError(err, "")
+	}
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+// ListWebhooks godoc
+//
+// @Summary     Lists the webhooks
+// @Description Lists all the webhooks, including the disabled ones, without their secrets
+// @Tags        Webhooks
+// @Produce     json
+// @Success     200 {array}  models.Webhook
+// @Failure     401 {object} echo.HTTPError
+// @Failure     403 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks [get]
+func (h httpHandler) ListWebhooks(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+
+	res, err := h.manager.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetWebhook godoc
+//
+// @Summary     Gets a webhook
+// @Description Gets a webhook by its ID, without its secret
+// @Tags        Webhooks
+// @Produce     json
+// @Param       webhookId path     string true "Webhook id" format(uuid) example(3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b)
+// @Success     200       {object} models.Webhook
+// @Failure     400       {object} echo.HTTPError
+// @Failure     401       {object} echo.HTTPError
+// @Failure     403       {object} echo.HTTPError
+// @Failure     404       {object} echo.HTTPError
+// @Failure     500       {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks/{webhookId} [get]
+func (h httpHandler) GetWebhook(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	id, err := parseID(c, "webhookId")
+	if err != nil {
+		return err
+	}
+
+	res, err := h.manager.Get(c.Request().Context(), id)
+	if err != nil {
+		return toHTTPError(err, id)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// UpdateWebhook godoc
+//
+// @Summary     Updates a webhook
+// @Description Replaces the URL and the events of a webhook, and its secret if the body has one. It can be disabled, or enabled again resetting its failures, with the enabled field
+// @Tags        Webhooks
+// @Accept      json
+// @Produce     json
+// @Param       webhookId path     string                true "Webhook id" format(uuid) example(3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b)
+// @Param       body      body     models.WebhookRequest true "Webhook URL, events, secret and status"
+// @Success     200       {object} models.Webhook
+// @Failure     400       {object} echo.HTTPError
+// @Failure     401       {object} echo.HTTPError
+// @Failure     403       {object} echo.HTTPError
+// @Failure     404       {object} echo.HTTPError
+// @Failure     500       {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks/{webhookId} [put]
+func (h httpHandler) UpdateWebhook(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	id, err := parseID(c, "webhookId")
+	if err != nil {
+		return err
+	}
+	var body models.WebhookRequest
+	if err := c.Bind(&body); err != nil {
+		logrus.Errorf("Error in webhooks/http.UpdateWebhook -> error binding body: %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	res, err := h.manager.Update(c.Request().Context(), id, body)
+	if err != nil {
+		return toHTTPError(err, id)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// DeleteWebhook godoc
+//
+// @Summary     Deletes a webhook
+// @Description Deletes a webhook, its pending deliveries are failed. Its delivery log is kept until it expires
+// @Tags        Webhooks
+// @Param       webhookId path string true "Webhook id" format(uuid) example(3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b)
+// @Success     204
+// @Failure     400 {object} echo.HTTPError
+// @Failure     401 {object} echo.HTTPError
+// @Failure     403 {object} echo.HTTPError
+// @Failure     404 {object} echo.HTTPError
+// @Failure     500 {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks/{webhookId} [delete]
+func (h httpHandler) DeleteWebhook(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	id, err := parseID(c, "webhookId")
+	if err != nil {
+		return err
+	}
+
+	if err := h.manager.Delete(c.Request().Context(), id); err != nil {
+		return toHTTPError(err, id)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+//
+// @Summary     Lists the deliveries of a webhook
+// @Description Lists the last deliveries of a webhook (the delivery log), the newest first, with their payload and the result of their last attempt
+// @Tags        Webhooks
+// @Produce     json
+// @Param       webhookId path     string true  "Webhook id"           format(uuid) example(3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b)
+// @Param       limit     query    int    false "Number of deliveries" minimum(1)   maximum(100) default(50)
+// @Success     200       {array}  models.WebhookDelivery
+// @Failure     400       {object} echo.HTTPError
+// @Failure     401       {object} echo.HTTPError
+// @Failure     403       {object} echo.HTTPError
+// @Failure     404       {object} echo.HTTPError
+// @Failure     500       {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks/{webhookId}/deliveries [get]
+func (h httpHandler) ListDeliveries(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	id, err := parseID(c, "webhookId")
+	if err != nil {
+		return err
+	}
+	limit := webhooks.DefaultDeliveriesLimit
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > webhooks.MaxDeliveriesLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams)
+		}
+	}
+
+	res, err := h.manager.ListDeliveries(c.Request().Context(), id, limit)
+	if err != nil {
+		return toHTTPError(err, id)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// Redeliver godoc
+//
+// @Summary     Sends a delivery again
+// @Description Sends the payload of a delivery again in a new delivery, with the same event ID, which is retried as any other delivery
+// @Tags        Webhooks
+// @Produce     json
+// @Param       webhookId  path     string true "Webhook id"  format(uuid) example(3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b)
+// @Param       deliveryId path     string true "Delivery id" format(uuid) example(9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d)
+// @Success     202        {object} models.WebhookDelivery
+// @Failure     400        {object} echo.HTTPError
+// @Failure     401        {object} echo.HTTPError
+// @Failure     403        {object} echo.HTTPError
+// @Failure     404        {object} echo.HTTPError
+// @Failure     409        {object} echo.HTTPError
+// @Failure     500        {object} echo.HTTPError
+// @Security    BearerAuth
+// @Security    ApiKeyAuth
+// @Router      /webhooks/{webhookId}/deliveries/{deliveryId}/redeliver [post]
+func (h httpHandler) Redeliver(c echo.Context) error {
+	if err := auth.CheckRequest(c, auth.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	id, err := parseID(c, "webhookId")
+	if err != nil {
+		return err
+	}
+	deliveryID, err := parseID(c, "deliveryId")
+	if err != nil {
+		return err
+	}
+
+	res, err := h.manager.Redeliver(c.Request().Context(), id, deliveryID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Delivery not found for ID %s of webhook %s", deliveryID, id))
+		}
+		return toHTTPError(err, id)
+	}
+
+	return c.JSON(http.StatusAccepted, res)
+}
+
+// toHTTPError - returns the http error of the webhooks errors, or the error itself if it's not one of them
+func toHTTPError(err error, id string) error {
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidURL), errors.Is(err, webhooks.ErrInvalidEvents), errors.Is(err, webhooks.ErrInvalidSecret):
+		logrus.Infof("Info in webhooks/http -> %s", err)
+		return echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody)
+	case errors.Is(err, webhooks.ErrWebhookDisabled):
+		return echo.NewHTTPError(http.StatusConflict, httpErrors.ErrWebhookDisabled)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Webhook not found for ID %s", id))
+	}
+	return err
+}
+
+func parseID(c echo.Context, param string) (string, error) {
+	idStr := c.Param(param)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid ID %s", idStr))
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,281 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-microservice/internal/auth"
+	httpErrors "user-microservice/internal/errors/http"
+	"user-microservice/internal/models"
+	"user-microservice/internal/testutils"
+	"user-microservice/internal/webhooks"
+	webhooksHttp "user-microservice/internal/webhooks/http"
+	"user-microservice/internal/webhooks/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	webhookID  = "3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b"
+	deliveryID = "9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+)
+
+var (
+	admin   = auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleAdmin}
+	support = auth.Principal{Subject: "5cace01f-45c3-49f0-a725-c22866874095", Role: auth.RoleSupport}
+)
+
+func newHandler(repo webhooks.Repository) webhooks.Handler {
+	dispatcher := webhooks.NewDispatcher(repo, nil, nil, webhooks.Options{}, webhooks.NewMetrics(prometheus.NewRegistry()))
+	return webhooksHttp.NewHttpHandler(webhooks.NewManager(repo, dispatcher))
+}
+
+func newContext(method, target, body string, principal *auth.Principal) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if principal != nil {
+		req = req.WithContext(auth.NewPrincipalContext(req.Context(), *principal))
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+func TestCreateWebhook(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		body          string
+		principal     *auth.Principal
+		expectedCode  int
+		expectedError error
+	}{
+		{
+			"Create webhook successfully",
+			`{"url":"https://partner.example.com/hooks","events":["user-created"],"secret":"test-webhook-secret"}`,
+			&admin,
+			http.StatusCreated,
+			nil,
+		},
+		{
+			"Create webhook without auth",
+			`{"url":"https://partner.example.com/hooks","events":["user-created"],"secret":"test-webhook-secret"}`,
+			nil,
+			http.StatusCreated,
+			nil,
+		},
+		{
+			"Create webhook without admin role",
+			`{"url":"https://partner.example.com/hooks","events":["user-created"],"secret":"test-webhook-secret"}`,
+			&support,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+		},
+		{
+			"Create webhook with unknown event",
+			`{"url":"https://partner.example.com/hooks","events":["homemade"],"secret":"test-webhook-secret"}`,
+			&admin,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+		{
+			"Create webhook with short secret",
+			`{"url":"https://partner.example.com/hooks","events":["user-created"],"secret":"short"}`,
+			&admin,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidBody),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, webhook models.Webhook) (*models.Webhook, error) {
+				webhook.ID = webhookID
+				return &webhook, nil
+			}).MaxTimes(1)
+			c, rec := newContext(http.MethodPost, "/", tc.body, tc.principal)
+
+			// When
+			err := newHandler(repo).CreateWebhook(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			var res map[string]interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equalf(t, webhookID, res["id"], "Expected id to be %s, but was %v", webhookID, res["id"])
+			assert.NotContainsf(t, res, "secret", "Expected the secret not to be returned")
+		})
+	}
+}
+
+func TestListDeliveries(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		id            string
+		query         string
+		mockedError   error
+		expectedLimit int
+		expectedCode  int
+		expectedError error
+	}{
+		{"List deliveries successfully", webhookID, "", nil, webhooks.DefaultDeliveriesLimit, http.StatusOK, nil},
+		{"List deliveries with limit", webhookID, "?limit=10", nil, 10, http.StatusOK, nil},
+		{
+			"List deliveries with too large limit",
+			webhookID,
+			"?limit=101",
+			nil,
+			0,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, httpErrors.ErrInvalidParams),
+		},
+		{
+			"List deliveries with wrong id",
+			"wrong-id",
+			"",
+			nil,
+			0,
+			http.StatusBadRequest,
+			echo.NewHTTPError(http.StatusBadRequest, "Invalid ID wrong-id"),
+		},
+		{
+			"List deliveries of not found webhook",
+			webhookID,
+			"",
+			mongo.ErrNoDocuments,
+			0,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Webhook not found for ID %s", webhookID)),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			var mockedWebhook *models.Webhook
+			if tc.mockedError == nil {
+				mockedWebhook = &models.Webhook{ID: webhookID, Enabled: true}
+			}
+			repo.EXPECT().Get(gomock.Any(), webhookID).Return(mockedWebhook, tc.mockedError).MaxTimes(1)
+			if tc.expectedError == nil {
+				repo.EXPECT().ListDeliveries(gomock.Any(), webhookID, tc.expectedLimit).Return([]models.WebhookDelivery{{ID: deliveryID, WebhookID: webhookID}}, nil)
+			}
+			c, rec := newContext(http.MethodGet, "/"+tc.query, "", &admin)
+			c.SetParamNames("webhookId")
+			c.SetParamValues(tc.id)
+
+			// When
+			err := newHandler(repo).ListDeliveries(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			var res []models.WebhookDelivery
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Lenf(t, res, 1, "Expected 1 delivery, but were %d", len(res))
+		})
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		enabled          bool
+		mockedError      error
+		principal        *auth.Principal
+		expectedCode     int
+		expectedError    error
+		expectedDelivery bool
+	}{
+		{"Redeliver successfully", true, nil, &admin, http.StatusAccepted, nil, true},
+		{
+			"Redeliver without admin role",
+			true,
+			nil,
+			&support,
+			http.StatusForbidden,
+			echo.NewHTTPError(http.StatusForbidden, httpErrors.ErrForbidden),
+			false,
+		},
+		{
+			"Redeliver of disabled webhook",
+			false,
+			nil,
+			&admin,
+			http.StatusConflict,
+			echo.NewHTTPError(http.StatusConflict, httpErrors.ErrWebhookDisabled),
+			false,
+		},
+		{
+			"Redeliver not found delivery",
+			true,
+			mongo.ErrNoDocuments,
+			&admin,
+			http.StatusNotFound,
+			echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("Delivery not found for ID %s of webhook %s", deliveryID, webhookID)),
+			false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			repo.EXPECT().Get(gomock.Any(), webhookID).Return(&models.Webhook{ID: webhookID, Enabled: tc.enabled}, nil).MaxTimes(1)
+			var mockedDelivery *models.WebhookDelivery
+			if tc.mockedError == nil {
+				mockedDelivery = &models.WebhookDelivery{ID: deliveryID, WebhookID: webhookID, EventID: "e3b4c1d2-0a9f-4e8d-b7c6-5a4b3c2d1e0f", Status: models.DeliveryFailed}
+			}
+			repo.EXPECT().GetDelivery(gomock.Any(), webhookID, deliveryID).Return(mockedDelivery, tc.mockedError).MaxTimes(1)
+			if tc.expectedDelivery {
+				repo.EXPECT().CreateDelivery(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, delivery models.WebhookDelivery) (*models.WebhookDelivery, error) {
+					delivery.ID = "0d1e2f3a-4b5c-4d6e-8f7a-8b9c0d1e2f3a"
+					return &delivery, nil
+				})
+			}
+			c, rec := newContext(http.MethodPost, "/", "", tc.principal)
+			c.SetParamNames("webhookId", "deliveryId")
+			c.SetParamValues(webhookID, deliveryID)
+
+			// When
+			err := newHandler(repo).Redeliver(c)
+
+			// Then
+			if tc.expectedError != nil {
+				testutils.AssertExpectedErrorsHttpReponse(t, tc.expectedCode, rec.Code, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			var res models.WebhookDelivery
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equalf(t, deliveryID, res.RedeliveryOf, "Expected the redelivery of %s, but was of %s", deliveryID, res.RedeliveryOf)
+			assert.Equalf(t, models.DeliveryPending, res.Status, "Expected status to be %s, but was %s", models.DeliveryPending, res.Status)
+		})
+	}
+}
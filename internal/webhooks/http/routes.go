@@ -0,0 +1,18 @@
+package http
+
+import (
+	"user-microservice/internal/webhooks"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AppendWebhooksRoutes - Sets the webhooks routes for the given webhooks echo group
+func AppendWebhooksRoutes(e *echo.Group, h webhooks.Handler) {
+	e.POST("", h.CreateWebhook)
+	e.GET("", h.ListWebhooks)
+	e.GET("/:webhookId", h.GetWebhook)
+	e.PUT("/:webhookId", h.UpdateWebhook)
+	e.DELETE("/:webhookId", h.DeleteWebhook)
+	e.GET("/:webhookId/deliveries", h.ListDeliveries)
+	e.POST("/:webhookId/deliveries/:deliveryId/redeliver", h.Redeliver)
+}
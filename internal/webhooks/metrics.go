@@ -0,0 +1,26 @@
+package webhooks
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics - prometheus metrics of the webhooks
+type Metrics struct {
+	attempts *prometheus.CounterVec
+	disabled prometheus.Counter
+}
+
+// NewMetrics - returns the webhooks metrics, registered in the given registerer
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_delivery_attempts_total",
+			Help: "Number of webhook delivery attempts, by result (succeeded or failed)",
+		}, []string{"result"}),
+		disabled: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "webhooks_disabled_total",
+			Help: "Number of webhooks disabled after too many consecutive failed attempts",
+		}),
+	}
+	reg.MustRegister(m.attempts, m.disabled)
+
+	return m
+}
@@ -0,0 +1,42 @@
+//go:generate mockgen -source repository.go -destination mock/repository_mock.go -package mock
+package webhooks
+
+import (
+	"context"
+	"time"
+	"user-microservice/internal/models"
+)
+
+// Repository - webhooks and deliveries repository
+type Repository interface {
+	// Create - inserts the webhook, setting its ID and dates
+	Create(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	// Get - returns the webhook, or mongo.ErrNoDocuments if it does not exist
+	Get(ctx context.Context, id string) (*models.Webhook, error)
+	// List - returns all the webhooks, including the disabled ones
+	List(ctx context.Context) ([]models.Webhook, error)
+	// ListEnabled - returns the enabled webhooks receiving the events of the topic
+	ListEnabled(ctx context.Context, topic string) ([]models.Webhook, error)
+	// Update - replaces the URL, events, secret and status of the webhook, returning mongo.ErrNoDocuments if it does not exist
+	Update(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	// Delete - deletes the webhook, returning mongo.ErrNoDocuments if it does not exist. Its deliveries are kept
+	Delete(ctx context.Context, id string) error
+	// IncrementFailures - adds a failed attempt to the webhook and returns its consecutive failures
+	IncrementFailures(ctx context.Context, id string) (int, error)
+	// ResetFailures - clears the consecutive failures of the webhook after a successful attempt
+	ResetFailures(ctx context.Context, id string) error
+	// Disable - disables the webhook if it's enabled
+	Disable(ctx context.Context, id string, at time.Time) error
+
+	// CreateDelivery - inserts the delivery, setting its ID and creation date
+	CreateDelivery(ctx context.Context, delivery models.WebhookDelivery) (*models.WebhookDelivery, error)
+	// GetDelivery - returns the delivery of the webhook, or mongo.ErrNoDocuments if it does not exist
+	GetDelivery(ctx context.Context, webhookID, id string) (*models.WebhookDelivery, error)
+	// ListDeliveries - returns the last deliveries of the webhook, the newest first
+	ListDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error)
+	// ClaimDelivery - returns the pending delivery due the longest, delaying its next attempt by the lease so no
+	// one else claims it meanwhile. Returns mongo.ErrNoDocuments if there is no due delivery
+	ClaimDelivery(ctx context.Context, now time.Time, lease time.Duration) (*models.WebhookDelivery, error)
+	// UpdateDelivery - saves the status and the last attempt of the delivery
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+}
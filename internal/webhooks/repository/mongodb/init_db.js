@@ -0,0 +1,57 @@
+db.webhooks.createIndex({ events: 1, enabled: 1 });
+db.webhook_deliveries.createIndex({ webhook_id: 1, created_at: -1 });
+db.webhook_deliveries.createIndex({ status: 1, next_attempt_at: 1 });
+db.webhook_deliveries.createIndex({ created_at: 1 }, { expireAfterSeconds: 2592000 });
+
+db.webhooks.insertMany([
+  {
+    _id: "3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b",
+    url: "https://partner.example.com/hooks/users",
+    events: ["user-created", "user-deleted"],
+    secret: "8f2b7c4e1a9d6e3f0b5c8a7d4e1f2a3b",
+    enabled: true,
+    failures: 0,
+    created_at: new Date("2022-05-18T16:00:00Z"),
+    updated_at: new Date("2022-05-18T16:00:00Z"),
+  },
+  {
+    _id: "4a0b2d3f-6c7e-4f8a-9b0c-1d2e3f4a5b6c",
+    url: "https://broken.example.com/hooks/users",
+    events: ["user-created"],
+    secret: "0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d",
+    enabled: false,
+    failures: 20,
+    disabled_at: new Date("2022-06-18T16:00:00Z"),
+    created_at: new Date("2022-05-18T17:00:00Z"),
+    updated_at: new Date("2022-06-18T16:00:00Z"),
+  },
+]);
+
+db.webhook_deliveries.insertMany([
+  {
+    _id: "8b7a6d5e-4f3a-4b2c-9d1e-0f9a8b7c6d5e",
+    webhook_id: "3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b",
+    event_id: "2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d",
+    event: "user-deleted",
+    payload: '{"id":"2a3b4c5d-6e7f-4a8b-9c0d-1e2f3a4b5c6d","type":"user-deleted","userId":"ddd50d89-0cf4-4d35-b8e8-51a2b5a06ce4"}',
+    status: "succeeded",
+    attempts: 1,
+    response_status: 200,
+    created_at: new Date("2022-05-19T16:00:00Z"),
+    last_attempt_at: new Date("2022-05-19T16:00:01Z"),
+  },
+  {
+    _id: "9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+    webhook_id: "3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b",
+    event_id: "1f2e3d4c-5b6a-4978-8d6c-5b4a3f2e1d0c",
+    event: "user-created",
+    payload: '{"id":"1f2e3d4c-5b6a-4978-8d6c-5b4a3f2e1d0c","type":"user-created","userId":"5cace01f-45c3-49f0-a725-c22866874095"}',
+    status: "pending",
+    attempts: 1,
+    response_status: 503,
+    error: "unexpected status 503",
+    created_at: new Date("2022-05-20T16:00:00Z"),
+    last_attempt_at: new Date("2022-05-20T16:00:01Z"),
+    next_attempt_at: new Date("2022-05-20T16:00:31Z"),
+  },
+]);
@@ -0,0 +1,250 @@
+package mongodb
+
+import (
+	"context"
+	"strings"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/webhooks"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// webhooksCollection - collection of the webhooks, indexed by their events
+	webhooksCollection = "webhooks"
+	// deliveriesCollection - collection of the deliveries (the delivery log), expired after 30 days
+	deliveriesCollection = "webhook_deliveries"
+)
+
+type mongodbRepository struct {
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+var _ webhooks.Repository = mongodbRepository{}
+var _ webhooks.Repository = (*mongodbRepository)(nil)
+
+// NewMongoDBRepository - returns a new instance for the webhooks mongodb repository
+func NewMongoDBRepository(db *mongo.Database) webhooks.Repository {
+	return &mongodbRepository{db.Collection(webhooksCollection), db.Collection(deliveriesCollection)}
+}
+
+// Create - inserts the webhook into the database and returns the inserted version
+func (r mongodbRepository) Create(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	webhook.ID = strings.ToLower(uuid.New().String())
+	webhook.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	webhook.UpdatedAt = webhook.CreatedAt
+
+	if _, err := r.webhooks.InsertOne(ctx, &webhook); err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.Create -> error: %s", err)
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// Get - returns the webhook with the given ID
+func (r mongodbRepository) Get(ctx context.Context, id string) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := r.webhooks.FindOne(ctx, bson.M{"_id": strings.ToLower(id)}).Decode(&webhook); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in webhooks/repository/mongodb.Get -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// List - returns all the webhooks sorted by creation date
+func (r mongodbRepository) List(ctx context.Context) ([]models.Webhook, error) {
+	return r.find(ctx, "List", bson.M{})
+}
+
+// ListEnabled - returns the enabled webhooks of the topic sorted by creation date
+func (r mongodbRepository) ListEnabled(ctx context.Context, topic string) ([]models.Webhook, error) {
+	return r.find(ctx, "ListEnabled", bson.M{"events": topic, "enabled": true})
+}
+
+func (r mongodbRepository) find(ctx context.Context, funcName string, filter bson.M) ([]models.Webhook, error) {
+	cursor, err := r.webhooks.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.%s -> error executing find command: %s", funcName, err)
+		return nil, err
+	}
+
+	res := []models.Webhook{}
+	if err := cursor.All(ctx, &res); err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.%s -> error decoding cursor: %s", funcName, err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Update - sets the URL, events, secret and status of the webhook and returns the updated version
+func (r mongodbRepository) Update(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	set := bson.M{
+		"url":        webhook.URL,
+		"events":     webhook.Events,
+		"secret":     webhook.Secret,
+		"enabled":    webhook.Enabled,
+		"failures":   webhook.Failures,
+		"updated_at": time.Now().UTC().Truncate(time.Millisecond),
+	}
+	update := bson.M{"$set": set}
+	if webhook.DisabledAt != nil {
+		set["disabled_at"] = webhook.DisabledAt
+	} else {
+		update["$unset"] = bson.M{"disabled_at": ""}
+	}
+
+	var updated models.Webhook
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := r.webhooks.FindOneAndUpdate(ctx, bson.M{"_id": strings.ToLower(webhook.ID)}, update, opts).Decode(&updated); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in webhooks/repository/mongodb.Update -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// Delete - deletes the webhook
+func (r mongodbRepository) Delete(ctx context.Context, id string) error {
+	res, err := r.webhooks.DeleteOne(ctx, bson.M{"_id": strings.ToLower(id)})
+	if err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.Delete -> error: %s", err)
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// IncrementFailures - increments the consecutive failures of the webhook
+func (r mongodbRepository) IncrementFailures(ctx context.Context, id string) (int, error) {
+	var webhook models.Webhook
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.webhooks.FindOneAndUpdate(ctx, bson.M{"_id": strings.ToLower(id)}, bson.M{"$inc": bson.M{"failures": 1}}, opts).Decode(&webhook)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in webhooks/repository/mongodb.IncrementFailures -> error: %s", err)
+		}
+		return 0, err
+	}
+
+	return webhook.Failures, nil
+}
+
+// ResetFailures - sets the consecutive failures of the webhook to 0
+func (r mongodbRepository) ResetFailures(ctx context.Context, id string) error {
+	_, err := r.webhooks.UpdateOne(ctx, bson.M{"_id": strings.ToLower(id)}, bson.M{"$set": bson.M{"failures": 0}})
+	if err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.ResetFailures -> error: %s", err)
+	}
+	return err
+}
+
+// Disable - disables the webhook, keeping the date it was disabled first
+func (r mongodbRepository) Disable(ctx context.Context, id string, at time.Time) error {
+	filter := bson.M{"_id": strings.ToLower(id), "enabled": true}
+	_, err := r.webhooks.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"enabled": false, "disabled_at": at, "updated_at": at}})
+	if err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.Disable -> error: %s", err)
+	}
+	return err
+}
+
+// CreateDelivery - inserts the delivery into the database and returns the inserted version
+func (r mongodbRepository) CreateDelivery(ctx context.Context, delivery models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery.ID = strings.ToLower(uuid.New().String())
+	delivery.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	if _, err := r.deliveries.InsertOne(ctx, &delivery); err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.CreateDelivery -> error: %s", err)
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// GetDelivery - returns the delivery with the given ID of the webhook
+func (r mongodbRepository) GetDelivery(ctx context.Context, webhookID, id string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	filter := bson.M{"_id": strings.ToLower(id), "webhook_id": strings.ToLower(webhookID)}
+	if err := r.deliveries.FindOne(ctx, filter).Decode(&delivery); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in webhooks/repository/mongodb.GetDelivery -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// ListDeliveries - returns the last deliveries of the webhook sorted by creation date, the newest first
+func (r mongodbRepository) ListDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.deliveries.Find(ctx, bson.M{"webhook_id": strings.ToLower(webhookID)}, opts)
+	if err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.ListDeliveries -> error executing find command: %s", err)
+		return nil, err
+	}
+
+	res := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &res); err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.ListDeliveries -> error decoding cursor: %s", err)
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ClaimDelivery - atomically moves the next attempt of the oldest due pending delivery to the end of the lease
+func (r mongodbRepository) ClaimDelivery(ctx context.Context, now time.Time, lease time.Duration) (*models.WebhookDelivery, error) {
+	filter := bson.M{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1})
+
+	var delivery models.WebhookDelivery
+	if err := r.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Errorf("Error in webhooks/repository/mongodb.ClaimDelivery -> error: %s", err)
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// UpdateDelivery - sets the status, attempts and last attempt result of the delivery
+func (r mongodbRepository) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	set := bson.M{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_status": delivery.ResponseStatus,
+		"error":           delivery.Error,
+		"last_attempt_at": delivery.LastAttemptAt,
+	}
+	update := bson.M{"$set": set}
+	if delivery.NextAttemptAt != nil {
+		set["next_attempt_at"] = delivery.NextAttemptAt
+	} else {
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+	}
+
+	_, err := r.deliveries.UpdateOne(ctx, bson.M{"_id": strings.ToLower(delivery.ID)}, update)
+	if err != nil {
+		logrus.Errorf("Error in webhooks/repository/mongodb.UpdateDelivery -> error: %s", err)
+	}
+	return err
+}
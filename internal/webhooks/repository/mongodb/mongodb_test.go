@@ -0,0 +1,227 @@
+package mongodb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	"user-microservice/internal/testutils"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/webhooks/repository/mongodb"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	enabledWebhookID    = "3f9a1c2e-5b6d-4e7f-8a9b-0c1d2e3f4a5b"
+	disabledWebhookID   = "4a0b2d3f-6c7e-4f8a-9b0c-1d2e3f4a5b6c"
+	succeededDeliveryID = "8b7a6d5e-4f3a-4b2c-9d1e-0f9a8b7c6d5e"
+	pendingDeliveryID   = "9c8b7a6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	pendingDeliveryDue  = "2022-05-20T16:00:31Z"
+)
+
+var dbClientTest *mongo.Client
+
+func TestMain(m *testing.M) {
+	dbClientTest = new(mongo.Client)
+	testutils.ExecuteTestMain(m, dbClientTest)
+}
+
+func TestMongoDBRepository_CreateAndGet(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	webhook := models.Webhook{
+		URL:     "https://created.example.com/hooks",
+		Events:  []string{userPS.TopicUserUpdate},
+		Secret:  "created-webhook-secret",
+		Enabled: true,
+	}
+
+	// When
+	res, err := repo.Create(ctx, webhook)
+
+	// Then
+	require.NoError(t, err)
+	require.NotNil(t, res, "Expected res not to be nil")
+	assert.NotEmpty(t, res.ID, "Expected ID not to be empty")
+	found, err := repo.Get(ctx, res.ID)
+	require.NoError(t, err)
+	assert.Equalf(t, webhook.URL, found.URL, "Expected URL to be %s, but was %s", webhook.URL, found.URL)
+	assert.Equalf(t, webhook.Secret, found.Secret, "Expected Secret to be %s, but was %s", webhook.Secret, found.Secret)
+	assert.Truef(t, res.CreatedAt.Equal(found.CreatedAt), "Expected CreatedAt to be %s, but was %s", res.CreatedAt, found.CreatedAt)
+
+	// When the webhook does not exist
+	_, err = repo.Get(ctx, uuid.New().String())
+
+	// Then
+	assert.Equalf(t, mongo.ErrNoDocuments, err, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, err)
+}
+
+func TestMongoDBRepository_ListEnabled(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		topic       string
+		expectedIDs []string
+	}{
+		{
+			"List enabled webhooks of a topic",
+			userPS.TopicUserCreation,
+			[]string{enabledWebhookID},
+		},
+		{
+			"List enabled webhooks of a topic without webhooks",
+			userPS.TopicUserPurge,
+			nil,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+
+			// When
+			res, err := repo.ListEnabled(context.TODO(), tc.topic)
+
+			// Then
+			require.NoError(t, err)
+			var ids []string
+			for _, webhook := range res {
+				ids = append(ids, webhook.ID)
+			}
+			assert.Equalf(t, tc.expectedIDs, ids, "Expected IDs to be %v, but were %v", tc.expectedIDs, ids)
+		})
+	}
+}
+
+func TestMongoDBRepository_UpdateFailuresAndDelete(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	created, err := repo.Create(ctx, models.Webhook{
+		URL:     "https://to-update.example.com/hooks",
+		Events:  []string{userPS.TopicUserCreation},
+		Secret:  "to-update-webhook-secret",
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	// When
+	first, firstErr := repo.IncrementFailures(ctx, created.ID)
+	second, secondErr := repo.IncrementFailures(ctx, created.ID)
+	disabledAt := time.Now().UTC().Truncate(time.Millisecond)
+	disableErr := repo.Disable(ctx, created.ID, disabledAt)
+
+	// Then
+	require.NoError(t, firstErr)
+	require.NoError(t, secondErr)
+	require.NoError(t, disableErr)
+	assert.Equalf(t, 1, first, "Expected 1 failure, but were %d", first)
+	assert.Equalf(t, 2, second, "Expected 2 failures, but were %d", second)
+	found, err := repo.Get(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Falsef(t, found.Enabled, "Expected the webhook to be disabled")
+	require.NotNil(t, found.DisabledAt, "Expected DisabledAt not to be nil")
+	assert.Truef(t, disabledAt.Equal(*found.DisabledAt), "Expected DisabledAt to be %s, but was %s", disabledAt, found.DisabledAt)
+
+	// When it's enabled again
+	found.Enabled = true
+	found.Failures = 0
+	found.DisabledAt = nil
+	found.Events = []string{userPS.TopicUserCreation, userPS.TopicUserDeletion}
+	updated, err := repo.Update(ctx, *found)
+
+	// Then
+	require.NoError(t, err)
+	assert.Truef(t, updated.Enabled, "Expected the webhook to be enabled")
+	assert.Nilf(t, updated.DisabledAt, "Expected DisabledAt to be nil, but was %v", updated.DisabledAt)
+	assert.Equalf(t, found.Events, updated.Events, "Expected Events to be %v, but were %v", found.Events, updated.Events)
+	require.NoError(t, repo.ResetFailures(ctx, created.ID))
+
+	// When it's deleted
+	deleteErr := repo.Delete(ctx, created.ID)
+	deleteAgainErr := repo.Delete(ctx, created.ID)
+	_, updateErr := repo.Update(ctx, *found)
+
+	// Then
+	require.NoError(t, deleteErr)
+	assert.Equalf(t, mongo.ErrNoDocuments, deleteAgainErr, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, deleteAgainErr)
+	assert.Equalf(t, mongo.ErrNoDocuments, updateErr, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, updateErr)
+}
+
+func TestMongoDBRepository_Deliveries(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	next := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	delivery := models.WebhookDelivery{
+		WebhookID:     disabledWebhookID,
+		EventID:       uuid.New().String(),
+		Event:         userPS.TopicUserCreation,
+		Payload:       `{"type":"user-created"}`,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &next,
+	}
+
+	// When
+	created, err := repo.CreateDelivery(ctx, delivery)
+
+	// Then
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID, "Expected ID not to be empty")
+
+	// When the last attempt is saved
+	attemptedAt := time.Now().UTC().Truncate(time.Millisecond)
+	created.Status = models.DeliveryFailed
+	created.Attempts = 1
+	created.ResponseStatus = 500
+	created.Error = "unexpected status 500"
+	created.LastAttemptAt = &attemptedAt
+	created.NextAttemptAt = nil
+	updateErr := repo.UpdateDelivery(ctx, *created)
+	found, getErr := repo.GetDelivery(ctx, disabledWebhookID, created.ID)
+	_, otherWebhookErr := repo.GetDelivery(ctx, enabledWebhookID, created.ID)
+
+	// Then
+	require.NoError(t, updateErr)
+	require.NoError(t, getErr)
+	assert.Equalf(t, models.DeliveryFailed, found.Status, "Expected Status to be %s, but was %s", models.DeliveryFailed, found.Status)
+	assert.Equalf(t, 500, found.ResponseStatus, "Expected ResponseStatus to be 500, but was %d", found.ResponseStatus)
+	assert.Nilf(t, found.NextAttemptAt, "Expected NextAttemptAt to be nil, but was %v", found.NextAttemptAt)
+	assert.Equalf(t, mongo.ErrNoDocuments, otherWebhookErr, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, otherWebhookErr)
+
+	// When the deliveries of a webhook are listed
+	deliveries, err := repo.ListDeliveries(ctx, enabledWebhookID, 10)
+
+	// Then
+	require.NoError(t, err)
+	var ids []string
+	for _, d := range deliveries {
+		ids = append(ids, d.ID)
+	}
+	assert.Equalf(t, []string{pendingDeliveryID, succeededDeliveryID}, ids, "Expected IDs to be the newest first, but were %v", ids)
+}
+
+func TestMongoDBRepository_ClaimDelivery(t *testing.T) {
+	// Given
+	repo := mongodb.NewMongoDBRepository(testutils.GetDatabaseFromClient(dbClientTest))
+	ctx := context.TODO()
+	now, err := time.Parse(time.RFC3339, pendingDeliveryDue)
+	require.NoError(t, err)
+
+	// When
+	claimed, claimErr := repo.ClaimDelivery(ctx, now, time.Minute)
+	_, claimAgainErr := repo.ClaimDelivery(ctx, now, time.Minute)
+	_, claimAfterLeaseErr := repo.ClaimDelivery(ctx, now.Add(time.Minute), time.Minute)
+
+	// Then
+	require.NoError(t, claimErr)
+	assert.Equalf(t, pendingDeliveryID, claimed.ID, "Expected ID to be %s, but was %s", pendingDeliveryID, claimed.ID)
+	assert.Equalf(t, mongo.ErrNoDocuments, claimAgainErr, "Expected error to be %s, but was %s", mongo.ErrNoDocuments, claimAgainErr)
+	assert.NoErrorf(t, claimAfterLeaseErr, "Expected the delivery to be claimed again after the lease, but was %v", claimAfterLeaseErr)
+}
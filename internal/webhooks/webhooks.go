@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MinSecretLength - minimum length of the webhook secrets
+	MinSecretLength = 16
+	// DefaultDeliveriesLimit - default number of deliveries listed
+	DefaultDeliveriesLimit = 50
+	// MaxDeliveriesLimit - maximum number of deliveries listed
+	MaxDeliveriesLimit = 100
+)
+
+var (
+	// ErrInvalidURL - the webhook URL is not an absolute http or https URL
+	ErrInvalidURL = errors.New("invalid webhook URL")
+	// ErrInvalidEvents - the webhook has no events or one of them is not a user topic
+	ErrInvalidEvents = errors.New("invalid webhook events")
+	// ErrInvalidSecret - the webhook secret is shorter than MinSecretLength
+	ErrInvalidSecret = errors.New("invalid webhook secret")
+	// ErrWebhookDisabled - the webhook is disabled, so its deliveries cannot be sent again
+	ErrWebhookDisabled = errors.New("webhook disabled")
+)
+
+// Manager - registers the webhooks and gives access to their delivery log
+type Manager struct {
+	repo       Repository
+	dispatcher *Dispatcher
+}
+
+// NewManager - returns a new Manager of the webhooks delivered by the dispatcher
+func NewManager(repo Repository, dispatcher *Dispatcher) *Manager {
+	return &Manager{repo: repo, dispatcher: dispatcher}
+}
+
+// Create - registers a new enabled webhook
+func (m *Manager) Create(ctx context.Context, req models.WebhookRequest) (*models.Webhook, error) {
+	webhook := models.Webhook{Enabled: true}
+	if err := apply(&webhook, req, true); err != nil {
+		return nil, err
+	}
+
+	res, err := m.repo.Create(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithFields(logrus.Fields{"webhook": res.ID, "url": res.URL}).Info("Webhook registered")
+
+	return res, nil
+}
+
+// Get - returns the webhook, or mongo.ErrNoDocuments if it does not exist
+func (m *Manager) Get(ctx context.Context, id string) (*models.Webhook, error) {
+	return m.repo.Get(ctx, id)
+}
+
+// List - returns all the webhooks
+func (m *Manager) List(ctx context.Context) ([]models.Webhook, error) {
+	return m.repo.List(ctx)
+}
+
+// Update - replaces the URL and the events of the webhook, and its secret if the request has one.
+// Enabling a disabled webhook resets its failures
+func (m *Manager) Update(ctx context.Context, id string, req models.WebhookRequest) (*models.Webhook, error) {
+	webhook, err := m.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := apply(webhook, req, false); err != nil {
+		return nil, err
+	}
+	if req.Enabled != nil && *req.Enabled != webhook.Enabled {
+		webhook.Enabled = *req.Enabled
+		if webhook.Enabled {
+			webhook.Failures = 0
+			webhook.DisabledAt = nil
+		} else {
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			webhook.DisabledAt = &now
+		}
+	}
+
+	return m.repo.Update(ctx, *webhook)
+}
+
+// Delete - deletes the webhook, its pending deliveries fail on their next attempt
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	if err := m.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	logrus.WithField("webhook", id).Info("Webhook deleted")
+
+	return nil
+}
+
+// ListDeliveries - returns the last deliveries of the webhook, the newest first. The limit must be
+// between 1 and MaxDeliveriesLimit, DefaultDeliveriesLimit is used otherwise
+func (m *Manager) ListDeliveries(ctx context.Context, id string, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := m.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxDeliveriesLimit {
+		limit = DefaultDeliveriesLimit
+	}
+
+	return m.repo.ListDeliveries(ctx, id, limit)
+}
+
+// Redeliver - sends the payload of the delivery again in a new delivery, retried as the original one.
+// Returns ErrWebhookDisabled if the webhook is disabled
+func (m *Manager) Redeliver(ctx context.Context, webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	webhook, err := m.repo.Get(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if !webhook.Enabled {
+		return nil, ErrWebhookDisabled
+	}
+	original, err := m.repo.GetDelivery(ctx, webhook.ID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	res, err := m.repo.CreateDelivery(ctx, models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		RedeliveryOf:  original.ID,
+		NextAttemptAt: &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.dispatcher.wake()
+
+	return res, nil
+}
+
+// apply - validates the request and sets its fields in the webhook. The secret is only required on creation
+func apply(webhook *models.Webhook, req models.WebhookRequest, create bool) error {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w %q", ErrInvalidURL, req.URL)
+	}
+
+	valid := map[string]bool{}
+	for _, topic := range userPS.GetAllUsersTopics() {
+		valid[topic] = true
+	}
+	var events []string
+	added := map[string]bool{}
+	for _, event := range req.Events {
+		event = strings.TrimSpace(event)
+		if !valid[event] {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidEvents, event)
+		}
+		if !added[event] {
+			added[event] = true
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("%w: there are no events", ErrInvalidEvents)
+	}
+
+	if req.Secret != "" || create {
+		if len(req.Secret) < MinSecretLength {
+			return fmt.Errorf("%w: it must have at least %d characters", ErrInvalidSecret, MinSecretLength)
+		}
+		webhook.Secret = req.Secret
+	}
+	webhook.URL = target.String()
+	webhook.Events = events
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package webhooks_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user-microservice/internal/models"
+	userPS "user-microservice/internal/users/pubsub"
+	"user-microservice/internal/webhooks"
+	"user-microservice/internal/webhooks/mock"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newManager(repo webhooks.Repository) *webhooks.Manager {
+	return webhooks.NewManager(repo, webhooks.NewDispatcher(repo, nil, nil, webhooks.Options{}, webhooks.NewMetrics(prometheus.NewRegistry())))
+}
+
+func TestManager_Create(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		req            models.WebhookRequest
+		expectedEvents []string
+		expectedError  error
+	}{
+		{
+			"Create webhook successfully",
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserCreation, userPS.TopicUserCreation, userPS.TopicUserDeletion}, Secret: secret},
+			[]string{userPS.TopicUserCreation, userPS.TopicUserDeletion},
+			nil,
+		},
+		{
+			"Create webhook with relative URL",
+			models.WebhookRequest{URL: "/hooks", Events: []string{userPS.TopicUserCreation}, Secret: secret},
+			nil,
+			webhooks.ErrInvalidURL,
+		},
+		{
+			"Create webhook with non http URL",
+			models.WebhookRequest{URL: "ftp://partner.example.com/hooks", Events: []string{userPS.TopicUserCreation}, Secret: secret},
+			nil,
+			webhooks.ErrInvalidURL,
+		},
+		{
+			"Create webhook without events",
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Secret: secret},
+			nil,
+			webhooks.ErrInvalidEvents,
+		},
+		{
+			"Create webhook with unknown event",
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{"homemade"}, Secret: secret},
+			nil,
+			webhooks.ErrInvalidEvents,
+		},
+		{
+			"Create webhook with short secret",
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserCreation}, Secret: "short"},
+			nil,
+			webhooks.ErrInvalidSecret,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			if tc.expectedError == nil {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, webhook models.Webhook) (*models.Webhook, error) {
+					webhook.ID = uuid.NewString()
+					return &webhook, nil
+				})
+			}
+
+			// When
+			res, err := newManager(repo).Create(context.Background(), tc.req)
+
+			// Then
+			assert.ErrorIsf(t, err, tc.expectedError, "Expected error to be %v, but was %v", tc.expectedError, err)
+			if tc.expectedError == nil {
+				require.NotNil(t, res, "Expected res not to be nil")
+				assert.Truef(t, res.Enabled, "Expected the webhook to be enabled")
+				assert.Equalf(t, tc.req.Secret, res.Secret, "Expected the secret to be set")
+				assert.Equalf(t, tc.expectedEvents, res.Events, "Expected events to be %v, but were %v", tc.expectedEvents, res.Events)
+			}
+		})
+	}
+}
+
+func TestManager_Update(t *testing.T) {
+	enabled, disabled := true, false
+	disabledAt := time.Now().Add(-time.Hour).UTC()
+	for _, tc := range []struct {
+		name             string
+		current          models.Webhook
+		req              models.WebhookRequest
+		mockedError      error
+		expectedSecret   string
+		expectedEnabled  bool
+		expectedFailures int
+		expectedDisabled bool
+		expectedError    error
+	}{
+		{
+			"Update webhook keeping its secret",
+			models.Webhook{Secret: secret, Enabled: true, Failures: 3},
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserUpdate}},
+			nil,
+			secret,
+			true,
+			3,
+			false,
+			nil,
+		},
+		{
+			"Update webhook secret",
+			models.Webhook{Secret: secret, Enabled: true},
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserUpdate}, Secret: "another-webhook-secret"},
+			nil,
+			"another-webhook-secret",
+			true,
+			0,
+			false,
+			nil,
+		},
+		{
+			"Enable disabled webhook",
+			models.Webhook{Secret: secret, Failures: 20, DisabledAt: &disabledAt},
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserUpdate}, Enabled: &enabled},
+			nil,
+			secret,
+			true,
+			0,
+			false,
+			nil,
+		},
+		{
+			"Disable webhook",
+			models.Webhook{Secret: secret, Enabled: true},
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserUpdate}, Enabled: &disabled},
+			nil,
+			secret,
+			false,
+			0,
+			true,
+			nil,
+		},
+		{
+			"Update webhook with short secret",
+			models.Webhook{Secret: secret, Enabled: true},
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserUpdate}, Secret: "short"},
+			nil,
+			"",
+			false,
+			0,
+			false,
+			webhooks.ErrInvalidSecret,
+		},
+		{
+			"Update unknown webhook",
+			models.Webhook{},
+			models.WebhookRequest{URL: "https://partner.example.com/hooks", Events: []string{userPS.TopicUserUpdate}},
+			mongo.ErrNoDocuments,
+			"",
+			false,
+			0,
+			false,
+			mongo.ErrNoDocuments,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			ctrl := gomock.NewController(t)
+			repo := mock.NewMockRepository(ctrl)
+			tc.current.ID = uuid.NewString()
+			if tc.mockedError != nil {
+				repo.EXPECT().Get(gomock.Any(), tc.current.ID).Return(nil, tc.mockedError)
+			} else {
+				repo.EXPECT().Get(gomock.Any(), tc.current.ID).Return(&tc.current, nil)
+			}
+			if tc.expectedError == nil {
+				repo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, webhook models.Webhook) (*models.Webhook, error) {
+					return &webhook, nil
+				})
+			}
+
+			// When
+			res, err := newManager(repo).Update(context.Background(), tc.current.ID, tc.req)
+
+			// Then
+			assert.ErrorIsf(t, err, tc.expectedError, "Expected error to be %v, but was %v", tc.expectedError, err)
+			if tc.expectedError != nil {
+				return
+			}
+			require.NotNil(t, res, "Expected res not to be nil")
+			assert.Equalf(t, tc.req.URL, res.URL, "Expected URL to be %s, but was %s", tc.req.URL, res.URL)
+			assert.Equalf(t, tc.expectedSecret, res.Secret, "Expected secret to be %s, but was %s", tc.expectedSecret, res.Secret)
+			assert.Equalf(t, tc.expectedEnabled, res.Enabled, "Expected enabled to be %t, but was %t", tc.expectedEnabled, res.Enabled)
+			assert.Equalf(t, tc.expectedFailures, res.Failures, "Expected %d failures, but were %d", tc.expectedFailures, res.Failures)
+			assert.Equalf(t, tc.expectedDisabled, res.DisabledAt != nil, "Expected disabled date to be set: %t", tc.expectedDisabled)
+		})
+	}
+}
+
+func TestManager_Redeliver(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		webhook       models.Webhook
+		deliveryFound bool
+		expectedError error
+	}{
+		{
+			"Redeliver delivery successfully",
+			models.Webhook{Enabled: true},
+			true,
+			nil,
+		},
+		{
+			"Redeliver delivery of disabled webhook",
+			models.Webhook{},
+			true,
+			webhooks.ErrWebhookDisabled,
+		},
+		{
+			"Redeliver unknown delivery",
+			models.Webhook{Enabled: true},
+			false,
+			mongo.ErrNoDocuments,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Given
+			tc.webhook.ID = uuid.NewString()
+			repo := newFakeRepository(tc.webhook)
+			deliveryID := uuid.NewString()
+			if tc.deliveryFound {
+				original, err := repo.CreateDelivery(context.Background(), models.WebhookDelivery{
+					WebhookID: tc.webhook.ID,
+					EventID:   uuid.NewString(),
+					Event:     userPS.TopicUserDeletion,
+					Payload:   `{"type":"user-deleted"}`,
+					Status:    models.DeliveryFailed,
+					Attempts:  6,
+				})
+				require.NoError(t, err)
+				deliveryID = original.ID
+			}
+
+			// When
+			res, err := newManager(repo).Redeliver(context.Background(), tc.webhook.ID, deliveryID)
+
+			// Then
+			assert.ErrorIsf(t, err, tc.expectedError, "Expected error to be %v, but was %v", tc.expectedError, err)
+			if tc.expectedError != nil {
+				return
+			}
+			original, _ := repo.GetDelivery(context.Background(), tc.webhook.ID, deliveryID)
+			assert.NotEqualf(t, deliveryID, res.ID, "Expected a new delivery")
+			assert.Equalf(t, deliveryID, res.RedeliveryOf, "Expected the redelivery of %s, but was of %s", deliveryID, res.RedeliveryOf)
+			assert.Equalf(t, original.EventID, res.EventID, "Expected the event ID to be %s, but was %s", original.EventID, res.EventID)
+			assert.Equalf(t, original.Payload, res.Payload, "Expected the same payload")
+			assert.Equalf(t, models.DeliveryPending, res.Status, "Expected status to be %s, but was %s", models.DeliveryPending, res.Status)
+			assert.Zerof(t, res.Attempts, "Expected no attempts, but were %d", res.Attempts)
+		})
+	}
+}